@@ -0,0 +1,105 @@
+// Command export writes orders, executions, risk alerts, daily P&L or movers
+// positions from the trading database as CSV, XLSX or Parquet. It takes the
+// same filters as the export API and streams rows, so large ranges do not
+// need to fit in memory.
+//
+//	go run ./cmd/export -dataset executions -format parquet -from 2024-01-01 -o executions.parquet
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hft/backend/models"
+	"github.com/hft/backend/services"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	godotenv.Load()
+
+	dataset := flag.String("dataset", models.ExportExecutions, "orders, executions, risk_alerts, daily_pnl or movers_positions")
+	format := flag.String("format", models.ExportCSV, "csv, xlsx or parquet")
+	output := flag.String("o", "", "output file; standard output when empty")
+	databaseURL := flag.String("database-url", os.Getenv("DATABASE_URL"), "database connection string")
+	from := flag.String("from", "", "start, inclusive (RFC3339 or YYYY-MM-DD)")
+	to := flag.String("to", "", "end, exclusive (RFC3339 or YYYY-MM-DD)")
+
+	var filter models.RecordFilter
+	flag.StringVar(&filter.Symbol, "symbol", "", "symbol")
+	flag.StringVar(&filter.Side, "side", "", "BUY or SELL")
+	flag.StringVar(&filter.Status, "status", "", "order or movers position status")
+	flag.StringVar(&filter.Strategy, "strategy", "", "strategy; manual for orders without one")
+	flag.StringVar(&filter.Trader, "trader", "", "submitting user")
+	flag.StringVar(&filter.Account, "account", "", "account")
+	flag.StringVar(&filter.Tag, "tag", "", "order tag")
+	flag.StringVar(&filter.AlertType, "alert-type", "", "risk alert type")
+	flag.StringVar(&filter.Severity, "severity", "", "risk alert severity")
+	flag.IntVar(&filter.Limit, "limit", 0, "maximum rows; 0 for all")
+	flag.Parse()
+
+	for _, bound := range []struct {
+		value  string
+		target **time.Time
+	}{{*from, &filter.From}, {*to, &filter.To}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := parseTime(bound.value)
+		if err != nil {
+			log.Fatalf("Invalid time %q: use RFC3339 or YYYY-MM-DD", bound.value)
+		}
+		*bound.target = &t
+	}
+	if err := services.ValidateExport(*dataset, *format); err != nil {
+		log.Fatal(err)
+	}
+	if err := services.ValidateRecordFilter(&filter); err != nil {
+		log.Fatal(err)
+	}
+
+	dbService := services.NewDatabaseService(*databaseURL)
+	if dbService.GetDB() == nil {
+		log.Fatal("Database unavailable; set DATABASE_URL or -database-url")
+	}
+
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if *output != "" {
+		var err error
+		if file, err = os.Create(*output); err != nil {
+			log.Fatalf("Failed to create %s: %v", *output, err)
+		}
+		w = file
+	}
+	buffered := bufio.NewWriterSize(w, 1<<16)
+
+	rows, err := services.NewExporter(dbService).Export(*dataset, *format, filter, buffered)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err == nil && file != nil {
+		err = file.Close()
+	}
+	if err != nil {
+		log.Fatalf("Export failed after %d rows: %v", rows, err)
+	}
+
+	destination := *output
+	if destination == "" {
+		destination = "standard output"
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d %s rows as %s to %s\n", rows, *dataset, *format, destination)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 	
 	"github.com/gin-gonic/gin"
+	"github.com/hft/backend/models"
 	"github.com/hft/backend/services"
 	"github.com/rs/zerolog/log"
 )
 
 // GetAnalytics returns trading analytics and P&L
-func GetAnalytics(dbService *services.DatabaseService, engineClient *services.EngineClient) gin.HandlerFunc {
+func GetAnalytics(dbService *services.DatabaseService, engineClient *services.EngineClient, pnlLedger *services.PnLLedger, performance *services.TradePerformance) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get all orders from Alpaca for comprehensive analytics
 		request := map[string]interface{}{
@@ -65,27 +69,10 @@ func GetAnalytics(dbService *services.DatabaseService, engineClient *services.En
 			fillRate = (float64(filledOrders) / float64(totalOrders)) * 100
 		}
 		
-		// Get executions from database for additional stats
-		var executions []struct {
-			Side      string
-			FillPrice float64
-			FillQty   float64
-		}
-		
-		dbService.GetDB().Table("executions").
-			Select("side, fill_price, fill_qty").
-			Find(&executions)
-		
-		var buyValue, sellValue float64
-		for _, exec := range executions {
-			if exec.Side == "BUY" {
-				buyValue += exec.FillPrice * exec.FillQty
-			} else if exec.Side == "SELL" {
-				sellValue += exec.FillPrice * exec.FillQty
-			}
-		}
-		
-		totalPnL := sellValue - buyValue
+		// Realised P&L from the cost-basis ledger, net of fees
+		grossPnL, _, _ := pnlLedger.Totals()
+		fees := pnlLedger.Fees()
+		totalPnL := grossPnL - fees
 		
 		// Get symbol-wise breakdown
 		type SymbolStats struct {
@@ -101,10 +88,20 @@ func GetAnalytics(dbService *services.DatabaseService, engineClient *services.En
 			Group("symbol").
 			Find(&symbolStats)
 		
-		// Calculate win rate (simplified)
-		winRate := 0.0
-		if totalPnL > 0 && filledOrders > 0 {
-			winRate = 100.0 // Simplified - all trades winning if overall P&L positive
+		// Order latency from receipt to response over the last quarter hour
+		latency := services.GetLatencyRecorder()
+		orderLatency := latency.Stats(services.LatencyStageOrder, 15)
+		latencyWindows := gin.H{}
+		for window, minutes := range services.LatencyWindows {
+			latencyWindows[window] = latency.Snapshot(minutes)
+		}
+		
+		// Win rate over closed round trips
+		var trades models.PerformanceStats
+		if report, err := performance.Report(nil, nil, false); err == nil {
+			trades = report.Overall
+		} else {
+			log.Error().Err(err).Msg("Failed to match round trips")
 		}
 		
 		log.Info().
@@ -124,12 +121,19 @@ func GetAnalytics(dbService *services.DatabaseService, engineClient *services.En
 			"fill_rate":        fillRate,
 			"total_volume":     totalVolume,
 			"total_pnl":        totalPnL,
-			"winning_trades":   0, // Placeholder
-			"losing_trades":    0, // Placeholder
-			"win_rate":         winRate,
-			"avg_latency_ms":   12.5,  // Placeholder - would need actual metrics
-			"p50_latency_ms":   10.0,  // Placeholder
-			"p99_latency_ms":   45.0,  // Placeholder
+			"gross_pnl":        grossPnL,
+			"fees":             fees,
+			"cost_method":      pnlLedger.Method(),
+			"round_trips":      trades.Trades,
+			"winning_trades":   trades.Wins,
+			"losing_trades":    trades.Losses,
+			"win_rate":         trades.WinRate,
+			"avg_latency_ms":   orderLatency.MeanMs,
+			"p50_latency_ms":   orderLatency.P50Ms,
+			"p90_latency_ms":   orderLatency.P90Ms,
+			"p99_latency_ms":   orderLatency.P99Ms,
+			"p999_latency_ms":  orderLatency.P999Ms,
+			"latency":          latencyWindows,
 			"symbol_stats":     symbolStats,
 		})
 	}
@@ -141,21 +145,20 @@ func parseFloat(s string) (float64, error) {
 	return f, err
 }
 
-// GetDailyPnL returns daily P&L breakdown
+// GetDailyPnL returns daily realised P&L net of fees from the cost-basis
+// ledger
 func GetDailyPnL(dbService *services.DatabaseService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		type DailyPnL struct {
-			Date  string  `json:"date"`
-			PnL   float64 `json:"pnl"`
-			Count int64   `json:"count"`
+		filter, err := parseRecordFilter(c, 30)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
 		}
 
-		var dailyPnL []DailyPnL
-		dbService.GetDB().Table("executions").
-			Select("DATE(timestamp) as date, SUM(CASE WHEN side = 'SELL' THEN fill_price * fill_qty ELSE -fill_price * fill_qty END) as pnl, COUNT(*) as count").
-			Group("DATE(timestamp)").
+		var dailyPnL []models.DailyRealizedPnL
+		services.DailyPnLQuery(dbService.GetDB(), filter).
 			Order("date DESC").
-			Limit(30).
+			Limit(filter.Limit).
 			Find(&dailyPnL)
 
 		c.JSON(200, gin.H{
@@ -164,3 +167,220 @@ func GetDailyPnL(dbService *services.DatabaseService) gin.HandlerFunc {
 	}
 }
 
+
+// GetPnLLots returns open lots and realised P&L per symbol
+func GetPnLLots(pnlLedger *services.PnLLedger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"cost_method": pnlLedger.Method(),
+			"positions":   pnlLedger.Positions(),
+		})
+	}
+}
+
+// SetCostMethod switches the cost-basis method and rebuilds realised P&L
+func SetCostMethod(pnlLedger *services.PnLLedger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Method string `json:"method" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := pnlLedger.SetMethod(req.Method); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success":     true,
+			"cost_method": pnlLedger.Method(),
+		})
+	}
+}
+
+// GetPnLAttribution breaks P&L down by the comma-separated group_by
+// dimensions (strategy, trader, symbol, tag) and an optional interval (hour,
+// day, week). strategy, trader, symbol and tag filter the fills; from and to
+// take RFC3339 timestamps or dates.
+func GetPnLAttribution(attribution *services.PnLAttribution) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := models.PnLAttributionQuery{
+			GroupBy:  strings.Split(c.DefaultQuery("group_by", models.AttributeByStrategy), ","),
+			Interval: c.Query("interval"),
+			Strategy: c.Query("strategy"),
+			Trader:   c.Query("trader"),
+			Symbol:   c.Query("symbol"),
+			Tag:      c.Query("tag"),
+		}
+
+		for param, target := range map[string]**time.Time{"from": &query.From, "to": &query.To} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			t, err := parseAttributionTime(value)
+			if err != nil {
+				c.JSON(400, gin.H{"error": param + " must be an RFC3339 timestamp or a YYYY-MM-DD date"})
+				return
+			}
+			*target = &t
+		}
+
+		if err := services.ValidateAttributionQuery(&query); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		report, err := attribution.Attribute(query)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, report)
+	}
+}
+
+// parseAttributionTime parses an RFC3339 timestamp or a date at local
+// midnight
+func parseAttributionTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// GetPerformance returns round-trip trading statistics overall, per symbol
+// and per strategy. from and to restrict the trades by exit time; trades=true
+// includes the round trips themselves.
+func GetPerformance(performance *services.TradePerformance) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var from, to *time.Time
+		for param, target := range map[string]**time.Time{"from": &from, "to": &to} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			t, err := parseAttributionTime(value)
+			if err != nil {
+				c.JSON(400, gin.H{"error": param + " must be an RFC3339 timestamp or a YYYY-MM-DD date"})
+				return
+			}
+			*target = &t
+		}
+		if from != nil && to != nil && !from.Before(*to) {
+			c.JSON(400, gin.H{"error": "from must be before to"})
+			return
+		}
+
+		report, err := performance.Report(from, to, c.Query("trades") == "true")
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, report)
+	}
+}
+
+// GetTCA returns transaction cost analysis of orders against their arrival
+// price. from and to restrict the orders by creation time; symbol and
+// strategy filter them.
+func GetTCA(tca *services.TransactionCostAnalysis) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var from, to *time.Time
+		for param, target := range map[string]**time.Time{"from": &from, "to": &to} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			t, err := parseAttributionTime(value)
+			if err != nil {
+				c.JSON(400, gin.H{"error": param + " must be an RFC3339 timestamp or a YYYY-MM-DD date"})
+				return
+			}
+			*target = &t
+		}
+		if from != nil && to != nil && !from.Before(*to) {
+			c.JSON(400, gin.H{"error": "from must be before to"})
+			return
+		}
+
+		report, err := tca.Report(from, to, c.Query("symbol"), c.Query("strategy"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, report)
+	}
+}
+
+// UpdateTCAConfig changes the slippage alert threshold
+func UpdateTCAConfig(tca *services.TransactionCostAnalysis) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := tca.Config()
+		if err := c.ShouldBindJSON(&config); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := tca.SetConfig(config); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"config":  tca.Config(),
+		})
+	}
+}
+
+// GetEquityCurve returns account equity and P&L over time. bucket is 1m, 5m,
+// 1h (default) or 1d; from and to take RFC3339 timestamps or dates;
+// max_points merges buckets when the range holds more.
+func GetEquityCurve(equityCurve *services.EquityCurve) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var from, to time.Time
+		for param, target := range map[string]*time.Time{"from": &from, "to": &to} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			t, err := parseAttributionTime(value)
+			if err != nil {
+				c.JSON(400, gin.H{"error": param + " must be an RFC3339 timestamp or a YYYY-MM-DD date"})
+				return
+			}
+			*target = t
+		}
+
+		maxPoints := 0
+		if value := c.Query("max_points"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "max_points must be a number"})
+				return
+			}
+			maxPoints = parsed
+		}
+
+		bucket := c.DefaultQuery("bucket", models.EquityBucketHour)
+		if err := services.ValidateEquitySeries(bucket, &from, &to, maxPoints); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		series, err := equityCurve.Series(bucket, from, to, maxPoints)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, series)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hft/backend/models"
+	"github.com/hft/backend/services"
+)
+
+// GetCorporateActions returns corporate actions, optionally filtered by
+// symbol and status
+func GetCorporateActions(corporateActions *services.CorporateActions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actions, err := corporateActions.GetActions(c.Query("symbol"), c.Query("status"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch corporate actions"})
+			return
+		}
+		c.JSON(200, gin.H{"actions": actions})
+	}
+}
+
+// GetCorporateAction returns a corporate action with the position
+// adjustments it made
+func GetCorporateAction(corporateActions *services.CorporateActions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid action id"})
+			return
+		}
+
+		action, adjustments, err := corporateActions.GetAction(uint(id))
+		if errors.Is(err, services.ErrCorporateActionNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"action":      action,
+			"adjustments": adjustments,
+		})
+	}
+}
+
+// LoadCorporateActions stores new pending corporate actions
+func LoadCorporateActions(corporateActions *services.CorporateActions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Actions []models.CorporateAction `json:"actions" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		loaded, err := corporateActions.Load(req.Actions, "API")
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"loaded":  loaded,
+			"skipped": len(req.Actions) - loaded,
+		})
+	}
+}
+
+// ApplyCorporateAction applies a pending corporate action ahead of its
+// scheduled processing
+func ApplyCorporateAction(corporateActions *services.CorporateActions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid action id"})
+			return
+		}
+
+		action, err := corporateActions.Apply(uint(id), requestUser(c, ""))
+		switch {
+		case errors.Is(err, services.ErrCorporateActionNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrCorporateActionApplied):
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"action":  action,
+		})
+	}
+}
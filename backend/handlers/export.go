@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hft/backend/models"
+	"github.com/hft/backend/services"
+)
+
+// recordFilterParams are the query parameters that select records. The row
+// limit is not one: it pages a result without changing what is selected.
+var recordFilterParams = []string{
+	"from", "to", "symbol", "side", "status", "strategy", "trader", "account", "tag", "alert_type", "severity",
+}
+
+// parseRecordFilter reads the filters shared by the order, execution, risk
+// alert and daily P&L queries and their exports. defaultLimit applies when
+// no positive limit is given; zero means no limit.
+func parseRecordFilter(c *gin.Context, defaultLimit int) (models.RecordFilter, error) {
+	filter := models.RecordFilter{
+		Symbol:    c.Query("symbol"),
+		Side:      c.Query("side"),
+		Status:    c.Query("status"),
+		Strategy:  c.Query("strategy"),
+		Trader:    c.Query("trader"),
+		Account:   c.Query("account"),
+		Tag:       c.Query("tag"),
+		AlertType: c.Query("alert_type"),
+		Severity:  c.Query("severity"),
+		Limit:     defaultLimit,
+	}
+
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseAttributionTime(value)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be an RFC3339 timestamp or a YYYY-MM-DD date", param)
+		}
+		*target = &t
+	}
+
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+
+	return filter, services.ValidateRecordFilter(&filter)
+}
+
+// hasRecordFilter reports whether any record filter was given
+func hasRecordFilter(c *gin.Context) bool {
+	for _, param := range recordFilterParams {
+		if c.Query(param) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetExportSchemas lists the export datasets with their columns and the
+// supported formats
+func GetExportSchemas() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"datasets": services.ExportSchemas(),
+			"formats":  []string{models.ExportCSV, models.ExportXLSX, models.ExportParquet},
+		})
+	}
+}
+
+// ExportRecords streams every row of a dataset matching the record filters
+// as CSV, XLSX or Parquet, oldest first
+func ExportRecords(exporter *services.Exporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dataset := c.Param("dataset")
+		format := strings.ToLower(c.DefaultQuery("format", models.ExportCSV))
+		if err := services.ValidateExport(dataset, format); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		filter, err := parseRecordFilter(c, 0)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		filename := fmt.Sprintf("%s-%s.%s", dataset, time.Now().UTC().Format("20060102T150405Z"), format)
+		c.Header("Content-Type", services.ExportContentType(format))
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+		rows, err := exporter.Export(dataset, format, filter, c.Writer)
+		if err != nil {
+			if !c.Writer.Written() {
+				c.Writer.Header().Del("Content-Type")
+				c.Writer.Header().Del("Content-Disposition")
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			// The status is already sent; a truncated file is all that can be done
+			log.Printf("Export of %s as %s failed after %d rows: %v", dataset, format, rows, err)
+			return
+		}
+		log.Printf("Exported %d %s rows as %s", rows, dataset, format)
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hft/backend/models"
+	"github.com/hft/backend/services"
+)
+
+// GetFeeSchedules returns every version of the fee schedules, optionally
+// for one account, with the version in force now
+func GetFeeSchedules(feeModel *services.FeeModel) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		account := c.Query("account")
+
+		response := gin.H{"schedules": feeModel.Schedules(account)}
+		if account != "" {
+			response["current"] = feeModel.ScheduleAt(account, time.Now())
+		}
+		c.JSON(200, response)
+	}
+}
+
+// AddFeeSchedule stores a new fee schedule version. A backdated schedule
+// reprices every execution and rebuilds realised P&L.
+func AddFeeSchedule(feeModel *services.FeeModel, pnlLedger *services.PnLLedger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var schedule models.FeeSchedule
+		if err := c.ShouldBindJSON(&schedule); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		schedule.Author = requestUser(c, schedule.Author)
+
+		created, backdated, err := feeModel.AddSchedule(schedule)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if backdated {
+			if err := pnlLedger.Rebuild(); err != nil {
+				c.JSON(500, gin.H{"error": "Fee schedule saved but P&L was not rebuilt: " + err.Error()})
+				return
+			}
+		}
+
+		c.JSON(200, gin.H{
+			"success":  true,
+			"schedule": created,
+			"repriced": backdated,
+		})
+	}
+}
+
+// GetExecutionFees returns the fee breakdown of recent executions
+func GetExecutionFees(feeModel *services.FeeModel) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 100
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+
+		fees, err := feeModel.GetExecutionFees(c.Query("account"), limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch execution fees"})
+			return
+		}
+
+		var commission, secFees, tafFees, total float64
+		for _, fee := range fees {
+			commission += fee.Commission
+			secFees += fee.SECFee
+			tafFees += fee.TAFFee
+			total += fee.TotalFees
+		}
+
+		c.JSON(200, gin.H{
+			"fees":       fees,
+			"commission": commission,
+			"sec_fees":   secFees,
+			"taf_fees":   tafFees,
+			"total_fees": total,
+		})
+	}
+}
@@ -58,10 +58,13 @@ func HealthCheck(engineClient *services.EngineClient, dbService *services.Databa
 			err := redisService.GetClient().Ping(ctx).Err()
 			redisLatency = int(time.Since(redisStart).Milliseconds())
 			if err == nil {
+				hitRate, hits, misses := redisService.HitRate()
 				status["services"].(gin.H)["redis"] = gin.H{
 					"status":     "healthy",
 					"latency_ms": redisLatency,
-					"hit_rate":   95.5, // Placeholder
+					"hit_rate":   hitRate,
+					"hits":       hits,
+					"misses":     misses,
 				}
 			} else {
 				status["services"].(gin.H)["redis"] = gin.H{"status": "unhealthy", "error": err.Error()}
@@ -102,8 +105,10 @@ func HealthCheck(engineClient *services.EngineClient, dbService *services.Databa
 		status["memory_mb"] = m.Alloc / 1024 / 1024
 		status["goroutines"] = runtime.NumGoroutine()
 		
-		// Placeholder for requests per second
-		status["requests_per_sec"] = 0
+		// Request rate and order path latency over the last minute
+		latency := services.GetLatencyRecorder()
+		status["requests_per_sec"] = latency.Rate(services.LatencyStageHTTP, 1)
+		status["latency"] = latency.Snapshot(1)
 		status["active_websockets"] = 0
 
 		if !allHealthy {
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hft/backend/models"
+	"github.com/hft/backend/services"
+)
+
+var earlyClosePattern = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d$`)
+
+// GetMarketSession returns the current trading session in New York time
+func GetMarketSession(calendar *services.MarketCalendar) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, calendar.Session(time.Now()))
+	}
+}
+
+// GetMarketHolidays returns exchange holidays and early closes for a year
+func GetMarketHolidays(calendar *services.MarketCalendar) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		year := time.Now().Year()
+		if yearStr := c.Query("year"); yearStr != "" {
+			parsed, err := strconv.Atoi(yearStr)
+			if err != nil || parsed < 1990 || parsed > 2100 {
+				c.JSON(400, gin.H{"error": "Invalid year"})
+				return
+			}
+			year = parsed
+		}
+
+		holidays := calendar.Holidays(year)
+		c.JSON(200, gin.H{
+			"year":     year,
+			"holidays": holidays,
+			"count":    len(holidays),
+		})
+	}
+}
+
+// SaveMarketHoliday adds or updates a closure or early close that overrides
+// the built-in calendar
+func SaveMarketHoliday(dbService *services.DatabaseService, calendar *services.MarketCalendar) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var holiday models.MarketHoliday
+		if err := c.ShouldBindJSON(&holiday); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if _, err := time.Parse("2006-01-02", holiday.Date); err != nil {
+			c.JSON(400, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+		if holiday.EarlyClose != "" && !earlyClosePattern.MatchString(holiday.EarlyClose) {
+			c.JSON(400, gin.H{"error": "early_close must be HH:MM"})
+			return
+		}
+
+		if err := dbService.GetDB().Save(&holiday).Error; err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save holiday"})
+			return
+		}
+		calendar.Reload()
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"holiday": holiday,
+		})
+	}
+}
+
+// DeleteMarketHoliday removes a calendar override
+func DeleteMarketHoliday(dbService *services.DatabaseService, calendar *services.MarketCalendar) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := dbService.GetDB().Delete(&models.MarketHoliday{}, "date = ?", c.Param("date"))
+		if result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to delete holiday"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(404, gin.H{"error": "Holiday override not found"})
+			return
+		}
+		calendar.Reload()
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
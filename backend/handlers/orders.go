@@ -8,14 +8,17 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hft/backend/middleware"
 	"github.com/hft/backend/models"
 	"github.com/hft/backend/services"
 )
 
-func SubmitOrder(engineClient *services.EngineClient, kafkaService *services.KafkaService, dbService *services.DatabaseService, riskManager *services.RiskManager, positionTracker *services.PositionTracker, redisService *services.RedisService) gin.HandlerFunc {
+func SubmitOrder(engineClient *services.EngineClient, kafkaService *services.KafkaService, dbService *services.DatabaseService, riskManager *services.RiskManager, orderLedger *services.OpenOrderLedger, redisService *services.RedisService, pnlLedger *services.PnLLedger, tca *services.TransactionCostAnalysis) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
 		metrics := services.GetMetrics()
+		latency := services.GetLatencyRecorder()
+		middleware.OrderTimer(c).Lap(services.LatencyStageRisk)
 		
 		// Get validated order from risk middleware (if it was used)
 		var req models.OrderRequest
@@ -36,6 +39,12 @@ func SubmitOrder(engineClient *services.EngineClient, kafkaService *services.Kaf
 			}
 		}
 		
+		// Arrival price for transaction cost analysis
+		var arrivalPrice, arrivalBid, arrivalAsk float64
+		if tca != nil {
+			arrivalPrice, arrivalBid, arrivalAsk = tca.Arrival(strings.ToUpper(req.Symbol))
+		}
+
 	log.Printf("Received order request: Symbol=%s, Side=%s, Quantity=%f, Price=%f, OrderType=%s, ClientOrderID=%s", 
 		req.Symbol, req.Side, req.Quantity, req.Price, req.OrderType, req.ClientOrderID)
 
@@ -84,12 +93,13 @@ func SubmitOrder(engineClient *services.EngineClient, kafkaService *services.Kaf
 		// Generate order ID
 		orderID := req.ClientOrderID
 		if orderID == "" {
-			orderID = generateOrderID()
+			orderID = services.NewClientOrderID()
 		}
 
-		// Track as pending order before submission
-		if positionTracker != nil {
-			positionTracker.AddPendingOrder(req.Symbol, req.Side, req.Quantity, orderID)
+		// Reserve quantity and notional before submission, unless the risk
+		// checks already did
+		if orderLedger != nil {
+			orderLedger.Reserve(&req, orderID)
 		}
 
 		// Prepare order data for engine
@@ -101,16 +111,24 @@ func SubmitOrder(engineClient *services.EngineClient, kafkaService *services.Kaf
 			"price":           req.Price,
 			"order_type":      req.OrderType,
 		}
+		if req.ExtendedHours {
+			orderData["extended_hours"] = true
+		}
 
 		// Submit to engine
+		engineStart := time.Now()
 		response, err := engineClient.SubmitOrder(orderData)
+		latency.Since(services.LatencyStageEngine, engineStart)
 		if err != nil {
 			log.Printf("Error submitting order to engine: %v", err)
 			metrics.ExecutionErrors.WithLabelValues("engine_submit").Inc()
+			if riskManager != nil {
+				riskManager.RecordEngineError()
+			}
 			
-			// Remove from pending on error
-			if positionTracker != nil {
-				positionTracker.RemovePendingOrder(req.Symbol, req.Side, orderID)
+			// Release the reservation on error
+			if orderLedger != nil {
+				orderLedger.Release(orderID, "ENGINE_ERROR")
 			}
 			
 			c.JSON(500, gin.H{"error": "Failed to submit order"})
@@ -118,8 +136,7 @@ func SubmitOrder(engineClient *services.EngineClient, kafkaService *services.Kaf
 		}
 		
 		// Record latency
-		latency := time.Since(startTime).Microseconds()
-		metrics.OrderLatency.WithLabelValues("submit_order").Observe(float64(latency))
+		metrics.OrderLatency.WithLabelValues("submit_order").Observe(float64(time.Since(startTime).Microseconds()))
 
 		// Save to database
 		responseOrderID, _ := response["order_id"].(string)
@@ -138,11 +155,30 @@ func SubmitOrder(engineClient *services.EngineClient, kafkaService *services.Kaf
 			Status:        responseStatus,
 			FilledQty:     responseFillQty,
 			RemainingQty:  responseRemainingQty,
+			Strategy:      req.Strategy,
+			Trader:        middleware.ThrottleIdentity(c, req.Strategy, req.Symbol).UserID,
+			Account:       req.Account,
+			Tags:          req.Tags,
+			ArrivalPrice:  arrivalPrice,
+			ArrivalBid:    arrivalBid,
+			ArrivalAsk:    arrivalAsk,
 		}
+		if order.Account == "" {
+			order.Account = services.DefaultAccount
+		}
+		persistStart := time.Now()
 		dbService.SaveOrder(order)
+		latency.Since(services.LatencyStageDBPersist, persistStart)
 
 		// Publish to Kafka
+		publishStart := time.Now()
 		kafkaService.PublishOrder(order)
+		latency.Since(services.LatencyStageKafkaPublish, publishStart)
+
+		// Feed the rejection-rate breaker
+		if riskManager != nil {
+			riskManager.RecordOrderOutcome(strings.EqualFold(responseStatus, "REJECTED"))
+		}
 		
 		// Record order metrics by status
 		metrics.OrdersTotal.WithLabelValues(order.Status, order.Symbol, order.Side).Inc()
@@ -164,43 +200,50 @@ func SubmitOrder(engineClient *services.EngineClient, kafkaService *services.Kaf
 				Side:          order.Side,
 				FillPrice:     fillPrice,
 				FillQty:       order.FilledQty,
+				Strategy:      order.Strategy,
+				Trader:        order.Trader,
+				Account:       order.Account,
+				Tags:          order.Tags,
 				Timestamp:     time.Now(),
 			}
+			persistStart = time.Now()
 			dbService.SaveExecution(execution)
+			latency.Since(services.LatencyStageDBPersist, persistStart)
+
+			publishStart = time.Now()
 			kafkaService.PublishExecution(execution)
-		}
+			latency.Since(services.LatencyStageKafkaPublish, publishStart)
 
-		// Handle order status and pending tracking
-		if responseStatus == "FILLED" || responseStatus == "PARTIALLY_FILLED" {
-			// Remove from pending tracking when filled
-			if positionTracker != nil {
-				positionTracker.RemovePendingOrder(req.Symbol, req.Side, responseOrderID)
+			if tca != nil {
+				tca.CheckFill(order, execution)
 			}
+		}
 
-			// Update daily P&L if order resulted in realized profit/loss
-			if riskManager != nil && responseStatus == "FILLED" {
-				// Calculate realized P&L (simplified - would need cost basis in real implementation)
-				responseFillPrice, _ := response["fill_price"].(float64)
-				responseFillQtyForPnL, _ := response["fill_qty"].(float64)
-				
-				// This is a simplified P&L calculation
-				// Real implementation would track cost basis per position
-				realizedPnL := 0.0
-				if req.Side == "SELL" {
-					// Estimate P&L on sell (would need actual cost basis)
-					realizedPnL = responseFillPrice * responseFillQtyForPnL * 0.01 // Placeholder
+		// Move the reservation through the order lifecycle
+		if orderLedger != nil {
+			orderLedger.Acknowledge(orderID, responseOrderID, responseStatus)
+			switch strings.ToUpper(responseStatus) {
+			case "REJECTED", "CANCELED", "CANCELLED", "EXPIRED":
+				orderLedger.Release(orderID, strings.ToUpper(responseStatus))
+			default:
+				if responseFillQty > 0 {
+					orderLedger.ApplyFill(orderID, responseFillQty)
 				}
+			}
+		}
 
-				// Get current daily P&L
-				dailyPnL, _ := riskManager.GetDailyPnL()
-				if dailyPnL != nil {
-					riskManager.UpdateDailyPnL(dailyPnL.RealizedPnL+realizedPnL, dailyPnL.UnrealizedPnL)
+		// Book the fill against open lots and check the daily loss limit
+		// against the updated realised P&L
+		if order.FilledQty > 0 && pnlLedger != nil {
+			if err := pnlLedger.Sync(); err != nil {
+				log.Printf("Error updating P&L ledger: %v", err)
+			}
 
-					// Check if circuit breaker should trigger
+			if riskManager != nil {
+				if dailyPnL, err := riskManager.GetDailyPnL(); err == nil {
 					limits := riskManager.GetLimits()
-					newTotal := dailyPnL.RealizedPnL + realizedPnL + dailyPnL.UnrealizedPnL
-					if newTotal <= -limits.DailyLossLimit && !dailyPnL.CircuitBreakerTriggered {
-						riskManager.TriggerCircuitBreaker("DAILY_LOSS", newTotal, -limits.DailyLossLimit)
+					if dailyPnL.TotalPnL <= -limits.DailyLossLimit && !dailyPnL.CircuitBreakerTriggered {
+						riskManager.TriggerCircuitBreaker("DAILY_LOSS", dailyPnL.TotalPnL, -limits.DailyLossLimit)
 					}
 				}
 			}
@@ -212,14 +255,15 @@ func SubmitOrder(engineClient *services.EngineClient, kafkaService *services.Kaf
 	}
 }
 
-// generateOrderID creates a unique order ID
-func generateOrderID() string {
-	return fmt.Sprintf("ORD-%d", time.Now().UnixNano())
-}
-
 func GetOrders(dbService *services.DatabaseService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		orders, err := dbService.GetOrders(100)
+		filter, err := parseRecordFilter(c, 100)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		orders, err := dbService.GetOrders(filter)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to fetch orders"})
 			return
@@ -280,7 +324,7 @@ func GetOpenOrders(engineClient *services.EngineClient, redisService *services.R
 	}
 }
 
-func CancelOrder(engineClient *services.EngineClient, redisService *services.RedisService) gin.HandlerFunc {
+func CancelOrder(engineClient *services.EngineClient, redisService *services.RedisService, orderLedger *services.OpenOrderLedger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		orderID := c.Param("id")
 		
@@ -299,6 +343,27 @@ func CancelOrder(engineClient *services.EngineClient, redisService *services.Red
 			return
 		}
 		
+		// Only a confirmed cancel releases the open order reservation; an
+		// unconfirmed one is left to the order's cancel or fill report
+		confirmed, _ := response["success"].(bool)
+		if status, _ := response["status"].(string); strings.EqualFold(status, "CANCELED") || strings.EqualFold(status, "CANCELLED") {
+			confirmed = true
+		}
+		if !confirmed {
+			log.Printf("Engine did not confirm cancel of order %s: %v", orderID, response["message"])
+			c.JSON(409, gin.H{
+				"success":  false,
+				"error":    "Order cancel not confirmed",
+				"order_id": orderID,
+				"message":  response["message"],
+			})
+			return
+		}
+
+		if orderLedger != nil {
+			orderLedger.Release(orderID, "CANCELED")
+		}
+
 		// Invalidate open orders cache to force fresh fetch from Alpaca
 		if redisService != nil {
 			redisService.InvalidateOpenOrders()
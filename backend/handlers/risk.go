@@ -1,23 +1,44 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hft/backend/middleware"
 	"github.com/hft/backend/models"
 	"github.com/hft/backend/services"
 )
 
-// GetRiskLimits returns current risk limits
+// GetRiskLimits returns current risk limits, or the limits in force at
+// as_of (RFC3339)
 func GetRiskLimits(riskManager *services.RiskManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if asOfStr := c.Query("as_of"); asOfStr != "" {
+			asOf, err := time.Parse(time.RFC3339, asOfStr)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "as_of must be an RFC3339 timestamp"})
+				return
+			}
+
+			limits, err := riskManager.GetLimitsAsOf(asOf)
+			if err != nil {
+				c.JSON(404, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(200, limits)
+			return
+		}
+
 		limits := riskManager.GetLimits()
 		c.JSON(200, limits)
 	}
 }
 
-// UpdateRiskLimits updates risk limits
+// UpdateRiskLimits applies changes that only tighten the risk limits and
+// queues changes that loosen any limit for approval by a second user
 func UpdateRiskLimits(riskManager *services.RiskManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var update models.RiskLimitsUpdate
@@ -26,18 +47,36 @@ func UpdateRiskLimits(riskManager *services.RiskManager) gin.HandlerFunc {
 			return
 		}
 
-		if err := riskManager.UpdateLimit(&update); err != nil {
+		request, diff, err := riskManager.ProposeLimitChange(&update, limitChangeMeta(c, update.Reason))
+		if errors.Is(err, services.ErrLimitChangeAnonymous) {
+			c.JSON(401, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to update limits"})
 			return
 		}
 
+		if request != nil {
+			c.JSON(202, gin.H{
+				"success": true,
+				"pending": true,
+				"request": request,
+			})
+			return
+		}
+
 		// Send alert about limit change
-		riskManager.SendAlert("LIMITS_UPDATED", "INFO", "",
-			"Risk limits have been updated",
-			map[string]interface{}{"update": update})
+		if len(diff) > 0 {
+			riskManager.SendAlert("LIMITS_UPDATED", "INFO", "",
+				"Risk limits have been updated",
+				map[string]interface{}{"update": update, "diff": diff})
+		}
 
 		c.JSON(200, gin.H{
 			"success": true,
+			"pending": false,
+			"diff":    diff,
 			"limits":  riskManager.GetLimits(),
 		})
 	}
@@ -46,16 +85,15 @@ func UpdateRiskLimits(riskManager *services.RiskManager) gin.HandlerFunc {
 // GetRiskAlerts returns recent risk alerts
 func GetRiskAlerts(dbService *services.DatabaseService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get limit from query param, default to 100
-		limit := 100
-		if limitStr := c.Query("limit"); limitStr != "" {
-			if val, parseErr := strconv.Atoi(limitStr); parseErr == nil && val > 0 {
-				limit = val
-			}
+		// Same filters as the export; limit defaults to 100
+		filter, err := parseRecordFilter(c, 100)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
 		}
 
 		var alerts []models.RiskAlert
-		result := dbService.GetDB().Order("created_at DESC").Limit(limit).Find(&alerts)
+		result := services.RiskAlertsQuery(dbService.GetDB(), filter).Order("created_at DESC").Limit(filter.Limit).Find(&alerts)
 
 		if result.Error != nil {
 			c.JSON(500, gin.H{"error": "Failed to fetch alerts"})
@@ -85,29 +123,68 @@ func GetDailyPnLRisk(riskManager *services.RiskManager) gin.HandlerFunc {
 // GetCircuitBreakerStatus returns current circuit breaker status
 func GetCircuitBreakerStatus(riskManager *services.RiskManager, dbService *services.DatabaseService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Check if circuit breaker is active
+		// Check if a global circuit breaker is active
 		isActive := riskManager.IsCircuitBreakerActive()
-		
-		// Get latest circuit breaker event from database
-		var latestBreaker models.CircuitBreakerEvent
-		result := dbService.GetDB().Where("active = ?", true).Order("created_at DESC").First(&latestBreaker)
-		
-		if result.Error != nil {
+
+		// Get all open and half-open breakers across scopes
+		breakers, err := riskManager.GetActiveCircuitBreakers()
+		if err != nil || len(breakers) == 0 {
 			// No active circuit breaker
 			c.JSON(200, gin.H{
-				"active": false,
-				"status": "normal",
+				"active":   false,
+				"status":   "normal",
+				"breakers": []models.CircuitBreakerEvent{},
 			})
 			return
 		}
-		
+
+		latestBreaker := breakers[0]
 		c.JSON(200, gin.H{
 			"active":        isActive,
 			"id":            latestBreaker.ID,
+			"scope":         latestBreaker.Scope,
+			"scope_key":     latestBreaker.ScopeKey,
+			"state":         latestBreaker.State,
 			"trigger_type":  latestBreaker.TriggerType,
 			"trigger_value": latestBreaker.TriggerValue,
 			"threshold":     latestBreaker.Threshold,
 			"created_at":    latestBreaker.CreatedAt,
+			"breakers":      breakers,
+		})
+	}
+}
+
+// TriggerCircuitBreaker manually trips a global, symbol or strategy breaker
+func TriggerCircuitBreaker(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CircuitBreakerTrigger
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.Scope != models.BreakerScopeGlobal && req.ScopeKey == "" {
+			c.JSON(400, gin.H{"error": "scope_key required for " + req.Scope + " scope"})
+			return
+		}
+		if req.Scope == models.BreakerScopeGlobal {
+			req.ScopeKey = ""
+		}
+
+		triggerType := "MANUAL"
+		if req.Reason != "" {
+			triggerType = "MANUAL: " + req.Reason
+		}
+
+		cooldown := time.Duration(req.DurationSeconds) * time.Second
+		if err := riskManager.TriggerScopedCircuitBreaker(req.Scope, req.ScopeKey, triggerType, 0, 0, cooldown); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to trigger circuit breaker"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"message": "Circuit breaker triggered",
 		})
 	}
 }
@@ -160,8 +237,9 @@ func GetPositionLimit(riskManager *services.RiskManager, dbService *services.Dat
 	}
 }
 
-// UpdatePositionLimit updates or creates position limit for a symbol
-func UpdatePositionLimit(riskManager *services.RiskManager, dbService *services.DatabaseService) gin.HandlerFunc {
+// UpdatePositionLimit updates or creates position limit for a symbol.
+// Raising either limit is queued for approval by a second user.
+func UpdatePositionLimit(riskManager *services.RiskManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		symbol := c.Param("symbol")
 
@@ -171,16 +249,24 @@ func UpdatePositionLimit(riskManager *services.RiskManager, dbService *services.
 			return
 		}
 
-		// Upsert position limit
-		limit := models.PositionLimit{
-			Symbol:              symbol,
-			MaxPosition:         update.MaxPosition,
-			MaxConcentrationPct: update.MaxConcentrationPct,
-			UpdatedAt:           time.Now(),
-			CreatedAt:           time.Now(),
+		request, limit, err := riskManager.ProposePositionLimitChange(symbol, &update, limitChangeMeta(c, update.Reason))
+		if errors.Is(err, services.ErrLimitChangeAnonymous) {
+			c.JSON(401, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to update position limit"})
+			return
 		}
 
-		dbService.GetDB().Save(&limit)
+		if request != nil {
+			c.JSON(202, gin.H{
+				"success": true,
+				"pending": true,
+				"request": request,
+			})
+			return
+		}
 
 		// Send alert
 		riskManager.SendAlert("POSITION_LIMIT_UPDATED", "INFO", symbol,
@@ -193,8 +279,631 @@ func UpdatePositionLimit(riskManager *services.RiskManager, dbService *services.
 
 		c.JSON(200, gin.H{
 			"success": true,
+			"pending": false,
 			"limit":   limit,
 		})
 	}
 }
 
+// GetThrottleLimits returns configured order throttle limits
+func GetThrottleLimits(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limits := riskManager.GetThrottleLimits()
+		c.JSON(200, gin.H{
+			"throttle_limits": limits,
+			"count":           len(limits),
+		})
+	}
+}
+
+// UpdateThrottleLimit creates or updates a token bucket limit for a scope key
+// and action. Raising the rate or burst is queued for approval by a second
+// user.
+func UpdateThrottleLimit(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var update models.ThrottleLimitUpdate
+		if err := c.ShouldBindJSON(&update); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		request, limit, err := riskManager.ProposeThrottleLimitChange(&update, limitChangeMeta(c, update.Reason))
+		if errors.Is(err, services.ErrLimitChangeAnonymous) {
+			c.JSON(401, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to update throttle limit"})
+			return
+		}
+
+		if request != nil {
+			c.JSON(202, gin.H{
+				"success": true,
+				"pending": true,
+				"request": request,
+			})
+			return
+		}
+
+		riskManager.SendAlert("THROTTLE_LIMIT_UPDATED", "INFO", "",
+			"Throttle limit updated for "+update.Scope+" "+update.Key,
+			map[string]interface{}{"update": update})
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"pending": false,
+			"limit":   limit,
+		})
+	}
+}
+
+// SimulateRisk runs the pre-trade risk chain on one or more orders without
+// submitting them and reports rule results and projected limit utilisation
+func SimulateRisk(riskSimulator *services.RiskSimulator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.RiskSimulationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := riskSimulator.Simulate(req.Orders, middleware.ThrottleIdentity(c, "", ""))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to simulate orders"})
+			return
+		}
+
+		c.JSON(200, result)
+	}
+}
+
+// GetOpenOrderExposure returns open-order reservations and exposure per account and symbol
+func GetOpenOrderExposure(orderLedger *services.OpenOrderLedger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		account := c.DefaultQuery("account", services.DefaultAccount)
+
+		response := gin.H{
+			"account":               account,
+			"exposure":              orderLedger.GetExposure(),
+			"open_orders":           orderLedger.GetOpenOrders(),
+			"reserved_buying_power": orderLedger.ReservedBuyingPower(account),
+		}
+		if available, ok := orderLedger.AvailableBuyingPower(account); ok {
+			response["available_buying_power"] = available
+		}
+
+		c.JSON(200, response)
+	}
+}
+
+// GetTradingActivity returns day-trade, trade count and turnover usage
+func GetTradingActivity(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		activity, err := riskManager.GetTradingActivity()
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to load trading activity"})
+			return
+		}
+
+		c.JSON(200, activity)
+	}
+}
+
+// GetRestrictedSymbols returns restricted, close-only and hard-to-borrow list entries
+func GetRestrictedSymbols(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entries := riskManager.GetRestrictedSymbols(c.Query("list_type"), c.Query("include_expired") == "true")
+		c.JSON(200, gin.H{
+			"entries": entries,
+			"count":   len(entries),
+		})
+	}
+}
+
+// SaveRestrictedSymbol adds a symbol to a restricted list, or updates its entry
+func SaveRestrictedSymbol(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.RestrictedSymbolRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		req.Author = authenticatedUser(c)
+		if req.Author == "" {
+			c.JSON(401, gin.H{"error": "Authorization required"})
+			return
+		}
+
+		entry, err := riskManager.SaveRestrictedSymbol(&req)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save restricted symbol"})
+			return
+		}
+
+		riskManager.SendAlert("RESTRICTED_LIST_UPDATED", "INFO", entry.Symbol,
+			entry.Symbol+" added to "+entry.ListType+" list by "+entry.Author,
+			map[string]interface{}{"entry": entry})
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"entry":   entry,
+		})
+	}
+}
+
+// UpdateRestrictedSymbol updates a restricted list entry by ID
+func UpdateRestrictedSymbol(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid entry id"})
+			return
+		}
+
+		var req models.RestrictedSymbolRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		req.Author = authenticatedUser(c)
+		if req.Author == "" {
+			c.JSON(401, gin.H{"error": "Authorization required"})
+			return
+		}
+
+		entry, err := riskManager.UpdateRestrictedSymbol(uint(id), &req)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to update restricted symbol"})
+			return
+		}
+		if entry == nil {
+			c.JSON(404, gin.H{"error": "Restricted symbol not found"})
+			return
+		}
+
+		riskManager.SendAlert("RESTRICTED_LIST_UPDATED", "INFO", entry.Symbol,
+			entry.Symbol+" "+entry.ListType+" entry updated by "+entry.Author,
+			map[string]interface{}{"entry": entry})
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"entry":   entry,
+		})
+	}
+}
+
+// DeleteRestrictedSymbol removes a restricted list entry
+func DeleteRestrictedSymbol(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid entry id"})
+			return
+		}
+
+		user := authenticatedUser(c)
+		if user == "" {
+			c.JSON(401, gin.H{"error": "Authorization required"})
+			return
+		}
+
+		found, err := riskManager.DeleteRestrictedSymbol(uint(id))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to delete restricted symbol"})
+			return
+		}
+		if !found {
+			c.JSON(404, gin.H{"error": "Restricted symbol not found"})
+			return
+		}
+
+		riskManager.SendAlert("RESTRICTED_LIST_UPDATED", "INFO", "",
+			"Restricted list entry "+c.Param("id")+" removed by "+user,
+			map[string]interface{}{"entry_id": id})
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// requestUser prefers the authenticated user over the name in the body
+func requestUser(c *gin.Context, name string) string {
+	if user := authenticatedUser(c); user != "" {
+		return user
+	}
+	if name == "" {
+		return "anonymous"
+	}
+	return name
+}
+
+// authenticatedUser returns the caller's user ID from a verified token, or ""
+func authenticatedUser(c *gin.Context) string {
+	if userID, exists := c.Get("user_id"); exists && userID != nil {
+		return fmt.Sprintf("%v", userID)
+	}
+	return ""
+}
+
+// GetLimitChangeRequests returns proposed limit changes, optionally filtered by status
+func GetLimitChangeRequests(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 100
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+				limit = l
+			}
+		}
+
+		requests, err := riskManager.GetLimitChangeRequests(c.Query("status"), limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch limit change requests"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"requests": requests,
+			"count":    len(requests),
+		})
+	}
+}
+
+// ApproveLimitChange applies a pending limit change. The approver must be
+// authenticated and differ from the requester.
+func ApproveLimitChange(riskManager *services.RiskManager) gin.HandlerFunc {
+	return reviewLimitChange(riskManager, true)
+}
+
+// RejectLimitChange declines a pending limit change
+func RejectLimitChange(riskManager *services.RiskManager) gin.HandlerFunc {
+	return reviewLimitChange(riskManager, false)
+}
+
+func reviewLimitChange(riskManager *services.RiskManager, approve bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request id"})
+			return
+		}
+
+		var review models.LimitChangeReview
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&review); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		reviewer := requestUser(c, "")
+
+		var request *models.LimitChangeRequest
+		if approve {
+			request, err = riskManager.ApproveLimitChange(uint(id), reviewer, review.Comment)
+		} else {
+			request, err = riskManager.RejectLimitChange(uint(id), reviewer, review.Comment)
+		}
+
+		switch {
+		case errors.Is(err, services.ErrLimitChangeNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrLimitChangeAnonymous):
+			c.JSON(401, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrLimitChangeSelfReview):
+			c.JSON(403, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrLimitChangeNotPending):
+			c.JSON(409, gin.H{"error": err.Error(), "status": request.Status})
+			return
+		case errors.Is(err, services.ErrLimitChangeExpired):
+			c.JSON(410, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(500, gin.H{"error": "Failed to apply limit change"})
+			return
+		}
+
+		if approve {
+			riskManager.SendAlert("LIMITS_UPDATED", "INFO", request.Symbol,
+				fmt.Sprintf("Limit change %d requested by %s approved by %s", request.ID, request.RequestedBy, reviewer),
+				map[string]interface{}{"request_id": request.ID, "diff": request.Diff})
+		} else {
+			riskManager.SendAlert("LIMIT_CHANGE_REJECTED", "INFO", request.Symbol,
+				fmt.Sprintf("Limit change %d requested by %s rejected by %s", request.ID, request.RequestedBy, reviewer),
+				map[string]interface{}{"request_id": request.ID, "comment": review.Comment})
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"request": request,
+			"limits":  riskManager.GetLimits(),
+		})
+	}
+}
+
+// limitChangeMeta attributes an API limit change to the caller
+func limitChangeMeta(c *gin.Context, reason string) models.LimitChangeMeta {
+	return models.LimitChangeMeta{
+		Author: authenticatedUser(c),
+		Reason: reason,
+		Source: models.LimitSourceUpdate,
+	}
+}
+
+// GetLimitHistory returns recorded versions of the risk and position limits
+func GetLimitHistory(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 100
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+				limit = l
+			}
+		}
+
+		versions, err := riskManager.GetLimitHistory(c.Query("kind"), c.Query("symbol"), limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch limit history"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"versions": versions,
+			"count":    len(versions),
+		})
+	}
+}
+
+// RollbackLimits restores the limits recorded in an earlier version.
+// Restoring looser limits is queued for approval like any other change.
+func RollbackLimits(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid version id"})
+			return
+		}
+
+		var rollback models.LimitRollback
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&rollback); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		version, request, err := riskManager.RollbackLimits(uint(id), authenticatedUser(c), rollback.Reason)
+		if errors.Is(err, services.ErrLimitChangeAnonymous) {
+			c.JSON(401, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrLimitVersionNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to roll back limits"})
+			return
+		}
+
+		if request != nil {
+			c.JSON(202, gin.H{
+				"success": true,
+				"pending": true,
+				"version": version,
+				"request": request,
+			})
+			return
+		}
+
+		riskManager.SendAlert("LIMITS_UPDATED", "INFO", version.Symbol,
+			fmt.Sprintf("%s limits rolled back to version %d", version.Kind, version.Version),
+			map[string]interface{}{"version_id": version.ID})
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"pending": false,
+			"version": version,
+			"limits":  riskManager.GetLimits(),
+		})
+	}
+}
+
+// GetLimitTree returns the limit hierarchy with P&L, exposure and
+// utilisation rolled up at every node
+func GetLimitTree(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"tree":      riskManager.GetLimitTree(),
+			"timestamp": time.Now(),
+		})
+	}
+}
+
+// SaveLimitNode creates a limit hierarchy node, or updates one by ID.
+// Changes that loosen a limit or move orders between nodes are queued for
+// approval by a second user.
+func SaveLimitNode(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var id uint64
+		if idStr := c.Param("id"); idStr != "" {
+			parsed, err := strconv.ParseUint(idStr, 10, 64)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid node id"})
+				return
+			}
+			id = parsed
+		}
+
+		var req models.LimitNodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		request, node, err := riskManager.ProposeLimitNodeChange(uint(id), &req, limitChangeMeta(c, req.Reason))
+		if errors.Is(err, services.ErrLimitChangeAnonymous) {
+			c.JSON(401, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrLimitNodeNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if request != nil {
+			c.JSON(202, gin.H{
+				"success": true,
+				"pending": true,
+				"request": request,
+			})
+			return
+		}
+
+		riskManager.SendAlert("LIMIT_NODE_UPDATED", "INFO", "",
+			fmt.Sprintf("%s limit node %s saved by %s", node.Level, node.Name, requestUser(c, "")),
+			map[string]interface{}{"node": node})
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"pending": false,
+			"node":    node,
+		})
+	}
+}
+
+// DeleteLimitNode queues the removal of a limit hierarchy node without
+// children for approval by a second user
+func DeleteLimitNode(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid node id"})
+			return
+		}
+
+		request, err := riskManager.ProposeLimitNodeDelete(uint(id), limitChangeMeta(c, c.Query("reason")))
+		switch {
+		case errors.Is(err, services.ErrLimitChangeAnonymous):
+			c.JSON(401, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrLimitNodeNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrLimitNodeHasChildren):
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(500, gin.H{"error": "Failed to delete limit node"})
+			return
+		}
+
+		c.JSON(202, gin.H{
+			"success": true,
+			"pending": true,
+			"request": request,
+		})
+	}
+}
+
+// GetRiskUtilisation returns how close the portfolio is to each global and
+// per-symbol limit
+func GetRiskUtilisation(riskSnapshot *services.RiskSnapshotService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		utilisation, err := riskSnapshot.GetUtilisation()
+		if err != nil {
+			c.JSON(503, gin.H{"error": "Failed to compute limit utilisation: " + err.Error()})
+			return
+		}
+		c.JSON(200, utilisation)
+	}
+}
+
+// GetSessionSummaries returns end-of-day summaries, newest first
+func GetSessionSummaries(sessionRollover *services.SessionRollover) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 30
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+				limit = l
+			}
+		}
+
+		summaries, err := sessionRollover.GetSessionSummaries(limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch session summaries"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"sessions": summaries,
+			"count":    len(summaries),
+		})
+	}
+}
+
+// GetSession returns the summary and frozen positions of a trading day
+func GetSession(sessionRollover *services.SessionRollover) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		detail, err := sessionRollover.GetSession(c.Param("day"))
+		if err != nil {
+			c.JSON(404, gin.H{"error": "No end-of-day snapshot for " + c.Param("day")})
+			return
+		}
+		c.JSON(200, detail)
+	}
+}
+
+// CloseSession runs the end-of-day for the current trading day now
+func CloseSession(sessionRollover *services.SessionRollover) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		summary, err := sessionRollover.CloseDay()
+		if errors.Is(err, services.ErrSessionAlreadyClosed) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to close trading day: " + err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"summary": summary,
+		})
+	}
+}
+
+// GetMarks returns open positions marked to market with the price used for
+// each and any symbols whose price is stale
+func GetMarks(markToMarket *services.MarkToMarket) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, markToMarket.Snapshot())
+	}
+}
+
+// UpdateMarkConfig changes the marking rule and stale-price threshold
+func UpdateMarkConfig(markToMarket *services.MarkToMarket) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := markToMarket.Config()
+		if err := c.ShouldBindJSON(&config); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := markToMarket.SetConfig(config); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"config":  markToMarket.Config(),
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hft/backend/models"
+	"github.com/hft/backend/services"
+)
+
+// GetVaR returns historical and parametric VaR/ES of the live portfolio
+func GetVaR(riskAnalytics *services.RiskAnalytics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Confidence levels, e.g. ?confidence=0.95,0.99 (percent values also accepted)
+		confidences := []float64{}
+		for _, part := range strings.Split(c.DefaultQuery("confidence", "0.95,0.99"), ",") {
+			value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid confidence level: " + part})
+				return
+			}
+			if value > 1 {
+				value /= 100
+			}
+			if value <= 0.5 || value >= 1 {
+				c.JSON(400, gin.H{"error": "Confidence levels must be between 0.5 and 1"})
+				return
+			}
+			confidences = append(confidences, value)
+		}
+
+		lookback, err := strconv.Atoi(c.DefaultQuery("lookback", "250"))
+		if err != nil || lookback < 20 || lookback > 2500 {
+			c.JSON(400, gin.H{"error": "Lookback must be between 20 and 2500 days"})
+			return
+		}
+
+		horizon, err := strconv.Atoi(c.DefaultQuery("horizon", "1"))
+		if err != nil || horizon < 1 || horizon > 30 {
+			c.JSON(400, gin.H{"error": "Horizon must be between 1 and 30 days"})
+			return
+		}
+
+		report, err := riskAnalytics.ComputeVaR(confidences, lookback, horizon)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to compute VaR"})
+			return
+		}
+
+		c.JSON(200, report)
+	}
+}
+
+// GetStressResults runs stored stress scenarios against the live portfolio
+func GetStressResults(riskAnalytics *services.RiskAnalytics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Query("scenario")
+
+		results := []*models.StressResult{}
+		for _, scenario := range riskAnalytics.GetScenarios() {
+			if name != "" && scenario.Name != name {
+				continue
+			}
+
+			result, err := riskAnalytics.RunStress(&scenario)
+			if err != nil {
+				c.JSON(500, gin.H{"error": "Failed to run stress scenario " + scenario.Name})
+				return
+			}
+			results = append(results, result)
+		}
+
+		if name != "" && len(results) == 0 {
+			c.JSON(404, gin.H{"error": "Scenario not found"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"results": results,
+			"count":   len(results),
+		})
+	}
+}
+
+// RunStressScenario runs an ad-hoc stress scenario without storing it
+func RunStressScenario(riskAnalytics *services.RiskAnalytics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var scenario models.StressScenario
+		if err := c.ShouldBindJSON(&scenario); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := riskAnalytics.RunStress(&scenario)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, result)
+	}
+}
+
+// GetStressScenarios returns the stress scenarios that GET /risk/stress runs
+func GetStressScenarios(riskAnalytics *services.RiskAnalytics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scenarios := riskAnalytics.GetScenarios()
+		c.JSON(200, gin.H{
+			"scenarios": scenarios,
+			"count":     len(scenarios),
+		})
+	}
+}
+
+// SaveStressScenario creates or updates a named stress scenario
+func SaveStressScenario(dbService *services.DatabaseService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var scenario models.StressScenario
+		if err := c.ShouldBindJSON(&scenario); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		var existing models.StressScenario
+		if dbService.GetDB().Where("name = ?", scenario.Name).First(&existing).Error == nil {
+			scenario.ID = existing.ID
+			scenario.CreatedAt = existing.CreatedAt
+		}
+
+		if err := dbService.GetDB().Save(&scenario).Error; err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save scenario"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success":  true,
+			"scenario": scenario,
+		})
+	}
+}
+
+// DeleteStressScenario deletes a stored stress scenario
+func DeleteStressScenario(dbService *services.DatabaseService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid scenario id"})
+			return
+		}
+
+		result := dbService.GetDB().Delete(&models.StressScenario{}, id)
+		if result.Error != nil {
+			c.JSON(500, gin.H{"error": "Failed to delete scenario"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(404, gin.H{"error": "Scenario not found"})
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
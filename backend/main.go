@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/hft/backend/handlers"
 	"github.com/hft/backend/middleware"
+	"github.com/hft/backend/models"
 	"github.com/hft/backend/services"
 	"github.com/joho/godotenv"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -39,21 +41,90 @@ func main() {
 	dbService := services.NewDatabaseService(getEnv("DATABASE_URL", ""))
 	redisService := services.NewRedisService(getEnv("REDIS_URL", "redis://hft-redis:6379"))
 	kafkaService := services.NewKafkaService(getEnv("KAFKA_BROKERS", "hft-kafka:9092"))
+	cheetrClient := services.NewCheetrClient(getEnv("CHEETR_API_KEY", ""), getEnv("CHEETR_API_URL", ""))
 
 	// Initialize risk management services
 	wsHub := services.NewWebSocketHub()
-	riskManager := services.NewRiskManager(dbService, redisService, wsHub)
-	positionTracker := services.NewPositionTracker(dbService, redisService, engineClient)
-	pnlMonitor := services.NewPnLMonitor(riskManager, engineClient)
+	marketCalendar := services.NewMarketCalendar(dbService, cheetrClient)
+	orderLedger := services.NewOpenOrderLedger(redisService, engineClient)
+	riskManager := services.NewRiskManager(dbService, redisService, wsHub, orderLedger, marketCalendar)
+	feeModel := services.NewFeeModel(dbService)
+	pnlLedger := services.NewPnLLedger(dbService, riskManager, feeModel, getEnv("PNL_COST_METHOD", "FIFO"))
+	positionBook := services.NewPositionBook(pnlLedger, engineClient, riskManager)
+	positionTracker := services.NewPositionTracker(dbService, redisService, engineClient, orderLedger, positionBook)
+	sessionRollover := services.NewSessionRollover(riskManager, marketCalendar, positionBook, orderLedger)
+	riskSimulator := services.NewRiskSimulator(riskManager, positionTracker, engineClient)
+	pnlMonitor := services.NewPnLMonitor(riskManager, positionBook)
+	markToMarket := services.NewMarkToMarket(redisService, positionBook, pnlMonitor, riskManager, models.MarkConfig{
+		Rule:              getEnv("MTM_MARK_RULE", models.MarkRuleMid),
+		StaleAfterSeconds: getEnvInt("MTM_STALE_SECONDS", 30),
+	})
+	positionBook.UseMarks(markToMarket)
+	marketDataFeed := services.NewMarketDataFeed(redisService, cheetrClient, positionBook, orderLedger)
+	tca := services.NewTransactionCostAnalysis(dbService, markToMarket, riskManager, models.TCAConfig{
+		SlippageAlertBps: float64(getEnvInt("TCA_SLIPPAGE_ALERT_BPS", 25)),
+	})
+	pnlAttribution := services.NewPnLAttribution(dbService, pnlLedger, positionBook, riskManager)
+	tradePerformance := services.NewTradePerformance(dbService, pnlLedger, marketCalendar)
+	equityCurve := services.NewEquityCurve(dbService, engineClient, pnlLedger, positionBook, riskManager)
+	exporter := services.NewExporter(dbService)
 	configReloader := services.NewConfigReloader(riskManager)
+	riskAnalytics := services.NewRiskAnalytics(riskManager, engineClient, cheetrClient, dbService)
+	corporateActions := services.NewCorporateActions(dbService, riskManager, pnlLedger, orderLedger)
+	riskAnalytics.UseCorporateActions(corporateActions)
+	if path := getEnv("CORPORATE_ACTIONS_FILE", ""); path != "" {
+		if loaded, err := corporateActions.LoadFile(path); err != nil {
+			log.Error().Err(err).Str("path", path).Msg("Failed to load corporate actions")
+		} else {
+			log.Info().Int("loaded", loaded).Str("path", path).Msg("Loaded corporate actions")
+		}
+	}
+	selfTradeGuard := services.NewSelfTradeGuard(riskManager, engineClient, redisService, orderLedger, dbService)
+	riskSnapshot := services.NewRiskSnapshotService(riskManager, engineClient, wsHub)
 
 	// Start background services
+	marketCalendar.Start()
+	defer marketCalendar.Stop()
+
 	pnlMonitor.Start()
 	defer pnlMonitor.Stop()
 	
 	configReloader.Start()
 	defer configReloader.Stop()
 
+	riskAnalytics.Start()
+	defer riskAnalytics.Stop()
+
+	orderLedger.Start()
+	defer orderLedger.Stop()
+
+	selfTradeGuard.Start()
+	defer selfTradeGuard.Stop()
+
+	riskSnapshot.Start()
+	defer riskSnapshot.Stop()
+
+	pnlLedger.Start()
+	defer pnlLedger.Stop()
+
+	corporateActions.Start()
+	defer corporateActions.Stop()
+
+	positionBook.Start()
+	defer positionBook.Stop()
+
+	markToMarket.Start()
+	defer markToMarket.Stop()
+
+	marketDataFeed.Start()
+	defer marketDataFeed.Stop()
+
+	equityCurve.Start()
+	defer equityCurve.Stop()
+
+	sessionRollover.Start()
+	defer sessionRollover.Stop()
+
 	log.Info().Msg("All services initialized successfully")
 	log.Info().Msg("Risk management system enabled")
 
@@ -65,8 +136,9 @@ func main() {
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"*"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-API-Key"}
 	r.Use(cors.New(config))
+	r.Use(middleware.RequestLatency(services.GetLatencyRecorder()))
 
 	// Health check with detailed status
 	r.GET("/health", handlers.HealthCheck(engineClient, dbService, redisService))
@@ -91,28 +163,56 @@ func main() {
 		api.GET("/", handlers.APIHomePage())
 		
 		// Order endpoints with risk validation
-		api.POST("/order", middleware.RiskValidation(riskManager, positionTracker), handlers.SubmitOrder(engineClient, kafkaService, dbService, riskManager, positionTracker, redisService))
+		api.POST("/order", middleware.OrderLatency(services.GetLatencyRecorder()), middleware.OptionalAuth(), middleware.RiskValidation(riskManager, positionTracker), middleware.SelfTradePrevention(selfTradeGuard, riskManager), handlers.SubmitOrder(engineClient, kafkaService, dbService, riskManager, orderLedger, redisService, pnlLedger, tca))
 		api.GET("/orders", middleware.OptionalAuth(), handlers.GetOrders(dbService))
 		api.GET("/orders/open", middleware.OptionalAuth(), handlers.GetOpenOrders(engineClient, redisService))
 		api.GET("/orders/:id", middleware.OptionalAuth(), handlers.GetOrder(dbService))
-		api.DELETE("/order/:id", middleware.OptionalAuth(), handlers.CancelOrder(engineClient, redisService))
+		api.DELETE("/order/:id", middleware.OptionalAuth(), middleware.CancelThrottle(riskManager), handlers.CancelOrder(engineClient, redisService, orderLedger))
 
 		// Account endpoints
 		api.GET("/account", middleware.OptionalAuth(), handlers.GetAccount(engineClient))
 
 		// Position endpoints
-		api.GET("/positions", middleware.OptionalAuth(), handlers.GetPositions(engineClient, redisService))
+		api.GET("/positions", middleware.OptionalAuth(), handlers.GetPositions(positionBook))
+		api.GET("/positions/reconciliation", middleware.OptionalAuth(), handlers.GetPositionReconciliation(positionBook))
+		api.POST("/positions/reconcile", middleware.RequireAuth(), handlers.ReconcilePositions(positionBook))
+
+		// Corporate action endpoints
+		api.GET("/corporate-actions", middleware.OptionalAuth(), handlers.GetCorporateActions(corporateActions))
+		api.GET("/corporate-actions/:id", middleware.OptionalAuth(), handlers.GetCorporateAction(corporateActions))
+		api.POST("/corporate-actions", middleware.RequireAuth(), handlers.LoadCorporateActions(corporateActions))
+		api.POST("/corporate-actions/:id/apply", middleware.RequireAuth(), handlers.ApplyCorporateAction(corporateActions))
 
 		// Execution endpoints
 		api.GET("/executions", middleware.OptionalAuth(), handlers.GetExecutions(dbService, engineClient))
 
 		// Analytics endpoints
-		api.GET("/analytics", middleware.OptionalAuth(), handlers.GetAnalytics(dbService, engineClient))
+		api.GET("/analytics", middleware.OptionalAuth(), handlers.GetAnalytics(dbService, engineClient, pnlLedger, tradePerformance))
 		api.GET("/analytics/daily-pnl", middleware.OptionalAuth(), handlers.GetDailyPnL(dbService))
+		api.GET("/analytics/lots", middleware.OptionalAuth(), handlers.GetPnLLots(pnlLedger))
+		api.GET("/analytics/attribution", middleware.OptionalAuth(), handlers.GetPnLAttribution(pnlAttribution))
+		api.GET("/analytics/performance", middleware.OptionalAuth(), handlers.GetPerformance(tradePerformance))
+		api.GET("/analytics/tca", middleware.OptionalAuth(), handlers.GetTCA(tca))
+		api.GET("/analytics/equity", middleware.OptionalAuth(), handlers.GetEquityCurve(equityCurve))
+		api.PUT("/analytics/tca/config", middleware.RequireAuth(), handlers.UpdateTCAConfig(tca))
+		api.GET("/fees/schedules", middleware.OptionalAuth(), handlers.GetFeeSchedules(feeModel))
+		api.POST("/fees/schedules", middleware.RequireAuth(), handlers.AddFeeSchedule(feeModel, pnlLedger))
+		api.GET("/fees/executions", middleware.OptionalAuth(), handlers.GetExecutionFees(feeModel))
+		api.PUT("/analytics/cost-method", middleware.RequireAuth(), handlers.SetCostMethod(pnlLedger))
+
+		// Export endpoints
+		api.GET("/export", middleware.OptionalAuth(), handlers.GetExportSchemas())
+		api.GET("/export/:dataset", middleware.OptionalAuth(), handlers.ExportRecords(exporter))
 
 		// Market data (if implemented)
 		api.GET("/marketdata/:symbol", handlers.GetMarketData(redisService))
 		
+		// Market calendar
+		api.GET("/market/session", handlers.GetMarketSession(marketCalendar))
+		api.GET("/market/holidays", handlers.GetMarketHolidays(marketCalendar))
+		api.POST("/market/holidays", middleware.RequireAuth(), handlers.SaveMarketHoliday(dbService, marketCalendar))
+		api.DELETE("/market/holidays/:date", middleware.RequireAuth(), handlers.DeleteMarketHoliday(dbService, marketCalendar))
+
 		// Market movers
 		api.GET("/movers", middleware.OptionalAuth(), handlers.GetMarketMovers(engineClient))
 		
@@ -132,13 +232,44 @@ func main() {
 		risk := api.Group("/risk")
 		{
 			risk.GET("/limits", handlers.GetRiskLimits(riskManager))
-			risk.PUT("/limits", middleware.OptionalAuth(), handlers.UpdateRiskLimits(riskManager))
+			risk.PUT("/limits", middleware.RequireAuth(), handlers.UpdateRiskLimits(riskManager))
+			risk.GET("/limits/history", handlers.GetLimitHistory(riskManager))
+			risk.POST("/limits/history/:id/rollback", middleware.RequireAuth(), handlers.RollbackLimits(riskManager))
 			risk.GET("/alerts", handlers.GetRiskAlerts(dbService))
 			risk.GET("/daily-pnl", handlers.GetDailyPnLRisk(riskManager))
 			risk.GET("/circuit-breaker", handlers.GetCircuitBreakerStatus(riskManager, dbService))
+			risk.POST("/circuit-breaker/trigger", middleware.OptionalAuth(), handlers.TriggerCircuitBreaker(riskManager))
 			risk.POST("/circuit-breaker/reset", middleware.OptionalAuth(), handlers.ResetCircuitBreaker(riskManager))
+			risk.GET("/limit-changes", handlers.GetLimitChangeRequests(riskManager))
+			risk.POST("/limit-changes/:id/approve", middleware.RequireAuth(), handlers.ApproveLimitChange(riskManager))
+			risk.POST("/limit-changes/:id/reject", middleware.RequireAuth(), handlers.RejectLimitChange(riskManager))
 			risk.GET("/position-limits/:symbol", handlers.GetPositionLimit(riskManager, dbService))
-			risk.PUT("/position-limits/:symbol", middleware.OptionalAuth(), handlers.UpdatePositionLimit(riskManager, dbService))
+			risk.PUT("/position-limits/:symbol", middleware.RequireAuth(), handlers.UpdatePositionLimit(riskManager))
+			risk.POST("/simulate", middleware.OptionalAuth(), handlers.SimulateRisk(riskSimulator))
+			risk.GET("/var", handlers.GetVaR(riskAnalytics))
+			risk.GET("/stress", handlers.GetStressResults(riskAnalytics))
+			risk.POST("/stress", middleware.OptionalAuth(), handlers.RunStressScenario(riskAnalytics))
+			risk.GET("/stress/scenarios", handlers.GetStressScenarios(riskAnalytics))
+			risk.POST("/stress/scenarios", middleware.OptionalAuth(), handlers.SaveStressScenario(dbService))
+			risk.DELETE("/stress/scenarios/:id", middleware.OptionalAuth(), handlers.DeleteStressScenario(dbService))
+			risk.GET("/throttle-limits", handlers.GetThrottleLimits(riskManager))
+			risk.PUT("/throttle-limits", middleware.RequireAuth(), handlers.UpdateThrottleLimit(riskManager))
+			risk.GET("/exposure", handlers.GetOpenOrderExposure(orderLedger))
+			risk.GET("/trading-activity", handlers.GetTradingActivity(riskManager))
+			risk.GET("/sessions", handlers.GetSessionSummaries(sessionRollover))
+			risk.GET("/sessions/:day", handlers.GetSession(sessionRollover))
+			risk.POST("/sessions/close", middleware.RequireAuth(), handlers.CloseSession(sessionRollover))
+			risk.GET("/restricted", handlers.GetRestrictedSymbols(riskManager))
+			risk.POST("/restricted", middleware.RequireAuth(), handlers.SaveRestrictedSymbol(riskManager))
+			risk.PUT("/restricted/:id", middleware.RequireAuth(), handlers.UpdateRestrictedSymbol(riskManager))
+			risk.DELETE("/restricted/:id", middleware.RequireAuth(), handlers.DeleteRestrictedSymbol(riskManager))
+			risk.GET("/tree", handlers.GetLimitTree(riskManager))
+			risk.GET("/utilisation", handlers.GetRiskUtilisation(riskSnapshot))
+			risk.GET("/marks", handlers.GetMarks(markToMarket))
+			risk.PUT("/marks/config", middleware.RequireAuth(), handlers.UpdateMarkConfig(markToMarket))
+			risk.POST("/nodes", middleware.RequireAuth(), handlers.SaveLimitNode(riskManager))
+			risk.PUT("/nodes/:id", middleware.RequireAuth(), handlers.SaveLimitNode(riskManager))
+			risk.DELETE("/nodes/:id", middleware.RequireAuth(), handlers.DeleteLimitNode(riskManager))
 		}
 	}
 
@@ -185,3 +316,10 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hft/backend/services"
+)
+
+// RequestLatency records how long every API request takes
+func RequestLatency(recorder *services.LatencyRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timer := recorder.StartTimer()
+		c.Next()
+		timer.Total(services.LatencyStageHTTP)
+	}
+}
+
+// OrderLatency starts timing the hops of an order. Must run first on the
+// order route; the end-to-end latency is recorded for accepted orders only.
+func OrderLatency(recorder *services.LatencyRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timer := recorder.StartTimer()
+		c.Set("order_timer", timer)
+
+		c.Next()
+
+		if c.Writer.Status() < 400 {
+			timer.Total(services.LatencyStageOrder)
+		}
+	}
+}
+
+// OrderTimer returns the order's latency timer, or nil when the route does
+// not time orders
+func OrderTimer(c *gin.Context) *services.LatencyTimer {
+	if timer, exists := c.Get("order_timer"); exists {
+		return timer.(*services.LatencyTimer)
+	}
+	return nil
+}
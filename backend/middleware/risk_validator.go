@@ -1,8 +1,9 @@
 package middleware
 
 import (
+	"fmt"
 	"log"
-	
+
 	"github.com/gin-gonic/gin"
 	"github.com/hft/backend/models"
 	"github.com/hft/backend/services"
@@ -23,6 +24,12 @@ func RiskValidation(riskManager *services.RiskManager, positionTracker *services
 			c.Abort()
 			return
 		}
+		OrderTimer(c).Lap(services.LatencyStageAPIReceive)
+
+		// The risk checks reserve the order under its client order ID
+		if req.ClientOrderID == "" {
+			req.ClientOrderID = services.NewClientOrderID()
+		}
 
 		// Get current positions (including pending orders)
 		effectivePos, err := positionTracker.GetEffectivePosition(req.Symbol)
@@ -32,7 +39,7 @@ func RiskValidation(riskManager *services.RiskManager, positionTracker *services
 		}
 
 		// Run all risk checks
-		result := riskManager.ValidateOrder(&req, effectivePos)
+		result := riskManager.ValidateOrder(&req, effectivePos, ThrottleIdentity(c, req.Strategy, req.Symbol))
 
 		if !result.Allowed {
 			// Send alert via WebSocket
@@ -60,6 +67,86 @@ func RiskValidation(riskManager *services.RiskManager, positionTracker *services
 		c.Set("effective_position", effectivePos)
 
 		c.Next()
+
+		// Release the reservation if a later step rejected the order
+		if c.Writer.Status() >= 400 {
+			riskManager.ReleaseOrder(req.ClientOrderID, "REJECTED")
+		}
+	}
+}
+
+// SelfTradePrevention checks a validated order against our own resting orders.
+// Must run after RiskValidation.
+func SelfTradePrevention(guard *services.SelfTradeGuard, riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		validated, exists := c.Get("validated_order")
+		if !exists {
+			c.Next()
+			return
+		}
+		req := validated.(*models.OrderRequest)
+
+		result := guard.Prevent(req)
+		if len(result.Conflicts) > 0 {
+			severity := "INFO"
+			if !result.Allowed {
+				severity = "WARNING"
+			}
+			riskManager.SendAlert("SELF_TRADE", severity, req.Symbol,
+				fmt.Sprintf("Self-trade detected for %s %s (action: %s)", req.Side, req.Symbol, result.Action),
+				map[string]interface{}{
+					"client_order_id": req.ClientOrderID,
+					"conflicts":       result.Conflicts,
+					"canceled_orders": result.CanceledOrders,
+					"allowed":         result.Allowed,
+				})
+		}
+
+		if !result.Allowed {
+			c.JSON(403, gin.H{
+				"error":           "Order rejected by risk management",
+				"reason":          result.Reason,
+				"alerts":          []string{"Self-trade prevented"},
+				"conflicts":       result.Conflicts,
+				"canceled_orders": result.CanceledOrders,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
 	}
 }
 
+// CancelThrottle applies the cancel rate limits before an order is cancelled
+func CancelThrottle(riskManager *services.RiskManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := riskManager.CheckOrderThrottle(models.ThrottleActionCancel, ThrottleIdentity(c, "", "")); err != nil {
+			c.JSON(429, gin.H{
+				"error":  "Cancel rejected by risk management",
+				"reason": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ThrottleIdentity builds the throttle identity for a request from the
+// authenticated user (set by OptionalAuth) and the X-API-Key header.
+// Unauthenticated requests share the "anonymous" user bucket.
+func ThrottleIdentity(c *gin.Context, strategy, symbol string) models.ThrottleIdentity {
+	userID := "anonymous"
+	if id, exists := c.Get("user_id"); exists && id != nil {
+		userID = fmt.Sprintf("%v", id)
+	}
+
+	return models.ThrottleIdentity{
+		UserID:   userID,
+		APIKey:   c.GetHeader("X-API-Key"),
+		Strategy: strategy,
+		Symbol:   symbol,
+	}
+}
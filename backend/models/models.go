@@ -17,6 +17,13 @@ type Order struct {
 	Status          string    `json:"status"`     // NEW, PARTIALLY_FILLED, FILLED, REJECTED, CANCELED
 	FilledQty       float64   `json:"filled_qty"`
 	RemainingQty    float64   `json:"remaining_qty"`
+	Strategy        string    `json:"strategy" gorm:"index"`
+	Trader          string    `json:"trader" gorm:"index"` // Submitting user
+	Account         string    `json:"account" gorm:"index"`
+	Tags            []string  `json:"tags" gorm:"serializer:json;type:jsonb"`
+	ArrivalPrice    float64   `json:"arrival_price"` // Mid when received, or last trade without a two-sided quote
+	ArrivalBid      float64   `json:"arrival_bid"`
+	ArrivalAsk      float64   `json:"arrival_ask"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
@@ -30,6 +37,10 @@ type Execution struct {
 	Side            string    `json:"side"`
 	FillPrice       float64   `json:"fill_price"`
 	FillQty         float64   `json:"fill_qty"`
+	Strategy        string    `json:"strategy" gorm:"index"` // Copied from the order
+	Trader          string    `json:"trader" gorm:"index"`
+	Account         string    `json:"account" gorm:"index"`
+	Tags            []string  `json:"tags" gorm:"serializer:json;type:jsonb"`
 	Timestamp       time.Time `json:"timestamp"`
 	CreatedAt       time.Time `json:"created_at"`
 }
@@ -39,19 +50,604 @@ type Position struct {
 	Symbol         string    `json:"symbol"`
 	Quantity       float64   `json:"quantity"`
 	AvgPrice       float64   `json:"avg_price"`
+	MarketPrice    float64   `json:"market_price"`
+	MarketValue    float64   `json:"market_value"`
 	UnrealizedPnL  float64   `json:"unrealized_pnl"`
 	RealizedPnL    float64   `json:"realized_pnl"`
 	Timestamp      time.Time `json:"timestamp"`
 }
 
+// Marking rules for unrealised P&L
+const (
+	MarkRuleLast = "LAST"
+	MarkRuleMid  = "MID"
+	MarkRuleSide = "SIDE" // Bid for longs, ask for shorts
+)
+
+// Quote is the latest market data for a symbol from the market data cache
+type Quote struct {
+	Symbol    string    `json:"symbol"`
+	Bid       float64   `json:"bid"`
+	Ask       float64   `json:"ask"`
+	Last      float64   `json:"last"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PriceMark is the price a symbol is marked at and how it was derived
+type PriceMark struct {
+	Symbol     string    `json:"symbol"`
+	Price      float64   `json:"price"`
+	Rule       string    `json:"rule"` // Rule applied, after falling back for missing prices
+	Quote      Quote     `json:"quote"`
+	Stale      bool      `json:"stale"`
+	AgeSeconds float64   `json:"age_seconds"`
+	MarkedAt   time.Time `json:"marked_at"`
+}
+
+// MarkConfig controls how positions are marked to market
+type MarkConfig struct {
+	Rule              string `json:"rule"`
+	StaleAfterSeconds int    `json:"stale_after_seconds"`
+}
+
+// MarkToMarketSnapshot is the current marking of every open position
+type MarkToMarketSnapshot struct {
+	Config        MarkConfig  `json:"config"`
+	UnrealizedPnL float64     `json:"unrealized_pnl"`
+	Positions     []Position  `json:"positions"`
+	Marks         []PriceMark `json:"marks"`
+	StaleSymbols  []string    `json:"stale_symbols"`
+	Timestamp     time.Time   `json:"timestamp"`
+}
+
+// PositionBreak is a symbol whose booked quantity differs from the broker's
+type PositionBreak struct {
+	Symbol         string    `json:"symbol"`
+	BookQuantity   float64   `json:"book_quantity"`
+	BrokerQuantity float64   `json:"broker_quantity"`
+	Difference     float64   `json:"difference"` // Broker minus book
+	DetectedAt     time.Time `json:"detected_at"`
+}
+
+// PositionReconciliation is the result of comparing the position book with
+// the broker
+type PositionReconciliation struct {
+	Symbols   int             `json:"symbols"`
+	Breaks    []PositionBreak `json:"breaks"`
+	Error     string          `json:"error,omitempty"`
+	CheckedAt time.Time       `json:"checked_at"`
+}
+
+// Cost-basis methods for matching closing fills to open lots
+const (
+	CostMethodFIFO    = "FIFO"
+	CostMethodLIFO    = "LIFO"
+	CostMethodAverage = "AVERAGE"
+)
+
+// ExecutionPnL is the realised P&L of one execution under the ledger's
+// cost-basis method
+type ExecutionPnL struct {
+	ExecutionID   uint      `json:"execution_id" gorm:"primaryKey;autoIncrement:false"`
+	Symbol        string    `json:"symbol" gorm:"index"`
+	Side          string    `json:"side"`
+	FillQty       float64   `json:"fill_qty"`
+	FillPrice     float64   `json:"fill_price"`
+	ClosedQty     float64   `json:"closed_qty"` // Quantity that closed open lots
+	CostBasis     float64   `json:"cost_basis"` // Average price of the closed lots
+	RealizedPnL   float64   `json:"realized_pnl" gorm:"column:realized_pnl"`
+	Fees          float64   `json:"fees"`                          // Commission and regulatory fees of the fill
+	NetPnL        float64   `json:"net_pnl" gorm:"column:net_pnl"` // Realised P&L less fees
+	PositionAfter float64   `json:"position_after"`
+	Method        string    `json:"method"`
+	Timestamp     time.Time `json:"timestamp" gorm:"index"`
+}
+
+// TableName keeps the table name readable
+func (ExecutionPnL) TableName() string {
+	return "execution_pnl"
+}
+
+// CommissionTier is the per-share commission charged while month-to-date
+// share volume is below UpToShares; zero means no upper bound
+type CommissionTier struct {
+	UpToShares float64 `json:"up_to_shares"`
+	PerShare   float64 `json:"per_share"`
+}
+
+// FeeSchedule is one immutable version of an account's commission and
+// regulatory fee rates. The latest version effective at a fill's time
+// prices it.
+type FeeSchedule struct {
+	ID                 uint             `json:"id" gorm:"primaryKey"`
+	Account            string           `json:"account" gorm:"index"`
+	Version            int              `json:"version"` // Sequential per account
+	EffectiveFrom      time.Time        `json:"effective_from" gorm:"index"`
+	CommissionPerShare float64          `json:"commission_per_share"`
+	CommissionBps      float64          `json:"commission_bps"` // Basis points of notional
+	CommissionTiers    []CommissionTier `json:"commission_tiers" gorm:"serializer:json;type:jsonb"`
+	MinimumCommission  float64          `json:"minimum_commission"` // Per fill
+	SECFeeRate         float64          `json:"sec_fee_rate"`       // Section 31 fee per dollar sold
+	TAFPerShare        float64          `json:"taf_per_share"`      // FINRA Trading Activity Fee per share sold
+	TAFMaximum         float64          `json:"taf_maximum"`        // TAF cap per fill
+	Author             string           `json:"author"`
+	Reason             string           `json:"reason"`
+	CreatedAt          time.Time        `json:"created_at"`
+}
+
+// ExecutionFee is the breakdown of the fees charged on one execution
+type ExecutionFee struct {
+	ExecutionID       uint      `json:"execution_id" gorm:"primaryKey;autoIncrement:false"`
+	Account           string    `json:"account" gorm:"index"`
+	Symbol            string    `json:"symbol"`
+	Side              string    `json:"side"`
+	FeeScheduleID     uint      `json:"fee_schedule_id"` // Zero when no schedule applied
+	Commission        float64   `json:"commission"`
+	SECFee            float64   `json:"sec_fee"`
+	TAFFee            float64   `json:"taf_fee"`
+	TotalFees         float64   `json:"total_fees"`
+	MonthToDateShares float64   `json:"month_to_date_shares"` // Volume before this fill, used for tiers
+	Timestamp         time.Time `json:"timestamp" gorm:"index"`
+}
+
+// Corporate action types and statuses
+const (
+	CorporateActionSplit        = "SPLIT"
+	CorporateActionReverseSplit = "REVERSE_SPLIT"
+	CorporateActionDividend     = "CASH_DIVIDEND"
+	CorporateActionSymbolChange = "SYMBOL_CHANGE"
+
+	CorporateActionPending = "PENDING"
+	CorporateActionApplied = "APPLIED"
+)
+
+// CorporateAction is a split, reverse split, cash dividend or symbol change
+// taking effect at the start of its ex-date. Splits turn OldShares into
+// NewShares.
+type CorporateAction struct {
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	Type                string     `json:"type" gorm:"uniqueIndex:idx_corporate_action"`
+	Symbol              string     `json:"symbol" gorm:"uniqueIndex:idx_corporate_action"`
+	ExDate              string     `json:"ex_date" gorm:"uniqueIndex:idx_corporate_action"` // YYYY-MM-DD
+	OldShares           float64    `json:"old_shares,omitempty"`
+	NewShares           float64    `json:"new_shares,omitempty"`
+	CashAmount          float64    `json:"cash_amount,omitempty"` // Dividend per share
+	NewSymbol           string     `json:"new_symbol,omitempty"`
+	Source              string     `json:"source"` // FILE, API
+	Status              string     `json:"status"`
+	AppliedAt           *time.Time `json:"applied_at,omitempty"`
+	AppliedBy           string     `json:"applied_by,omitempty"`
+	PositionLimitBefore float64    `json:"position_limit_before,omitempty"`
+	PositionLimitAfter  float64    `json:"position_limit_after,omitempty"`
+	OrdersAdjusted      int        `json:"orders_adjusted"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// Ratio returns the shares held after a split per share held before, or 1
+// for actions that don't change share counts
+func (a *CorporateAction) Ratio() float64 {
+	if (a.Type != CorporateActionSplit && a.Type != CorporateActionReverseSplit) || a.OldShares <= 0 || a.NewShares <= 0 {
+		return 1
+	}
+	return a.NewShares / a.OldShares
+}
+
+// CorporateActionAdjustment is the effect of an applied corporate action on
+// one symbol's booked position
+type CorporateActionAdjustment struct {
+	ActionID       uint      `json:"action_id" gorm:"primaryKey;autoIncrement:false"`
+	Symbol         string    `json:"symbol" gorm:"primaryKey"`
+	QuantityBefore float64   `json:"quantity_before"`
+	QuantityAfter  float64   `json:"quantity_after"`
+	AvgCostBefore  float64   `json:"avg_cost_before"`
+	AvgCostAfter   float64   `json:"avg_cost_after"`
+	CashAmount     float64   `json:"cash_amount"` // Dividend received, negative when short
+	EffectiveAt    time.Time `json:"effective_at" gorm:"index"`
+}
+
+// OpenLot is an open position lot; negative quantities are short
+type OpenLot struct {
+	Symbol      string    `json:"symbol"`
+	Quantity    float64   `json:"quantity"`
+	Price       float64   `json:"price"`
+	ExecutionID uint      `json:"execution_id"`
+	OpenedAt    time.Time `json:"opened_at"`
+}
+
+// SymbolPnL summarises realised P&L and open lots of one symbol
+type SymbolPnL struct {
+	Symbol        string    `json:"symbol"`
+	Position      float64   `json:"position"`
+	AvgCost       float64   `json:"avg_cost"`
+	LastPrice     float64   `json:"last_price"` // Price of the latest fill
+	RealizedPnL   float64   `json:"realized_pnl"`
+	Fees          float64   `json:"fees"`
+	ClosingTrades int       `json:"closing_trades"`
+	Lots          []OpenLot `json:"lots"`
+}
+
+// P&L attribution dimensions and time buckets
+const (
+	AttributeByStrategy = "strategy"
+	AttributeByTrader   = "trader"
+	AttributeBySymbol   = "symbol"
+	AttributeByTag      = "tag"
+
+	AttributionHourly = "hour"
+	AttributionDaily  = "day"
+	AttributionWeekly = "week"
+)
+
+// PnLAttributionQuery selects executions and open lots and how their P&L is
+// grouped. Empty filters match everything.
+type PnLAttributionQuery struct {
+	GroupBy  []string   `json:"group_by"`
+	Interval string     `json:"interval,omitempty"` // Time bucket; empty for none
+	Strategy string     `json:"strategy,omitempty"`
+	Trader   string     `json:"trader,omitempty"`
+	Symbol   string     `json:"symbol,omitempty"`
+	Tag      string     `json:"tag,omitempty"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+}
+
+// PnLAttributionRow is the P&L of one group. Only the grouped dimensions are
+// set.
+type PnLAttributionRow struct {
+	Strategy      string  `json:"strategy,omitempty"`
+	Trader        string  `json:"trader,omitempty"`
+	Symbol        string  `json:"symbol,omitempty"`
+	Tag           string  `json:"tag,omitempty"`
+	Bucket        string  `json:"bucket,omitempty"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	Fees          float64 `json:"fees"`
+	TotalPnL      float64 `json:"total_pnl"` // Net of fees
+	Fills         int     `json:"fills"`
+	Volume        float64 `json:"volume"` // Filled notional
+}
+
+// PnLAttributionReport breaks P&L down by the query's dimensions. Totals
+// count each execution once even when grouping by tag puts it in several
+// rows.
+type PnLAttributionReport struct {
+	Query         PnLAttributionQuery `json:"query"`
+	Rows          []PnLAttributionRow `json:"rows"`
+	RealizedPnL   float64             `json:"realized_pnl"`
+	UnrealizedPnL float64             `json:"unrealized_pnl"`
+	Fees          float64             `json:"fees"`
+	TotalPnL      float64             `json:"total_pnl"`
+	Timestamp     time.Time           `json:"timestamp"`
+}
+
+// RoundTrip is a position in one symbol from the fill that opened it to the
+// fill that closed it. A fill that flips the position closes one round trip
+// and opens the next; its fees are split between them by quantity.
+type RoundTrip struct {
+	Symbol         string    `json:"symbol"`
+	Strategy       string    `json:"strategy"` // Strategy of the opening fill
+	Side           string    `json:"side"`     // LONG or SHORT
+	Quantity       float64   `json:"quantity"` // Largest position held
+	EntryPrice     float64   `json:"entry_price"`
+	ExitPrice      float64   `json:"exit_price"`
+	EntryTime      time.Time `json:"entry_time"`
+	ExitTime       time.Time `json:"exit_time"`
+	HoldingSeconds float64   `json:"holding_seconds"`
+	Executions     int       `json:"executions"`
+	GrossPnL       float64   `json:"gross_pnl"`
+	Fees           float64   `json:"fees"`
+	NetPnL         float64   `json:"net_pnl"`
+}
+
+// PerformanceStats summarises closed round trips. A trade wins or loses on
+// its P&L net of fees. Sharpe and Sortino are annualised from daily P&L over
+// every trading day of the report, which gives the same ratios as returns
+// on a fixed capital base.
+type PerformanceStats struct {
+	Trades            int     `json:"trades"`
+	Wins              int     `json:"wins"`
+	Losses            int     `json:"losses"`
+	WinRate           float64 `json:"win_rate"` // Percent
+	AvgWin            float64 `json:"avg_win"`
+	AvgLoss           float64 `json:"avg_loss"` // Negative
+	LargestWin        float64 `json:"largest_win"`
+	LargestLoss       float64 `json:"largest_loss"`
+	ProfitFactor      float64 `json:"profit_factor"` // Zero without losing trades
+	Expectancy        float64 `json:"expectancy"`    // Net P&L per trade
+	GrossPnL          float64 `json:"gross_pnl"`
+	Fees              float64 `json:"fees"`
+	NetPnL            float64 `json:"net_pnl"`
+	AvgHoldingSeconds float64 `json:"avg_holding_seconds"`
+	SharpeRatio       float64 `json:"sharpe_ratio"`
+	SortinoRatio      float64 `json:"sortino_ratio"`
+	MaxDrawdown       float64 `json:"max_drawdown"` // Largest fall in cumulative net P&L
+	MaxWinStreak      int     `json:"max_win_streak"`
+	MaxLossStreak     int     `json:"max_loss_streak"`
+	CurrentStreak     int     `json:"current_streak"` // Positive for wins, negative for losses
+}
+
+// PerformanceReport holds round-trip statistics overall, per symbol and per
+// strategy for the trades closed between From and To
+type PerformanceReport struct {
+	From        *time.Time                  `json:"from,omitempty"`
+	To          *time.Time                  `json:"to,omitempty"`
+	Overall     PerformanceStats            `json:"overall"`
+	BySymbol    map[string]PerformanceStats `json:"by_symbol"`
+	ByStrategy  map[string]PerformanceStats `json:"by_strategy"`
+	TradingDays int                         `json:"trading_days"`
+	OpenTrades  int                         `json:"open_trades"`
+	Trades      []RoundTrip                 `json:"trades,omitempty"`
+	Timestamp   time.Time                   `json:"timestamp"`
+}
+
+// Equity curve bucket widths
+const (
+	EquityBucketMinute     = "1m"
+	EquityBucketFiveMinute = "5m"
+	EquityBucketHour       = "1h"
+	EquityBucketDay        = "1d"
+)
+
+// EquitySample is account equity and P&L at one point in time. Realised P&L
+// and fees are cumulative since the first execution; realised P&L is net of
+// fees.
+type EquitySample struct {
+	Timestamp     time.Time `json:"timestamp" gorm:"primaryKey"`
+	Equity        float64   `json:"equity" gorm:"type:decimal(20,8)"`
+	Estimated     bool      `json:"estimated"` // Equity carried forward by P&L while the engine was unreachable
+	MarketValue   float64   `json:"market_value" gorm:"type:decimal(20,8)"`
+	RealizedPnL   float64   `json:"realized_pnl" gorm:"column:realized_pnl;type:decimal(20,8)"`
+	UnrealizedPnL float64   `json:"unrealized_pnl" gorm:"column:unrealized_pnl;type:decimal(20,8)"`
+	TotalPnL      float64   `json:"total_pnl" gorm:"column:total_pnl;type:decimal(20,8)"`
+	DailyPnL      float64   `json:"daily_pnl" gorm:"column:daily_pnl;type:decimal(20,8)"`
+	Fees          float64   `json:"fees" gorm:"type:decimal(20,8)"`
+}
+
+// EquityPoint is one bucket of the equity curve: equity as open, high, low
+// and close, and the P&L at the end of the bucket
+type EquityPoint struct {
+	Time          time.Time `json:"time"`
+	EquityOpen    float64   `json:"equity_open"`
+	EquityHigh    float64   `json:"equity_high"`
+	EquityLow     float64   `json:"equity_low"`
+	Equity        float64   `json:"equity"`
+	MarketValue   float64   `json:"market_value"`
+	RealizedPnL   float64   `json:"realized_pnl" gorm:"column:realized_pnl"`
+	UnrealizedPnL float64   `json:"unrealized_pnl" gorm:"column:unrealized_pnl"`
+	TotalPnL      float64   `json:"total_pnl" gorm:"column:total_pnl"`
+	DailyPnL      float64   `json:"daily_pnl" gorm:"column:daily_pnl"`
+	Fees          float64   `json:"fees"`
+	Samples       int       `json:"samples"`
+}
+
+// EquitySeries is the equity curve between From and To. Downsampled is set
+// when buckets were dropped to fit the requested number of points.
+type EquitySeries struct {
+	Bucket      string        `json:"bucket"`
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	Points      []EquityPoint `json:"points"`
+	Downsampled bool          `json:"downsampled"`
+}
+
+// TCAConfig controls transaction cost alerts. A fill slipping more than
+// SlippageAlertBps against the arrival price raises an alert; zero disables
+// them.
+type TCAConfig struct {
+	SlippageAlertBps float64 `json:"slippage_alert_bps"`
+}
+
+// TCAOrder is the transaction cost of one order against its arrival price.
+// Costs are positive when they lose money. Spread paid is measured from the
+// arrival mid and needs a two-sided quote; opportunity cost marks the
+// unfilled quantity at the latest price.
+type TCAOrder struct {
+	OrderID                 string    `json:"order_id"`
+	ClientOrderID           string    `json:"client_order_id"`
+	Symbol                  string    `json:"symbol"`
+	Side                    string    `json:"side"`
+	OrderType               string    `json:"order_type"`
+	Strategy                string    `json:"strategy"`
+	Quantity                float64   `json:"quantity"`
+	FilledQty               float64   `json:"filled_qty"`
+	Fills                   int       `json:"fills"`
+	ArrivalPrice            float64   `json:"arrival_price"`
+	AvgFillPrice            float64   `json:"avg_fill_price"`
+	SlippageCost            float64   `json:"slippage_cost"`
+	SlippageBps             float64   `json:"slippage_bps"`
+	SpreadPaid              float64   `json:"spread_paid"`
+	EffectiveSpreadBps      float64   `json:"effective_spread_bps"`
+	OpportunityCost         float64   `json:"opportunity_cost"`
+	Fees                    float64   `json:"fees"`
+	ImplementationShortfall float64   `json:"implementation_shortfall"`
+	ShortfallBps            float64   `json:"shortfall_bps"` // Of the arrival value of the whole order
+	CreatedAt               time.Time `json:"created_at"`
+}
+
+// TCASummary adds up the transaction costs of a group of orders; bps are
+// weighted by arrival value
+type TCASummary struct {
+	Key                     string  `json:"key,omitempty"`
+	Orders                  int     `json:"orders"`
+	Fills                   int     `json:"fills"`
+	FilledQty               float64 `json:"filled_qty"`
+	Notional                float64 `json:"notional"`
+	SlippageCost            float64 `json:"slippage_cost"`
+	SlippageBps             float64 `json:"slippage_bps"`
+	SpreadPaid              float64 `json:"spread_paid"`
+	EffectiveSpreadBps      float64 `json:"effective_spread_bps"`
+	OpportunityCost         float64 `json:"opportunity_cost"`
+	Fees                    float64 `json:"fees"`
+	ImplementationShortfall float64 `json:"implementation_shortfall"`
+	ShortfallBps            float64 `json:"shortfall_bps"`
+}
+
+// TCAReport is transaction cost analysis per order and summed overall, per
+// symbol, per strategy and per order type
+type TCAReport struct {
+	Config         TCAConfig    `json:"config"`
+	Overall        TCASummary   `json:"overall"`
+	BySymbol       []TCASummary `json:"by_symbol"`
+	ByStrategy     []TCASummary `json:"by_strategy"`
+	ByOrderType    []TCASummary `json:"by_order_type"`
+	Orders         []TCAOrder   `json:"orders"`
+	UnpricedOrders int          `json:"unpriced_orders"` // Filled orders without an arrival price
+	Timestamp      time.Time    `json:"timestamp"`
+}
+
+// LatencyStats summarises the latencies recorded for one hop of the order
+// path over a rolling window
+type LatencyStats struct {
+	Count  int64   `json:"count"`
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	P999Ms float64 `json:"p999_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// DailyRealizedPnL is the realised P&L of the executions filled on one day
+type DailyRealizedPnL struct {
+	Date     string  `json:"date"`
+	PnL      float64 `json:"pnl"` // Net of fees
+	GrossPnL float64 `json:"gross_pnl"`
+	Fees     float64 `json:"fees"`
+	Count    int64   `json:"count"`
+}
+
+// RecordFilter selects orders, executions, risk alerts, daily P&L and movers
+// positions for the query and export APIs. Empty filters match everything;
+// a filter on a field a record does not have is ignored.
+type RecordFilter struct {
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	Symbol    string     `json:"symbol,omitempty"`
+	Side      string     `json:"side,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	Strategy  string     `json:"strategy,omitempty"`
+	Trader    string     `json:"trader,omitempty"`
+	Account   string     `json:"account,omitempty"`
+	Tag       string     `json:"tag,omitempty"`
+	AlertType string     `json:"alert_type,omitempty"`
+	Severity  string     `json:"severity,omitempty"`
+	Limit     int        `json:"limit,omitempty"` // Zero for no limit
+}
+
+// Export datasets
+const (
+	ExportOrders          = "orders"
+	ExportExecutions      = "executions"
+	ExportRiskAlerts      = "risk_alerts"
+	ExportDailyPnL        = "daily_pnl"
+	ExportMoversPositions = "movers_positions"
+)
+
+// Export formats
+const (
+	ExportCSV     = "csv"
+	ExportXLSX    = "xlsx"
+	ExportParquet = "parquet"
+)
+
+// Export column types
+const (
+	ExportString    = "string"
+	ExportInteger   = "integer"
+	ExportNumber    = "number"
+	ExportBoolean   = "boolean"
+	ExportTimestamp = "timestamp" // UTC
+)
+
+// ExportColumn is one column of a dataset's export schema. Columns are only
+// ever appended, so files from older exports keep their layout.
+type ExportColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// OpenOrderReservation represents the quantity and notional an open order reserves
+type OpenOrderReservation struct {
+	ClientOrderID string    `json:"client_order_id"`
+	OrderID       string    `json:"order_id"`
+	Account       string    `json:"account"`
+	Symbol        string    `json:"symbol"`
+	Side          string    `json:"side"`
+	Quantity      float64   `json:"quantity"`
+	FilledQty     float64   `json:"filled_qty"`
+	Price         float64   `json:"price"` // Limit price, or estimated price for market orders
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// RemainingQty returns the quantity still reserved by the order
+func (r *OpenOrderReservation) RemainingQty() float64 {
+	if r.FilledQty >= r.Quantity {
+		return 0
+	}
+	return r.Quantity - r.FilledQty
+}
+
+// Notional returns the notional still reserved by the order
+func (r *OpenOrderReservation) Notional() float64 {
+	return r.RemainingQty() * r.Price
+}
+
+// SymbolExposure represents open-order exposure for a symbol and account
+type SymbolExposure struct {
+	Account      string  `json:"account"`
+	Symbol       string  `json:"symbol"`
+	BuyQty       float64 `json:"buy_qty"`
+	SellQty      float64 `json:"sell_qty"`
+	BuyNotional  float64 `json:"buy_notional"`
+	SellNotional float64 `json:"sell_notional"`
+	OpenOrders   int     `json:"open_orders"`
+}
+
+// Trading sessions
+const (
+	SessionPreMarket  = "PRE_MARKET"
+	SessionRegular    = "REGULAR"
+	SessionPostMarket = "POST_MARKET"
+	SessionClosed     = "CLOSED"
+)
+
+// MarketHoliday is an exchange closure or early close that overrides the built-in calendar
+type MarketHoliday struct {
+	Date       string    `json:"date" gorm:"primaryKey;type:varchar(10)"` // YYYY-MM-DD
+	Name       string    `json:"name" binding:"required"`
+	EarlyClose string    `json:"early_close"` // HH:MM New York close time; empty means closed all day
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// MarketSession describes the trading session at a point in time (New York time)
+type MarketSession struct {
+	Session      string     `json:"session"`
+	TradingDay   string     `json:"trading_day"`
+	IsOpen       bool       `json:"is_open"` // Regular session
+	Holiday      string     `json:"holiday,omitempty"`
+	EarlyClose   bool       `json:"early_close"`
+	PreOpen      *time.Time `json:"pre_open,omitempty"`
+	RegularOpen  *time.Time `json:"regular_open,omitempty"`
+	RegularClose *time.Time `json:"regular_close,omitempty"`
+	PostClose    *time.Time `json:"post_close,omitempty"`
+	NextOpen     time.Time  `json:"next_open"`
+	Timestamp    time.Time  `json:"timestamp"`
+}
+
 // OrderRequest is the API request format
 type OrderRequest struct {
-	ClientOrderID string  `json:"client_order_id" binding:"required"`
-	Symbol        string  `json:"symbol" binding:"required"`
-	Side          string  `json:"side" binding:"required"`
-	Quantity      float64 `json:"quantity" binding:"required,gt=0"`
-	Price         float64 `json:"price"`  // Optional - required only for LIMIT orders
-	OrderType     string  `json:"order_type"`
+	ClientOrderID string   `json:"client_order_id" binding:"required"`
+	Symbol        string   `json:"symbol" binding:"required"`
+	Side          string   `json:"side" binding:"required"`
+	Quantity      float64  `json:"quantity" binding:"required,gt=0"`
+	Price         float64  `json:"price"` // Optional - required only for LIMIT orders
+	OrderType     string   `json:"order_type"`
+	Strategy      string   `json:"strategy"`       // Optional - originating strategy, used for scoped risk checks
+	Account       string   `json:"account"`        // Optional - defaults to the primary account
+	ExtendedHours bool     `json:"extended_hours"` // Optional - allow LIMIT orders in pre/post-market sessions
+	Tags          []string `json:"tags"`           // Optional - free-form labels for P&L attribution
 }
 
 // OrderResponse is the API response format
@@ -13,6 +13,22 @@ type RiskLimits struct {
 	MaxPortfolioConcentration float64   `json:"max_portfolio_concentration" gorm:"type:decimal(5,2)"`
 	MaxLeverage               float64   `json:"max_leverage" gorm:"type:decimal(5,2)"`
 	MaxOrdersPerSecond        int       `json:"max_orders_per_second"`
+	BreakerCooldownSeconds    int       `json:"breaker_cooldown_seconds" gorm:"default:300"`
+	RejectionRateThreshold    float64   `json:"rejection_rate_threshold" gorm:"type:decimal(5,2);default:50"`
+	EngineErrorBurst          int       `json:"engine_error_burst" gorm:"default:5"`
+	SymbolLossLimit           float64   `json:"symbol_loss_limit" gorm:"type:decimal(20,8);default:1000"`
+	MaxVaR                    float64   `json:"max_var" gorm:"column:max_var;type:decimal(20,8);default:0"` // 0 = not enforced
+	VaRConfidence             float64   `json:"var_confidence" gorm:"column:var_confidence;type:decimal(5,2);default:95"`
+	SelfTradeAction           string    `json:"self_trade_action" gorm:"default:REJECT_NEW"`
+	WashTradeWindowSeconds    int       `json:"wash_trade_window_seconds" gorm:"default:60"`
+	WashTradePriceTolerance   float64   `json:"wash_trade_price_tolerance" gorm:"type:decimal(5,2);default:0.10"` // Percent
+	PDTMaxDayTrades           int       `json:"pdt_max_day_trades" gorm:"column:pdt_max_day_trades;default:3"`
+	PDTEquityThreshold        float64   `json:"pdt_equity_threshold" gorm:"column:pdt_equity_threshold;type:decimal(20,8);default:25000"`
+	MaxTradesPerDay           int       `json:"max_trades_per_day" gorm:"default:0"`                    // 0 = not enforced
+	MaxDailyTurnover          float64   `json:"max_daily_turnover" gorm:"type:decimal(20,8);default:0"` // Gross notional; 0 = not enforced
+	EnforceTradingSession     bool      `json:"enforce_trading_session" gorm:"default:true"`
+	UtilisationWarningPct     float64   `json:"utilisation_warning_pct" gorm:"type:decimal(5,2);default:80"`  // 0 = no alert
+	UtilisationCriticalPct    float64   `json:"utilisation_critical_pct" gorm:"type:decimal(5,2);default:95"` // 0 = no alert
 	Enabled                   bool      `json:"enabled" gorm:"default:true"`
 	UpdatedAt                 time.Time `json:"updated_at"`
 	CreatedAt                 time.Time `json:"created_at"`
@@ -27,6 +43,283 @@ type PositionLimit struct {
 	CreatedAt           time.Time `json:"created_at"`
 }
 
+// Limit change request kinds
+const (
+	LimitChangeRiskLimits    = "RISK_LIMITS"
+	LimitChangePositionLimit = "POSITION_LIMIT"
+	LimitChangeLimitNode     = "LIMIT_NODE"
+	LimitChangeThrottleLimit = "THROTTLE_LIMIT"
+)
+
+// Limit change request statuses
+const (
+	LimitChangePending  = "PENDING"
+	LimitChangeApplied  = "APPLIED"
+	LimitChangeRejected = "REJECTED"
+	LimitChangeExpired  = "EXPIRED"
+)
+
+// LimitDiff is one field of a proposed limit change
+type LimitDiff struct {
+	Field    string      `json:"field"`
+	Current  interface{} `json:"current"`
+	Proposed interface{} `json:"proposed"`
+	Loosens  bool        `json:"loosens"`
+}
+
+// LimitChangeRequest is a proposed change to the risk limits, a symbol's
+// position limit, a limit node or a throttle limit awaiting approval by a
+// second user
+type LimitChangeRequest struct {
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	Kind        string      `json:"kind" gorm:"index"`
+	Symbol      string      `json:"symbol,omitempty"`    // POSITION_LIMIT only
+	Target      string      `json:"target,omitempty"`    // Node ID for LIMIT_NODE, scope|key|action for THROTTLE_LIMIT
+	Payload     string      `json:"-" gorm:"type:jsonb"` // The update as submitted
+	Diff        []LimitDiff `json:"diff" gorm:"serializer:json;type:jsonb"`
+	Status      string      `json:"status" gorm:"index"`
+	RequestedBy string      `json:"requested_by"`
+	Reason      string      `json:"reason,omitempty"`
+	Source      string      `json:"source"` // UPDATE or ROLLBACK
+	ReviewedBy  string      `json:"reviewed_by,omitempty"`
+	Comment     string      `json:"comment,omitempty"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+	ReviewedAt  *time.Time  `json:"reviewed_at,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// Limit version sources
+const (
+	LimitSourceInitial         = "INITIAL"          // First snapshot of existing limits
+	LimitSourceUpdate          = "UPDATE"           // Changed through the API
+	LimitSourceRollback        = "ROLLBACK"         // Restored from an earlier version
+	LimitSourceReload          = "RELOAD"           // Changed in the database outside the API
+	LimitSourceCorporateAction = "CORPORATE_ACTION" // Rescaled by a split or moved by a symbol change
+)
+
+// LimitChangeMeta describes who changed a limit and why
+type LimitChangeMeta struct {
+	Author     string
+	ApprovedBy string
+	Reason     string
+	Source     string
+}
+
+// RiskLimitVersion is an immutable snapshot of the risk limits, a symbol's
+// position limit or a limit node, taken every time they change
+type RiskLimitVersion struct {
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	Kind          string         `json:"kind" gorm:"index:idx_limit_version"` // RISK_LIMITS, POSITION_LIMIT or LIMIT_NODE
+	Symbol        string         `json:"symbol,omitempty" gorm:"index:idx_limit_version"`
+	Target        string         `json:"target,omitempty" gorm:"index:idx_limit_version"` // Node ID for LIMIT_NODE
+	Version       int            `json:"version"`                                         // Sequential per kind, symbol and target
+	RiskLimits    *RiskLimits    `json:"risk_limits,omitempty" gorm:"serializer:json;type:jsonb"`
+	PositionLimit *PositionLimit `json:"position_limit,omitempty" gorm:"serializer:json;type:jsonb"`
+	LimitNode     *LimitNode     `json:"limit_node,omitempty" gorm:"serializer:json;type:jsonb"` // Nil once the node is deleted
+	Author        string         `json:"author"`
+	ApprovedBy    string         `json:"approved_by,omitempty"`
+	Reason        string         `json:"reason,omitempty"`
+	Source        string         `json:"source"`
+	CreatedAt     time.Time      `json:"created_at" gorm:"index"` // In force from this time
+}
+
+// LimitsAsOf is the set of limits in force at a point in time
+type LimitsAsOf struct {
+	AsOf           time.Time         `json:"as_of"`
+	Limits         *RiskLimits       `json:"limits"`
+	Version        *RiskLimitVersion `json:"version"`
+	PositionLimits []PositionLimit   `json:"position_limits"`
+}
+
+// LimitRollback is the body of a rollback call
+type LimitRollback struct {
+	Reason string `json:"reason"`
+}
+
+// LimitChangeReview is the body of an approve or reject call
+type LimitChangeReview struct {
+	Comment string `json:"comment"`
+}
+
+// Limit hierarchy levels, from the root down
+const (
+	LimitLevelFirm     = "FIRM"
+	LimitLevelDesk     = "DESK"
+	LimitLevelTrader   = "TRADER"
+	LimitLevelStrategy = "STRATEGY"
+)
+
+// LimitNode is one node of the firm -> desk -> trader -> strategy limit
+// hierarchy. Orders are attributed to the strategy node matching their
+// strategy, else the trader node matching the submitting user, else the
+// firm, and must pass the limits of that node and every ancestor.
+// Zero limits are not enforced.
+type LimitNode struct {
+	ID                 uint      `json:"id" gorm:"primaryKey"`
+	Name               string    `json:"name"`
+	Level              string    `json:"level" gorm:"uniqueIndex:idx_limit_node_key"`
+	Key                string    `json:"key" gorm:"uniqueIndex:idx_limit_node_key"` // User ID for TRADER, strategy name for STRATEGY
+	ParentID           *uint     `json:"parent_id" gorm:"index"`
+	MaxPosition        float64   `json:"max_position" gorm:"type:decimal(20,8)"`   // Per symbol, shares
+	MaxOrderSize       float64   `json:"max_order_size" gorm:"type:decimal(20,8)"` // Notional
+	DailyLossLimit     float64   `json:"daily_loss_limit" gorm:"type:decimal(20,8)"`
+	MaxOrdersPerMinute int       `json:"max_orders_per_minute"`
+	Enabled            bool      `json:"enabled" gorm:"default:true"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// LimitNodeRequest represents a request to create or update a limit node
+type LimitNodeRequest struct {
+	Name               string  `json:"name" binding:"required"`
+	Level              string  `json:"level" binding:"required,oneof=FIRM DESK TRADER STRATEGY"`
+	Key                string  `json:"key"`
+	ParentID           *uint   `json:"parent_id"`
+	MaxPosition        float64 `json:"max_position" binding:"gte=0"`
+	MaxOrderSize       float64 `json:"max_order_size" binding:"gte=0"`
+	DailyLossLimit     float64 `json:"daily_loss_limit" binding:"gte=0"`
+	MaxOrdersPerMinute int     `json:"max_orders_per_minute" binding:"gte=0"`
+	Enabled            *bool   `json:"enabled"`
+	Reason             string  `json:"reason,omitempty"`
+}
+
+// LimitTreeNode is a limit node with P&L, exposure and utilisation rolled up
+// from its subtree
+type LimitTreeNode struct {
+	LimitNode
+	Positions        map[string]float64          `json:"positions"`
+	GrossExposure    float64                     `json:"gross_exposure"`
+	DailyPnL         float64                     `json:"daily_pnl"`
+	OrdersLastMinute int                         `json:"orders_last_minute"`
+	Utilisation      map[string]LimitUtilisation `json:"utilisation"`
+	Children         []*LimitTreeNode            `json:"children"`
+}
+
+// TradingActivity summarises day trades, trade count and turnover used by
+// the pattern-day-trader and turnover limits
+type TradingActivity struct {
+	DayTrades        int            `json:"day_trades"` // Over the rolling window
+	DayTradesByDate  map[string]int `json:"day_trades_by_date"`
+	WindowStart      string         `json:"window_start"`
+	DayTradeLimit    int            `json:"day_trade_limit"`
+	Equity           float64        `json:"equity"`
+	EquityKnown      bool           `json:"equity_known"`
+	PDTRestricted    bool           `json:"pdt_restricted"` // True when the next day trade would be blocked
+	TradesToday      int            `json:"trades_today"`
+	MaxTradesPerDay  int            `json:"max_trades_per_day"`
+	TurnoverToday    float64        `json:"turnover_today"`
+	MaxDailyTurnover float64        `json:"max_daily_turnover"`
+	Timestamp        time.Time      `json:"timestamp"`
+}
+
+// Restricted list types
+const (
+	RestrictedListRestricted   = "RESTRICTED"     // No trading at all
+	RestrictedListCloseOnly    = "CLOSE_ONLY"     // Only orders that reduce the position
+	RestrictedListHardToBorrow = "HARD_TO_BORROW" // No new or increased shorts
+)
+
+// RestrictedSymbol is a compliance-maintained entry on a restricted list
+type RestrictedSymbol struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	Symbol    string     `json:"symbol" gorm:"uniqueIndex:idx_restricted_symbol"`
+	ListType  string     `json:"list_type" gorm:"uniqueIndex:idx_restricted_symbol"`
+	Reason    string     `json:"reason"`
+	Author    string     `json:"author"`
+	ExpiresAt *time.Time `json:"expires_at"` // Nil never expires
+	UpdatedAt time.Time  `json:"updated_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Active reports whether the entry applies at t
+func (r *RestrictedSymbol) Active(t time.Time) bool {
+	return r.ExpiresAt == nil || t.Before(*r.ExpiresAt)
+}
+
+// RestrictedSymbolRequest represents a request to add or update a restricted list entry
+type RestrictedSymbolRequest struct {
+	Symbol    string     `json:"symbol" binding:"required"`
+	ListType  string     `json:"list_type" binding:"required,oneof=RESTRICTED CLOSE_ONLY HARD_TO_BORROW"`
+	Reason    string     `json:"reason" binding:"required"`
+	Author    string     `json:"-"` // Set from the authenticated user
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Self-trade prevention actions
+const (
+	SelfTradeActionNone          = "NONE"
+	SelfTradeActionRejectNew     = "REJECT_NEW"
+	SelfTradeActionCancelResting = "CANCEL_RESTING"
+	SelfTradeActionCancelBoth    = "CANCEL_BOTH"
+)
+
+// RestingOrder is one of our own open orders as reported by the engine
+type RestingOrder struct {
+	OrderID       string  `json:"order_id"`
+	ClientOrderID string  `json:"client_order_id"`
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`
+	OrderType     string  `json:"order_type"`
+	Price         float64 `json:"price"` // 0 for market orders
+	Quantity      float64 `json:"quantity"`
+}
+
+// SelfTradeResult is the outcome of the self-trade prevention check
+type SelfTradeResult struct {
+	Allowed        bool           `json:"allowed"`
+	Action         string         `json:"action"`
+	Reason         string         `json:"reason,omitempty"`
+	Conflicts      []RestingOrder `json:"conflicts"`
+	CanceledOrders []string       `json:"canceled_orders"`
+}
+
+// Throttle scopes
+const (
+	ThrottleScopeUser     = "USER"
+	ThrottleScopeAPIKey   = "API_KEY"
+	ThrottleScopeStrategy = "STRATEGY"
+	ThrottleScopeSymbol   = "SYMBOL"
+)
+
+// Throttled order actions
+const (
+	ThrottleActionNew    = "NEW"
+	ThrottleActionCancel = "CANCEL"
+	ThrottleActionAmend  = "AMEND"
+)
+
+// ThrottleLimit represents a token bucket rate limit for a scope and action.
+// Key "*" is the default for every key in the scope.
+type ThrottleLimit struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Scope     string    `json:"scope" gorm:"uniqueIndex:idx_throttle_limit"`
+	Key       string    `json:"key" gorm:"uniqueIndex:idx_throttle_limit"`
+	Action    string    `json:"action" gorm:"uniqueIndex:idx_throttle_limit"`
+	Rate      float64   `json:"rate" gorm:"type:decimal(10,2)"` // Tokens refilled per second
+	Burst     int       `json:"burst"`                          // Bucket capacity
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ThrottleIdentity identifies who an order is throttled against
+type ThrottleIdentity struct {
+	UserID   string `json:"user_id"`
+	APIKey   string `json:"api_key"`
+	Strategy string `json:"strategy"`
+	Symbol   string `json:"symbol"`
+}
+
+// ThrottleLimitUpdate represents a request to set a throttle limit
+type ThrottleLimitUpdate struct {
+	Scope  string  `json:"scope" binding:"required,oneof=USER API_KEY STRATEGY SYMBOL"`
+	Key    string  `json:"key" binding:"required"`
+	Action string  `json:"action" binding:"required,oneof=NEW CANCEL AMEND"`
+	Rate   float64 `json:"rate" binding:"required,gt=0"`
+	Burst  int     `json:"burst" binding:"required,gt=0"`
+	Reason string  `json:"reason,omitempty"`
+}
+
 // RiskAlert represents a risk management alert or violation
 type RiskAlert struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
@@ -44,23 +337,96 @@ type DailyPnLTracking struct {
 	RealizedPnL             float64   `json:"realized_pnl" gorm:"type:decimal(20,8);default:0"`
 	UnrealizedPnL           float64   `json:"unrealized_pnl" gorm:"type:decimal(20,8);default:0"`
 	TotalPnL                float64   `json:"total_pnl" gorm:"type:decimal(20,8);default:0"`
+	Fees                    float64   `json:"fees" gorm:"type:decimal(20,8);default:0"` // Realised P&L is net of these
 	CircuitBreakerTriggered bool      `json:"circuit_breaker_triggered" gorm:"default:false"`
 	UpdatedAt               time.Time `json:"updated_at"`
 	CreatedAt               time.Time `json:"created_at"`
 }
 
+// PositionSnapshot is a position frozen at the end of a trading day
+type PositionSnapshot struct {
+	TradingDay    string    `json:"trading_day" gorm:"index"`
+	Symbol        string    `json:"symbol"`
+	Quantity      float64   `json:"quantity" gorm:"type:decimal(20,8)"`
+	AvgPrice      float64   `json:"avg_price" gorm:"type:decimal(20,8)"`
+	MarketPrice   float64   `json:"market_price" gorm:"type:decimal(20,8)"`
+	UnrealizedPnL float64   `json:"unrealized_pnl" gorm:"column:unrealized_pnl;type:decimal(20,8)"`
+	RealizedPnL   float64   `json:"realized_pnl" gorm:"column:realized_pnl;type:decimal(20,8)"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// SessionSummary is the end-of-day record of a trading day and the
+// start-of-day rollover into the next one
+type SessionSummary struct {
+	TradingDay              string                 `json:"trading_day" gorm:"primaryKey"`
+	RealizedPnL             float64                `json:"realized_pnl" gorm:"column:realized_pnl;type:decimal(20,8)"`
+	UnrealizedPnL           float64                `json:"unrealized_pnl" gorm:"column:unrealized_pnl;type:decimal(20,8)"`
+	TotalPnL                float64                `json:"total_pnl" gorm:"column:total_pnl;type:decimal(20,8)"`
+	Fees                    float64                `json:"fees" gorm:"type:decimal(20,8)"` // Realised P&L is net of these
+	CircuitBreakerTriggered bool                   `json:"circuit_breaker_triggered"`
+	Trades                  int                    `json:"trades"`
+	Turnover                float64                `json:"turnover" gorm:"type:decimal(20,8)"`
+	Positions               int                    `json:"positions"`
+	GrossExposure           float64                `json:"gross_exposure" gorm:"type:decimal(20,8)"`
+	OpenOrders              []OpenOrderReservation `json:"open_orders" gorm:"serializer:json"`
+	ActiveBreakers          []CircuitBreakerEvent  `json:"active_breakers" gorm:"serializer:json"`
+	Late                    bool                   `json:"late"` // Closed after the session had already rolled over
+	ClosedAt                time.Time              `json:"closed_at"`
+	NextTradingDay          string                 `json:"next_trading_day"`
+	BreakersReset           int                    `json:"breakers_reset"`
+	RolledOverAt            *time.Time             `json:"rolled_over_at"`
+}
+
+// SessionDetail is a session summary with its position snapshots
+type SessionDetail struct {
+	Summary   SessionSummary     `json:"summary"`
+	Positions []PositionSnapshot `json:"positions"`
+}
+
+// Circuit breaker scopes
+const (
+	BreakerScopeGlobal   = "GLOBAL"
+	BreakerScopeSymbol   = "SYMBOL"
+	BreakerScopeStrategy = "STRATEGY"
+)
+
+// Circuit breaker states
+const (
+	BreakerStateOpen     = "OPEN"      // All orders in scope blocked
+	BreakerStateHalfOpen = "HALF_OPEN" // Only reduce-only orders allowed
+	BreakerStateClosed   = "CLOSED"    // Breaker reset
+)
+
 // CircuitBreakerEvent represents a circuit breaker activation
 type CircuitBreakerEvent struct {
 	ID              uint       `json:"id" gorm:"primaryKey"`
+	Scope           string     `json:"scope" gorm:"default:'GLOBAL';index"`
+	ScopeKey        string     `json:"scope_key" gorm:"index"` // Symbol or strategy name, empty for GLOBAL
 	TriggerType     string     `json:"trigger_type"`
 	TriggerValue    float64    `json:"trigger_value" gorm:"type:decimal(20,8)"`
 	Threshold       float64    `json:"threshold" gorm:"type:decimal(20,8)"`
-	DurationSeconds int        `json:"duration_seconds"`
+	DurationSeconds int        `json:"duration_seconds"` // Cooldown before half-open, 0 = manual reset only
+	State           string     `json:"state" gorm:"default:'OPEN'"`
 	Active          bool       `json:"active" gorm:"default:true;index:idx_circuit_breaker_active"`
 	CreatedAt       time.Time  `json:"created_at" gorm:"index:idx_circuit_breaker_active"`
+	HalfOpenAt      *time.Time `json:"half_open_at"`
 	ResetAt         *time.Time `json:"reset_at"`
 }
 
+// AppliesTo reports whether the breaker covers an order for symbol/strategy.
+// The strategy is set by the client, so an order without one is covered by
+// every strategy breaker rather than slipping past them.
+func (e *CircuitBreakerEvent) AppliesTo(symbol, strategy string) bool {
+	switch e.Scope {
+	case BreakerScopeSymbol:
+		return e.ScopeKey == symbol
+	case BreakerScopeStrategy:
+		return strategy == "" || e.ScopeKey == strategy
+	default:
+		return true
+	}
+}
+
 // RiskCheckResult represents the result of a risk validation check
 type RiskCheckResult struct {
 	Allowed         bool     `json:"allowed"`
@@ -68,6 +434,125 @@ type RiskCheckResult struct {
 	Alerts          []string `json:"alerts"`
 }
 
+// RiskRuleResult represents the outcome of a single pre-trade risk rule
+type RiskRuleResult struct {
+	Rule    string `json:"rule"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// LimitUtilisation represents how much of a limit a value consumes
+type LimitUtilisation struct {
+	Value          float64 `json:"value"`
+	Limit          float64 `json:"limit"`
+	UtilisationPct float64 `json:"utilisation_pct"`
+	Headroom       float64 `json:"headroom"`
+}
+
+// Utilisation alert levels
+const (
+	UtilisationLevelWarning  = "WARNING"
+	UtilisationLevelCritical = "CRITICAL"
+)
+
+// UtilisationBreach is a limit at or above a soft utilisation threshold
+type UtilisationBreach struct {
+	Limit          string  `json:"limit"`
+	Symbol         string  `json:"symbol,omitempty"`
+	Level          string  `json:"level"`
+	UtilisationPct float64 `json:"utilisation_pct"`
+}
+
+// RiskUtilisation is a snapshot of how close the portfolio is to each global
+// and per-symbol limit
+type RiskUtilisation struct {
+	Global         map[string]LimitUtilisation            `json:"global"`
+	Symbols        map[string]map[string]LimitUtilisation `json:"symbols"`
+	Breaches       []UtilisationBreach                    `json:"breaches"`
+	WarningPct     float64                                `json:"warning_pct"`
+	CriticalPct    float64                                `json:"critical_pct"`
+	PortfolioValue float64                                `json:"portfolio_value"`
+	Timestamp      time.Time                              `json:"timestamp"`
+}
+
+// RiskSimulationRequest represents a what-if check of one or more orders
+type RiskSimulationRequest struct {
+	Orders []OrderRequest `json:"orders" binding:"required,min=1,dive"`
+}
+
+// OrderSimulation represents the simulated risk outcome of a single order
+type OrderSimulation struct {
+	Order             OrderRequest                `json:"order"`
+	Allowed           bool                        `json:"allowed"`
+	Rules             []RiskRuleResult            `json:"rules"`
+	CurrentPosition   float64                     `json:"current_position"`
+	ProjectedPosition float64                     `json:"projected_position"`
+	Utilisation       map[string]LimitUtilisation `json:"utilisation"`
+}
+
+// RiskSimulationResult represents the simulated risk outcome of a basket
+type RiskSimulationResult struct {
+	Allowed           bool                        `json:"allowed"`
+	Orders            []OrderSimulation           `json:"orders"`
+	BasketUtilisation map[string]LimitUtilisation `json:"basket_utilisation"`
+	PortfolioValue    float64                     `json:"portfolio_value"`
+	Timestamp         time.Time                   `json:"timestamp"`
+}
+
+// VaRResult represents value at risk and expected shortfall for one method and confidence
+type VaRResult struct {
+	Method            string  `json:"method"` // HISTORICAL, PARAMETRIC
+	Confidence        float64 `json:"confidence"`
+	VaR               float64 `json:"var"`
+	ExpectedShortfall float64 `json:"expected_shortfall"`
+}
+
+// VaRReport represents the VaR of the live portfolio
+type VaRReport struct {
+	PortfolioValue float64     `json:"portfolio_value"`
+	GrossExposure  float64     `json:"gross_exposure"`
+	LookbackDays   int         `json:"lookback_days"`
+	HorizonDays    int         `json:"horizon_days"`
+	Observations   int         `json:"observations"`
+	Results        []VaRResult `json:"results"`
+	MissingSymbols []string    `json:"missing_symbols"`
+	Timestamp      time.Time   `json:"timestamp"`
+}
+
+// StressScenario represents a user-defined stress test.
+// SHOCK scenarios apply DefaultShockPct to every symbol unless overridden in
+// Shocks (JSON map of symbol to percent); HISTORICAL scenarios replay each
+// symbol's move on ReplayDate.
+type StressScenario struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	Name            string    `json:"name" gorm:"uniqueIndex" binding:"required"`
+	Description     string    `json:"description"`
+	ScenarioType    string    `json:"scenario_type" binding:"required,oneof=SHOCK HISTORICAL"`
+	DefaultShockPct float64   `json:"default_shock_pct" gorm:"type:decimal(10,4)"`
+	Shocks          string    `json:"shocks" gorm:"type:jsonb"` // JSON map symbol -> percent
+	ReplayDate      string    `json:"replay_date"`              // YYYY-MM-DD
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// StressPositionResult represents the stressed P&L of one position
+type StressPositionResult struct {
+	Symbol      string  `json:"symbol"`
+	Quantity    float64 `json:"quantity"`
+	MarketValue float64 `json:"market_value"`
+	ShockPct    float64 `json:"shock_pct"`
+	PnL         float64 `json:"pnl"`
+}
+
+// StressResult represents the outcome of a stress scenario on the portfolio
+type StressResult struct {
+	Scenario  string                 `json:"scenario"`
+	PnL       float64                `json:"pnl"`
+	PnLPct    float64                `json:"pnl_pct"`
+	Positions []StressPositionResult `json:"positions"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
 // RiskLimitsUpdate represents a request to update risk limits
 type RiskLimitsUpdate struct {
 	MaxPositionSize           *float64 `json:"max_position_size"`
@@ -76,12 +561,38 @@ type RiskLimitsUpdate struct {
 	MaxPortfolioConcentration *float64 `json:"max_portfolio_concentration"`
 	MaxLeverage               *float64 `json:"max_leverage"`
 	MaxOrdersPerSecond        *int     `json:"max_orders_per_second"`
+	BreakerCooldownSeconds    *int     `json:"breaker_cooldown_seconds"`
+	RejectionRateThreshold    *float64 `json:"rejection_rate_threshold"`
+	EngineErrorBurst          *int     `json:"engine_error_burst"`
+	SymbolLossLimit           *float64 `json:"symbol_loss_limit"`
+	MaxVaR                    *float64 `json:"max_var"`
+	VaRConfidence             *float64 `json:"var_confidence"`
+	SelfTradeAction           *string  `json:"self_trade_action" binding:"omitempty,oneof=NONE REJECT_NEW CANCEL_RESTING CANCEL_BOTH"`
+	WashTradeWindowSeconds    *int     `json:"wash_trade_window_seconds"`
+	WashTradePriceTolerance   *float64 `json:"wash_trade_price_tolerance"`
+	PDTMaxDayTrades           *int     `json:"pdt_max_day_trades"`
+	PDTEquityThreshold        *float64 `json:"pdt_equity_threshold"`
+	MaxTradesPerDay           *int     `json:"max_trades_per_day"`
+	MaxDailyTurnover          *float64 `json:"max_daily_turnover"`
+	EnforceTradingSession     *bool    `json:"enforce_trading_session"`
+	UtilisationWarningPct     *float64 `json:"utilisation_warning_pct"`
+	UtilisationCriticalPct    *float64 `json:"utilisation_critical_pct"`
 	Enabled                   *bool    `json:"enabled"`
+	Reason                    string   `json:"reason"`
+}
+
+// CircuitBreakerTrigger represents a request to manually trip a scoped breaker
+type CircuitBreakerTrigger struct {
+	Scope           string `json:"scope" binding:"required,oneof=GLOBAL SYMBOL STRATEGY"`
+	ScopeKey        string `json:"scope_key"`
+	Reason          string `json:"reason"`
+	DurationSeconds int    `json:"duration_seconds"`
 }
 
 // PositionLimitUpdate represents a request to update position limits for a symbol
 type PositionLimitUpdate struct {
 	MaxPosition         float64 `json:"max_position" binding:"required"`
 	MaxConcentrationPct float64 `json:"max_concentration_pct" binding:"required"`
+	Reason              string  `json:"reason"`
 }
 
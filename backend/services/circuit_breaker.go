@@ -0,0 +1,373 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+const (
+	// breakerWindow is the rolling window used by the automatic triggers
+	breakerWindow = 60 * time.Second
+	// rejectionMinSamples is the minimum number of orders in the window
+	// before the rejection rate is considered meaningful
+	rejectionMinSamples = 10
+)
+
+// eventWindow counts events over a rolling time window
+type eventWindow struct {
+	window time.Duration
+	events []windowEvent
+	mu     sync.Mutex
+}
+
+type windowEvent struct {
+	at     time.Time
+	failed bool
+}
+
+func newEventWindow(window time.Duration) *eventWindow {
+	return &eventWindow{window: window}
+}
+
+// Record adds an event to the window
+func (w *eventWindow) Record(failed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	w.events = append(w.events, windowEvent{at: now, failed: failed})
+	w.prune(now)
+}
+
+// Counts returns the total and failed events inside the window
+func (w *eventWindow) Counts() (total int, failed int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.prune(time.Now())
+	for _, e := range w.events {
+		if e.failed {
+			failed++
+		}
+	}
+	return len(w.events), failed
+}
+
+// Peak returns the most events inside any interval within the window
+func (w *eventWindow) Peak(interval time.Duration) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.prune(time.Now())
+	peak, start := 0, 0
+	for end := range w.events {
+		for w.events[end].at.Sub(w.events[start].at) >= interval {
+			start++
+		}
+		if count := end - start + 1; count > peak {
+			peak = count
+		}
+	}
+	return peak
+}
+
+func (w *eventWindow) prune(now time.Time) {
+	cutoff := now.Add(-w.window)
+	i := 0
+	for i < len(w.events) && w.events[i].at.Before(cutoff) {
+		i++
+	}
+	w.events = w.events[i:]
+}
+
+// isReduceOnly returns true if the order only reduces the absolute position
+func isReduceOnly(side string, quantity float64, currentPosition float64) bool {
+	if side == "BUY" {
+		return currentPosition < 0 && quantity <= math.Abs(currentPosition)
+	}
+	return currentPosition > 0 && quantity <= currentPosition
+}
+
+// CheckCircuitBreakerForOrder checks all active breakers that apply to the order.
+// Open breakers block the order; half-open breakers only let reduce-only orders through.
+func (rm *RiskManager) CheckCircuitBreakerForOrder(order *models.OrderRequest, currentPosition float64) error {
+	return rm.checkCircuitBreakerForOrder(order, currentPosition, false)
+}
+
+func (rm *RiskManager) checkCircuitBreakerForOrder(order *models.OrderRequest, currentPosition float64, dryRun bool) error {
+	breakers, err := rm.GetActiveCircuitBreakers()
+	if err != nil {
+		return nil // Allow if can't check
+	}
+
+	for i := range breakers {
+		breaker := &breakers[i]
+		if !breaker.AppliesTo(order.Symbol, order.Strategy) {
+			continue
+		}
+
+		state := projectedBreakerState(breaker, time.Now())
+		if !dryRun {
+			state = rm.refreshBreakerState(breaker)
+		}
+
+		switch state {
+		case models.BreakerStateClosed:
+			continue
+		case models.BreakerStateHalfOpen:
+			if isReduceOnly(order.Side, order.Quantity, currentPosition) {
+				continue
+			}
+			return fmt.Errorf("circuit breaker half-open: %s %s (only reduce-only orders allowed)", breaker.TriggerType, describeScope(breaker))
+		default:
+			if breaker.Scope == models.BreakerScopeStrategy && order.Strategy == "" {
+				return fmt.Errorf("circuit breaker active: %s %s (orders must name their strategy while a strategy breaker is open)", breaker.TriggerType, describeScope(breaker))
+			}
+			return fmt.Errorf("circuit breaker active: %s %s (triggered at %s)", breaker.TriggerType, describeScope(breaker), breaker.CreatedAt.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// GetActiveCircuitBreakers returns all breakers that are open or half-open
+func (rm *RiskManager) GetActiveCircuitBreakers() ([]models.CircuitBreakerEvent, error) {
+	var breakers []models.CircuitBreakerEvent
+	result := rm.db.GetDB().Where("active = ?", true).Order("created_at DESC").Find(&breakers)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return breakers, nil
+}
+
+// refreshBreakerState moves a breaker through OPEN -> HALF_OPEN -> CLOSED
+// once its cooldown has elapsed and its recovery condition holds
+func (rm *RiskManager) refreshBreakerState(breaker *models.CircuitBreakerEvent) string {
+	if breaker.State == "" {
+		breaker.State = models.BreakerStateOpen
+	}
+
+	// Manual breakers stay open until reset
+	if breaker.DurationSeconds <= 0 {
+		return breaker.State
+	}
+
+	now := time.Now()
+	if breaker.State == models.BreakerStateOpen {
+		cooldownEnd := breaker.CreatedAt.Add(time.Duration(breaker.DurationSeconds) * time.Second)
+		if now.Before(cooldownEnd) {
+			return breaker.State
+		}
+
+		breaker.State = models.BreakerStateHalfOpen
+		breaker.HalfOpenAt = &now
+		rm.db.GetDB().Model(&models.CircuitBreakerEvent{}).
+			Where("id = ?", breaker.ID).
+			Updates(map[string]interface{}{
+				"state":        breaker.State,
+				"half_open_at": now,
+			})
+
+		rm.SendAlert("CIRCUIT_BREAKER_HALF_OPEN", "WARNING", breaker.ScopeKey,
+			fmt.Sprintf("Circuit breaker %d %s is half-open: reduce-only orders allowed", breaker.ID, describeScope(breaker)),
+			map[string]interface{}{
+				"breaker_id":   breaker.ID,
+				"scope":        breaker.Scope,
+				"scope_key":    breaker.ScopeKey,
+				"trigger_type": breaker.TriggerType,
+			})
+
+		if rm.wsHub != nil {
+			rm.wsHub.BroadcastCircuitBreaker(breaker)
+		}
+	}
+
+	if breaker.State == models.BreakerStateHalfOpen && rm.breakerRecovered(breaker) {
+		rm.ResetCircuitBreaker(breaker.ID)
+		breaker.State = models.BreakerStateClosed
+	}
+
+	return breaker.State
+}
+
+// projectedBreakerState returns the state a breaker would be in at now
+// without persisting the transition or evaluating recovery
+func projectedBreakerState(breaker *models.CircuitBreakerEvent, now time.Time) string {
+	if breaker.State == "" || breaker.State == models.BreakerStateOpen {
+		cooldownEnd := breaker.CreatedAt.Add(time.Duration(breaker.DurationSeconds) * time.Second)
+		if breaker.DurationSeconds > 0 && !now.Before(cooldownEnd) {
+			return models.BreakerStateHalfOpen
+		}
+		return models.BreakerStateOpen
+	}
+	return breaker.State
+}
+
+// breakerRecovered evaluates the recovery condition for the breaker's trigger
+func (rm *RiskManager) breakerRecovered(breaker *models.CircuitBreakerEvent) bool {
+	switch breaker.TriggerType {
+	case "DAILY_LOSS":
+		pnl, err := rm.GetDailyPnL()
+		if err != nil {
+			return false
+		}
+		return pnl.TotalPnL > breaker.Threshold
+
+	case "SYMBOL_LOSS":
+		rm.breakerMu.Lock()
+		pnl := rm.symbolPnL[breaker.ScopeKey]
+		rm.breakerMu.Unlock()
+		return pnl > breaker.Threshold
+
+	case "REJECTION_RATE":
+		total, rejected := rm.orderOutcomes.Counts()
+		if total == 0 {
+			return true
+		}
+		return float64(rejected)/float64(total)*100 < breaker.Threshold
+
+	case "ENGINE_ERRORS":
+		_, errors := rm.engineErrors.Counts()
+		return float64(errors) < breaker.Threshold
+
+	default:
+		// Breakers without a measurable condition recover after the cooldown
+		return true
+	}
+}
+
+// TriggerScopedCircuitBreaker activates a breaker for a scope with a cooldown.
+// A zero cooldown keeps the breaker open until it is manually reset.
+func (rm *RiskManager) TriggerScopedCircuitBreaker(scope, scopeKey, triggerType string, value, threshold float64, cooldown time.Duration) error {
+	event := models.CircuitBreakerEvent{
+		Scope:           scope,
+		ScopeKey:        scopeKey,
+		TriggerType:     triggerType,
+		TriggerValue:    value,
+		Threshold:       threshold,
+		DurationSeconds: int(cooldown.Seconds()),
+		State:           models.BreakerStateOpen,
+		Active:          true,
+		CreatedAt:       time.Now(),
+	}
+
+	if result := rm.db.GetDB().Create(&event); result.Error != nil {
+		return result.Error
+	}
+
+	// Only global breakers mark the trading day as halted
+	if scope == models.BreakerScopeGlobal {
+		today := rm.TradingDay()
+		rm.db.GetDB().Model(&models.DailyPnLTracking{}).
+			Where("date = ?", today).
+			Update("circuit_breaker_triggered", true)
+	}
+
+	// Send critical alert
+	rm.SendAlert("CIRCUIT_BREAKER", "CRITICAL", scopeKey,
+		fmt.Sprintf("Circuit breaker triggered %s: %s (value: %.2f, threshold: %.2f)", describeScope(&event), triggerType, value, threshold),
+		map[string]interface{}{
+			"scope":            scope,
+			"scope_key":        scopeKey,
+			"trigger_type":     triggerType,
+			"trigger_value":    value,
+			"threshold":        threshold,
+			"duration_seconds": event.DurationSeconds,
+		})
+
+	// Broadcast via WebSocket
+	if rm.wsHub != nil {
+		rm.wsHub.BroadcastCircuitBreaker(&event)
+	}
+
+	return nil
+}
+
+// hasActiveBreaker reports whether a breaker for the scope and trigger is already active
+func (rm *RiskManager) hasActiveBreaker(scope, scopeKey, triggerType string) bool {
+	var count int64
+	rm.db.GetDB().Model(&models.CircuitBreakerEvent{}).
+		Where("active = ? AND scope = ? AND scope_key = ? AND trigger_type = ?", true, scope, scopeKey, triggerType).
+		Count(&count)
+	return count > 0
+}
+
+// breakerCooldown returns the configured breaker cooldown
+func (rm *RiskManager) breakerCooldown() time.Duration {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return time.Duration(rm.limits.BreakerCooldownSeconds) * time.Second
+}
+
+// RecordOrderOutcome records whether the engine accepted or rejected an order
+// and trips a global breaker when the rejection rate spikes
+func (rm *RiskManager) RecordOrderOutcome(rejected bool) {
+	rm.orderOutcomes.Record(rejected)
+
+	rm.mu.RLock()
+	threshold := rm.limits.RejectionRateThreshold
+	rm.mu.RUnlock()
+
+	if threshold <= 0 {
+		return
+	}
+
+	total, rejections := rm.orderOutcomes.Counts()
+	if total < rejectionMinSamples {
+		return
+	}
+
+	rate := float64(rejections) / float64(total) * 100
+	if rate >= threshold && !rm.hasActiveBreaker(models.BreakerScopeGlobal, "", "REJECTION_RATE") {
+		rm.TriggerScopedCircuitBreaker(models.BreakerScopeGlobal, "", "REJECTION_RATE", rate, threshold, rm.breakerCooldown())
+	}
+}
+
+// RecordEngineError records an engine failure and trips a global breaker on an error burst
+func (rm *RiskManager) RecordEngineError() {
+	rm.engineErrors.Record(true)
+
+	rm.mu.RLock()
+	burst := rm.limits.EngineErrorBurst
+	rm.mu.RUnlock()
+
+	if burst <= 0 {
+		return
+	}
+
+	_, errors := rm.engineErrors.Counts()
+	if errors >= burst && !rm.hasActiveBreaker(models.BreakerScopeGlobal, "", "ENGINE_ERRORS") {
+		rm.TriggerScopedCircuitBreaker(models.BreakerScopeGlobal, "", "ENGINE_ERRORS", float64(errors), float64(burst), rm.breakerCooldown())
+	}
+}
+
+// UpdateSymbolPnL records the P&L of a symbol and trips a symbol breaker
+// when it breaches the per-symbol loss limit
+func (rm *RiskManager) UpdateSymbolPnL(symbol string, pnl float64) {
+	rm.breakerMu.Lock()
+	rm.symbolPnL[symbol] = pnl
+	rm.breakerMu.Unlock()
+
+	rm.mu.RLock()
+	limit := rm.limits.SymbolLossLimit
+	rm.mu.RUnlock()
+
+	if limit <= 0 {
+		return
+	}
+
+	if pnl <= -limit && !rm.hasActiveBreaker(models.BreakerScopeSymbol, symbol, "SYMBOL_LOSS") {
+		rm.TriggerScopedCircuitBreaker(models.BreakerScopeSymbol, symbol, "SYMBOL_LOSS", pnl, -limit, rm.breakerCooldown())
+	}
+}
+
+func describeScope(breaker *models.CircuitBreakerEvent) string {
+	if breaker.Scope == "" || breaker.Scope == models.BreakerScopeGlobal {
+		return "[GLOBAL]"
+	}
+	return fmt.Sprintf("[%s %s]", breaker.Scope, breaker.ScopeKey)
+}
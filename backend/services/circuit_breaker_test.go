@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestIsReduceOnly(t *testing.T) {
+	cases := []struct {
+		side     string
+		quantity float64
+		position float64
+		want     bool
+	}{
+		{"SELL", 50, 100, true},
+		{"SELL", 100, 100, true},
+		{"SELL", 150, 100, false},
+		{"BUY", 50, 100, false},
+		{"BUY", 50, -100, true},
+		{"SELL", 50, -100, false},
+		{"BUY", 10, 0, false},
+	}
+
+	for _, tc := range cases {
+		if got := isReduceOnly(tc.side, tc.quantity, tc.position); got != tc.want {
+			t.Errorf("isReduceOnly(%s, %.0f, %.0f) = %v, want %v", tc.side, tc.quantity, tc.position, got, tc.want)
+		}
+	}
+}
+
+func TestEventWindow(t *testing.T) {
+	w := newEventWindow(50 * time.Millisecond)
+	w.Record(false)
+	w.Record(true)
+	w.Record(true)
+
+	total, failed := w.Counts()
+	if total != 3 || failed != 2 {
+		t.Errorf("Expected 3 events with 2 failures, got %d/%d", total, failed)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if total, _ := w.Counts(); total != 0 {
+		t.Errorf("Expected window to be empty after expiry, got %d events", total)
+	}
+}
+
+func TestCircuitBreakerAppliesTo(t *testing.T) {
+	global := &models.CircuitBreakerEvent{Scope: models.BreakerScopeGlobal}
+	symbol := &models.CircuitBreakerEvent{Scope: models.BreakerScopeSymbol, ScopeKey: "AAPL"}
+	strategy := &models.CircuitBreakerEvent{Scope: models.BreakerScopeStrategy, ScopeKey: "movers"}
+
+	if !global.AppliesTo("TSLA", "") {
+		t.Error("Expected global breaker to apply to every order")
+	}
+	if !symbol.AppliesTo("AAPL", "") || symbol.AppliesTo("TSLA", "") {
+		t.Error("Expected symbol breaker to apply only to its symbol")
+	}
+	if !strategy.AppliesTo("AAPL", "movers") || strategy.AppliesTo("AAPL", "pairs") {
+		t.Error("Expected strategy breaker to apply only to its strategy")
+	}
+	if !strategy.AppliesTo("AAPL", "") {
+		t.Error("Expected strategy breaker to cover orders without a strategy")
+	}
+}
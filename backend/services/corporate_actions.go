@@ -0,0 +1,465 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCorporateActionNotFound is returned for an unknown action ID
+	ErrCorporateActionNotFound = errors.New("corporate action not found")
+	// ErrCorporateActionApplied is returned when applying an action twice
+	ErrCorporateActionApplied = errors.New("corporate action already applied")
+)
+
+// CorporateActions stores splits, dividends and symbol changes and applies
+// them to the books, open orders and position limits once their ex-date
+// arrives
+type CorporateActions struct {
+	db          *DatabaseService
+	riskManager *RiskManager
+	ledger      *PnLLedger
+	orderLedger *OpenOrderLedger
+	mu          sync.Mutex
+	ticker      *time.Ticker
+	stopChan    chan bool
+}
+
+// NewCorporateActions creates a new corporate action processor
+func NewCorporateActions(db *DatabaseService, riskManager *RiskManager, ledger *PnLLedger, orderLedger *OpenOrderLedger) *CorporateActions {
+	return &CorporateActions{
+		db:          db,
+		riskManager: riskManager,
+		ledger:      ledger,
+		orderLedger: orderLedger,
+		stopChan:    make(chan bool),
+	}
+}
+
+// Start applies due actions now and then every hour
+func (ca *CorporateActions) Start() {
+	ca.ApplyDue()
+
+	ca.ticker = time.NewTicker(1 * time.Hour)
+
+	go func() {
+		log.Println("Corporate actions started (applying due actions every hour)")
+
+		for {
+			select {
+			case <-ca.ticker.C:
+				ca.ApplyDue()
+
+			case <-ca.stopChan:
+				log.Println("Corporate actions stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the corporate action processor
+func (ca *CorporateActions) Stop() {
+	if ca.ticker != nil {
+		ca.ticker.Stop()
+	}
+	ca.stopChan <- true
+}
+
+// corporateActionEffectiveAt returns the start of an action's ex-date in
+// market time
+func corporateActionEffectiveAt(exDate string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", exDate, marketLocation)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func validateCorporateAction(action *models.CorporateAction) error {
+	action.Type = strings.ToUpper(strings.TrimSpace(action.Type))
+	action.Symbol = strings.ToUpper(strings.TrimSpace(action.Symbol))
+	action.NewSymbol = strings.ToUpper(strings.TrimSpace(action.NewSymbol))
+
+	if action.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	if _, err := time.Parse("2006-01-02", action.ExDate); err != nil {
+		return fmt.Errorf("ex_date must be YYYY-MM-DD")
+	}
+
+	switch action.Type {
+	case models.CorporateActionSplit:
+		if action.OldShares <= 0 || action.NewShares <= action.OldShares {
+			return fmt.Errorf("a split needs new_shares greater than old_shares")
+		}
+	case models.CorporateActionReverseSplit:
+		if action.NewShares <= 0 || action.NewShares >= action.OldShares {
+			return fmt.Errorf("a reverse split needs new_shares less than old_shares")
+		}
+	case models.CorporateActionDividend:
+		if action.CashAmount <= 0 {
+			return fmt.Errorf("a cash dividend needs a positive cash_amount")
+		}
+	case models.CorporateActionSymbolChange:
+		if action.NewSymbol == "" || action.NewSymbol == action.Symbol {
+			return fmt.Errorf("a symbol change needs a different new_symbol")
+		}
+	default:
+		return fmt.Errorf("type must be %s, %s, %s or %s", models.CorporateActionSplit,
+			models.CorporateActionReverseSplit, models.CorporateActionDividend, models.CorporateActionSymbolChange)
+	}
+
+	action.Status = models.CorporateActionPending
+	action.AppliedAt = nil
+	action.AppliedBy = ""
+	return nil
+}
+
+// Load stores new corporate actions as pending. Actions already stored for
+// the same type, symbol and ex-date are skipped. Returns the number stored.
+func (ca *CorporateActions) Load(actions []models.CorporateAction, source string) (int, error) {
+	db := ca.db.GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("database unavailable")
+	}
+	if len(actions) == 0 {
+		return 0, nil
+	}
+
+	for i := range actions {
+		if err := validateCorporateAction(&actions[i]); err != nil {
+			return 0, fmt.Errorf("action %d (%s %s): %w", i+1, actions[i].Type, actions[i].Symbol, err)
+		}
+		actions[i].ID = 0
+		actions[i].Source = source
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&actions)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return int(result.RowsAffected), nil
+}
+
+// LoadFile stores the corporate actions in a JSON array or a CSV file with
+// a header of type, symbol, ex_date, old_shares, new_shares, cash_amount and
+// new_symbol columns
+func (ca *CorporateActions) LoadFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var actions []models.CorporateAction
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.NewDecoder(file).Decode(&actions)
+	} else {
+		actions, err = parseCorporateActionsCSV(file)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return ca.Load(actions, "FILE")
+}
+
+func parseCorporateActionsCSV(r io.Reader) ([]models.CorporateAction, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"type", "symbol", "ex_date"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	var actions []models.CorporateAction
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return actions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		number := func(name string) (float64, error) {
+			if value := field(name); value != "" {
+				return strconv.ParseFloat(value, 64)
+			}
+			return 0, nil
+		}
+
+		action := models.CorporateAction{
+			Type:      field("type"),
+			Symbol:    field("symbol"),
+			ExDate:    field("ex_date"),
+			NewSymbol: field("new_symbol"),
+		}
+		for name, target := range map[string]*float64{
+			"old_shares":  &action.OldShares,
+			"new_shares":  &action.NewShares,
+			"cash_amount": &action.CashAmount,
+		} {
+			if *target, err = number(name); err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s", line, name)
+			}
+		}
+		actions = append(actions, action)
+	}
+}
+
+// GetActions returns corporate actions, optionally for one symbol or status,
+// latest ex-date first
+func (ca *CorporateActions) GetActions(symbol, status string) ([]models.CorporateAction, error) {
+	actions := []models.CorporateAction{}
+	db := ca.db.GetDB()
+	if db == nil {
+		return actions, nil
+	}
+
+	query := db.Order("ex_date DESC, id DESC")
+	if symbol != "" {
+		query = query.Where("symbol = ? OR new_symbol = ?", strings.ToUpper(symbol), strings.ToUpper(symbol))
+	}
+	if status != "" {
+		query = query.Where("status = ?", strings.ToUpper(status))
+	}
+	if result := query.Find(&actions); result.Error != nil {
+		return nil, result.Error
+	}
+	return actions, nil
+}
+
+// GetAction returns a corporate action and its effect on the books
+func (ca *CorporateActions) GetAction(id uint) (*models.CorporateAction, []models.CorporateActionAdjustment, error) {
+	db := ca.db.GetDB()
+	if db == nil {
+		return nil, nil, fmt.Errorf("database unavailable")
+	}
+
+	var action models.CorporateAction
+	if result := db.First(&action, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrCorporateActionNotFound
+		}
+		return nil, nil, result.Error
+	}
+
+	adjustments := []models.CorporateActionAdjustment{}
+	db.Where("action_id = ?", id).Find(&adjustments)
+	return &action, adjustments, nil
+}
+
+// ApplyDue applies every pending action whose ex-date has arrived
+func (ca *CorporateActions) ApplyDue() {
+	db := ca.db.GetDB()
+	if db == nil {
+		return
+	}
+
+	var due []models.CorporateAction
+	db.Where("status = ? AND ex_date <= ?", models.CorporateActionPending, ca.riskManager.TradingDay()).
+		Order("ex_date, id").Find(&due)
+
+	for _, action := range due {
+		if _, err := ca.Apply(action.ID, "system"); err != nil {
+			log.Printf("Error applying corporate action %d (%s %s): %v", action.ID, action.Type, action.Symbol, err)
+		}
+	}
+}
+
+// Apply adjusts the symbol's position limit and open orders for an action,
+// marks it applied and rebuilds the books so positions and cost-basis lots
+// reflect it from its ex-date
+func (ca *CorporateActions) Apply(id uint, author string) (*models.CorporateAction, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	db := ca.db.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database unavailable")
+	}
+
+	var action models.CorporateAction
+	if result := db.First(&action, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrCorporateActionNotFound
+		}
+		return nil, result.Error
+	}
+	if action.Status == models.CorporateActionApplied {
+		return nil, ErrCorporateActionApplied
+	}
+
+	meta := models.LimitChangeMeta{
+		Author: author,
+		Reason: describeCorporateAction(&action),
+		Source: models.LimitSourceCorporateAction,
+	}
+	switch action.Type {
+	case models.CorporateActionSplit, models.CorporateActionReverseSplit:
+		// Symbols on the global limit get their own limit so the rescaled
+		// position fits
+		limit := ca.riskManager.currentPositionLimit(action.Symbol)
+		updated, err := ca.riskManager.SetPositionLimit(action.Symbol, &models.PositionLimitUpdate{
+			MaxPosition:         limit.MaxPosition * action.Ratio(),
+			MaxConcentrationPct: limit.MaxConcentrationPct,
+		}, meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rescale position limit: %w", err)
+		}
+		action.PositionLimitBefore = limit.MaxPosition
+		action.PositionLimitAfter = updated.MaxPosition
+
+	case models.CorporateActionSymbolChange:
+		var limit models.PositionLimit
+		if db.Where("symbol = ?", action.Symbol).First(&limit).Error == nil {
+			if _, err := ca.riskManager.SetPositionLimit(action.NewSymbol, &models.PositionLimitUpdate{
+				MaxPosition:         limit.MaxPosition,
+				MaxConcentrationPct: limit.MaxConcentrationPct,
+			}, meta); err != nil {
+				return nil, fmt.Errorf("failed to move position limit: %w", err)
+			}
+			action.PositionLimitBefore = limit.MaxPosition
+			action.PositionLimitAfter = limit.MaxPosition
+		}
+	}
+
+	if ca.orderLedger != nil {
+		action.OrdersAdjusted = ca.orderLedger.ApplyCorporateAction(&action)
+	}
+
+	now := time.Now()
+	action.Status = models.CorporateActionApplied
+	action.AppliedAt = &now
+	action.AppliedBy = author
+	if result := db.Save(&action); result.Error != nil {
+		return nil, result.Error
+	}
+
+	if err := ca.ledger.Rebuild(); err != nil {
+		log.Printf("Error rebuilding P&L ledger after corporate action %d: %v", action.ID, err)
+	}
+
+	ca.riskManager.SendAlert("CORPORATE_ACTION", "INFO", action.Symbol,
+		fmt.Sprintf("%s %s applied by %s (%d open orders adjusted)",
+			action.Symbol, describeCorporateAction(&action), author, action.OrdersAdjusted),
+		map[string]interface{}{
+			"action_id":             action.ID,
+			"type":                  action.Type,
+			"ex_date":               action.ExDate,
+			"position_limit_before": action.PositionLimitBefore,
+			"position_limit_after":  action.PositionLimitAfter,
+			"orders_adjusted":       action.OrdersAdjusted,
+		})
+
+	return &action, nil
+}
+
+func describeCorporateAction(action *models.CorporateAction) string {
+	switch action.Type {
+	case models.CorporateActionSplit:
+		return fmt.Sprintf("%g-for-%g split", action.NewShares, action.OldShares)
+	case models.CorporateActionReverseSplit:
+		return fmt.Sprintf("%g-for-%g reverse split", action.NewShares, action.OldShares)
+	case models.CorporateActionDividend:
+		return fmt.Sprintf("$%.4f cash dividend", action.CashAmount)
+	case models.CorporateActionSymbolChange:
+		return "symbol change to " + action.NewSymbol
+	}
+	return action.Type
+}
+
+// AdjustBars back-adjusts daily bars of a symbol for its splits and
+// dividends so returns across ex-dates are not distorted
+func (ca *CorporateActions) AdjustBars(symbol string, bars []PriceBar) []PriceBar {
+	db := ca.db.GetDB()
+	if db == nil || len(bars) == 0 {
+		return bars
+	}
+
+	var actions []models.CorporateAction
+	db.Where("symbol = ? AND type IN ? AND ex_date <= ?", symbol,
+		[]string{models.CorporateActionSplit, models.CorporateActionReverseSplit, models.CorporateActionDividend},
+		ca.riskManager.TradingDay()).Find(&actions)
+	return adjustBars(bars, actions)
+}
+
+// adjustBars scales every bar before each action's ex-date: splits divide
+// prices and multiply volume by the split ratio; dividends multiply prices
+// by one less the dividend's share of the last close before the ex-date
+func adjustBars(bars []PriceBar, actions []models.CorporateAction) []PriceBar {
+	adjusted := make([]PriceBar, len(bars))
+	copy(adjusted, bars)
+	if len(actions) == 0 {
+		return adjusted
+	}
+	sort.Slice(adjusted, func(i, j int) bool { return adjusted[i].Timestamp < adjusted[j].Timestamp })
+
+	for _, action := range actions {
+		// Bars before the ex-date
+		before := sort.Search(len(adjusted), func(i int) bool {
+			return barDate(adjusted[i].Timestamp) >= action.ExDate
+		})
+		if before == 0 {
+			continue
+		}
+
+		priceFactor, volumeFactor := 1.0, 1.0
+		switch action.Type {
+		case models.CorporateActionSplit, models.CorporateActionReverseSplit:
+			priceFactor = 1 / action.Ratio()
+			volumeFactor = action.Ratio()
+		case models.CorporateActionDividend:
+			lastClose := adjusted[before-1].Close
+			if lastClose <= action.CashAmount {
+				continue
+			}
+			priceFactor = 1 - action.CashAmount/lastClose
+		}
+
+		for i := 0; i < before; i++ {
+			bar := &adjusted[i]
+			bar.Open *= priceFactor
+			bar.High *= priceFactor
+			bar.Low *= priceFactor
+			bar.Close *= priceFactor
+			bar.VWAP *= priceFactor
+			bar.Volume = int64(float64(bar.Volume)*volumeFactor + 0.5)
+		}
+	}
+	return adjusted
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hft/backend/models"
+)
+
+func TestValidateCorporateAction(t *testing.T) {
+	tests := []struct {
+		name   string
+		action models.CorporateAction
+		valid  bool
+	}{
+		{"split", models.CorporateAction{Type: "split", Symbol: "aapl", ExDate: "2026-06-01", OldShares: 1, NewShares: 4}, true},
+		{"split that shrinks", models.CorporateAction{Type: "SPLIT", Symbol: "AAPL", ExDate: "2026-06-01", OldShares: 4, NewShares: 1}, false},
+		{"reverse split", models.CorporateAction{Type: "REVERSE_SPLIT", Symbol: "AAPL", ExDate: "2026-06-01", OldShares: 10, NewShares: 1}, true},
+		{"dividend", models.CorporateAction{Type: "CASH_DIVIDEND", Symbol: "AAPL", ExDate: "2026-06-01", CashAmount: 0.25}, true},
+		{"dividend without cash", models.CorporateAction{Type: "CASH_DIVIDEND", Symbol: "AAPL", ExDate: "2026-06-01"}, false},
+		{"symbol change", models.CorporateAction{Type: "SYMBOL_CHANGE", Symbol: "FB", ExDate: "2026-06-01", NewSymbol: "meta"}, true},
+		{"symbol change to itself", models.CorporateAction{Type: "SYMBOL_CHANGE", Symbol: "FB", ExDate: "2026-06-01", NewSymbol: "FB"}, false},
+		{"bad date", models.CorporateAction{Type: "SPLIT", Symbol: "AAPL", ExDate: "06/01/2026", OldShares: 1, NewShares: 2}, false},
+		{"unknown type", models.CorporateAction{Type: "SPINOFF", Symbol: "AAPL", ExDate: "2026-06-01"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCorporateAction(&tt.action)
+			if (err == nil) != tt.valid {
+				t.Fatalf("expected valid=%v, got %v", tt.valid, err)
+			}
+			if tt.valid && (tt.action.Status != models.CorporateActionPending || tt.action.Symbol != strings.ToUpper(tt.action.Symbol)) {
+				t.Errorf("expected a normalised pending action, got %+v", tt.action)
+			}
+		})
+	}
+}
+
+func TestParseCorporateActionsCSV(t *testing.T) {
+	input := "type,symbol,ex_date,old_shares,new_shares,cash_amount,new_symbol\n" +
+		"SPLIT,AAPL,2026-06-01,1,4,,\n" +
+		"CASH_DIVIDEND,MSFT,2026-06-02,,,0.83,\n" +
+		"SYMBOL_CHANGE,FB,2026-06-03,,,,META\n"
+
+	actions, err := parseCorporateActionsCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(actions) != 3 {
+		t.Fatalf("expected 3 actions, got %d", len(actions))
+	}
+	if actions[0].Ratio() != 4 || actions[1].CashAmount != 0.83 || actions[2].NewSymbol != "META" {
+		t.Errorf("unexpected actions %+v", actions)
+	}
+
+	if _, err := parseCorporateActionsCSV(strings.NewReader("symbol,ex_date\nAAPL,2026-06-01\n")); err == nil {
+		t.Error("expected an error without a type column")
+	}
+	if _, err := parseCorporateActionsCSV(strings.NewReader("type,symbol,ex_date,new_shares\nSPLIT,AAPL,2026-06-01,four\n")); err == nil {
+		t.Error("expected an error for a non-numeric share count")
+	}
+}
+
+func TestLotBookSplitAndDividend(t *testing.T) {
+	book := newLotBook(models.CostMethodFIFO)
+	book.apply(fill(1, "BUY", 100, 200))
+	book.apply(fill(2, "BUY", 50, 230))
+
+	adjustment := book.applyAction(models.CorporateAction{ID: 1, Type: models.CorporateActionSplit, Symbol: "AAPL", ExDate: "2026-06-01", OldShares: 1, NewShares: 4})
+	if adjustment.QuantityBefore != 150 || adjustment.QuantityAfter != 600 {
+		t.Errorf("expected 150 shares to become 600, got %.0f to %.0f", adjustment.QuantityBefore, adjustment.QuantityAfter)
+	}
+	if !closeTo(adjustment.AvgCostAfter, adjustment.AvgCostBefore/4) {
+		t.Errorf("expected cost to quarter, got %.4f from %.4f", adjustment.AvgCostAfter, adjustment.AvgCostBefore)
+	}
+
+	// Selling the first post-split lot realises the same P&L as before the split
+	result := book.apply(fill(3, "SELL", 400, 55))
+	if !closeTo(result.RealizedPnL, 400*(55-50)) {
+		t.Errorf("expected realised 2000, got %.2f", result.RealizedPnL)
+	}
+
+	adjustment = book.applyAction(models.CorporateAction{ID: 2, Type: models.CorporateActionDividend, Symbol: "AAPL", ExDate: "2026-06-02", CashAmount: 0.5})
+	if !closeTo(adjustment.CashAmount, 200*0.5) || !closeTo(book.realized, 2000+100) {
+		t.Errorf("expected dividend of 100 in realised, got %.2f and %.2f", adjustment.CashAmount, book.realized)
+	}
+	if adjustment.QuantityAfter != 200 {
+		t.Errorf("expected a dividend to leave the position, got %.0f", adjustment.QuantityAfter)
+	}
+}
+
+func TestAdjustBars(t *testing.T) {
+	bars := []PriceBar{
+		{Timestamp: "2026-05-29T20:00:00Z", Open: 400, High: 410, Low: 390, Close: 400, Volume: 1000},
+		{Timestamp: "2026-06-01T20:00:00Z", Open: 100, High: 105, Low: 95, Close: 100, Volume: 4000},
+		{Timestamp: "2026-06-02T20:00:00Z", Open: 99, High: 101, Low: 97, Close: 99, Volume: 4000},
+	}
+	actions := []models.CorporateAction{
+		{Type: models.CorporateActionSplit, Symbol: "AAPL", ExDate: "2026-06-01", OldShares: 1, NewShares: 4},
+		{Type: models.CorporateActionDividend, Symbol: "AAPL", ExDate: "2026-06-02", CashAmount: 1},
+	}
+
+	adjusted := adjustBars(bars, actions)
+	if bars[0].Close != 400 {
+		t.Error("expected the input bars to be left unchanged")
+	}
+	// Split quarters the price and quadruples the volume, then the dividend
+	// scales everything before 2026-06-02 by 1 - 1/100
+	if !closeTo(adjusted[0].Close, 100*0.99) || adjusted[0].Volume != 4000 {
+		t.Errorf("expected pre-split bar at 99 on 4000, got %.4f on %d", adjusted[0].Close, adjusted[0].Volume)
+	}
+	if !closeTo(adjusted[1].Close, 99) || adjusted[1].Volume != 4000 {
+		t.Errorf("expected split-day bar at 99 on 4000, got %.4f on %d", adjusted[1].Close, adjusted[1].Volume)
+	}
+	if adjusted[2].Close != 99 {
+		t.Errorf("expected ex-dividend bar unchanged, got %.4f", adjusted[2].Close)
+	}
+}
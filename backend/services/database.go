@@ -37,6 +37,21 @@ func NewDatabaseService(dsn string) *DatabaseService {
 		&models.RiskAlert{},
 		&models.DailyPnLTracking{},
 		&models.CircuitBreakerEvent{},
+		&models.ThrottleLimit{},
+		&models.StressScenario{},
+		&models.MarketHoliday{},
+		&models.RestrictedSymbol{},
+		&models.LimitChangeRequest{},
+		&models.RiskLimitVersion{},
+		&models.LimitNode{},
+		&models.ExecutionPnL{},
+		&models.FeeSchedule{},
+		&models.ExecutionFee{},
+		&models.CorporateAction{},
+		&models.CorporateActionAdjustment{},
+		&models.PositionSnapshot{},
+		&models.EquitySample{},
+		&models.SessionSummary{},
 	); err != nil {
 		log.Printf("Failed to migrate database: %v", err)
 		return &DatabaseService{db: nil}
@@ -61,13 +76,18 @@ func (ds *DatabaseService) UpdateOrder(order *models.Order) error {
 	return ds.db.Save(order).Error
 }
 
-func (ds *DatabaseService) GetOrders(limit int) ([]models.Order, error) {
+func (ds *DatabaseService) GetOrders(filter models.RecordFilter) ([]models.Order, error) {
 	if ds.db == nil {
 		return []models.Order{}, nil
 	}
 
+	query := OrdersQuery(ds.db, filter).Order("created_at DESC")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
 	var orders []models.Order
-	err := ds.db.Order("created_at DESC").Limit(limit).Find(&orders).Error
+	err := query.Find(&orders).Error
 	return orders, err
 }
 
@@ -88,13 +108,18 @@ func (ds *DatabaseService) SaveExecution(execution *models.Execution) error {
 	return ds.db.Create(execution).Error
 }
 
-func (ds *DatabaseService) GetExecutions(limit int) ([]models.Execution, error) {
+func (ds *DatabaseService) GetExecutions(filter models.RecordFilter) ([]models.Execution, error) {
 	if ds.db == nil {
 		return []models.Execution{}, nil
 	}
 
+	query := ExecutionsQuery(ds.db, filter).Order("timestamp DESC")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
 	var executions []models.Execution
-	err := ds.db.Order("timestamp DESC").Limit(limit).Find(&executions).Error
+	err := query.Find(&executions).Error
 	return executions, err
 }
 
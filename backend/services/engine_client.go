@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
 	"sync"
 	"time"
 
@@ -358,3 +359,20 @@ func (ec *EngineClient) SendRequest(requestData map[string]interface{}) (map[str
 
 	return response, nil
 }
+
+// EngineNumber reads a numeric field from an engine response map. The engine
+// forwards broker payloads where numbers may be JSON numbers or strings, so
+// each key is tried in order and both representations are accepted.
+func EngineNumber(data map[string]interface{}, keys ...string) (float64, bool) {
+	for _, key := range keys {
+		switch v := data[key].(type) {
+		case float64:
+			return v, true
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// equitySampleInterval is how often equity and P&L are sampled, the width
+// of the finest bucket
+const equitySampleInterval = 1 * time.Minute
+
+// equityBuckets maps each bucket to its width and the range served when no
+// start is given
+var equityBuckets = map[string]struct {
+	width        time.Duration
+	defaultRange time.Duration
+}{
+	models.EquityBucketMinute:     {time.Minute, 24 * time.Hour},
+	models.EquityBucketFiveMinute: {5 * time.Minute, 5 * 24 * time.Hour},
+	models.EquityBucketHour:       {time.Hour, 30 * 24 * time.Hour},
+	models.EquityBucketDay:        {24 * time.Hour, 365 * 24 * time.Hour},
+}
+
+// Hourly and daily buckets are served from continuous aggregates; finer
+// buckets are bucketed from the samples
+const (
+	equitySamplesQuery = `SELECT time_bucket(CAST(? AS interval), timestamp) AS time,
+	first(equity, timestamp) AS equity_open, MAX(equity) AS equity_high, MIN(equity) AS equity_low,
+	last(equity, timestamp) AS equity, last(market_value, timestamp) AS market_value,
+	last(realized_pnl, timestamp) AS realized_pnl, last(unrealized_pnl, timestamp) AS unrealized_pnl,
+	last(total_pnl, timestamp) AS total_pnl, last(daily_pnl, timestamp) AS daily_pnl,
+	last(fees, timestamp) AS fees, COUNT(*) AS samples
+FROM equity_samples WHERE timestamp >= ? AND timestamp < ?
+GROUP BY 1 ORDER BY 1`
+	equityAggregateQuery = `SELECT bucket AS time, equity_open, equity_high, equity_low, equity, market_value,
+	realized_pnl, unrealized_pnl, total_pnl, daily_pnl, fees, samples
+FROM %s WHERE bucket >= ? AND bucket < ? ORDER BY bucket`
+)
+
+// EquityCurve samples account equity and P&L every minute into a
+// hypertable and serves them as a bucketed time series
+type EquityCurve struct {
+	db           *DatabaseService
+	engineClient *EngineClient
+	ledger       *PnLLedger
+	positionBook *PositionBook
+	riskManager  *RiskManager
+	last         *models.EquitySample
+	mu           sync.Mutex
+	ticker       *time.Ticker
+	stopChan     chan bool
+}
+
+// NewEquityCurve creates a new equity curve sampler
+func NewEquityCurve(db *DatabaseService, engineClient *EngineClient, ledger *PnLLedger, positionBook *PositionBook, riskManager *RiskManager) *EquityCurve {
+	return &EquityCurve{
+		db:           db,
+		engineClient: engineClient,
+		ledger:       ledger,
+		positionBook: positionBook,
+		riskManager:  riskManager,
+		stopChan:     make(chan bool),
+	}
+}
+
+// Start takes a sample now and then every minute
+func (ec *EquityCurve) Start() {
+	if db := ec.db.GetDB(); db != nil {
+		var last models.EquitySample
+		if db.Order("timestamp DESC").Limit(1).Find(&last).RowsAffected > 0 {
+			ec.last = &last
+		}
+	}
+	ec.Sample()
+
+	ec.ticker = time.NewTicker(equitySampleInterval)
+
+	go func() {
+		log.Printf("Equity curve started (sampling every %s)", equitySampleInterval)
+
+		for {
+			select {
+			case <-ec.ticker.C:
+				ec.Sample()
+
+			case <-ec.stopChan:
+				log.Println("Equity curve stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the equity curve sampler
+func (ec *EquityCurve) Stop() {
+	if ec.ticker != nil {
+		ec.ticker.Stop()
+	}
+	ec.stopChan <- true
+}
+
+// Sample records current equity and P&L. Equity comes from the broker
+// account; while the engine is unreachable it is carried forward from the
+// previous sample by the change in total P&L.
+func (ec *EquityCurve) Sample() {
+	db := ec.db.GetDB()
+	if db == nil {
+		return // Database disabled
+	}
+
+	realized, _, _ := ec.ledger.Totals()
+	fees := ec.ledger.Fees()
+	sample := models.EquitySample{
+		Timestamp:   time.Now(),
+		RealizedPnL: realized - fees,
+		Fees:        fees,
+	}
+	for _, position := range ec.positionBook.Positions() {
+		sample.UnrealizedPnL += position.UnrealizedPnL
+		sample.MarketValue += position.MarketValue
+	}
+	sample.TotalPnL = sample.RealizedPnL + sample.UnrealizedPnL
+
+	if ec.riskManager != nil {
+		if daily, err := ec.riskManager.GetDailyPnL(); err == nil {
+			sample.DailyPnL = daily.TotalPnL
+		}
+	}
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	equity, ok := ec.accountEquity()
+	if !ok && ec.last == nil {
+		return // Nothing to carry forward yet
+	}
+	sample.Equity = carryEquity(ec.last, sample, equity, ok)
+	sample.Estimated = !ok
+
+	if result := db.Create(&sample); result.Error != nil {
+		log.Printf("Error saving equity sample: %v", result.Error)
+		return
+	}
+	ec.last = &sample
+}
+
+func (ec *EquityCurve) accountEquity() (float64, bool) {
+	if ec.engineClient == nil {
+		return 0, false
+	}
+	response, err := ec.engineClient.GetAccount()
+	if err != nil {
+		return 0, false
+	}
+	account, ok := response["account"].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	return EngineNumber(account, "equity", "portfolio_value")
+}
+
+// carryEquity returns the broker equity when known, or else the previous
+// sample's equity moved by the change in total P&L since
+func carryEquity(last *models.EquitySample, sample models.EquitySample, equity float64, known bool) float64 {
+	if known || last == nil {
+		return equity
+	}
+	return last.Equity + sample.TotalPnL - last.TotalPnL
+}
+
+// ValidateEquitySeries checks a series request and fills in its range. A
+// zero to means now and a zero from the bucket's default range before to.
+func ValidateEquitySeries(bucket string, from, to *time.Time, maxPoints int) error {
+	spec, ok := equityBuckets[bucket]
+	if !ok {
+		return fmt.Errorf("bucket must be %s, %s, %s or %s", models.EquityBucketMinute,
+			models.EquityBucketFiveMinute, models.EquityBucketHour, models.EquityBucketDay)
+	}
+	if to.IsZero() {
+		*to = time.Now()
+	}
+	if from.IsZero() {
+		*from = to.Add(-spec.defaultRange)
+	}
+	if !from.Before(*to) {
+		return fmt.Errorf("from must be before to")
+	}
+	if maxPoints < 0 {
+		return fmt.Errorf("max_points cannot be negative")
+	}
+	return nil
+}
+
+// Series returns the equity curve in buckets between from and to, defaulted
+// as by ValidateEquitySeries. When maxPoints is positive and exceeded,
+// adjacent buckets are merged to fit.
+func (ec *EquityCurve) Series(bucket string, from, to time.Time, maxPoints int) (*models.EquitySeries, error) {
+	if err := ValidateEquitySeries(bucket, &from, &to, maxPoints); err != nil {
+		return nil, err
+	}
+
+	series := &models.EquitySeries{
+		Bucket: bucket,
+		From:   from,
+		To:     to,
+		Points: []models.EquityPoint{},
+	}
+	db := ec.db.GetDB()
+	if db == nil {
+		return series, nil // Database disabled
+	}
+
+	var result error
+	switch bucket {
+	case models.EquityBucketHour:
+		result = db.Raw(fmt.Sprintf(equityAggregateQuery, "equity_hourly"), from, to).Scan(&series.Points).Error
+	case models.EquityBucketDay:
+		result = db.Raw(fmt.Sprintf(equityAggregateQuery, "equity_daily"), from, to).Scan(&series.Points).Error
+	default:
+		interval := fmt.Sprintf("%d seconds", int(equityBuckets[bucket].width.Seconds()))
+		result = db.Raw(equitySamplesQuery, interval, from, to).Scan(&series.Points).Error
+	}
+	if result != nil {
+		return nil, result
+	}
+
+	if maxPoints > 0 && len(series.Points) > maxPoints {
+		series.Points = downsampleEquity(series.Points, maxPoints)
+		series.Downsampled = true
+	}
+	return series, nil
+}
+
+// downsampleEquity merges runs of adjacent buckets so that at most
+// maxPoints remain. Merged buckets keep the first open, the highest high,
+// the lowest low and the last close and P&L, so drawdowns are not lost.
+func downsampleEquity(points []models.EquityPoint, maxPoints int) []models.EquityPoint {
+	if maxPoints <= 0 || len(points) <= maxPoints {
+		return points
+	}
+	size := (len(points) + maxPoints - 1) / maxPoints
+
+	merged := make([]models.EquityPoint, 0, maxPoints)
+	for start := 0; start < len(points); start += size {
+		end := start + size
+		if end > len(points) {
+			end = len(points)
+		}
+
+		point := points[end-1]
+		point.Time = points[start].Time
+		point.EquityOpen = points[start].EquityOpen
+		point.Samples = 0
+		for _, p := range points[start:end] {
+			if p.EquityHigh > point.EquityHigh {
+				point.EquityHigh = p.EquityHigh
+			}
+			if p.EquityLow < point.EquityLow {
+				point.EquityLow = p.EquityLow
+			}
+			point.Samples += p.Samples
+		}
+		merged = append(merged, point)
+	}
+	return merged
+}
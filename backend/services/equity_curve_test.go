@@ -0,0 +1,92 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func equityPoint(minute int, open, high, low, close float64) models.EquityPoint {
+	return models.EquityPoint{
+		Time:       time.Date(2024, 3, 4, 14, minute, 0, 0, time.UTC),
+		EquityOpen: open,
+		EquityHigh: high,
+		EquityLow:  low,
+		Equity:     close,
+		TotalPnL:   close - 100000,
+		Samples:    1,
+	}
+}
+
+func TestDownsampleEquity(t *testing.T) {
+	points := []models.EquityPoint{
+		equityPoint(0, 100000, 100050, 99990, 100020),
+		equityPoint(1, 100020, 100030, 99500, 99600),
+		equityPoint(2, 99600, 100200, 99600, 100100),
+		equityPoint(3, 100100, 100150, 100000, 100010),
+		equityPoint(4, 100010, 100020, 99900, 99950),
+	}
+
+	merged := downsampleEquity(points, 2)
+	if len(merged) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(merged))
+	}
+
+	first := merged[0]
+	if !first.Time.Equal(points[0].Time) || first.EquityOpen != 100000 {
+		t.Errorf("expected the first bucket's time and open, got %s %.2f", first.Time, first.EquityOpen)
+	}
+	if first.EquityHigh != 100200 || first.EquityLow != 99500 {
+		t.Errorf("expected high 100200 and low 99500, got %.2f and %.2f", first.EquityHigh, first.EquityLow)
+	}
+	if first.Equity != 100100 || first.TotalPnL != 100 {
+		t.Errorf("expected the last bucket's close and P&L, got %.2f and %.2f", first.Equity, first.TotalPnL)
+	}
+	if first.Samples != 3 || merged[1].Samples != 2 {
+		t.Errorf("expected 3 and 2 samples, got %d and %d", first.Samples, merged[1].Samples)
+	}
+	if merged[1].EquityOpen != 100100 || merged[1].Equity != 99950 {
+		t.Errorf("expected open 100100 and close 99950, got %.2f and %.2f", merged[1].EquityOpen, merged[1].Equity)
+	}
+
+	if unchanged := downsampleEquity(points, 0); len(unchanged) != len(points) {
+		t.Errorf("expected no downsampling without a limit, got %d points", len(unchanged))
+	}
+}
+
+func TestCarryEquity(t *testing.T) {
+	last := &models.EquitySample{Equity: 100000, TotalPnL: 250}
+	sample := models.EquitySample{TotalPnL: 400}
+
+	if equity := carryEquity(last, sample, 100500, true); equity != 100500 {
+		t.Errorf("expected the broker equity, got %.2f", equity)
+	}
+	if equity := carryEquity(last, sample, 0, false); !closeTo(equity, 100150) {
+		t.Errorf("expected equity carried by the P&L change, got %.2f", equity)
+	}
+}
+
+func TestValidateEquitySeries(t *testing.T) {
+	var from, to time.Time
+	if err := ValidateEquitySeries("2m", &from, &to, 0); err == nil {
+		t.Error("expected an unknown bucket to be rejected")
+	}
+
+	if err := ValidateEquitySeries(models.EquityBucketHour, &from, &to, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if to.IsZero() || to.Sub(from) != 30*24*time.Hour {
+		t.Errorf("expected the hourly default range of 30 days, got %s", to.Sub(from))
+	}
+
+	from, to = to, from
+	if err := ValidateEquitySeries(models.EquityBucketHour, &from, &to, 0); err == nil {
+		t.Error("expected from after to to be rejected")
+	}
+
+	from, to = to, from
+	if err := ValidateEquitySeries(models.EquityBucketMinute, &from, &to, -1); err == nil {
+		t.Error("expected negative max_points to be rejected")
+	}
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hft/backend/models"
+	"gorm.io/gorm"
+)
+
+// exportDataset is the column schema of a dataset, the query its rows are
+// read from, oldest first, and how a row is scanned into cells
+type exportDataset struct {
+	columns []models.ExportColumn
+	query   func(db *gorm.DB, filter models.RecordFilter) *gorm.DB
+	scan    func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error)
+}
+
+var exportDatasets = map[string]exportDataset{
+	models.ExportOrders: {
+		columns: []models.ExportColumn{
+			{Name: "id", Type: models.ExportInteger},
+			{Name: "client_order_id", Type: models.ExportString},
+			{Name: "order_id", Type: models.ExportString},
+			{Name: "symbol", Type: models.ExportString},
+			{Name: "side", Type: models.ExportString},
+			{Name: "order_type", Type: models.ExportString},
+			{Name: "status", Type: models.ExportString},
+			{Name: "quantity", Type: models.ExportNumber},
+			{Name: "price", Type: models.ExportNumber},
+			{Name: "filled_qty", Type: models.ExportNumber},
+			{Name: "remaining_qty", Type: models.ExportNumber},
+			{Name: "strategy", Type: models.ExportString},
+			{Name: "trader", Type: models.ExportString},
+			{Name: "account", Type: models.ExportString},
+			{Name: "tags", Type: models.ExportString},
+			{Name: "arrival_price", Type: models.ExportNumber},
+			{Name: "arrival_bid", Type: models.ExportNumber},
+			{Name: "arrival_ask", Type: models.ExportNumber},
+			{Name: "created_at", Type: models.ExportTimestamp},
+			{Name: "updated_at", Type: models.ExportTimestamp},
+		},
+		query: func(db *gorm.DB, filter models.RecordFilter) *gorm.DB {
+			return OrdersQuery(db, filter).Order("created_at, id")
+		},
+		scan: func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error) {
+			var order models.Order
+			if err := db.ScanRows(rows, &order); err != nil {
+				return nil, err
+			}
+			return orderRow(order), nil
+		},
+	},
+	models.ExportExecutions: {
+		columns: []models.ExportColumn{
+			{Name: "id", Type: models.ExportInteger},
+			{Name: "order_id", Type: models.ExportString},
+			{Name: "client_order_id", Type: models.ExportString},
+			{Name: "symbol", Type: models.ExportString},
+			{Name: "side", Type: models.ExportString},
+			{Name: "fill_qty", Type: models.ExportNumber},
+			{Name: "fill_price", Type: models.ExportNumber},
+			{Name: "strategy", Type: models.ExportString},
+			{Name: "trader", Type: models.ExportString},
+			{Name: "account", Type: models.ExportString},
+			{Name: "tags", Type: models.ExportString},
+			{Name: "timestamp", Type: models.ExportTimestamp},
+			{Name: "created_at", Type: models.ExportTimestamp},
+		},
+		query: func(db *gorm.DB, filter models.RecordFilter) *gorm.DB {
+			return ExecutionsQuery(db, filter).Order("timestamp, id")
+		},
+		scan: func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error) {
+			var execution models.Execution
+			if err := db.ScanRows(rows, &execution); err != nil {
+				return nil, err
+			}
+			return executionRow(execution), nil
+		},
+	},
+	models.ExportRiskAlerts: {
+		columns: []models.ExportColumn{
+			{Name: "id", Type: models.ExportInteger},
+			{Name: "created_at", Type: models.ExportTimestamp},
+			{Name: "alert_type", Type: models.ExportString},
+			{Name: "severity", Type: models.ExportString},
+			{Name: "symbol", Type: models.ExportString},
+			{Name: "message", Type: models.ExportString},
+			{Name: "metadata", Type: models.ExportString},
+		},
+		query: func(db *gorm.DB, filter models.RecordFilter) *gorm.DB {
+			return RiskAlertsQuery(db, filter).Order("created_at, id")
+		},
+		scan: func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error) {
+			var alert models.RiskAlert
+			if err := db.ScanRows(rows, &alert); err != nil {
+				return nil, err
+			}
+			return riskAlertRow(alert), nil
+		},
+	},
+	models.ExportDailyPnL: {
+		columns: []models.ExportColumn{
+			{Name: "date", Type: models.ExportString},
+			{Name: "gross_pnl", Type: models.ExportNumber},
+			{Name: "fees", Type: models.ExportNumber},
+			{Name: "pnl", Type: models.ExportNumber},
+			{Name: "count", Type: models.ExportInteger},
+		},
+		query: func(db *gorm.DB, filter models.RecordFilter) *gorm.DB {
+			return DailyPnLQuery(db, filter).Order("date")
+		},
+		scan: func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error) {
+			var day models.DailyRealizedPnL
+			if err := db.ScanRows(rows, &day); err != nil {
+				return nil, err
+			}
+			return dailyPnLRow(day), nil
+		},
+	},
+	models.ExportMoversPositions: {
+		columns: []models.ExportColumn{
+			{Name: "id", Type: models.ExportInteger},
+			{Name: "symbol", Type: models.ExportString},
+			{Name: "status", Type: models.ExportString},
+			{Name: "strategy_type", Type: models.ExportString},
+			{Name: "purchase_time", Type: models.ExportTimestamp},
+			{Name: "purchase_price", Type: models.ExportNumber},
+			{Name: "purchase_quantity", Type: models.ExportNumber},
+			{Name: "purchase_order_id", Type: models.ExportString},
+			{Name: "sell_time", Type: models.ExportTimestamp},
+			{Name: "sell_price", Type: models.ExportNumber},
+			{Name: "sell_quantity", Type: models.ExportNumber},
+			{Name: "sell_order_id", Type: models.ExportString},
+			{Name: "profit_loss", Type: models.ExportNumber},
+			{Name: "profit_pct", Type: models.ExportNumber},
+			{Name: "created_at", Type: models.ExportTimestamp},
+			{Name: "updated_at", Type: models.ExportTimestamp},
+		},
+		query: func(db *gorm.DB, filter models.RecordFilter) *gorm.DB {
+			return MoversPositionsQuery(db, filter).Order("purchase_time, id")
+		},
+		scan: func(db *gorm.DB, rows *sql.Rows) ([]interface{}, error) {
+			var position models.MoversPosition
+			if err := db.ScanRows(rows, &position); err != nil {
+				return nil, err
+			}
+			return moversPositionRow(position), nil
+		},
+	},
+}
+
+// Exporter streams datasets to CSV, XLSX or Parquet files
+type Exporter struct {
+	db *DatabaseService
+}
+
+// NewExporter creates a new exporter
+func NewExporter(db *DatabaseService) *Exporter {
+	return &Exporter{db: db}
+}
+
+// ExportSchemas returns the columns of every dataset
+func ExportSchemas() map[string][]models.ExportColumn {
+	schemas := make(map[string][]models.ExportColumn, len(exportDatasets))
+	for name, dataset := range exportDatasets {
+		schemas[name] = dataset.columns
+	}
+	return schemas
+}
+
+// ValidateExport checks a dataset and format
+func ValidateExport(dataset, format string) error {
+	if _, ok := exportDatasets[dataset]; !ok {
+		return fmt.Errorf("dataset must be %s, %s, %s, %s or %s", models.ExportOrders, models.ExportExecutions,
+			models.ExportRiskAlerts, models.ExportDailyPnL, models.ExportMoversPositions)
+	}
+	switch format {
+	case models.ExportCSV, models.ExportXLSX, models.ExportParquet:
+	default:
+		return fmt.Errorf("format must be %s, %s or %s", models.ExportCSV, models.ExportXLSX, models.ExportParquet)
+	}
+	return nil
+}
+
+// ValidateRecordFilter normalises a filter and checks its range and limit
+func ValidateRecordFilter(filter *models.RecordFilter) error {
+	filter.Symbol = strings.ToUpper(strings.TrimSpace(filter.Symbol))
+	filter.Side = strings.ToUpper(strings.TrimSpace(filter.Side))
+	switch filter.Side {
+	case "", "BUY", "SELL":
+	default:
+		return fmt.Errorf("side must be BUY or SELL")
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	if filter.Limit < 0 {
+		return fmt.Errorf("limit cannot be negative")
+	}
+	return nil
+}
+
+// Export writes the rows of a dataset matching filter to w, oldest first,
+// one row at a time, and returns how many were written. Nothing is written
+// when the dataset cannot be read.
+func (ex *Exporter) Export(dataset, format string, filter models.RecordFilter, w io.Writer) (int, error) {
+	if err := ValidateExport(dataset, format); err != nil {
+		return 0, err
+	}
+	if err := ValidateRecordFilter(&filter); err != nil {
+		return 0, err
+	}
+	db := ex.db.GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("database unavailable")
+	}
+
+	spec := exportDatasets[dataset]
+	query := spec.query(db, filter)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	writer, err := newRowWriter(format, w, dataset, spec.columns)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for rows.Next() {
+		row, err := spec.scan(db, rows)
+		if err != nil {
+			return count, err
+		}
+		if err := writer.WriteRow(row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, writer.Close()
+}
+
+// OrdersQuery restricts orders to a record filter on their creation time
+func OrdersQuery(db *gorm.DB, filter models.RecordFilter) *gorm.DB {
+	return filterRecords(db.Model(&models.Order{}), "created_at", filter,
+		"symbol", "side", "status", "strategy", "trader", "account", "tag")
+}
+
+// ExecutionsQuery restricts executions to a record filter on their fill time
+func ExecutionsQuery(db *gorm.DB, filter models.RecordFilter) *gorm.DB {
+	return filterRecords(db.Model(&models.Execution{}), "timestamp", filter,
+		"symbol", "side", "strategy", "trader", "account", "tag")
+}
+
+// RiskAlertsQuery restricts risk alerts to a record filter on when they were
+// raised
+func RiskAlertsQuery(db *gorm.DB, filter models.RecordFilter) *gorm.DB {
+	return filterRecords(db.Model(&models.RiskAlert{}), "created_at", filter,
+		"symbol", "alert_type", "severity")
+}
+
+// DailyPnLQuery sums the realised P&L of the executions matching a record
+// filter by fill date
+func DailyPnLQuery(db *gorm.DB, filter models.RecordFilter) *gorm.DB {
+	return filterRecords(db.Model(&models.ExecutionPnL{}), "timestamp", filter, "symbol", "side").
+		Select("TO_CHAR(DATE(timestamp), 'YYYY-MM-DD') as date, SUM(net_pnl) as pnl, " +
+			"SUM(realized_pnl) as gross_pnl, SUM(fees) as fees, COUNT(*) as count").
+		Group("DATE(timestamp)")
+}
+
+// MoversPositionsQuery restricts movers strategy positions to a record filter
+// on their purchase time
+func MoversPositionsQuery(db *gorm.DB, filter models.RecordFilter) *gorm.DB {
+	return filterRecords(db.Model(&models.MoversPosition{}), "purchase_time", filter, "symbol", "status")
+}
+
+// filterRecords applies the time range of a filter to timeColumn and the
+// named fields of the filter to their columns. Enumerated fields match
+// regardless of case.
+func filterRecords(tx *gorm.DB, timeColumn string, filter models.RecordFilter, fields ...string) *gorm.DB {
+	if filter.From != nil {
+		tx = tx.Where(timeColumn+" >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		tx = tx.Where(timeColumn+" < ?", *filter.To)
+	}
+
+	for _, field := range fields {
+		switch field {
+		case "symbol":
+			if filter.Symbol != "" {
+				tx = tx.Where("symbol = ?", filter.Symbol)
+			}
+		case "trader":
+			if filter.Trader != "" {
+				tx = tx.Where("trader = ?", filter.Trader)
+			}
+		case "account":
+			if filter.Account != "" {
+				tx = tx.Where("account = ?", filter.Account)
+			}
+		case "strategy":
+			tx = whereStrategy(tx, filter.Strategy)
+		case "tag":
+			tx = whereTag(tx, filter.Tag)
+		default:
+			values := map[string]string{
+				"side":       filter.Side,
+				"status":     filter.Status,
+				"alert_type": filter.AlertType,
+				"severity":   filter.Severity,
+			}
+			if value := values[field]; value != "" {
+				tx = tx.Where("UPPER("+field+") = UPPER(?)", value)
+			}
+		}
+	}
+	return tx
+}
+
+func orderRow(order models.Order) []interface{} {
+	return []interface{}{
+		int64(order.ID), order.ClientOrderID, order.OrderID, order.Symbol, order.Side, order.OrderType, order.Status,
+		order.Quantity, order.Price, order.FilledQty, order.RemainingQty,
+		order.Strategy, order.Trader, order.Account, strings.Join(order.Tags, ","),
+		order.ArrivalPrice, order.ArrivalBid, order.ArrivalAsk,
+		exportTime(order.CreatedAt), exportTime(order.UpdatedAt),
+	}
+}
+
+func executionRow(execution models.Execution) []interface{} {
+	return []interface{}{
+		int64(execution.ID), execution.OrderID, execution.ClientOrderID, execution.Symbol, execution.Side,
+		execution.FillQty, execution.FillPrice,
+		execution.Strategy, execution.Trader, execution.Account, strings.Join(execution.Tags, ","),
+		exportTime(execution.Timestamp), exportTime(execution.CreatedAt),
+	}
+}
+
+func riskAlertRow(alert models.RiskAlert) []interface{} {
+	return []interface{}{
+		int64(alert.ID), exportTime(alert.CreatedAt), alert.AlertType, alert.Severity, alert.Symbol,
+		alert.Message, alert.Metadata,
+	}
+}
+
+func dailyPnLRow(day models.DailyRealizedPnL) []interface{} {
+	return []interface{}{day.Date, day.GrossPnL, day.Fees, day.PnL, day.Count}
+}
+
+func moversPositionRow(position models.MoversPosition) []interface{} {
+	return []interface{}{
+		int64(position.ID), position.Symbol, position.Status, position.StrategyType,
+		exportTime(position.PurchaseTime), position.PurchasePrice, position.PurchaseQuantity, position.PurchaseOrderID,
+		exportTimePtr(position.SellTime), exportFloat(position.SellPrice), exportFloat(position.SellQuantity),
+		exportString(position.SellOrderID), exportFloat(position.ProfitLoss), exportFloat(position.ProfitPct),
+		exportTime(position.CreatedAt), exportTime(position.UpdatedAt),
+	}
+}
+
+// exportTime leaves unset times empty rather than year one
+func exportTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func exportTimePtr(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return exportTime(*t)
+}
+
+func exportFloat(value *float64) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func exportString(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
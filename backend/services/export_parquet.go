@@ -0,0 +1,368 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// parquetRowGroupRows is how many rows are buffered before a row group is
+// written, which bounds the memory an export holds
+const parquetRowGroupRows = 10000
+
+// Parquet format enums
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetOptional        = 1
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+
+	parquetPlain    = 0
+	parquetRLE      = 3
+	parquetDataPage = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn buffers the values of one column for the current row group.
+// Every column is optional so that missing values are null.
+type parquetColumn struct {
+	name      string
+	physical  int32
+	converted int32 // -1 for none
+	defined   []bool
+	values    bytes.Buffer
+	flags     []bool // Boolean values, bit-packed when written
+}
+
+// parquetChunk locates a column chunk written to the file
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+type parquetRowGroup struct {
+	rows   int64
+	chunks []parquetChunk
+}
+
+// parquetRowWriter writes an uncompressed, plain-encoded Parquet file, one
+// data page per column per row group. Timestamps are microseconds in UTC.
+type parquetRowWriter struct {
+	w       io.Writer
+	offset  int64
+	err     error
+	columns []*parquetColumn
+	rows    int
+	groups  []parquetRowGroup
+}
+
+func newParquetRowWriter(w io.Writer, columns []models.ExportColumn) *parquetRowWriter {
+	pw := &parquetRowWriter{w: w}
+	for _, column := range columns {
+		pc := &parquetColumn{name: column.Name, converted: -1}
+		switch column.Type {
+		case models.ExportInteger:
+			pc.physical = parquetInt64
+		case models.ExportNumber:
+			pc.physical = parquetDouble
+		case models.ExportBoolean:
+			pc.physical = parquetBoolean
+		case models.ExportTimestamp:
+			pc.physical, pc.converted = parquetInt64, parquetTimestampMicros
+		default:
+			pc.physical, pc.converted = parquetByteArray, parquetUTF8
+		}
+		pw.columns = append(pw.columns, pc)
+	}
+	pw.write(parquetMagic)
+	return pw
+}
+
+func (pw *parquetRowWriter) write(p []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(p)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+func (pw *parquetRowWriter) WriteRow(row []interface{}) error {
+	for i, cell := range row {
+		if err := pw.columns[i].add(cell); err != nil {
+			return err
+		}
+	}
+	pw.rows++
+	if pw.rows >= parquetRowGroupRows {
+		pw.flushRowGroup()
+	}
+	return pw.err
+}
+
+func (pc *parquetColumn) add(cell interface{}) error {
+	if cell == nil {
+		pc.defined = append(pc.defined, false)
+		return nil
+	}
+
+	var buf [8]byte
+	switch value := cell.(type) {
+	case string:
+		if pc.physical != parquetByteArray {
+			return fmt.Errorf("column %s: unexpected string", pc.name)
+		}
+		binary.LittleEndian.PutUint32(buf[:4], uint32(len(value)))
+		pc.values.Write(buf[:4])
+		pc.values.WriteString(value)
+	case int64:
+		if pc.physical != parquetInt64 || pc.converted != -1 {
+			return fmt.Errorf("column %s: unexpected integer", pc.name)
+		}
+		binary.LittleEndian.PutUint64(buf[:], uint64(value))
+		pc.values.Write(buf[:])
+	case float64:
+		if pc.physical != parquetDouble {
+			return fmt.Errorf("column %s: unexpected number", pc.name)
+		}
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(value))
+		pc.values.Write(buf[:])
+	case bool:
+		if pc.physical != parquetBoolean {
+			return fmt.Errorf("column %s: unexpected boolean", pc.name)
+		}
+		pc.flags = append(pc.flags, value)
+	case time.Time:
+		if pc.converted != parquetTimestampMicros {
+			return fmt.Errorf("column %s: unexpected timestamp", pc.name)
+		}
+		binary.LittleEndian.PutUint64(buf[:], uint64(value.UnixMicro()))
+		pc.values.Write(buf[:])
+	default:
+		return fmt.Errorf("column %s: unsupported value %T", pc.name, cell)
+	}
+	pc.defined = append(pc.defined, true)
+	return nil
+}
+
+// page returns the data page of the buffered values: the definition levels,
+// run-length encoded behind their length, then the plain values
+func (pc *parquetColumn) page() []byte {
+	var levels []byte
+	for start := 0; start < len(pc.defined); {
+		end := start
+		for end < len(pc.defined) && pc.defined[end] == pc.defined[start] {
+			end++
+		}
+		levels = binary.AppendUvarint(levels, uint64(end-start)<<1)
+		if pc.defined[start] {
+			levels = append(levels, 1)
+		} else {
+			levels = append(levels, 0)
+		}
+		start = end
+	}
+
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	page = append(page, levels...)
+	if pc.physical == parquetBoolean {
+		packed := make([]byte, (len(pc.flags)+7)/8)
+		for i, flag := range pc.flags {
+			if flag {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		return append(page, packed...)
+	}
+	return append(page, pc.values.Bytes()...)
+}
+
+func (pw *parquetRowWriter) flushRowGroup() {
+	if pw.rows == 0 {
+		return
+	}
+
+	group := parquetRowGroup{rows: int64(pw.rows)}
+	for _, pc := range pw.columns {
+		page := pc.page()
+		var header thriftWriter
+		header.begin()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.beginStruct(5)
+		header.i32(1, int32(pw.rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+
+		chunk := parquetChunk{offset: pw.offset, size: int64(header.buf.Len() + len(page))}
+		pw.write(header.buf.Bytes())
+		pw.write(page)
+		group.chunks = append(group.chunks, chunk)
+
+		pc.defined = pc.defined[:0]
+		pc.flags = pc.flags[:0]
+		pc.values.Reset()
+	}
+	pw.groups = append(pw.groups, group)
+	pw.rows = 0
+}
+
+// Close writes the last row group and the footer
+func (pw *parquetRowWriter) Close() error {
+	pw.flushRowGroup()
+
+	var total int64
+	for _, group := range pw.groups {
+		total += group.rows
+	}
+
+	var meta thriftWriter
+	meta.begin()
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(pw.columns)+1)
+	meta.begin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(pw.columns)))
+	meta.end()
+	for _, pc := range pw.columns {
+		meta.begin()
+		meta.i32(1, pc.physical)
+		meta.i32(3, parquetOptional)
+		meta.binary(4, pc.name)
+		if pc.converted >= 0 {
+			meta.i32(6, pc.converted)
+		}
+		meta.end()
+	}
+	meta.i64(3, total)
+	meta.list(4, thriftStruct, len(pw.groups))
+	for _, group := range pw.groups {
+		var size int64
+		meta.begin()
+		meta.list(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			pc := pw.columns[i]
+			size += chunk.size
+			meta.begin()
+			meta.i64(2, chunk.offset)
+			meta.beginStruct(3)
+			meta.i32(1, pc.physical)
+			meta.list(2, thriftI32, 2)
+			meta.varint(zigzag(parquetPlain))
+			meta.varint(zigzag(parquetRLE))
+			meta.list(3, thriftBinary, 1)
+			meta.varint(uint64(len(pc.name)))
+			meta.buf.WriteString(pc.name)
+			meta.i32(4, 0) // Uncompressed
+			meta.i64(5, group.rows)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.end()
+			meta.end()
+		}
+		meta.i64(2, size)
+		meta.i64(3, group.rows)
+		meta.end()
+	}
+	meta.binary(6, "hft-backend export")
+	meta.end()
+
+	pw.write(meta.buf.Bytes())
+	pw.write(binary.LittleEndian.AppendUint32(nil, uint32(meta.buf.Len())))
+	pw.write(parquetMagic)
+	return pw.err
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Parquet page headers and footer in the Thrift
+// compact protocol
+type thriftWriter struct {
+	buf   bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+// begin starts a struct that is not a field, such as a list element
+func (t *thriftWriter) begin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) field(id int16, kind byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.buf.WriteByte(kind)
+		t.varint(zigzag(int64(id)))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, value int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(value)))
+}
+
+func (t *thriftWriter) i64(id int16, value int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(value))
+}
+
+func (t *thriftWriter) binary(id int16, value string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(value)))
+	t.buf.WriteString(value)
+}
+
+// list starts a list field; its elements follow
+func (t *thriftWriter) list(id int16, kind byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | kind)
+		return
+	}
+	t.buf.WriteByte(0xF0 | kind)
+	t.varint(uint64(size))
+}
+
+func (t *thriftWriter) varint(value uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, value))
+}
+
+func zigzag(value int64) uint64 {
+	return uint64(value<<1) ^ uint64(value>>63)
+}
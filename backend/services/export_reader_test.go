@@ -0,0 +1,552 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// The readers in this file decode export files from the Parquet and
+// SpreadsheetML specs and share no code with the writers, so the round trips
+// below check the files rather than the writers against themselves.
+
+// roundTripRows returns rows with a null in every column and enough of them
+// to fill more than one Parquet row group
+func roundTripRows() [][]interface{} {
+	at := time.Date(2024, 3, 4, 14, 30, 0, 123456000, time.UTC)
+	rows := [][]interface{}{
+		testExportRow(),
+		{int64(-8), nil, nil, false, nil},
+		{nil, "ünïcode ✓", -0.5, nil, at},
+	}
+	for i := len(rows); i < parquetRowGroupRows+5; i++ {
+		rows = append(rows, []interface{}{int64(i), "S" + strconv.Itoa(i%7), float64(i) / 4, i%3 == 0, at.Add(time.Duration(i) * time.Second)})
+	}
+	return rows
+}
+
+func writeExport(t *testing.T, format string, rows [][]interface{}) []byte {
+	var buf bytes.Buffer
+	w, err := newRowWriter(format, &buf, "orders", testExportColumns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	rows := roundTripRows()
+	names, got, err := readParquet(writeExport(t, models.ExportParquet, rows))
+	if err != nil {
+		t.Fatalf("failed to read the parquet file: %v", err)
+	}
+
+	if want := []string{"id", "symbol", "price", "active", "at"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected columns %v, got %v", want, names)
+	}
+	if len(got) != len(rows) {
+		t.Fatalf("expected %d rows, got %d", len(rows), len(got))
+	}
+	for i := range rows {
+		if !reflect.DeepEqual(got[i], rows[i]) {
+			t.Fatalf("row %d: expected %v, got %v", i, rows[i], got[i])
+		}
+	}
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	rows := roundTripRows()[:3]
+	sheet, got, err := readXLSX(writeExport(t, models.ExportXLSX, rows))
+	if err != nil {
+		t.Fatalf("failed to read the workbook: %v", err)
+	}
+
+	if sheet != "orders" {
+		t.Errorf("expected sheet orders, got %q", sheet)
+	}
+	if want := []interface{}{"id", "symbol", "price", "active", "at"}; !reflect.DeepEqual(got[0], want) {
+		t.Errorf("expected header %v, got %v", want, got[0])
+	}
+	if len(got) != len(rows)+1 {
+		t.Fatalf("expected %d rows, got %d", len(rows)+1, len(got))
+	}
+	for i, row := range rows {
+		want := make([]interface{}, len(row))
+		for j, cell := range row {
+			// Spreadsheets keep numbers as doubles and dates to the millisecond
+			switch value := cell.(type) {
+			case int64:
+				want[j] = float64(value)
+			case time.Time:
+				want[j] = value.Round(time.Millisecond)
+			default:
+				want[j] = cell
+			}
+		}
+		if !reflect.DeepEqual(got[i+1], want) {
+			t.Errorf("row %d: expected %v, got %v", i+1, want, got[i+1])
+		}
+	}
+}
+
+// TestExportFilesOpenInPython reads the files with pyarrow and openpyxl when
+// they are installed
+func TestExportFilesOpenInPython(t *testing.T) {
+	dir := t.TempDir()
+	rows := roundTripRows()
+
+	cases := []struct {
+		format, module, script string
+		rows                   int
+	}{
+		{models.ExportParquet, "pyarrow", `
+import json, sys, pyarrow.parquet as pq
+table = pq.read_table(sys.argv[1])
+first = table.slice(0, 1).to_pylist()[0]
+print(json.dumps({"rows": table.num_rows, "columns": table.column_names,
+	"first": [first["id"], first["symbol"], first["price"], first["active"], first["at"].strftime("%Y-%m-%dT%H:%M:%S")]}))
+`, len(rows)},
+		{models.ExportXLSX, "openpyxl", `
+import json, sys, openpyxl
+sheet = openpyxl.load_workbook(sys.argv[1], read_only=True).active
+values = list(sheet.iter_rows(values_only=True))
+first = values[1]
+print(json.dumps({"rows": len(values) - 1, "columns": list(values[0]),
+	"first": [int(first[0]), first[1], first[2], first[3], first[4].strftime("%Y-%m-%dT%H:%M:%S")]}))
+`, 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.format, func(t *testing.T) {
+			if exec.Command("python3", "-c", "import "+tc.module).Run() != nil {
+				t.Skipf("python3 with %s is not installed", tc.module)
+			}
+
+			file := filepath.Join(dir, "export."+tc.format)
+			if err := os.WriteFile(file, writeExport(t, tc.format, rows[:tc.rows]), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			out, err := exec.Command("python3", "-c", tc.script, file).CombinedOutput()
+			if err != nil {
+				t.Fatalf("%s could not read the file: %v\n%s", tc.module, err, out)
+			}
+
+			var result struct {
+				Rows    int           `json:"rows"`
+				Columns []string      `json:"columns"`
+				First   []interface{} `json:"first"`
+			}
+			if err := json.Unmarshal(out, &result); err != nil {
+				t.Fatalf("unexpected output %s: %v", out, err)
+			}
+			want := []interface{}{7.0, `A&B "X"`, 101.25, true, "2024-03-04T14:30:00"}
+			if result.Rows != tc.rows || len(result.Columns) != len(testExportColumns) || !reflect.DeepEqual(result.First, want) {
+				t.Errorf("unexpected result %+v", result)
+			}
+		})
+	}
+}
+
+// thriftReader decodes the Thrift compact protocol into maps of field ID to
+// value. Integers are int64, binaries are strings and lists are slices.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	if r.pos >= len(r.data) {
+		panic("thrift: unexpected end of data")
+	}
+	r.pos++
+	return r.data[r.pos-1]
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic("thrift: bad varint")
+	}
+	r.pos += n
+	return value
+}
+
+func (r *thriftReader) varint() int64 {
+	value := r.uvarint()
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func (r *thriftReader) value(kind byte) interface{} {
+	switch kind {
+	case 1, 2: // Booleans inside a list
+		return r.byte() == 1
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.varint()
+	case 7:
+		r.pos += 8
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos-8:]))
+	case 8:
+		n := int(r.uvarint())
+		r.pos += n
+		return string(r.data[r.pos-n : r.pos])
+	case 9, 10:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case 12:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("thrift: unsupported type %d", kind))
+}
+
+func (r *thriftReader) structure() map[int64]interface{} {
+	fields := map[int64]interface{}{}
+	var id int64
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		if delta := int64(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = r.varint()
+		}
+		switch kind := header & 0x0f; kind {
+		case 1, 2:
+			fields[id] = kind == 1
+		default:
+			fields[id] = r.value(kind)
+		}
+	}
+}
+
+// readParquet decodes a file of optional flat columns with uncompressed,
+// plain-encoded v1 data pages
+func readParquet(file []byte) (names []string, rows [][]interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	if len(file) < 12 || string(file[:4]) != "PAR1" || string(file[len(file)-4:]) != "PAR1" {
+		return nil, nil, fmt.Errorf("missing PAR1 magic")
+	}
+	size := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := &thriftReader{data: file[len(file)-8-size : len(file)-8]}
+	meta := footer.structure()
+	if footer.pos != size {
+		return nil, nil, fmt.Errorf("footer is %d bytes, decoded %d", size, footer.pos)
+	}
+
+	// Schema: the root, then one leaf per column
+	schema := meta[2].([]interface{})
+	root := schema[0].(map[int64]interface{})
+	if int(root[5].(int64)) != len(schema)-1 {
+		return nil, nil, fmt.Errorf("root has %v children for %d columns", root[5], len(schema)-1)
+	}
+	types := make([]int64, len(schema)-1)
+	converted := make([]int64, len(schema)-1)
+	for i, element := range schema[1:] {
+		leaf := element.(map[int64]interface{})
+		if leaf[3].(int64) != 1 {
+			return nil, nil, fmt.Errorf("column %v is not optional", leaf[4])
+		}
+		names = append(names, leaf[4].(string))
+		types[i] = leaf[1].(int64)
+		converted[i] = -1
+		if c, ok := leaf[6]; ok {
+			converted[i] = c.(int64)
+		}
+	}
+
+	for _, g := range meta[4].([]interface{}) {
+		group := g.(map[int64]interface{})
+		groupRows := int(group[3].(int64))
+		start := len(rows)
+		for i := 0; i < groupRows; i++ {
+			rows = append(rows, make([]interface{}, len(names)))
+		}
+
+		for c, chunk := range group[1].([]interface{}) {
+			columnMeta := chunk.(map[int64]interface{})[3].(map[int64]interface{})
+			if columnMeta[4].(int64) != 0 {
+				return nil, nil, fmt.Errorf("column %s is compressed", names[c])
+			}
+			offset := int(columnMeta[9].(int64))
+			header := &thriftReader{data: file[offset:]}
+			page := header.structure()
+			if page[1].(int64) != 0 {
+				return nil, nil, fmt.Errorf("column %s: expected a data page", names[c])
+			}
+			dataPage := page[5].(map[int64]interface{})
+			count := int(dataPage[1].(int64))
+			if count != groupRows || dataPage[2].(int64) != 0 || dataPage[3].(int64) != 3 {
+				return nil, nil, fmt.Errorf("column %s: unexpected page header %v", names[c], dataPage)
+			}
+			body := file[offset+header.pos : offset+header.pos+int(page[3].(int64))]
+
+			// Definition levels: 4-byte length, then the RLE/bit-packed hybrid
+			levelsLen := int(binary.LittleEndian.Uint32(body))
+			levels := &thriftReader{data: body[4 : 4+levelsLen]}
+			defined := []bool{}
+			for len(defined) < count {
+				run := levels.uvarint()
+				if run&1 == 0 {
+					value := levels.byte() == 1
+					for i := uint64(0); i < run>>1; i++ {
+						defined = append(defined, value)
+					}
+					continue
+				}
+				for i := uint64(0); i < (run>>1)*8; i++ {
+					if i%8 == 0 {
+						levels.byte()
+					}
+					defined = append(defined, levels.data[levels.pos-1]>>(i%8)&1 == 1)
+				}
+			}
+
+			values := body[4+levelsLen:]
+			bit := 0
+			for i := 0; i < count; i++ {
+				if !defined[i] {
+					continue
+				}
+				var cell interface{}
+				switch types[c] {
+				case 0: // BOOLEAN, bit-packed
+					cell = values[bit/8]>>(bit%8)&1 == 1
+					bit++
+				case 2: // INT64
+					v := int64(binary.LittleEndian.Uint64(values))
+					values = values[8:]
+					cell = v
+					if converted[c] == 10 { // TIMESTAMP_MICROS
+						cell = time.UnixMicro(v).UTC()
+					}
+				case 5: // DOUBLE
+					cell = math.Float64frombits(binary.LittleEndian.Uint64(values))
+					values = values[8:]
+				case 6: // BYTE_ARRAY
+					n := int(binary.LittleEndian.Uint32(values))
+					cell = string(values[4 : 4+n])
+					values = values[4+n:]
+				default:
+					return nil, nil, fmt.Errorf("column %s: unsupported type %d", names[c], types[c])
+				}
+				rows[start+i][c] = cell
+			}
+		}
+	}
+
+	if total := int(meta[3].(int64)); total != len(rows) {
+		return nil, nil, fmt.Errorf("footer counts %d rows, row groups hold %d", total, len(rows))
+	}
+	return names, rows, nil
+}
+
+// readXLSX decodes the first sheet of a workbook by following the package
+// relationships. Numbers are float64 and cells with a date format are times.
+func readXLSX(file []byte) (sheet string, rows [][]interface{}, err error) {
+	archive, err := zip.NewReader(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		return "", nil, err
+	}
+	parts := map[string][]byte{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			return "", nil, err
+		}
+		parts[f.Name], err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	type relationships struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Type   string `xml:"Type,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	target := func(part, relType string) (string, error) {
+		var rels relationships
+		relsPart := "_rels/.rels"
+		if part != "" {
+			relsPart = path.Join(path.Dir(part), "_rels", path.Base(part)+".rels")
+		}
+		if err := xml.Unmarshal(parts[relsPart], &rels); err != nil {
+			return "", fmt.Errorf("%s: %v", relsPart, err)
+		}
+		for _, rel := range rels.Items {
+			if strings.HasSuffix(rel.Type, "/"+relType) || rel.ID == relType {
+				return path.Join(path.Dir(part), rel.Target), nil
+			}
+		}
+		return "", fmt.Errorf("%s has no %s relationship", relsPart, relType)
+	}
+
+	var contentTypes struct {
+		Overrides []struct {
+			PartName    string `xml:"PartName,attr"`
+			ContentType string `xml:"ContentType,attr"`
+		} `xml:"Override"`
+	}
+	if err := xml.Unmarshal(parts["[Content_Types].xml"], &contentTypes); err != nil {
+		return "", nil, fmt.Errorf("content types: %v", err)
+	}
+	declared := map[string]string{}
+	for _, override := range contentTypes.Overrides {
+		declared[strings.TrimPrefix(override.PartName, "/")] = override.ContentType
+	}
+
+	workbookPart, err := target("", "officeDocument")
+	if err != nil {
+		return "", nil, err
+	}
+	if !strings.HasSuffix(declared[workbookPart], ".sheet.main+xml") {
+		return "", nil, fmt.Errorf("workbook %s has content type %q", workbookPart, declared[workbookPart])
+	}
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(parts[workbookPart], &workbook); err != nil || len(workbook.Sheets) == 0 {
+		return "", nil, fmt.Errorf("workbook has no sheets: %v", err)
+	}
+	sheetPart, err := target(workbookPart, workbook.Sheets[0].RID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !strings.HasSuffix(declared[sheetPart], ".worksheet+xml") {
+		return "", nil, fmt.Errorf("sheet %s has content type %q", sheetPart, declared[sheetPart])
+	}
+
+	// Styles: which cell formats are dates
+	stylesPart, err := target(workbookPart, "styles")
+	if err != nil {
+		return "", nil, err
+	}
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := xml.Unmarshal(parts[stylesPart], &styles); err != nil {
+		return "", nil, fmt.Errorf("styles: %v", err)
+	}
+	dateFormats := map[int]bool{14: true, 22: true}
+	for _, format := range styles.NumFmts {
+		dateFormats[format.ID] = strings.ContainsAny(format.Code, "yd")
+	}
+
+	var worksheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				R  string `xml:"r,attr"`
+				S  int    `xml:"s,attr"`
+				T  string `xml:"t,attr"`
+				V  string `xml:"v"`
+				Is string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(parts[sheetPart], &worksheet); err != nil {
+		return "", nil, fmt.Errorf("sheet: %v", err)
+	}
+
+	for i, row := range worksheet.Rows {
+		if row.R != i+1 {
+			return "", nil, fmt.Errorf("row %d is numbered %d", i+1, row.R)
+		}
+		values := []interface{}{}
+		for _, cell := range row.Cells {
+			letters := strings.TrimRight(cell.R, "0123456789")
+			if cell.R[len(letters):] != strconv.Itoa(row.R) {
+				return "", nil, fmt.Errorf("cell %s is outside row %d", cell.R, row.R)
+			}
+			column := 0
+			for _, letter := range letters {
+				column = column*26 + int(letter-'A') + 1
+			}
+			for len(values) < column {
+				values = append(values, nil)
+			}
+
+			var value interface{}
+			switch cell.T {
+			case "inlineStr":
+				value = cell.Is
+			case "b":
+				value = cell.V == "1"
+			case "", "n":
+				number, err := strconv.ParseFloat(cell.V, 64)
+				if err != nil {
+					return "", nil, fmt.Errorf("cell %s: %v", cell.R, err)
+				}
+				value = number
+				if cell.S < len(styles.CellXfs) && dateFormats[styles.CellXfs[cell.S].NumFmtID] {
+					days := time.Duration(math.Round(number * 24 * float64(time.Hour) / float64(time.Millisecond)))
+					value = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).Add(days * time.Millisecond)
+				}
+			default:
+				return "", nil, fmt.Errorf("cell %s: unexpected type %q", cell.R, cell.T)
+			}
+			values[column-1] = value
+		}
+		rows = append(rows, values)
+	}
+
+	// Empty cells are left out, so pad rows to the header
+	for i := range rows {
+		for len(rows[i]) < len(rows[0]) {
+			rows[i] = append(rows[i], nil)
+		}
+	}
+	return workbook.Sheets[0].Name, rows, nil
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestExportRowsMatchSchemas(t *testing.T) {
+	now := time.Date(2024, 3, 4, 14, 30, 0, 0, time.UTC)
+	price, orderID := 101.5, "sell-1"
+	rows := map[string][]interface{}{
+		models.ExportOrders:     orderRow(models.Order{ID: 1, Symbol: "AAPL", Tags: []string{"a", "b"}, CreatedAt: now}),
+		models.ExportExecutions: executionRow(models.Execution{ID: 1, Symbol: "AAPL", Timestamp: now}),
+		models.ExportRiskAlerts: riskAlertRow(models.RiskAlert{ID: 1, AlertType: "SLIPPAGE", CreatedAt: now}),
+		models.ExportDailyPnL:   dailyPnLRow(models.DailyRealizedPnL{Date: "2024-03-04", PnL: 10, Count: 2}),
+		models.ExportMoversPositions: moversPositionRow(models.MoversPosition{
+			ID: 1, Symbol: "AAPL", PurchaseTime: now, SellTime: &now, SellPrice: &price, SellOrderID: &orderID,
+		}),
+	}
+
+	for dataset, columns := range ExportSchemas() {
+		row, ok := rows[dataset]
+		if !ok {
+			t.Errorf("no row builder tested for %s", dataset)
+			continue
+		}
+		if len(row) != len(columns) {
+			t.Errorf("%s: %d cells for %d columns", dataset, len(row), len(columns))
+			continue
+		}
+		for i, column := range columns {
+			if row[i] == nil {
+				continue
+			}
+			var ok bool
+			switch column.Type {
+			case models.ExportString:
+				_, ok = row[i].(string)
+			case models.ExportInteger:
+				_, ok = row[i].(int64)
+			case models.ExportNumber:
+				_, ok = row[i].(float64)
+			case models.ExportBoolean:
+				_, ok = row[i].(bool)
+			case models.ExportTimestamp:
+				_, ok = row[i].(time.Time)
+			}
+			if !ok {
+				t.Errorf("%s.%s: %T is not a %s", dataset, column.Name, row[i], column.Type)
+			}
+		}
+	}
+
+	if cell := orderRow(models.Order{})[18]; cell != nil {
+		t.Errorf("expected an unset time to export as null, got %v", cell)
+	}
+	if cell := moversPositionRow(models.MoversPosition{})[9]; cell != nil {
+		t.Errorf("expected an unset sell price to export as null, got %v", cell)
+	}
+}
+
+var testExportColumns = []models.ExportColumn{
+	{Name: "id", Type: models.ExportInteger},
+	{Name: "symbol", Type: models.ExportString},
+	{Name: "price", Type: models.ExportNumber},
+	{Name: "active", Type: models.ExportBoolean},
+	{Name: "at", Type: models.ExportTimestamp},
+}
+
+func testExportRow() []interface{} {
+	return []interface{}{int64(7), `A&B "X"`, 101.25, true, time.Date(2024, 3, 4, 14, 30, 0, 0, time.UTC)}
+}
+
+func TestCSVRowWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newRowWriter(models.ExportCSV, &buf, "test", testExportColumns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.WriteRow(testExportRow())
+	w.WriteRow([]interface{}{int64(8), nil, nil, false, nil})
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "id,symbol,price,active,at\n" +
+		"7,\"A&B \"\"X\"\"\",101.25,true,2024-03-04T14:30:00Z\n" +
+		"8,,,false,\n"
+	if buf.String() != want {
+		t.Errorf("unexpected csv:\n%s", buf.String())
+	}
+}
+
+func TestXLSXRowWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newRowWriter(models.ExportXLSX, &buf, "orders", testExportColumns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.WriteRow(testExportRow())
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected a zip archive: %v", err)
+	}
+	var sheet string
+	for _, f := range archive.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			content, _ := io.ReadAll(r)
+			sheet = string(content)
+		}
+	}
+
+	for _, cell := range []string{
+		`<c r="A1" s="2" t="inlineStr"><is><t xml:space="preserve">id</t></is></c>`,
+		`<c r="A2"><v>7</v></c>`,
+		`<t xml:space="preserve">A&amp;B &#34;X&#34;</t>`,
+		`<c r="C2"><v>101.25</v></c>`,
+		`<c r="D2" t="b"><v>1</v></c>`,
+		`<c r="E2" s="1"><v>45355.604166666664</v></c>`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("expected the sheet to contain %s", cell)
+		}
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(i); got != want {
+			t.Errorf("column %d: expected %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestParquetRowWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newRowWriter(models.ExportParquet, &buf, "test", testExportColumns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < parquetRowGroupRows+1; i++ {
+		if err := w.WriteRow(testExportRow()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file := buf.Bytes()
+	if !bytes.HasPrefix(file, parquetMagic) || !bytes.HasSuffix(file, parquetMagic) {
+		t.Fatal("expected the file to start and end with PAR1")
+	}
+	footer := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	if footer <= 0 || footer > len(file)-12 {
+		t.Fatalf("footer length %d out of range", footer)
+	}
+	meta := file[len(file)-8-footer : len(file)-8]
+	for _, column := range testExportColumns {
+		if !bytes.Contains(meta, []byte(column.Name)) {
+			t.Errorf("expected column %s in the footer", column.Name)
+		}
+	}
+
+	pw := w.(*parquetRowWriter)
+	if len(pw.groups) != 2 || pw.groups[0].rows != parquetRowGroupRows || pw.groups[1].rows != 1 {
+		t.Errorf("expected row groups of %d and 1 rows, got %+v", parquetRowGroupRows, pw.groups)
+	}
+	if err := w.WriteRow([]interface{}{"wrong", nil, nil, nil, nil}); err == nil {
+		t.Error("expected a string in an integer column to be rejected")
+	}
+}
+
+func TestParquetDefinitionLevels(t *testing.T) {
+	column := &parquetColumn{name: "price", physical: parquetDouble, converted: -1}
+	for _, cell := range []interface{}{1.0, 2.0, nil, 3.0} {
+		column.add(cell)
+	}
+
+	page := column.page()
+	levels := int(binary.LittleEndian.Uint32(page))
+	// Runs: two defined, one null, one defined
+	want := []byte{2 << 1, 1, 1 << 1, 0, 1 << 1, 1}
+	if !bytes.Equal(page[4:4+levels], want) {
+		t.Errorf("expected levels %v, got %v", want, page[4:4+levels])
+	}
+	if values := len(page) - 4 - levels; values != 3*8 {
+		t.Errorf("expected 3 plain doubles, got %d bytes", values)
+	}
+}
+
+func TestValidateRecordFilter(t *testing.T) {
+	filter := models.RecordFilter{Symbol: " aapl ", Side: "buy"}
+	if err := ValidateRecordFilter(&filter); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filter.Symbol != "AAPL" || filter.Side != "BUY" {
+		t.Errorf("expected normalised symbol and side, got %q %q", filter.Symbol, filter.Side)
+	}
+
+	if err := ValidateRecordFilter(&models.RecordFilter{Side: "SHORT"}); err == nil {
+		t.Error("expected an unknown side to be rejected")
+	}
+	from, to := time.Now(), time.Now().Add(-time.Hour)
+	if err := ValidateRecordFilter(&models.RecordFilter{From: &from, To: &to}); err == nil {
+		t.Error("expected from after to to be rejected")
+	}
+	if err := ValidateRecordFilter(&models.RecordFilter{Limit: -1}); err == nil {
+		t.Error("expected a negative limit to be rejected")
+	}
+	if err := ValidateExport(models.ExportOrders, "json"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+	if err := ValidateExport("positions", models.ExportCSV); err == nil {
+		t.Error("expected an unknown dataset to be rejected")
+	}
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hft/backend/models"
+)
+
+// rowWriter writes rows of an export file as they are read. Cells are
+// string, int64, float64, bool, time.Time or nil for no value, in the
+// order of the dataset's columns.
+type rowWriter interface {
+	WriteRow(row []interface{}) error
+	Close() error
+}
+
+func newRowWriter(format string, w io.Writer, name string, columns []models.ExportColumn) (rowWriter, error) {
+	switch format {
+	case models.ExportCSV:
+		return newCSVRowWriter(w, columns)
+	case models.ExportXLSX:
+		return newXLSXRowWriter(w, name, columns)
+	case models.ExportParquet:
+		return newParquetRowWriter(w, columns), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// ExportContentType returns the MIME type of an export format
+func ExportContentType(format string) string {
+	switch format {
+	case models.ExportXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case models.ExportParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// csvRowWriter writes a header row and then one line per row. Timestamps
+// are RFC 3339 in UTC and missing values are empty.
+type csvRowWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVRowWriter(w io.Writer, columns []models.ExportColumn) (*csvRowWriter, error) {
+	cw := &csvRowWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	for i, column := range columns {
+		cw.record[i] = column.Name
+	}
+	return cw, cw.w.Write(cw.record)
+}
+
+func (cw *csvRowWriter) WriteRow(row []interface{}) error {
+	for i, cell := range row {
+		cw.record[i] = formatExportCell(cell)
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvRowWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func formatExportCell(cell interface{}) string {
+	switch value := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(cell)
+}
+
+// Spreadsheet limits
+const (
+	xlsxMaxRows       = 1048576
+	xlsxMaxCellLength = 32767
+)
+
+// Styles of the generated workbook: 1 formats timestamps, 2 is the header
+const (
+	xlsxTimestampStyle = 1
+	xlsxHeaderStyle    = 2
+)
+
+// excelEpoch is day zero of spreadsheet date serials
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+const xlsxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", xlsxHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	{"xl/styles.xml", xlsxHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+		`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+		`</styleSheet>`},
+}
+
+// xlsxRowWriter streams a single-sheet workbook. The fixed parts are written
+// up front and the sheet last, so rows go straight into the zip stream.
+// Timestamps are spreadsheet dates in UTC.
+type xlsxRowWriter struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	refs  []string // Column letters
+	rows  int
+}
+
+func newXLSXRowWriter(w io.Writer, name string, columns []models.ExportColumn) (*xlsxRowWriter, error) {
+	xw := &xlsxRowWriter{zip: zip.NewWriter(w), refs: make([]string, len(columns))}
+	for i := range columns {
+		xw.refs[i] = xlsxColumn(i)
+	}
+
+	workbook := xlsxHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + xmlEscape(name) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	for _, part := range xlsxParts {
+		if err := xw.writePart(part.name, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := xw.writePart("xl/workbook.xml", workbook); err != nil {
+		return nil, err
+	}
+
+	f, err := xw.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xw.sheet = bufio.NewWriter(f)
+	xw.sheet.WriteString(xlsxHeader + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+		`</sheetView></sheetViews><sheetData>`)
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	return xw, xw.writeRow(header, xlsxHeaderStyle)
+}
+
+func (xw *xlsxRowWriter) writePart(name, content string) error {
+	f, err := xw.zip.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, content)
+	return err
+}
+
+func (xw *xlsxRowWriter) WriteRow(row []interface{}) error {
+	if xw.rows >= xlsxMaxRows {
+		return fmt.Errorf("xlsx holds at most %d rows; narrow the filter or export csv or parquet", xlsxMaxRows-1)
+	}
+	return xw.writeRow(row, 0)
+}
+
+func (xw *xlsxRowWriter) writeRow(row []interface{}, style int) error {
+	xw.rows++
+	fmt.Fprintf(xw.sheet, `<row r="%d">`, xw.rows)
+	for i, cell := range row {
+		ref := xw.refs[i] + strconv.Itoa(xw.rows)
+		styleAttr := ""
+		if style != 0 {
+			styleAttr = fmt.Sprintf(` s="%d"`, style)
+		}
+
+		switch value := cell.(type) {
+		case string:
+			if len(value) > xlsxMaxCellLength {
+				value = truncateUTF8(value, xlsxMaxCellLength)
+			}
+			fmt.Fprintf(xw.sheet, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, styleAttr, xmlEscape(value))
+		case int64:
+			fmt.Fprintf(xw.sheet, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr, value)
+		case float64:
+			if !math.IsNaN(value) && !math.IsInf(value, 0) {
+				fmt.Fprintf(xw.sheet, `<c r="%s"%s><v>%s</v></c>`, ref, styleAttr, strconv.FormatFloat(value, 'g', -1, 64))
+			}
+		case bool:
+			flag := 0
+			if value {
+				flag = 1
+			}
+			fmt.Fprintf(xw.sheet, `<c r="%s"%s t="b"><v>%d</v></c>`, ref, styleAttr, flag)
+		case time.Time:
+			serial := float64(value.Sub(excelEpoch)) / float64(24*time.Hour)
+			fmt.Fprintf(xw.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxTimestampStyle, strconv.FormatFloat(serial, 'f', -1, 64))
+		}
+	}
+	_, err := xw.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (xw *xlsxRowWriter) Close() error {
+	xw.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.zip.Close()
+}
+
+// xlsxColumn returns the letters of the zero-based column i
+func xlsxColumn(i int) string {
+	letters := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		letters = string(rune('A'+(i-1)%26)) + letters
+	}
+	return letters
+}
+
+func xmlEscape(value string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
+
+// truncateUTF8 cuts a string to at most n bytes without splitting a rune
+func truncateUTF8(value string, n int) string {
+	for n > 0 && !utf8.RuneStart(value[n]) {
+		n--
+	}
+	return value[:n]
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// FeeModel prices the commission and regulatory fees of executions from
+// time-versioned fee schedules per account. Accounts without a schedule of
+// their own use the default account's.
+type FeeModel struct {
+	db        *DatabaseService
+	schedules map[string][]models.FeeSchedule // By account, oldest effective first
+	mu        sync.RWMutex
+}
+
+// NewFeeModel creates a new fee model and loads the fee schedules
+func NewFeeModel(db *DatabaseService) *FeeModel {
+	fm := &FeeModel{
+		db:        db,
+		schedules: make(map[string][]models.FeeSchedule),
+	}
+
+	if err := fm.Reload(); err != nil {
+		log.Printf("Error loading fee schedules: %v", err)
+	}
+	return fm
+}
+
+// Reload reads every fee schedule version from the database
+func (fm *FeeModel) Reload() error {
+	db := fm.db.GetDB()
+	if db == nil {
+		return nil // Database disabled
+	}
+
+	var versions []models.FeeSchedule
+	if result := db.Order("effective_from, version").Find(&versions); result.Error != nil {
+		return result.Error
+	}
+
+	schedules := make(map[string][]models.FeeSchedule)
+	for _, version := range versions {
+		schedules[version.Account] = append(schedules[version.Account], version)
+	}
+
+	fm.mu.Lock()
+	fm.schedules = schedules
+	fm.mu.Unlock()
+	return nil
+}
+
+// ScheduleAt returns the schedule in force for an account at t, or nil when
+// neither the account nor the default account has one
+func (fm *FeeModel) ScheduleAt(account string, t time.Time) *models.FeeSchedule {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	if schedule := scheduleAt(fm.schedules[account], t); schedule != nil {
+		return schedule
+	}
+	return scheduleAt(fm.schedules[DefaultAccount], t)
+}
+
+// Schedules returns every version of an account's fee schedule, or of all
+// accounts when account is empty, newest first
+func (fm *FeeModel) Schedules(account string) []models.FeeSchedule {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	result := []models.FeeSchedule{}
+	for name, versions := range fm.schedules {
+		if account == "" || name == account {
+			result = append(result, versions...)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].EffectiveFrom.Equal(result[j].EffectiveFrom) {
+			return result[i].EffectiveFrom.After(result[j].EffectiveFrom)
+		}
+		return result[i].ID > result[j].ID
+	})
+	return result
+}
+
+// AddSchedule stores a new version of an account's fee schedule. It takes
+// effect now unless EffectiveFrom is set. Returns true when the schedule is
+// backdated, so executions already priced must be priced again.
+func (fm *FeeModel) AddSchedule(schedule models.FeeSchedule) (*models.FeeSchedule, bool, error) {
+	db := fm.db.GetDB()
+	if db == nil {
+		return nil, false, fmt.Errorf("database unavailable")
+	}
+	if err := validateFeeSchedule(&schedule); err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	schedule.ID = 0
+	schedule.CreatedAt = now
+	if schedule.EffectiveFrom.IsZero() {
+		schedule.EffectiveFrom = now
+	}
+
+	var latest int
+	db.Model(&models.FeeSchedule{}).Where("account = ?", schedule.Account).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest)
+	schedule.Version = latest + 1
+
+	if result := db.Create(&schedule); result.Error != nil {
+		return nil, false, result.Error
+	}
+	if err := fm.Reload(); err != nil {
+		return nil, false, err
+	}
+
+	log.Printf("Fee schedule v%d for %s effective %s by %s",
+		schedule.Version, schedule.Account, schedule.EffectiveFrom.Format(time.RFC3339), schedule.Author)
+	return &schedule, schedule.EffectiveFrom.Before(now), nil
+}
+
+// Price returns the fees of an execution. monthToDateShares is the
+// account's share volume earlier in the month, used to pick a commission
+// tier.
+func (fm *FeeModel) Price(exec models.Execution, monthToDateShares float64) models.ExecutionFee {
+	account := executionAccount(exec)
+	return calculateFees(fm.ScheduleAt(account, exec.Timestamp), exec, account, monthToDateShares)
+}
+
+func executionAccount(exec models.Execution) string {
+	if exec.Account == "" {
+		return DefaultAccount
+	}
+	return exec.Account
+}
+
+func validateFeeSchedule(schedule *models.FeeSchedule) error {
+	if schedule.Account == "" {
+		schedule.Account = DefaultAccount
+	}
+
+	rates := map[string]float64{
+		"commission_per_share": schedule.CommissionPerShare,
+		"commission_bps":       schedule.CommissionBps,
+		"minimum_commission":   schedule.MinimumCommission,
+		"sec_fee_rate":         schedule.SECFeeRate,
+		"taf_per_share":        schedule.TAFPerShare,
+		"taf_maximum":          schedule.TAFMaximum,
+	}
+	for name, rate := range rates {
+		if rate < 0 {
+			return fmt.Errorf("%s cannot be negative", name)
+		}
+	}
+
+	sort.SliceStable(schedule.CommissionTiers, func(i, j int) bool {
+		a, b := schedule.CommissionTiers[i].UpToShares, schedule.CommissionTiers[j].UpToShares
+		return a != 0 && (b == 0 || a < b)
+	})
+	for i, tier := range schedule.CommissionTiers {
+		if tier.UpToShares < 0 || tier.PerShare < 0 {
+			return fmt.Errorf("commission tier %d cannot be negative", i+1)
+		}
+		if tier.UpToShares == 0 && i != len(schedule.CommissionTiers)-1 {
+			return fmt.Errorf("only one commission tier can be unbounded")
+		}
+	}
+
+	if strings.TrimSpace(schedule.Author) == "" {
+		schedule.Author = "unknown"
+	}
+	return nil
+}
+
+// scheduleAt returns the latest version effective at t from versions
+// ordered oldest effective first
+func scheduleAt(versions []models.FeeSchedule, t time.Time) *models.FeeSchedule {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !versions[i].EffectiveFrom.After(t) {
+			return &versions[i]
+		}
+	}
+	return nil
+}
+
+// calculateFees prices an execution under a schedule. Commission is the
+// per-share rate, or the tier rate for the month-to-date volume, plus the
+// notional rate, raised to the minimum ticket. Section 31 and TAF fees are
+// charged on sells only; the SEC fee is rounded up to the cent and the TAF
+// is capped per fill.
+func calculateFees(schedule *models.FeeSchedule, exec models.Execution, account string, monthToDateShares float64) models.ExecutionFee {
+	fee := models.ExecutionFee{
+		ExecutionID:       exec.ID,
+		Account:           account,
+		Symbol:            exec.Symbol,
+		Side:              strings.ToUpper(exec.Side),
+		MonthToDateShares: monthToDateShares,
+		Timestamp:         exec.Timestamp,
+	}
+	if schedule == nil || exec.FillQty <= 0 {
+		return fee
+	}
+	fee.FeeScheduleID = schedule.ID
+
+	notional := exec.FillQty * exec.FillPrice
+	perShare := schedule.CommissionPerShare
+	for _, tier := range schedule.CommissionTiers {
+		if tier.UpToShares == 0 || monthToDateShares < tier.UpToShares {
+			perShare = tier.PerShare
+			break
+		}
+	}
+	commission := exec.FillQty*perShare + notional*schedule.CommissionBps/10000
+	fee.Commission = roundCents(math.Max(commission, schedule.MinimumCommission))
+
+	if fee.Side == "SELL" {
+		if secFee := notional * schedule.SECFeeRate; secFee > 0 {
+			fee.SECFee = math.Ceil(secFee*100-1e-9) / 100
+		}
+		taf := exec.FillQty * schedule.TAFPerShare
+		if schedule.TAFMaximum > 0 {
+			taf = math.Min(taf, schedule.TAFMaximum)
+		}
+		fee.TAFFee = roundCents(taf)
+	}
+
+	fee.TotalFees = roundCents(fee.Commission + fee.SECFee + fee.TAFFee)
+	return fee
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// feeMonth is the calendar month, in market time, whose volume counts
+// towards a fill's commission tier
+func feeMonth(account string, t time.Time) string {
+	return account + ":" + t.In(marketLocation).Format("2006-01")
+}
+
+// GetExecutionFees returns the fees of the most recent executions, of one
+// account when account is set
+func (fm *FeeModel) GetExecutionFees(account string, limit int) ([]models.ExecutionFee, error) {
+	fees := []models.ExecutionFee{}
+	db := fm.db.GetDB()
+	if db == nil {
+		return fees, nil
+	}
+
+	query := db.Order("execution_id DESC").Limit(limit)
+	if account != "" {
+		query = query.Where("account = ?", account)
+	}
+	if result := query.Find(&fees); result.Error != nil {
+		return nil, result.Error
+	}
+	return fees, nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestCalculateFeesCommission(t *testing.T) {
+	buy := models.Execution{ID: 1, Symbol: "AAPL", Side: "BUY", FillQty: 100, FillPrice: 50, Timestamp: time.Now()}
+
+	tests := []struct {
+		name     string
+		schedule models.FeeSchedule
+		mtd      float64
+		want     float64
+	}{
+		{"per share", models.FeeSchedule{CommissionPerShare: 0.005}, 0, 0.50},
+		{"per notional", models.FeeSchedule{CommissionBps: 1}, 0, 0.50},
+		{"minimum ticket", models.FeeSchedule{CommissionPerShare: 0.005, MinimumCommission: 1}, 0, 1},
+		{"first tier", tieredSchedule(), 0, 0.35},
+		{"second tier", tieredSchedule(), 300000, 0.20},
+		{"unbounded tier", tieredSchedule(), 5000000, 0.05},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee := calculateFees(&tt.schedule, buy, DefaultAccount, tt.mtd)
+			if math.Abs(fee.Commission-tt.want) > 1e-9 || fee.TotalFees != fee.Commission {
+				t.Errorf("expected commission %.2f, got %+v", tt.want, fee)
+			}
+			if fee.SECFee != 0 || fee.TAFFee != 0 {
+				t.Errorf("expected no regulatory fees on a buy, got %+v", fee)
+			}
+		})
+	}
+}
+
+func tieredSchedule() models.FeeSchedule {
+	return models.FeeSchedule{CommissionTiers: []models.CommissionTier{
+		{UpToShares: 300000, PerShare: 0.0035},
+		{UpToShares: 3000000, PerShare: 0.002},
+		{PerShare: 0.0005},
+	}}
+}
+
+func TestCalculateFeesRegulatory(t *testing.T) {
+	schedule := &models.FeeSchedule{SECFeeRate: 0.0000278, TAFPerShare: 0.000166, TAFMaximum: 8.30}
+
+	sell := models.Execution{Symbol: "AAPL", Side: "SELL", FillQty: 1000, FillPrice: 150, Timestamp: time.Now()}
+	fee := calculateFees(schedule, sell, DefaultAccount, 0)
+	// $150,000 sold: SEC fee 4.17 rounded up to 4.17, TAF 1000 * 0.000166 = 0.166
+	if fee.SECFee != 4.17 || fee.TAFFee != 0.17 || fee.TotalFees != 4.34 {
+		t.Errorf("unexpected regulatory fees %+v", fee)
+	}
+
+	small := models.Execution{Symbol: "AAPL", Side: "SELL", FillQty: 1, FillPrice: 10, Timestamp: time.Now()}
+	if fee := calculateFees(schedule, small, DefaultAccount, 0); fee.SECFee != 0.01 {
+		t.Errorf("expected the SEC fee to round up to a cent, got %.4f", fee.SECFee)
+	}
+
+	large := models.Execution{Symbol: "AAPL", Side: "SELL", FillQty: 100000, FillPrice: 1, Timestamp: time.Now()}
+	if fee := calculateFees(schedule, large, DefaultAccount, 0); fee.TAFFee != 8.30 {
+		t.Errorf("expected TAF capped at 8.30, got %.2f", fee.TAFFee)
+	}
+}
+
+func TestScheduleAt(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions := []models.FeeSchedule{
+		{ID: 1, Version: 1, EffectiveFrom: start},
+		{ID: 2, Version: 2, EffectiveFrom: start.AddDate(0, 3, 0)},
+	}
+
+	if schedule := scheduleAt(versions, start.Add(-time.Hour)); schedule != nil {
+		t.Errorf("expected no schedule before the first version, got v%d", schedule.Version)
+	}
+	if schedule := scheduleAt(versions, start.AddDate(0, 1, 0)); schedule == nil || schedule.Version != 1 {
+		t.Errorf("expected v1 in February, got %+v", schedule)
+	}
+	if schedule := scheduleAt(versions, start.AddDate(0, 3, 0)); schedule == nil || schedule.Version != 2 {
+		t.Errorf("expected v2 from its effective time, got %+v", schedule)
+	}
+}
+
+func TestValidateFeeSchedule(t *testing.T) {
+	schedule := models.FeeSchedule{CommissionTiers: []models.CommissionTier{
+		{PerShare: 0.0005},
+		{UpToShares: 3000000, PerShare: 0.002},
+		{UpToShares: 300000, PerShare: 0.0035},
+	}}
+	if err := validateFeeSchedule(&schedule); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if schedule.Account != DefaultAccount {
+		t.Errorf("expected the default account, got %q", schedule.Account)
+	}
+	if schedule.CommissionTiers[0].UpToShares != 300000 || schedule.CommissionTiers[2].UpToShares != 0 {
+		t.Errorf("expected tiers ordered with the unbounded tier last, got %+v", schedule.CommissionTiers)
+	}
+
+	if err := validateFeeSchedule(&models.FeeSchedule{SECFeeRate: -1}); err == nil {
+		t.Error("expected a negative rate to be rejected")
+	}
+	twoUnbounded := models.FeeSchedule{CommissionTiers: []models.CommissionTier{{PerShare: 0.001}, {PerShare: 0.002}}}
+	if err := validateFeeSchedule(&twoUnbounded); err == nil {
+		t.Error("expected two unbounded tiers to be rejected")
+	}
+}
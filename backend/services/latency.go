@@ -0,0 +1,283 @@
+package services
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Hops of the order path. LatencyStageOrder is the whole request from
+// receipt to response; LatencyStageHTTP covers every API request.
+const (
+	LatencyStageAPIReceive   = "api_receive"
+	LatencyStageRisk         = "risk"
+	LatencyStageEngine       = "engine"
+	LatencyStageDBPersist    = "db_persist"
+	LatencyStageKafkaPublish = "kafka_publish"
+	LatencyStageOrder        = "order"
+	LatencyStageHTTP         = "http"
+)
+
+// LatencyWindows are the rolling windows latency is reported over, in
+// minutes
+var LatencyWindows = map[string]int{"1m": 1, "5m": 5, "15m": 15}
+
+// Histogram layout: values below 256µs are counted exactly; above that each
+// power of two is split into 128 buckets, which keeps two significant digits
+// up to about four hours
+const (
+	latencySubBuckets  = 256
+	latencyHalfBuckets = latencySubBuckets / 2
+	latencyMaxShift    = 26
+	latencyBuckets     = latencySubBuckets + latencyMaxShift*latencyHalfBuckets
+
+	// One slot per minute; a window of n minutes spans the current minute and
+	// the n before it
+	latencySlots = 16
+)
+
+// latencyIndex returns the bucket of a latency in microseconds
+func latencyIndex(us int64) int {
+	if us < latencySubBuckets {
+		if us < 0 {
+			return 0
+		}
+		return int(us)
+	}
+	shift := bits.Len64(uint64(us)) - 8
+	if shift > latencyMaxShift {
+		return latencyBuckets - 1
+	}
+	return latencySubBuckets + (shift-1)*latencyHalfBuckets + int(us>>shift) - latencyHalfBuckets
+}
+
+// latencyValue returns the highest latency, in microseconds, counted in a
+// bucket
+func latencyValue(index int) int64 {
+	if index < latencySubBuckets {
+		return int64(index)
+	}
+	offset := index - latencySubBuckets
+	shift := offset/latencyHalfBuckets + 1
+	sub := int64(offset%latencyHalfBuckets + latencyHalfBuckets)
+	return (sub+1)<<shift - 1
+}
+
+// latencyHistogram counts the latencies of one hop in one minute
+type latencyHistogram struct {
+	minute int64
+	counts []uint64
+	count  int64
+	sum    int64
+	max    int64
+}
+
+func newLatencyHistogram(minute int64) *latencyHistogram {
+	return &latencyHistogram{minute: minute, counts: make([]uint64, latencyBuckets)}
+}
+
+func (h *latencyHistogram) record(us int64) {
+	if us < 0 {
+		us = 0
+	}
+	h.counts[latencyIndex(us)]++
+	h.count++
+	h.sum += us
+	if us > h.max {
+		h.max = us
+	}
+}
+
+func (h *latencyHistogram) merge(other *latencyHistogram) {
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	h.count += other.count
+	h.sum += other.sum
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// percentile returns the latency in microseconds at or below which q of the
+// recorded latencies fall
+func (h *latencyHistogram) percentile(q float64) int64 {
+	if h.count == 0 {
+		return 0
+	}
+	target := uint64(math.Ceil(q * float64(h.count)))
+	if target < 1 {
+		target = 1
+	}
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= target {
+			if value := latencyValue(i); value < h.max {
+				return value
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+func (h *latencyHistogram) stats() models.LatencyStats {
+	stats := models.LatencyStats{Count: h.count}
+	if h.count == 0 {
+		return stats
+	}
+	ms := func(us int64) float64 { return float64(us) / 1000 }
+	stats.MeanMs = float64(h.sum) / float64(h.count) / 1000
+	stats.P50Ms = ms(h.percentile(0.50))
+	stats.P90Ms = ms(h.percentile(0.90))
+	stats.P99Ms = ms(h.percentile(0.99))
+	stats.P999Ms = ms(h.percentile(0.999))
+	stats.MaxMs = ms(h.max)
+	return stats
+}
+
+// LatencyRecorder keeps a minute-by-minute latency histogram of each hop of
+// the order path for the last quarter hour and mirrors every sample to a
+// Prometheus histogram
+type LatencyRecorder struct {
+	slots     map[string]*[latencySlots]*latencyHistogram
+	histogram *prometheus.HistogramVec
+	started   time.Time
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+var latencyRecorder *LatencyRecorder
+var latencyRecorderOnce sync.Once
+
+// NewLatencyRecorder creates a latency recorder. histogram may be nil.
+func NewLatencyRecorder(histogram *prometheus.HistogramVec) *LatencyRecorder {
+	return &LatencyRecorder{
+		slots:     make(map[string]*[latencySlots]*latencyHistogram),
+		histogram: histogram,
+		started:   time.Now(),
+		now:       time.Now,
+	}
+}
+
+// GetLatencyRecorder returns the global latency recorder
+func GetLatencyRecorder() *LatencyRecorder {
+	latencyRecorderOnce.Do(func() {
+		latencyRecorder = NewLatencyRecorder(GetMetrics().OrderPathLatency)
+	})
+	return latencyRecorder
+}
+
+// Record adds a latency sample for a hop
+func (lr *LatencyRecorder) Record(stage string, latency time.Duration) {
+	us := latency.Microseconds()
+	if lr.histogram != nil {
+		lr.histogram.WithLabelValues(stage).Observe(float64(us))
+	}
+
+	minute := lr.now().Unix() / 60
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	slots, ok := lr.slots[stage]
+	if !ok {
+		slots = &[latencySlots]*latencyHistogram{}
+		lr.slots[stage] = slots
+	}
+	slot := &slots[minute%latencySlots]
+	if *slot == nil || (*slot).minute != minute {
+		*slot = newLatencyHistogram(minute)
+	}
+	(*slot).record(us)
+}
+
+// Since records the time elapsed since start for a hop
+func (lr *LatencyRecorder) Since(stage string, start time.Time) {
+	lr.Record(stage, time.Since(start))
+}
+
+// window merges a hop's histograms of the last minutes
+func (lr *LatencyRecorder) window(stage string, minutes int) *latencyHistogram {
+	current := lr.now().Unix() / 60
+	merged := newLatencyHistogram(current)
+	if slots, ok := lr.slots[stage]; ok {
+		for _, h := range slots {
+			if h != nil && h.minute >= current-int64(minutes) {
+				merged.merge(h)
+			}
+		}
+	}
+	return merged
+}
+
+// Stats returns the latency of one hop over the last minutes
+func (lr *LatencyRecorder) Stats(stage string, minutes int) models.LatencyStats {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.window(stage, minutes).stats()
+}
+
+// Snapshot returns the latency of every hop with samples over the last
+// minutes
+func (lr *LatencyRecorder) Snapshot(minutes int) map[string]models.LatencyStats {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	snapshot := make(map[string]models.LatencyStats, len(lr.slots))
+	for stage := range lr.slots {
+		if stats := lr.window(stage, minutes).stats(); stats.Count > 0 {
+			snapshot[stage] = stats
+		}
+	}
+	return snapshot
+}
+
+// Rate returns the samples per second of a hop over the last minutes
+func (lr *LatencyRecorder) Rate(stage string, minutes int) float64 {
+	now := lr.now()
+	span := time.Duration(minutes)*time.Minute + time.Duration(now.Unix()%60)*time.Second
+	if uptime := now.Sub(lr.started); uptime < span {
+		span = uptime
+	}
+	if span < time.Second {
+		span = time.Second
+	}
+	return float64(lr.Stats(stage, minutes).Count) / span.Seconds()
+}
+
+// LatencyTimer times consecutive hops of one request
+type LatencyTimer struct {
+	recorder *LatencyRecorder
+	started  time.Time
+	last     time.Time
+}
+
+// StartTimer starts timing a request's hops
+func (lr *LatencyRecorder) StartTimer() *LatencyTimer {
+	now := time.Now()
+	return &LatencyTimer{recorder: lr, started: now, last: now}
+}
+
+// Lap records the time since the previous lap, or since the timer started,
+// for a hop. A nil timer records nothing.
+func (t *LatencyTimer) Lap(stage string) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.recorder.Record(stage, now.Sub(t.last))
+	t.last = now
+}
+
+// Total records the time since the timer started for a hop
+func (t *LatencyTimer) Total(stage string) {
+	if t == nil {
+		return
+	}
+	t.recorder.Record(stage, time.Since(t.started))
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyBuckets(t *testing.T) {
+	for _, us := range []int64{0, 1, 255, 256, 257, 1000, 12345, 999999, 3600000000} {
+		index := latencyIndex(us)
+		upper := latencyValue(index)
+		if upper < us {
+			t.Errorf("%dµs: bucket %d tops out at %dµs", us, index, upper)
+		}
+		if us >= latencySubBuckets && float64(upper-us) > float64(us)/latencyHalfBuckets {
+			t.Errorf("%dµs: bucket top %dµs is outside the precision", us, upper)
+		}
+		if index > 0 && latencyValue(index-1) >= us {
+			t.Errorf("%dµs: belongs in an earlier bucket than %d", us, index)
+		}
+	}
+	if latencyIndex(1<<62) != latencyBuckets-1 {
+		t.Error("expected huge latencies in the last bucket")
+	}
+}
+
+func TestLatencyPercentiles(t *testing.T) {
+	now := time.Date(2026, 6, 1, 14, 30, 10, 0, time.UTC)
+	recorder := NewLatencyRecorder(nil)
+	recorder.now = func() time.Time { return now }
+
+	for i := 1; i <= 1000; i++ {
+		recorder.Record(LatencyStageEngine, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := recorder.Stats(LatencyStageEngine, 1)
+	if stats.Count != 1000 || !closeTo(stats.MeanMs, 500.5) || stats.MaxMs != 1000 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	for _, tt := range []struct {
+		name     string
+		got      float64
+		expected float64
+	}{
+		{"p50", stats.P50Ms, 500},
+		{"p90", stats.P90Ms, 900},
+		{"p99", stats.P99Ms, 990},
+		{"p999", stats.P999Ms, 999},
+	} {
+		if tt.got < tt.expected || tt.got > tt.expected*1.01 {
+			t.Errorf("expected %s within 1%% above %.0fms, got %.3fms", tt.name, tt.expected, tt.got)
+		}
+	}
+	if _, ok := recorder.Snapshot(1)[LatencyStageRisk]; ok {
+		t.Error("expected no stats for a hop without samples")
+	}
+}
+
+func TestLatencyRollingWindows(t *testing.T) {
+	now := time.Date(2026, 6, 1, 14, 0, 0, 0, time.UTC)
+	recorder := NewLatencyRecorder(nil)
+	recorder.started = now.Add(-time.Hour)
+	recorder.now = func() time.Time { return now }
+
+	recorder.Record(LatencyStageOrder, 100*time.Millisecond)
+	now = now.Add(4 * time.Minute)
+	recorder.Record(LatencyStageOrder, time.Millisecond)
+	recorder.Record(LatencyStageOrder, time.Millisecond)
+
+	if count := recorder.Stats(LatencyStageOrder, 1).Count; count != 2 {
+		t.Errorf("expected 2 samples in the last minute, got %d", count)
+	}
+	if stats := recorder.Stats(LatencyStageOrder, 5); stats.Count != 3 || stats.MaxMs != 100 {
+		t.Errorf("expected all 3 samples in 5 minutes, got %+v", stats)
+	}
+	if rate := recorder.Rate(LatencyStageOrder, 1); !closeTo(rate, 2.0/60) {
+		t.Errorf("expected 2 per minute, got %.4f/s", rate)
+	}
+
+	// Slots are reused once they fall out of the longest window
+	now = now.Add(latencySlots * time.Minute)
+	recorder.Record(LatencyStageOrder, 5*time.Millisecond)
+	if stats := recorder.Stats(LatencyStageOrder, 15); stats.Count != 1 || stats.MaxMs != 5 {
+		t.Errorf("expected only the latest sample after a quarter hour, got %+v", stats)
+	}
+}
+
+func TestLatencyTimer(t *testing.T) {
+	recorder := NewLatencyRecorder(nil)
+	timer := recorder.StartTimer()
+	timer.Lap(LatencyStageAPIReceive)
+	timer.Lap(LatencyStageRisk)
+	timer.Total(LatencyStageOrder)
+
+	snapshot := recorder.Snapshot(1)
+	for _, stage := range []string{LatencyStageAPIReceive, LatencyStageRisk, LatencyStageOrder} {
+		if snapshot[stage].Count != 1 {
+			t.Errorf("expected one %s sample, got %d", stage, snapshot[stage].Count)
+		}
+	}
+
+	var none *LatencyTimer
+	none.Lap(LatencyStageRisk) // Routes without a timer record nothing
+}
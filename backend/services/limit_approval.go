@@ -0,0 +1,572 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// limitChangeTTL is how long a proposed limit change waits for approval
+const limitChangeTTL = 4 * time.Hour
+
+// anonymousAuthor is how changes made without a token used to be attributed
+const anonymousAuthor = "anonymous"
+
+// Errors returned when reviewing a limit change request
+var (
+	ErrLimitChangeNotFound   = errors.New("limit change request not found")
+	ErrLimitChangeNotPending = errors.New("limit change request is no longer pending")
+	ErrLimitChangeExpired    = errors.New("limit change request has expired")
+	ErrLimitChangeSelfReview = errors.New("limit change must be approved by a different user")
+	ErrLimitChangeAnonymous  = errors.New("limit changes must be made by an authenticated user")
+)
+
+// attributed reports whether a limit change author or reviewer is a known
+// user. Review compares users, so an anonymous author could approve their
+// own change.
+func attributed(user string) bool {
+	return user != "" && user != anonymousAuthor
+}
+
+// limitDirection says which way a limit moves when it allows more risk
+type limitDirection int
+
+const (
+	higherLoosens limitDirection = iota
+	lowerLoosens
+)
+
+// loosens reports whether moving a limit from current to proposed allows
+// more risk. For limits where zero means "not enforced", switching the limit
+// off always loosens and switching it on always tightens.
+func loosens(current, proposed float64, direction limitDirection, zeroDisables bool) bool {
+	if current == proposed {
+		return false
+	}
+	if zeroDisables {
+		if proposed <= 0 {
+			return true
+		}
+		if current <= 0 {
+			return false
+		}
+	}
+	if direction == higherLoosens {
+		return proposed > current
+	}
+	return proposed < current
+}
+
+func appendFloatDiff(diffs []models.LimitDiff, field string, current float64, proposed *float64, direction limitDirection, zeroDisables bool) []models.LimitDiff {
+	if proposed == nil || *proposed == current {
+		return diffs
+	}
+	return append(diffs, models.LimitDiff{
+		Field:    field,
+		Current:  current,
+		Proposed: *proposed,
+		Loosens:  loosens(current, *proposed, direction, zeroDisables),
+	})
+}
+
+func appendIntDiff(diffs []models.LimitDiff, field string, current int, proposed *int, direction limitDirection, zeroDisables bool) []models.LimitDiff {
+	if proposed == nil || *proposed == current {
+		return diffs
+	}
+	return append(diffs, models.LimitDiff{
+		Field:    field,
+		Current:  current,
+		Proposed: *proposed,
+		Loosens:  loosens(float64(current), float64(*proposed), direction, zeroDisables),
+	})
+}
+
+// appendSwitchDiff records a change to an on/off control; turning it off loosens
+func appendSwitchDiff(diffs []models.LimitDiff, field string, current bool, proposed *bool) []models.LimitDiff {
+	if proposed == nil || *proposed == current {
+		return diffs
+	}
+	return append(diffs, models.LimitDiff{
+		Field:    field,
+		Current:  current,
+		Proposed: *proposed,
+		Loosens:  !*proposed,
+	})
+}
+
+// selfTradeStrictness ranks self-trade actions; any change that isn't
+// clearly stricter is treated as loosening
+func selfTradeStrictness(action string) int {
+	if action == models.SelfTradeActionNone {
+		return 0
+	}
+	return 1
+}
+
+// DiffRiskLimits returns the fields an update would change and whether each
+// change loosens the limit
+func DiffRiskLimits(current *models.RiskLimits, update *models.RiskLimitsUpdate) []models.LimitDiff {
+	diffs := []models.LimitDiff{}
+
+	diffs = appendFloatDiff(diffs, "max_position_size", current.MaxPositionSize, update.MaxPositionSize, higherLoosens, false)
+	diffs = appendFloatDiff(diffs, "max_order_size", current.MaxOrderSize, update.MaxOrderSize, higherLoosens, false)
+	diffs = appendFloatDiff(diffs, "daily_loss_limit", current.DailyLossLimit, update.DailyLossLimit, higherLoosens, false)
+	diffs = appendFloatDiff(diffs, "max_portfolio_concentration", current.MaxPortfolioConcentration, update.MaxPortfolioConcentration, higherLoosens, false)
+	diffs = appendFloatDiff(diffs, "max_leverage", current.MaxLeverage, update.MaxLeverage, higherLoosens, false)
+	diffs = appendIntDiff(diffs, "max_orders_per_second", current.MaxOrdersPerSecond, update.MaxOrdersPerSecond, higherLoosens, false)
+	diffs = appendIntDiff(diffs, "breaker_cooldown_seconds", current.BreakerCooldownSeconds, update.BreakerCooldownSeconds, lowerLoosens, false)
+	diffs = appendFloatDiff(diffs, "rejection_rate_threshold", current.RejectionRateThreshold, update.RejectionRateThreshold, higherLoosens, true)
+	diffs = appendIntDiff(diffs, "engine_error_burst", current.EngineErrorBurst, update.EngineErrorBurst, higherLoosens, true)
+	diffs = appendFloatDiff(diffs, "symbol_loss_limit", current.SymbolLossLimit, update.SymbolLossLimit, higherLoosens, true)
+	diffs = appendFloatDiff(diffs, "max_var", current.MaxVaR, update.MaxVaR, higherLoosens, true)
+	diffs = appendFloatDiff(diffs, "var_confidence", current.VaRConfidence, update.VaRConfidence, lowerLoosens, false)
+
+	if update.SelfTradeAction != nil && *update.SelfTradeAction != current.SelfTradeAction {
+		diffs = append(diffs, models.LimitDiff{
+			Field:    "self_trade_action",
+			Current:  current.SelfTradeAction,
+			Proposed: *update.SelfTradeAction,
+			Loosens:  selfTradeStrictness(*update.SelfTradeAction) <= selfTradeStrictness(current.SelfTradeAction),
+		})
+	}
+
+	diffs = appendIntDiff(diffs, "wash_trade_window_seconds", current.WashTradeWindowSeconds, update.WashTradeWindowSeconds, lowerLoosens, true)
+	diffs = appendFloatDiff(diffs, "wash_trade_price_tolerance", current.WashTradePriceTolerance, update.WashTradePriceTolerance, lowerLoosens, false)
+	diffs = appendIntDiff(diffs, "pdt_max_day_trades", current.PDTMaxDayTrades, update.PDTMaxDayTrades, higherLoosens, true)
+	diffs = appendFloatDiff(diffs, "pdt_equity_threshold", current.PDTEquityThreshold, update.PDTEquityThreshold, lowerLoosens, false)
+	diffs = appendIntDiff(diffs, "max_trades_per_day", current.MaxTradesPerDay, update.MaxTradesPerDay, higherLoosens, true)
+	diffs = appendFloatDiff(diffs, "max_daily_turnover", current.MaxDailyTurnover, update.MaxDailyTurnover, higherLoosens, true)
+	diffs = appendSwitchDiff(diffs, "enforce_trading_session", current.EnforceTradingSession, update.EnforceTradingSession)
+	diffs = appendFloatDiff(diffs, "utilisation_warning_pct", current.UtilisationWarningPct, update.UtilisationWarningPct, higherLoosens, true)
+	diffs = appendFloatDiff(diffs, "utilisation_critical_pct", current.UtilisationCriticalPct, update.UtilisationCriticalPct, higherLoosens, true)
+	diffs = appendSwitchDiff(diffs, "enabled", current.Enabled, update.Enabled)
+
+	return diffs
+}
+
+// DiffPositionLimit returns the changes an update would make to a symbol's
+// position limit. Without a symbol limit the global limits apply, so the
+// update is compared against those.
+func DiffPositionLimit(current *models.PositionLimit, update *models.PositionLimitUpdate) []models.LimitDiff {
+	diffs := []models.LimitDiff{}
+	diffs = appendFloatDiff(diffs, "max_position", current.MaxPosition, &update.MaxPosition, higherLoosens, false)
+	diffs = appendFloatDiff(diffs, "max_concentration_pct", current.MaxConcentrationPct, &update.MaxConcentrationPct, higherLoosens, false)
+	return diffs
+}
+
+// DiffLimitNode returns the changes saving a node would make, current being
+// nil for a new node. Adding a trader or strategy node, moving a node or
+// changing its level or key takes orders out from under the limits they are
+// attributed to today, so those changes loosen.
+func DiffLimitNode(current *models.LimitNode, req *models.LimitNodeRequest) []models.LimitDiff {
+	diffs := []models.LimitDiff{}
+	enabled := req.Enabled == nil || *req.Enabled
+
+	if current == nil {
+		if req.Level == models.LimitLevelTrader || req.Level == models.LimitLevelStrategy {
+			diffs = append(diffs, models.LimitDiff{Field: "node", Current: nil, Proposed: req.Level + " " + limitNodeKey(req), Loosens: true})
+		}
+		current = &models.LimitNode{Enabled: enabled}
+	} else {
+		if req.Level != current.Level {
+			diffs = append(diffs, models.LimitDiff{Field: "level", Current: current.Level, Proposed: req.Level, Loosens: true})
+		}
+		if key := limitNodeKey(req); key != current.Key {
+			diffs = append(diffs, models.LimitDiff{Field: "key", Current: current.Key, Proposed: key, Loosens: true})
+		}
+		if !sameParent(current.ParentID, req.ParentID) {
+			diffs = append(diffs, models.LimitDiff{Field: "parent_id", Current: current.ParentID, Proposed: req.ParentID, Loosens: true})
+		}
+	}
+
+	diffs = appendFloatDiff(diffs, "max_position", current.MaxPosition, &req.MaxPosition, higherLoosens, true)
+	diffs = appendFloatDiff(diffs, "max_order_size", current.MaxOrderSize, &req.MaxOrderSize, higherLoosens, true)
+	diffs = appendFloatDiff(diffs, "daily_loss_limit", current.DailyLossLimit, &req.DailyLossLimit, higherLoosens, true)
+	diffs = appendIntDiff(diffs, "max_orders_per_minute", current.MaxOrdersPerMinute, &req.MaxOrdersPerMinute, higherLoosens, true)
+	diffs = appendSwitchDiff(diffs, "enabled", current.Enabled, &enabled)
+
+	return diffs
+}
+
+// DiffThrottleLimit returns the changes an update would make to the throttle
+// in force for its scope key and action; a faster rate or larger burst
+// loosens it
+func DiffThrottleLimit(current models.ThrottleLimit, update *models.ThrottleLimitUpdate) []models.LimitDiff {
+	diffs := []models.LimitDiff{}
+	diffs = appendFloatDiff(diffs, "rate", current.Rate, &update.Rate, higherLoosens, false)
+	diffs = appendIntDiff(diffs, "burst", current.Burst, &update.Burst, higherLoosens, false)
+	return diffs
+}
+
+func sameParent(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// anyLoosens reports whether any change in the diff loosens a limit
+func anyLoosens(diffs []models.LimitDiff) bool {
+	for _, diff := range diffs {
+		if diff.Loosens {
+			return true
+		}
+	}
+	return false
+}
+
+// currentPositionLimit returns the symbol's position limit, or the global
+// limits it falls back to
+func (rm *RiskManager) currentPositionLimit(symbol string) *models.PositionLimit {
+	var limit models.PositionLimit
+	if rm.db.GetDB().Where("symbol = ?", symbol).First(&limit).Error == nil {
+		return &limit
+	}
+
+	globalLimits := rm.GetLimits()
+	return &models.PositionLimit{
+		Symbol:              symbol,
+		MaxPosition:         globalLimits.MaxPositionSize,
+		MaxConcentrationPct: globalLimits.MaxPortfolioConcentration,
+	}
+}
+
+// SetPositionLimit creates or replaces the position limit for a symbol
+func (rm *RiskManager) SetPositionLimit(symbol string, update *models.PositionLimitUpdate, meta models.LimitChangeMeta) (*models.PositionLimit, error) {
+	limit := models.PositionLimit{
+		Symbol:              symbol,
+		MaxPosition:         update.MaxPosition,
+		MaxConcentrationPct: update.MaxConcentrationPct,
+		UpdatedAt:           time.Now(),
+		CreatedAt:           time.Now(),
+	}
+
+	if result := rm.db.GetDB().Save(&limit); result.Error != nil {
+		return nil, result.Error
+	}
+
+	snapshot := limit
+	if err := rm.recordLimitVersion(&models.RiskLimitVersion{
+		Kind:          models.LimitChangePositionLimit,
+		Symbol:        symbol,
+		PositionLimit: &snapshot,
+		Author:        meta.Author,
+		ApprovedBy:    meta.ApprovedBy,
+		Reason:        meta.Reason,
+		Source:        meta.Source,
+	}); err != nil {
+		rm.SendAlert("LIMIT_HISTORY_FAILED", "WARNING", symbol,
+			"Failed to record position limit version: "+err.Error(), nil)
+	}
+
+	return &limit, nil
+}
+
+// ProposeLimitChange applies a risk limit update that only tightens limits
+// and stores anything that loosens one as a pending request. Returns the
+// pending request, or nil if the update was applied.
+func (rm *RiskManager) ProposeLimitChange(update *models.RiskLimitsUpdate, meta models.LimitChangeMeta) (*models.LimitChangeRequest, []models.LimitDiff, error) {
+	if !attributed(meta.Author) {
+		return nil, nil, ErrLimitChangeAnonymous
+	}
+	diffs := DiffRiskLimits(rm.GetLimits(), update)
+	if !anyLoosens(diffs) {
+		if err := rm.UpdateLimit(update, meta); err != nil {
+			return nil, diffs, err
+		}
+		return nil, diffs, nil
+	}
+
+	request, err := rm.createLimitChange(models.LimitChangeRiskLimits, "", "", update, diffs, meta)
+	return request, diffs, err
+}
+
+// ProposePositionLimitChange applies a position limit update that only
+// tightens the limit and stores one that loosens it as a pending request.
+// Returns the pending request, or nil and the new limit if it was applied.
+func (rm *RiskManager) ProposePositionLimitChange(symbol string, update *models.PositionLimitUpdate, meta models.LimitChangeMeta) (*models.LimitChangeRequest, *models.PositionLimit, error) {
+	if !attributed(meta.Author) {
+		return nil, nil, ErrLimitChangeAnonymous
+	}
+	diffs := DiffPositionLimit(rm.currentPositionLimit(symbol), update)
+	if !anyLoosens(diffs) {
+		limit, err := rm.SetPositionLimit(symbol, update, meta)
+		return nil, limit, err
+	}
+
+	request, err := rm.createLimitChange(models.LimitChangePositionLimit, symbol, "", update, diffs, meta)
+	return request, nil, err
+}
+
+// limitNodeChange is the payload of a pending limit node change
+type limitNodeChange struct {
+	Delete bool                     `json:"delete,omitempty"`
+	Node   *models.LimitNodeRequest `json:"node,omitempty"`
+}
+
+// ProposeLimitNodeChange saves a limit node when the change only tightens
+// limits and stores one that loosens them as a pending request. Returns the
+// pending request, or nil and the saved node.
+func (rm *RiskManager) ProposeLimitNodeChange(id uint, req *models.LimitNodeRequest, meta models.LimitChangeMeta) (*models.LimitChangeRequest, *models.LimitNode, error) {
+	if !attributed(meta.Author) {
+		return nil, nil, ErrLimitChangeAnonymous
+	}
+	var current *models.LimitNode
+	if id != 0 {
+		var node models.LimitNode
+		if rm.db.GetDB().First(&node, id).Error != nil {
+			return nil, nil, ErrLimitNodeNotFound
+		}
+		current = &node
+	}
+	if err := rm.validateLimitNode(id, req); err != nil {
+		return nil, nil, err
+	}
+
+	diffs := DiffLimitNode(current, req)
+	if !anyLoosens(diffs) {
+		node, err := rm.SaveLimitNode(id, req, meta)
+		return nil, node, err
+	}
+
+	request, err := rm.createLimitChange(models.LimitChangeLimitNode, "", strconv.FormatUint(uint64(id), 10), limitNodeChange{Node: req}, diffs, meta)
+	return request, nil, err
+}
+
+// ProposeLimitNodeDelete stores the removal of a node without children as a
+// pending request. Removing a node drops its limits, so it always needs
+// approval.
+func (rm *RiskManager) ProposeLimitNodeDelete(id uint, meta models.LimitChangeMeta) (*models.LimitChangeRequest, error) {
+	if !attributed(meta.Author) {
+		return nil, ErrLimitChangeAnonymous
+	}
+	var node models.LimitNode
+	if rm.db.GetDB().First(&node, id).Error != nil {
+		return nil, ErrLimitNodeNotFound
+	}
+	var children int64
+	rm.db.GetDB().Model(&models.LimitNode{}).Where("parent_id = ?", id).Count(&children)
+	if children > 0 {
+		return nil, ErrLimitNodeHasChildren
+	}
+
+	diffs := []models.LimitDiff{{Field: "node", Current: node.Level + " " + node.Key, Proposed: nil, Loosens: true}}
+	return rm.createLimitChange(models.LimitChangeLimitNode, "", strconv.FormatUint(uint64(id), 10), limitNodeChange{Delete: true}, diffs, meta)
+}
+
+// ProposeThrottleLimitChange applies a throttle limit update that only
+// lowers the rate or burst and stores one that raises either as a pending
+// request. Returns the pending request, or nil and the new limit.
+func (rm *RiskManager) ProposeThrottleLimitChange(update *models.ThrottleLimitUpdate, meta models.LimitChangeMeta) (*models.LimitChangeRequest, *models.ThrottleLimit, error) {
+	if !attributed(meta.Author) {
+		return nil, nil, ErrLimitChangeAnonymous
+	}
+	diffs := DiffThrottleLimit(rm.GetThrottleLimit(update.Scope, update.Key, update.Action), update)
+	if !anyLoosens(diffs) {
+		limit, err := rm.UpdateThrottleLimit(update)
+		return nil, limit, err
+	}
+
+	request, err := rm.createLimitChange(models.LimitChangeThrottleLimit, "", throttleKey(update.Scope, update.Key, update.Action), update, diffs, meta)
+	return request, nil, err
+}
+
+func (rm *RiskManager) createLimitChange(kind, symbol, target string, update interface{}, diffs []models.LimitDiff, meta models.LimitChangeMeta) (*models.LimitChangeRequest, error) {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	request := &models.LimitChangeRequest{
+		Kind:        kind,
+		Symbol:      symbol,
+		Target:      target,
+		Payload:     string(payload),
+		Diff:        diffs,
+		Status:      models.LimitChangePending,
+		RequestedBy: meta.Author,
+		Reason:      meta.Reason,
+		Source:      meta.Source,
+		ExpiresAt:   now.Add(limitChangeTTL),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if result := rm.db.GetDB().Create(request); result.Error != nil {
+		return nil, result.Error
+	}
+
+	subject := "risk limits"
+	switch {
+	case kind == models.LimitChangeLimitNode && target == "0":
+		subject = "limit hierarchy"
+	case kind == models.LimitChangeLimitNode:
+		subject = "limit node " + target
+	case kind == models.LimitChangeThrottleLimit:
+		subject = "throttle limit " + target
+	case symbol != "":
+		subject = "position limit for " + symbol
+	}
+	rm.SendAlert("LIMIT_CHANGE_REQUESTED", "WARNING", symbol,
+		fmt.Sprintf("%s requested a change to the %s that loosens %s; awaiting approval",
+			meta.Author, subject, strings.Join(loosenedFields(diffs), ", ")),
+		map[string]interface{}{
+			"request_id": request.ID,
+			"diff":       diffs,
+			"expires_at": request.ExpiresAt,
+		})
+
+	return request, nil
+}
+
+func loosenedFields(diffs []models.LimitDiff) []string {
+	fields := []string{}
+	for _, diff := range diffs {
+		if diff.Loosens {
+			fields = append(fields, diff.Field)
+		}
+	}
+	return fields
+}
+
+// expireLimitChanges marks pending requests past their TTL as expired
+func (rm *RiskManager) expireLimitChanges() {
+	rm.db.GetDB().Model(&models.LimitChangeRequest{}).
+		Where("status = ? AND expires_at < ?", models.LimitChangePending, time.Now()).
+		Updates(map[string]interface{}{"status": models.LimitChangeExpired, "updated_at": time.Now()})
+}
+
+// GetLimitChangeRequests returns limit change requests, newest first,
+// optionally filtered by status
+func (rm *RiskManager) GetLimitChangeRequests(status string, limit int) ([]models.LimitChangeRequest, error) {
+	rm.expireLimitChanges()
+
+	query := rm.db.GetDB().Order("created_at DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	requests := []models.LimitChangeRequest{}
+	if result := query.Find(&requests); result.Error != nil {
+		return nil, result.Error
+	}
+	return requests, nil
+}
+
+// loadPendingLimitChange fetches a request that can still be reviewed by reviewer
+func (rm *RiskManager) loadPendingLimitChange(id uint, reviewer string) (*models.LimitChangeRequest, error) {
+	var request models.LimitChangeRequest
+	if rm.db.GetDB().First(&request, id).Error != nil {
+		return nil, ErrLimitChangeNotFound
+	}
+	if request.Status != models.LimitChangePending {
+		return &request, ErrLimitChangeNotPending
+	}
+	if time.Now().After(request.ExpiresAt) {
+		request.Status = models.LimitChangeExpired
+		request.UpdatedAt = time.Now()
+		rm.db.GetDB().Save(&request)
+		return &request, ErrLimitChangeExpired
+	}
+	if !attributed(reviewer) || !attributed(request.RequestedBy) {
+		return &request, ErrLimitChangeAnonymous
+	}
+	if reviewer == request.RequestedBy {
+		return &request, ErrLimitChangeSelfReview
+	}
+	return &request, nil
+}
+
+// ApproveLimitChange applies a pending request on behalf of a second user
+func (rm *RiskManager) ApproveLimitChange(id uint, approver, comment string) (*models.LimitChangeRequest, error) {
+	request, err := rm.loadPendingLimitChange(id, approver)
+	if err != nil {
+		return request, err
+	}
+
+	meta := models.LimitChangeMeta{
+		Author:     request.RequestedBy,
+		ApprovedBy: approver,
+		Reason:     request.Reason,
+		Source:     request.Source,
+	}
+
+	switch request.Kind {
+	case models.LimitChangeRiskLimits:
+		var update models.RiskLimitsUpdate
+		if err := json.Unmarshal([]byte(request.Payload), &update); err != nil {
+			return request, err
+		}
+		if err := rm.UpdateLimit(&update, meta); err != nil {
+			return request, err
+		}
+
+	case models.LimitChangePositionLimit:
+		var update models.PositionLimitUpdate
+		if err := json.Unmarshal([]byte(request.Payload), &update); err != nil {
+			return request, err
+		}
+		if _, err := rm.SetPositionLimit(request.Symbol, &update, meta); err != nil {
+			return request, err
+		}
+
+	case models.LimitChangeLimitNode:
+		var change limitNodeChange
+		if err := json.Unmarshal([]byte(request.Payload), &change); err != nil {
+			return request, err
+		}
+		id, err := strconv.ParseUint(request.Target, 10, 64)
+		if err != nil {
+			return request, err
+		}
+		if change.Delete {
+			err = rm.DeleteLimitNode(uint(id), meta)
+		} else if change.Node != nil {
+			_, err = rm.SaveLimitNode(uint(id), change.Node, meta)
+		}
+		if err != nil {
+			return request, err
+		}
+
+	case models.LimitChangeThrottleLimit:
+		var update models.ThrottleLimitUpdate
+		if err := json.Unmarshal([]byte(request.Payload), &update); err != nil {
+			return request, err
+		}
+		if _, err := rm.UpdateThrottleLimit(&update); err != nil {
+			return request, err
+		}
+
+	default:
+		return request, fmt.Errorf("unknown limit change kind: %s", request.Kind)
+	}
+
+	rm.closeLimitChange(request, models.LimitChangeApplied, approver, comment)
+	return request, nil
+}
+
+// RejectLimitChange declines a pending request
+func (rm *RiskManager) RejectLimitChange(id uint, reviewer, comment string) (*models.LimitChangeRequest, error) {
+	request, err := rm.loadPendingLimitChange(id, reviewer)
+	if err != nil {
+		return request, err
+	}
+
+	rm.closeLimitChange(request, models.LimitChangeRejected, reviewer, comment)
+	return request, nil
+}
+
+func (rm *RiskManager) closeLimitChange(request *models.LimitChangeRequest, status, reviewer, comment string) {
+	now := time.Now()
+	request.Status = status
+	request.ReviewedBy = reviewer
+	request.Comment = comment
+	request.ReviewedAt = &now
+	request.UpdatedAt = now
+	rm.db.GetDB().Save(request)
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/hft/backend/models"
+)
+
+func TestDiffRiskLimits(t *testing.T) {
+	current := &models.RiskLimits{
+		DailyLossLimit:         5000,
+		MaxOrderSize:           10000,
+		BreakerCooldownSeconds: 300,
+		MaxVaR:                 0,
+		MaxTradesPerDay:        50,
+		SelfTradeAction:        models.SelfTradeActionRejectNew,
+		EnforceTradingSession:  true,
+	}
+
+	f := func(v float64) *float64 { return &v }
+	i := func(v int) *int { return &v }
+	s := func(v string) *string { return &v }
+	b := func(v bool) *bool { return &v }
+
+	cases := []struct {
+		name    string
+		update  models.RiskLimitsUpdate
+		changes int
+		loosens bool
+	}{
+		{"raise daily loss limit", models.RiskLimitsUpdate{DailyLossLimit: f(10000)}, 1, true},
+		{"lower daily loss limit", models.RiskLimitsUpdate{DailyLossLimit: f(2000)}, 1, false},
+		{"unchanged value", models.RiskLimitsUpdate{MaxOrderSize: f(10000)}, 0, false},
+		{"shorter breaker cooldown", models.RiskLimitsUpdate{BreakerCooldownSeconds: i(60)}, 1, true},
+		{"enable VaR limit", models.RiskLimitsUpdate{MaxVaR: f(2500)}, 1, false},
+		{"disable trades-per-day limit", models.RiskLimitsUpdate{MaxTradesPerDay: i(0)}, 1, true},
+		{"turn off self-trade prevention", models.RiskLimitsUpdate{SelfTradeAction: s(models.SelfTradeActionNone)}, 1, true},
+		{"switch self-trade action", models.RiskLimitsUpdate{SelfTradeAction: s(models.SelfTradeActionCancelBoth)}, 1, true},
+		{"stop enforcing sessions", models.RiskLimitsUpdate{EnforceTradingSession: b(false)}, 1, true},
+		{"mixed change", models.RiskLimitsUpdate{MaxOrderSize: f(5000), DailyLossLimit: f(6000)}, 2, true},
+	}
+
+	for _, tc := range cases {
+		diffs := DiffRiskLimits(current, &tc.update)
+		if len(diffs) != tc.changes {
+			t.Errorf("%s: expected %d changes, got %d", tc.name, tc.changes, len(diffs))
+		}
+		if anyLoosens(diffs) != tc.loosens {
+			t.Errorf("%s: expected loosens=%v, got %+v", tc.name, tc.loosens, diffs)
+		}
+	}
+}
+
+func TestDiffPositionLimit(t *testing.T) {
+	current := &models.PositionLimit{Symbol: "AAPL", MaxPosition: 1000, MaxConcentrationPct: 20}
+
+	tighter := DiffPositionLimit(current, &models.PositionLimitUpdate{MaxPosition: 500, MaxConcentrationPct: 20})
+	if len(tighter) != 1 || anyLoosens(tighter) {
+		t.Errorf("expected one tightening change, got %+v", tighter)
+	}
+
+	looser := DiffPositionLimit(current, &models.PositionLimitUpdate{MaxPosition: 500, MaxConcentrationPct: 30})
+	if !anyLoosens(looser) {
+		t.Errorf("expected raising concentration to loosen, got %+v", looser)
+	}
+}
+
+func TestDiffLimitNode(t *testing.T) {
+	desk, other := uint(2), uint(3)
+	current := &models.LimitNode{ID: 4, Name: "alice", Level: models.LimitLevelTrader, Key: "alice", ParentID: &desk, MaxOrderSize: 10000, Enabled: true}
+	off := false
+
+	cases := []struct {
+		name    string
+		current *models.LimitNode
+		req     models.LimitNodeRequest
+		loosens bool
+	}{
+		{"new desk", nil, models.LimitNodeRequest{Name: "Equities", Level: models.LimitLevelDesk, MaxOrderSize: 50000}, false},
+		{"new strategy", nil, models.LimitNodeRequest{Name: "momentum", Level: models.LimitLevelStrategy, ParentID: &desk}, true},
+		{"lower order size", current, models.LimitNodeRequest{Name: "alice", Level: models.LimitLevelTrader, ParentID: &desk, MaxOrderSize: 5000}, false},
+		{"rename only", current, models.LimitNodeRequest{Name: "Alice", Key: "alice", Level: models.LimitLevelTrader, ParentID: &desk, MaxOrderSize: 10000}, false},
+		{"raise order size", current, models.LimitNodeRequest{Name: "alice", Level: models.LimitLevelTrader, ParentID: &desk, MaxOrderSize: 20000}, true},
+		{"remove order size limit", current, models.LimitNodeRequest{Name: "alice", Level: models.LimitLevelTrader, ParentID: &desk}, true},
+		{"move to another desk", current, models.LimitNodeRequest{Name: "alice", Level: models.LimitLevelTrader, ParentID: &other, MaxOrderSize: 10000}, true},
+		{"disable", current, models.LimitNodeRequest{Name: "alice", Level: models.LimitLevelTrader, ParentID: &desk, MaxOrderSize: 10000, Enabled: &off}, true},
+	}
+
+	for _, tc := range cases {
+		diffs := DiffLimitNode(tc.current, &tc.req)
+		if anyLoosens(diffs) != tc.loosens {
+			t.Errorf("%s: expected loosens=%v, got %+v", tc.name, tc.loosens, diffs)
+		}
+	}
+}
+
+func TestDiffThrottleLimit(t *testing.T) {
+	current := models.ThrottleLimit{Scope: "USER", Key: "alice", Action: models.ThrottleActionNew, Rate: 10, Burst: 20}
+
+	slower := DiffThrottleLimit(current, &models.ThrottleLimitUpdate{Rate: 5, Burst: 20})
+	if len(slower) != 1 || anyLoosens(slower) {
+		t.Errorf("expected one tightening change, got %+v", slower)
+	}
+
+	larger := DiffThrottleLimit(current, &models.ThrottleLimitUpdate{Rate: 5, Burst: 40})
+	if !anyLoosens(larger) {
+		t.Errorf("expected a larger burst to loosen, got %+v", larger)
+	}
+}
+
+func TestProposeRejectsAnonymousAuthor(t *testing.T) {
+	rm := &RiskManager{}
+	f := func(v float64) *float64 { return &v }
+
+	for _, author := range []string{"", "anonymous"} {
+		meta := models.LimitChangeMeta{Author: author}
+		if _, _, err := rm.ProposeLimitChange(&models.RiskLimitsUpdate{DailyLossLimit: f(1e6)}, meta); err != ErrLimitChangeAnonymous {
+			t.Errorf("risk limits by %q: got %v", author, err)
+		}
+		if _, _, err := rm.ProposePositionLimitChange("AAPL", &models.PositionLimitUpdate{MaxPosition: 1e6}, meta); err != ErrLimitChangeAnonymous {
+			t.Errorf("position limit by %q: got %v", author, err)
+		}
+	}
+}
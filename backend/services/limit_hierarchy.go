@@ -0,0 +1,519 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// Errors returned when changing a limit node
+var (
+	ErrLimitNodeNotFound    = errors.New("limit node not found")
+	ErrLimitNodeHasChildren = errors.New("limit node has child nodes")
+)
+
+// hierarchyBookTTL is how long rolled-up node positions and P&L are reused
+// by pre-trade checks before being recomputed from executions
+const hierarchyBookTTL = 5 * time.Second
+
+// limitLevelDepth orders the hierarchy levels from the root down
+var limitLevelDepth = map[string]int{
+	models.LimitLevelFirm:     0,
+	models.LimitLevelDesk:     1,
+	models.LimitLevelTrader:   2,
+	models.LimitLevelStrategy: 3,
+}
+
+// limitHierarchy holds the limit nodes and per-node order rate windows
+type limitHierarchy struct {
+	nodes    map[uint]models.LimitNode
+	children map[uint][]uint
+	byKey    map[string]uint // level:key -> node
+	roots    []uint
+
+	mu     sync.Mutex
+	rates  map[uint]*eventWindow
+	book   *hierarchyBook
+	bookAt time.Time
+
+	// Net quantities of every strategy/trader pair, summed incrementally
+	// over executions up to heldThrough
+	held        map[attributionKey]float64
+	heldThrough uint
+}
+
+// attributionKey identifies a strategy/trader pair's holding in a symbol
+type attributionKey struct {
+	strategy, trader, symbol string
+}
+
+// hierarchyBook holds positions and today's P&L rolled up to every node
+type hierarchyBook struct {
+	positions map[uint]map[string]float64
+	dailyPnL  map[uint]float64
+	marks     map[string]float64
+}
+
+func newLimitHierarchy(nodes []models.LimitNode) *limitHierarchy {
+	h := &limitHierarchy{
+		nodes:    make(map[uint]models.LimitNode, len(nodes)),
+		children: make(map[uint][]uint),
+		byKey:    make(map[string]uint, len(nodes)),
+		rates:    make(map[uint]*eventWindow),
+		held:     make(map[attributionKey]float64),
+	}
+
+	for _, node := range nodes {
+		h.nodes[node.ID] = node
+		h.byKey[node.Level+":"+node.Key] = node.ID
+	}
+	for _, node := range nodes {
+		if node.ParentID == nil {
+			h.roots = append(h.roots, node.ID)
+		} else if _, ok := h.nodes[*node.ParentID]; ok {
+			h.children[*node.ParentID] = append(h.children[*node.ParentID], node.ID)
+		}
+	}
+
+	return h
+}
+
+// attribute returns the node an order is booked against: the strategy node,
+// else the trader node, else the firm
+func (h *limitHierarchy) attribute(strategy, trader string) (uint, bool) {
+	if strategy != "" {
+		if id, ok := h.byKey[models.LimitLevelStrategy+":"+strategy]; ok {
+			return id, true
+		}
+	}
+	if trader != "" {
+		if id, ok := h.byKey[models.LimitLevelTrader+":"+trader]; ok {
+			return id, true
+		}
+	}
+	for _, id := range h.roots {
+		if h.nodes[id].Level == models.LimitLevelFirm {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// path returns a node followed by its ancestors up to the root
+func (h *limitHierarchy) path(id uint) []models.LimitNode {
+	path := []models.LimitNode{}
+	for depth := 0; depth <= len(limitLevelDepth); depth++ {
+		node, ok := h.nodes[id]
+		if !ok {
+			break
+		}
+		path = append(path, node)
+		if node.ParentID == nil {
+			break
+		}
+		id = *node.ParentID
+	}
+	return path
+}
+
+// rate returns the one-minute order window of a node
+func (h *limitHierarchy) rate(id uint) *eventWindow {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	window, ok := h.rates[id]
+	if !ok {
+		window = newEventWindow(time.Minute)
+		h.rates[id] = window
+	}
+	return window
+}
+
+// attributedFill is a net quantity and cash flow of one strategy/trader pair
+// in a symbol
+type attributedFill struct {
+	Strategy string
+	Trader   string
+	Symbol   string
+	Quantity float64
+	Cash     float64
+	AvgPrice float64
+}
+
+// rollUpBook attributes positions and today's fills to nodes and adds each
+// node's figures to all its ancestors. Today's P&L is the cash flow of
+// today's fills marked to market; positions carried overnight belong to no
+// node.
+func (h *limitHierarchy) rollUpBook(positions, today []attributedFill, mark func(symbol string) float64) *hierarchyBook {
+	book := &hierarchyBook{
+		positions: make(map[uint]map[string]float64),
+		dailyPnL:  make(map[uint]float64),
+		marks:     make(map[string]float64),
+	}
+
+	for _, fill := range positions {
+		leaf, ok := h.attribute(fill.Strategy, fill.Trader)
+		if !ok {
+			continue
+		}
+		for _, node := range h.path(leaf) {
+			if book.positions[node.ID] == nil {
+				book.positions[node.ID] = make(map[string]float64)
+			}
+			book.positions[node.ID][fill.Symbol] += fill.Quantity
+		}
+	}
+
+	for _, fill := range today {
+		leaf, ok := h.attribute(fill.Strategy, fill.Trader)
+		if !ok {
+			continue
+		}
+
+		price, ok := book.marks[fill.Symbol]
+		if !ok {
+			price = mark(fill.Symbol)
+			if price <= 0 {
+				price = fill.AvgPrice
+			}
+			book.marks[fill.Symbol] = price
+		}
+
+		pnl := fill.Cash + fill.Quantity*price
+		for _, node := range h.path(leaf) {
+			book.dailyPnL[node.ID] += pnl
+		}
+	}
+
+	return book
+}
+
+// loadLimitHierarchy reads the limit nodes
+func (rm *RiskManager) loadLimitHierarchy() *limitHierarchy {
+	var nodes []models.LimitNode
+	rm.db.GetDB().Find(&nodes)
+	return newLimitHierarchy(nodes)
+}
+
+// refreshLimitHierarchy reloads the nodes, keeping order rate windows and
+// summed holdings
+func (rm *RiskManager) refreshLimitHierarchy() {
+	hierarchy := rm.loadLimitHierarchy()
+
+	rm.mu.Lock()
+	if rm.hierarchy != nil {
+		rm.hierarchy.mu.Lock()
+		hierarchy.rates = rm.hierarchy.rates
+		hierarchy.held = rm.hierarchy.held
+		hierarchy.heldThrough = rm.hierarchy.heldThrough
+		rm.hierarchy.mu.Unlock()
+	}
+	rm.hierarchy = hierarchy
+	rm.mu.Unlock()
+}
+
+// hierarchyBook returns the rolled-up book, rebuilding it when stale.
+// Holdings are advanced by the executions recorded since the last rebuild,
+// so only the first rebuild reads the full history.
+func (rm *RiskManager) hierarchyBook(h *limitHierarchy, maxAge time.Duration) *hierarchyBook {
+	h.mu.Lock()
+	if h.book != nil && time.Since(h.bookAt) < maxAge {
+		book := h.book
+		h.mu.Unlock()
+		return book
+	}
+	after := h.heldThrough
+	h.mu.Unlock()
+
+	var through struct{ ID uint }
+	rm.db.GetDB().Raw(`SELECT COALESCE(MAX(id), 0) AS id FROM executions`).Scan(&through)
+
+	fills := []attributedFill{}
+	if through.ID > after {
+		rm.db.GetDB().Raw(`
+			SELECT e.strategy, e.trader, e.symbol,
+				SUM(CASE WHEN e.side = 'BUY' THEN e.fill_qty ELSE -e.fill_qty END) AS quantity
+			FROM executions e
+			WHERE e.id > ? AND e.id <= ?
+			GROUP BY e.strategy, e.trader, e.symbol`, after, through.ID).Scan(&fills)
+	}
+
+	today := []attributedFill{}
+	rm.db.GetDB().Raw(`
+		SELECT e.strategy, e.trader, e.symbol,
+			SUM(CASE WHEN e.side = 'BUY' THEN e.fill_qty ELSE -e.fill_qty END) AS quantity,
+			SUM(CASE WHEN e.side = 'BUY' THEN -e.fill_qty * e.fill_price ELSE e.fill_qty * e.fill_price END) AS cash,
+			SUM(e.fill_qty * e.fill_price) / NULLIF(SUM(e.fill_qty), 0) AS avg_price
+		FROM executions e
+		WHERE e.timestamp >= ?
+		GROUP BY e.strategy, e.trader, e.symbol`, rm.tradingDayStart(time.Now())).Scan(&today)
+
+	h.mu.Lock()
+	if h.heldThrough == after {
+		for _, fill := range fills {
+			h.held[attributionKey{fill.Strategy, fill.Trader, fill.Symbol}] += fill.Quantity
+		}
+		h.heldThrough = through.ID
+	}
+
+	positions := make([]attributedFill, 0, len(h.held))
+	for key, quantity := range h.held {
+		if quantity != 0 {
+			positions = append(positions, attributedFill{Strategy: key.strategy, Trader: key.trader, Symbol: key.symbol, Quantity: quantity})
+		}
+	}
+
+	h.mu.Unlock()
+
+	book := h.rollUpBook(positions, today, rm.markPrice)
+
+	h.mu.Lock()
+	h.book = book
+	h.bookAt = time.Now()
+	h.mu.Unlock()
+
+	return book
+}
+
+// markPrice returns the last cached market price for a symbol, or 0
+func (rm *RiskManager) markPrice(symbol string) float64 {
+	if rm.ledger == nil {
+		return 0
+	}
+	return rm.ledger.estimatePrice(symbol)
+}
+
+// checkHierarchyLimits validates an order against the limits of the node it
+// is attributed to and every ancestor. With a basket it is a dry run that
+// also counts the basket's earlier orders. Caller holds rm.mu.
+func (rm *RiskManager) checkHierarchyLimits(order *models.OrderRequest, identity models.ThrottleIdentity, reduceOnly bool, basket *basketUsage) error {
+	h := rm.hierarchy
+	if h == nil || len(h.nodes) == 0 {
+		return nil
+	}
+
+	leaf, ok := h.attribute(order.Strategy, identity.UserID)
+	if !ok {
+		return nil
+	}
+	path := h.path(leaf)
+	book := rm.hierarchyBook(h, hierarchyBookTTL)
+
+	notional := rm.orderNotional(order)
+
+	delta := order.Quantity
+	if order.Side != "BUY" {
+		delta = -order.Quantity
+	}
+
+	for _, node := range path {
+		if !node.Enabled {
+			continue
+		}
+		name := fmt.Sprintf("%s %s", strings.ToLower(node.Level), node.Name)
+
+		if node.MaxOrderSize > 0 && notional > node.MaxOrderSize {
+			return fmt.Errorf("order size exceeds %s limit: $%.2f > $%.2f", name, notional, node.MaxOrderSize)
+		}
+
+		if node.MaxPosition > 0 {
+			current := book.positions[node.ID][order.Symbol]
+			if basket != nil {
+				current += basket.nodePositions[node.ID][order.Symbol]
+			}
+			projected := current + delta
+			if math.Abs(projected) > node.MaxPosition && math.Abs(projected) > math.Abs(current) {
+				return fmt.Errorf("position would exceed %s limit for %s: %.2f > %.2f", name, order.Symbol, math.Abs(projected), node.MaxPosition)
+			}
+		}
+
+		if node.DailyLossLimit > 0 && !reduceOnly && book.dailyPnL[node.ID] <= -node.DailyLossLimit {
+			return fmt.Errorf("%s daily loss limit reached: $%.2f <= -$%.2f", name, book.dailyPnL[node.ID], node.DailyLossLimit)
+		}
+
+		if node.MaxOrdersPerMinute > 0 {
+			count, _ := h.rate(node.ID).Counts()
+			if basket != nil {
+				count += basket.nodeOrders[node.ID]
+			}
+			if count >= node.MaxOrdersPerMinute {
+				return fmt.Errorf("%s order rate limit reached: %d orders in the last minute (limit: %d)", name, count, node.MaxOrdersPerMinute)
+			}
+		}
+	}
+
+	if basket == nil {
+		for _, node := range path {
+			h.rate(node.ID).Record(false)
+		}
+	}
+
+	return nil
+}
+
+// GetLimitTree returns every root of the limit hierarchy with positions,
+// P&L and limit utilisation rolled up through each subtree
+func (rm *RiskManager) GetLimitTree() []*models.LimitTreeNode {
+	rm.mu.RLock()
+	h := rm.hierarchy
+	rm.mu.RUnlock()
+
+	tree := []*models.LimitTreeNode{}
+	if h == nil {
+		return tree
+	}
+
+	book := rm.hierarchyBook(h, 0)
+	for _, id := range h.roots {
+		tree = append(tree, rm.limitTreeNode(h, book, id))
+	}
+	return tree
+}
+
+func (rm *RiskManager) limitTreeNode(h *limitHierarchy, book *hierarchyBook, id uint) *models.LimitTreeNode {
+	node := h.nodes[id]
+	orders, _ := h.rate(id).Counts()
+
+	treeNode := &models.LimitTreeNode{
+		LimitNode:        node,
+		Positions:        make(map[string]float64),
+		DailyPnL:         book.dailyPnL[id],
+		OrdersLastMinute: orders,
+		Children:         []*models.LimitTreeNode{},
+	}
+
+	var largestPosition float64
+	for symbol, quantity := range book.positions[id] {
+		if quantity == 0 {
+			continue
+		}
+		treeNode.Positions[symbol] = quantity
+		treeNode.GrossExposure += math.Abs(quantity) * book.marks[symbol]
+		largestPosition = math.Max(largestPosition, math.Abs(quantity))
+	}
+
+	treeNode.Utilisation = map[string]models.LimitUtilisation{
+		"position":   computeUtilisation(largestPosition, node.MaxPosition),
+		"daily_loss": computeUtilisation(math.Max(-treeNode.DailyPnL, 0), node.DailyLossLimit),
+		"order_rate": computeUtilisation(float64(orders), float64(node.MaxOrdersPerMinute)),
+	}
+
+	for _, childID := range h.children[id] {
+		treeNode.Children = append(treeNode.Children, rm.limitTreeNode(h, book, childID))
+	}
+	return treeNode
+}
+
+// limitNodeKey returns the key a node is saved with: the given key, else
+// its name
+func limitNodeKey(req *models.LimitNodeRequest) string {
+	if key := strings.TrimSpace(req.Key); key != "" {
+		return key
+	}
+	return strings.TrimSpace(req.Name)
+}
+
+// validateLimitNode checks that a node can sit under its parent and above
+// its children
+func (rm *RiskManager) validateLimitNode(id uint, req *models.LimitNodeRequest) error {
+	if req.ParentID == nil {
+		if req.Level != models.LimitLevelFirm {
+			return fmt.Errorf("only a FIRM node can be a root")
+		}
+	} else {
+		var parent models.LimitNode
+		if rm.db.GetDB().First(&parent, *req.ParentID).Error != nil {
+			return fmt.Errorf("parent node %d not found", *req.ParentID)
+		}
+		if limitLevelDepth[parent.Level] >= limitLevelDepth[req.Level] {
+			return fmt.Errorf("a %s node cannot sit under a %s node", req.Level, parent.Level)
+		}
+	}
+
+	if id != 0 {
+		var children []models.LimitNode
+		rm.db.GetDB().Where("parent_id = ?", id).Find(&children)
+		for _, child := range children {
+			if limitLevelDepth[child.Level] <= limitLevelDepth[req.Level] {
+				return fmt.Errorf("a %s node cannot sit above its %s child %s", req.Level, child.Level, child.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// SaveLimitNode creates a limit node, or updates it when id is non-zero,
+// and records the new version
+func (rm *RiskManager) SaveLimitNode(id uint, req *models.LimitNodeRequest, meta models.LimitChangeMeta) (*models.LimitNode, error) {
+	var node models.LimitNode
+	if id != 0 && rm.db.GetDB().First(&node, id).Error != nil {
+		return nil, ErrLimitNodeNotFound
+	}
+	if err := rm.validateLimitNode(id, req); err != nil {
+		return nil, err
+	}
+
+	node.Name = req.Name
+	node.Level = req.Level
+	node.Key = limitNodeKey(req)
+	node.ParentID = req.ParentID
+	node.MaxPosition = req.MaxPosition
+	node.MaxOrderSize = req.MaxOrderSize
+	node.DailyLossLimit = req.DailyLossLimit
+	node.MaxOrdersPerMinute = req.MaxOrdersPerMinute
+	node.Enabled = req.Enabled == nil || *req.Enabled
+	node.UpdatedAt = time.Now()
+
+	if result := rm.db.GetDB().Save(&node); result.Error != nil {
+		return nil, result.Error
+	}
+
+	snapshot := node
+	rm.recordLimitNode(node.ID, &snapshot, meta)
+	rm.refreshLimitHierarchy()
+	return &node, nil
+}
+
+// DeleteLimitNode removes a node without children and records its removal
+func (rm *RiskManager) DeleteLimitNode(id uint, meta models.LimitChangeMeta) error {
+	var children int64
+	rm.db.GetDB().Model(&models.LimitNode{}).Where("parent_id = ?", id).Count(&children)
+	if children > 0 {
+		return ErrLimitNodeHasChildren
+	}
+
+	result := rm.db.GetDB().Delete(&models.LimitNode{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLimitNodeNotFound
+	}
+
+	rm.recordLimitNode(id, nil, meta)
+	rm.refreshLimitHierarchy()
+	return nil
+}
+
+func (rm *RiskManager) recordLimitNode(id uint, node *models.LimitNode, meta models.LimitChangeMeta) {
+	target := strconv.FormatUint(uint64(id), 10)
+	err := rm.recordLimitVersion(&models.RiskLimitVersion{
+		Kind:       models.LimitChangeLimitNode,
+		Target:     target,
+		LimitNode:  node,
+		Author:     meta.Author,
+		ApprovedBy: meta.ApprovedBy,
+		Reason:     meta.Reason,
+		Source:     meta.Source,
+	})
+	if err != nil {
+		rm.SendAlert("LIMIT_HISTORY_FAILED", "WARNING", "",
+			"Failed to record version of limit node "+target+": "+err.Error(), nil)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func testHierarchy() *limitHierarchy {
+	id := func(v uint) *uint { return &v }
+	return newLimitHierarchy([]models.LimitNode{
+		{ID: 1, Name: "Firm", Level: models.LimitLevelFirm, Key: "firm", DailyLossLimit: 10000, Enabled: true},
+		{ID: 2, Name: "Equities", Level: models.LimitLevelDesk, Key: "equities", ParentID: id(1), MaxPosition: 500, Enabled: true},
+		{ID: 3, Name: "Alice", Level: models.LimitLevelTrader, Key: "alice", ParentID: id(2), MaxOrderSize: 5000, Enabled: true},
+		{ID: 4, Name: "Momentum", Level: models.LimitLevelStrategy, Key: "momentum", ParentID: id(3), MaxOrdersPerMinute: 2, Enabled: true},
+	})
+}
+
+func TestLimitHierarchyAttribution(t *testing.T) {
+	h := testHierarchy()
+
+	cases := []struct {
+		strategy, trader string
+		expected         uint
+	}{
+		{"momentum", "bob", 4},
+		{"unknown", "alice", 3},
+		{"", "bob", 1},
+	}
+	for _, tc := range cases {
+		if got, _ := h.attribute(tc.strategy, tc.trader); got != tc.expected {
+			t.Errorf("attribute(%q, %q) = %d, expected %d", tc.strategy, tc.trader, got, tc.expected)
+		}
+	}
+
+	if path := h.path(4); len(path) != 4 || path[3].ID != 1 {
+		t.Errorf("expected strategy path up to the firm, got %+v", path)
+	}
+}
+
+func TestLimitHierarchyRollUp(t *testing.T) {
+	h := testHierarchy()
+	mark := func(symbol string) float64 { return 110 }
+
+	book := h.rollUpBook(
+		[]attributedFill{
+			{Strategy: "momentum", Trader: "alice", Symbol: "AAPL", Quantity: 100},
+			{Trader: "alice", Symbol: "AAPL", Quantity: 50},
+			{Trader: "bob", Symbol: "MSFT", Quantity: -20},
+		},
+		[]attributedFill{
+			{Strategy: "momentum", Trader: "alice", Symbol: "AAPL", Quantity: 100, Cash: -10000},
+		},
+		mark)
+
+	if got := book.positions[4]["AAPL"]; got != 100 {
+		t.Errorf("strategy AAPL position = %.0f, expected 100", got)
+	}
+	if got := book.positions[2]["AAPL"]; got != 150 {
+		t.Errorf("desk AAPL position = %.0f, expected 150", got)
+	}
+	if got := book.positions[1]["MSFT"]; got != -20 {
+		t.Errorf("firm MSFT position = %.0f, expected -20", got)
+	}
+	if got := book.dailyPnL[1]; got != 1000 {
+		t.Errorf("firm daily P&L = %.2f, expected 1000", got)
+	}
+}
+
+func TestCheckHierarchyLimits(t *testing.T) {
+	h := testHierarchy()
+	h.book = &hierarchyBook{
+		positions: map[uint]map[string]float64{2: {"AAPL": 450}},
+		dailyPnL:  map[uint]float64{},
+		marks:     map[string]float64{},
+	}
+	h.bookAt = time.Now()
+	rm := &RiskManager{hierarchy: h}
+
+	alice := models.ThrottleIdentity{UserID: "alice"}
+
+	cases := []struct {
+		name    string
+		order   models.OrderRequest
+		allowed bool
+	}{
+		{"within limits", models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 10, Price: 100}, true},
+		{"trader order size", models.OrderRequest{Symbol: "MSFT", Side: "BUY", Quantity: 100, Price: 100}, false},
+		{"desk position at limit", models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 50, Price: 100}, true},
+		{"desk position exceeded", models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 51, Price: 50}, false},
+	}
+	for _, tc := range cases {
+		err := rm.checkHierarchyLimits(&tc.order, alice, false, newBasketUsage())
+		if (err == nil) != tc.allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", tc.name, tc.allowed, err)
+		}
+	}
+
+	// Strategy node allows two orders a minute
+	order := models.OrderRequest{Symbol: "AAPL", Side: "SELL", Quantity: 1, Price: 100, Strategy: "momentum"}
+	for i := 0; i < 2; i++ {
+		if err := rm.checkHierarchyLimits(&order, alice, false, nil); err != nil {
+			t.Fatalf("order %d: unexpected rejection: %v", i+1, err)
+		}
+	}
+	if err := rm.checkHierarchyLimits(&order, alice, false, nil); err == nil {
+		t.Error("expected the third order in a minute to be rejected")
+	}
+
+	// Firm loss limit blocks new risk but not reducing orders
+	h.book.dailyPnL[1] = -10000
+	buy := models.OrderRequest{Symbol: "MSFT", Side: "BUY", Quantity: 1, Price: 100}
+	if err := rm.checkHierarchyLimits(&buy, models.ThrottleIdentity{UserID: "bob"}, false, newBasketUsage()); err == nil {
+		t.Error("expected firm loss limit to block the order")
+	}
+	if err := rm.checkHierarchyLimits(&buy, models.ThrottleIdentity{UserID: "bob"}, true, newBasketUsage()); err != nil {
+		t.Errorf("expected reduce-only order to pass, got %v", err)
+	}
+}
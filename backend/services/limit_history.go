@@ -0,0 +1,219 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hft/backend/models"
+	"gorm.io/gorm"
+)
+
+// ErrLimitVersionNotFound is returned when rolling back to an unknown version
+var ErrLimitVersionNotFound = errors.New("limit version not found")
+
+// sameRiskLimits reports whether two sets of limits hold the same values,
+// ignoring row identity and timestamps
+func sameRiskLimits(a, b models.RiskLimits) bool {
+	a.ID, a.CreatedAt, a.UpdatedAt = 0, time.Time{}, time.Time{}
+	b.ID, b.CreatedAt, b.UpdatedAt = 0, time.Time{}, time.Time{}
+	return a == b
+}
+
+// recordLimitVersion appends an immutable snapshot of the risk limits, a
+// position limit or a limit node with the next version number for its kind,
+// symbol and target
+func (rm *RiskManager) recordLimitVersion(version *models.RiskLimitVersion) error {
+	return rm.db.GetDB().Transaction(func(tx *gorm.DB) error {
+		var latest int
+		tx.Model(&models.RiskLimitVersion{}).
+			Where("kind = ? AND symbol = ? AND target = ?", version.Kind, version.Symbol, version.Target).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest)
+
+		version.Version = latest + 1
+		if version.CreatedAt.IsZero() {
+			version.CreatedAt = time.Now()
+		}
+		return tx.Create(version).Error
+	})
+}
+
+func (rm *RiskManager) recordRiskLimits(limits models.RiskLimits, meta models.LimitChangeMeta) {
+	err := rm.recordLimitVersion(&models.RiskLimitVersion{
+		Kind:       models.LimitChangeRiskLimits,
+		RiskLimits: &limits,
+		Author:     meta.Author,
+		ApprovedBy: meta.ApprovedBy,
+		Reason:     meta.Reason,
+		Source:     meta.Source,
+	})
+	if err != nil {
+		rm.SendAlert("LIMIT_HISTORY_FAILED", "WARNING", "",
+			"Failed to record risk limit version: "+err.Error(), nil)
+	}
+}
+
+// snapshotLoadedLimits records limits loaded from the database when they
+// differ from the latest version, so edits made outside the API still
+// appear in the history
+func (rm *RiskManager) snapshotLoadedLimits(limits models.RiskLimits) {
+	latest, err := rm.latestRiskLimitVersion(time.Now())
+	if err == nil && latest.RiskLimits != nil && sameRiskLimits(*latest.RiskLimits, limits) {
+		return
+	}
+
+	meta := models.LimitChangeMeta{Author: "system", Source: models.LimitSourceReload}
+	if err != nil {
+		meta.Source = models.LimitSourceInitial
+	}
+	rm.recordRiskLimits(limits, meta)
+}
+
+// latestRiskLimitVersion returns the risk limits version in force at t
+func (rm *RiskManager) latestRiskLimitVersion(t time.Time) (*models.RiskLimitVersion, error) {
+	var version models.RiskLimitVersion
+	result := rm.db.GetDB().
+		Where("kind = ? AND created_at <= ?", models.LimitChangeRiskLimits, t).
+		Order("created_at DESC, id DESC").First(&version)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &version, nil
+}
+
+// GetLimitsAsOf returns the risk limits and position limits in force at t
+func (rm *RiskManager) GetLimitsAsOf(t time.Time) (*models.LimitsAsOf, error) {
+	version, err := rm.latestRiskLimitVersion(t)
+	if err != nil {
+		return nil, fmt.Errorf("no risk limits recorded at or before %s", t.Format(time.RFC3339))
+	}
+
+	// Latest version per symbol at or before t
+	latestPerSymbol := rm.db.GetDB().Raw(`
+		SELECT DISTINCT ON (symbol) id FROM risk_limit_versions
+		WHERE kind = ? AND created_at <= ?
+		ORDER BY symbol, created_at DESC, id DESC`,
+		models.LimitChangePositionLimit, t)
+
+	var versions []models.RiskLimitVersion
+	rm.db.GetDB().Where("id IN (?)", latestPerSymbol).Order("symbol").Find(&versions)
+
+	positionLimits := []models.PositionLimit{}
+	for _, v := range versions {
+		if v.PositionLimit != nil {
+			positionLimits = append(positionLimits, *v.PositionLimit)
+		}
+	}
+
+	return &models.LimitsAsOf{
+		AsOf:           t,
+		Limits:         version.RiskLimits,
+		Version:        version,
+		PositionLimits: positionLimits,
+	}, nil
+}
+
+// GetLimitHistory returns limit versions, newest first, optionally filtered
+// by kind and symbol
+func (rm *RiskManager) GetLimitHistory(kind, symbol string, limit int) ([]models.RiskLimitVersion, error) {
+	query := rm.db.GetDB().Order("created_at DESC, id DESC").Limit(limit)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+
+	versions := []models.RiskLimitVersion{}
+	if result := query.Find(&versions); result.Error != nil {
+		return nil, result.Error
+	}
+	return versions, nil
+}
+
+// limitsToUpdate converts a full set of limits into an update that sets
+// every field
+func limitsToUpdate(limits *models.RiskLimits) *models.RiskLimitsUpdate {
+	l := *limits
+	return &models.RiskLimitsUpdate{
+		MaxPositionSize:           &l.MaxPositionSize,
+		MaxOrderSize:              &l.MaxOrderSize,
+		DailyLossLimit:            &l.DailyLossLimit,
+		MaxPortfolioConcentration: &l.MaxPortfolioConcentration,
+		MaxLeverage:               &l.MaxLeverage,
+		MaxOrdersPerSecond:        &l.MaxOrdersPerSecond,
+		BreakerCooldownSeconds:    &l.BreakerCooldownSeconds,
+		RejectionRateThreshold:    &l.RejectionRateThreshold,
+		EngineErrorBurst:          &l.EngineErrorBurst,
+		SymbolLossLimit:           &l.SymbolLossLimit,
+		MaxVaR:                    &l.MaxVaR,
+		VaRConfidence:             &l.VaRConfidence,
+		SelfTradeAction:           &l.SelfTradeAction,
+		WashTradeWindowSeconds:    &l.WashTradeWindowSeconds,
+		WashTradePriceTolerance:   &l.WashTradePriceTolerance,
+		PDTMaxDayTrades:           &l.PDTMaxDayTrades,
+		PDTEquityThreshold:        &l.PDTEquityThreshold,
+		MaxTradesPerDay:           &l.MaxTradesPerDay,
+		MaxDailyTurnover:          &l.MaxDailyTurnover,
+		EnforceTradingSession:     &l.EnforceTradingSession,
+		UtilisationWarningPct:     &l.UtilisationWarningPct,
+		UtilisationCriticalPct:    &l.UtilisationCriticalPct,
+		Enabled:                   &l.Enabled,
+	}
+}
+
+// limitNodeToRequest converts a node snapshot into a request that restores it
+func limitNodeToRequest(node *models.LimitNode) *models.LimitNodeRequest {
+	enabled := node.Enabled
+	return &models.LimitNodeRequest{
+		Name:               node.Name,
+		Level:              node.Level,
+		Key:                node.Key,
+		ParentID:           node.ParentID,
+		MaxPosition:        node.MaxPosition,
+		MaxOrderSize:       node.MaxOrderSize,
+		DailyLossLimit:     node.DailyLossLimit,
+		MaxOrdersPerMinute: node.MaxOrdersPerMinute,
+		Enabled:            &enabled,
+	}
+}
+
+// RollbackLimits restores the limits of an earlier version. The rollback
+// goes through the same approval rules as any other change: restoring looser
+// limits is queued for a second user. Returns the pending request if one was
+// created.
+func (rm *RiskManager) RollbackLimits(versionID uint, author, reason string) (*models.RiskLimitVersion, *models.LimitChangeRequest, error) {
+	if !attributed(author) {
+		return nil, nil, ErrLimitChangeAnonymous
+	}
+
+	var version models.RiskLimitVersion
+	if rm.db.GetDB().First(&version, versionID).Error != nil {
+		return nil, nil, ErrLimitVersionNotFound
+	}
+
+	if reason == "" {
+		reason = fmt.Sprintf("rollback to version %d", version.Version)
+	}
+	meta := models.LimitChangeMeta{Author: author, Reason: reason, Source: models.LimitSourceRollback}
+
+	switch {
+	case version.Kind == models.LimitChangeRiskLimits && version.RiskLimits != nil:
+		request, _, err := rm.ProposeLimitChange(limitsToUpdate(version.RiskLimits), meta)
+		return &version, request, err
+
+	case version.Kind == models.LimitChangePositionLimit && version.PositionLimit != nil:
+		update := &models.PositionLimitUpdate{
+			MaxPosition:         version.PositionLimit.MaxPosition,
+			MaxConcentrationPct: version.PositionLimit.MaxConcentrationPct,
+		}
+		request, _, err := rm.ProposePositionLimitChange(version.Symbol, update, meta)
+		return &version, request, err
+
+	case version.Kind == models.LimitChangeLimitNode && version.LimitNode != nil:
+		request, _, err := rm.ProposeLimitNodeChange(version.LimitNode.ID, limitNodeToRequest(version.LimitNode), meta)
+		return &version, request, err
+	}
+
+	return &version, nil, fmt.Errorf("limit version %d has no snapshot", version.ID)
+}
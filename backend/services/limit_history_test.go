@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestSameRiskLimitsIgnoresTimestamps(t *testing.T) {
+	a := models.RiskLimits{ID: 1, DailyLossLimit: 5000, UpdatedAt: time.Now()}
+	b := models.RiskLimits{ID: 2, DailyLossLimit: 5000, CreatedAt: time.Now().Add(-time.Hour)}
+	if !sameRiskLimits(a, b) {
+		t.Error("expected limits with equal values to match")
+	}
+
+	b.DailyLossLimit = 6000
+	if sameRiskLimits(a, b) {
+		t.Error("expected limits with different values not to match")
+	}
+}
+
+func TestLimitsToUpdateRestoresEveryField(t *testing.T) {
+	previous := &models.RiskLimits{
+		MaxPositionSize:       10000,
+		DailyLossLimit:        5000,
+		MaxVaR:                2500,
+		SelfTradeAction:       models.SelfTradeActionRejectNew,
+		EnforceTradingSession: true,
+		Enabled:               true,
+	}
+
+	if diffs := DiffRiskLimits(previous, limitsToUpdate(previous)); len(diffs) != 0 {
+		t.Errorf("expected no changes restoring identical limits, got %+v", diffs)
+	}
+
+	current := *previous
+	current.DailyLossLimit = 2000
+	current.MaxVaR = 0
+	current.Enabled = false
+
+	diffs := DiffRiskLimits(&current, limitsToUpdate(previous))
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", diffs)
+	}
+	for _, diff := range diffs {
+		if diff.Field == "max_var" && diff.Loosens {
+			t.Error("re-enabling the VaR limit should tighten")
+		}
+		if diff.Field == "daily_loss_limit" && !diff.Loosens {
+			t.Error("restoring a higher daily loss limit should loosen")
+		}
+	}
+}
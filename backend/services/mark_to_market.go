@@ -0,0 +1,408 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// markPushInterval is the most often tick-driven marks are pushed to the
+// daily P&L; ticks in between are coalesced
+const markPushInterval = 1 * time.Second
+
+// MarkToMarket marks open positions to the latest prices in the market data
+// cache. Marks are recomputed on every price tick and pushed to the daily P&L.
+type MarkToMarket struct {
+	redis        *RedisService
+	positionBook *PositionBook
+	pnlMonitor   *PnLMonitor
+	riskManager  *RiskManager
+	config       models.MarkConfig
+	quotes       map[string]models.Quote
+	staleAlerted map[string]bool
+	lastPush     time.Time
+	dirty        bool
+	mu           sync.RWMutex
+	ticker       *time.Ticker
+	stopChan     chan bool
+}
+
+// NewMarkToMarket creates a new mark-to-market service
+func NewMarkToMarket(redis *RedisService, positionBook *PositionBook, pnlMonitor *PnLMonitor, riskManager *RiskManager, config models.MarkConfig) *MarkToMarket {
+	if err := validateMarkConfig(&config); err != nil {
+		log.Printf("Invalid mark-to-market config (%v), marking at %s", err, models.MarkRuleMid)
+		config = models.MarkConfig{Rule: models.MarkRuleMid, StaleAfterSeconds: 30}
+	}
+
+	return &MarkToMarket{
+		redis:        redis,
+		positionBook: positionBook,
+		pnlMonitor:   pnlMonitor,
+		riskManager:  riskManager,
+		config:       config,
+		quotes:       make(map[string]models.Quote),
+		staleAlerted: make(map[string]bool),
+		stopChan:     make(chan bool),
+	}
+}
+
+func validateMarkConfig(config *models.MarkConfig) error {
+	config.Rule = strings.ToUpper(config.Rule)
+	switch config.Rule {
+	case models.MarkRuleLast, models.MarkRuleMid, models.MarkRuleSide:
+	default:
+		return fmt.Errorf("mark rule must be %s, %s or %s", models.MarkRuleLast, models.MarkRuleMid, models.MarkRuleSide)
+	}
+	if config.StaleAfterSeconds <= 0 {
+		return fmt.Errorf("stale_after_seconds must be positive")
+	}
+	return nil
+}
+
+// Start subscribes to market data updates. A one-second sweep flushes
+// coalesced ticks and flags prices that went stale.
+func (mm *MarkToMarket) Start() {
+	mm.loadQuotes()
+
+	if pubsub, err := mm.redis.SubscribeMarketData(); err != nil {
+		log.Printf("Mark-to-market not receiving ticks: %v", err)
+	} else {
+		go mm.listen(pubsub.Channel())
+		go func() {
+			<-mm.stopChan
+			pubsub.Close()
+		}()
+	}
+
+	mm.ticker = time.NewTicker(markPushInterval)
+
+	go func() {
+		log.Printf("Mark-to-market started (%s marks, stale after %ds)", mm.Config().Rule, mm.Config().StaleAfterSeconds)
+
+		for {
+			select {
+			case <-mm.ticker.C:
+				mm.sweep()
+
+			case <-mm.stopChan:
+				log.Println("Mark-to-market stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the mark-to-market service
+func (mm *MarkToMarket) Stop() {
+	if mm.ticker != nil {
+		mm.ticker.Stop()
+	}
+	close(mm.stopChan)
+}
+
+// listen handles market data write notifications
+func (mm *MarkToMarket) listen(messages <-chan *redis.Message) {
+	for message := range messages {
+		if symbol, ok := tickSymbol(message); ok {
+			mm.OnTick(symbol)
+		}
+	}
+}
+
+// tickSymbol returns the symbol of a market data notification: either a
+// publish on the market data channel or a keyspace notification on
+// __keyspace@<db>__:marketdata:<symbol>
+func tickSymbol(message *redis.Message) (string, bool) {
+	if message.Channel == marketDataChannel {
+		return message.Payload, message.Payload != ""
+	}
+	i := strings.Index(message.Channel, "marketdata:")
+	if i < 0 || message.Payload != "set" {
+		return "", false
+	}
+	return message.Channel[i+len("marketdata:"):], true
+}
+
+// loadQuotes reads the cached quote of every open position
+func (mm *MarkToMarket) loadQuotes() {
+	for _, position := range mm.positionBook.Positions() {
+		mm.refreshQuote(position.Symbol)
+	}
+}
+
+func (mm *MarkToMarket) refreshQuote(symbol string) bool {
+	data, err := mm.redis.GetMarketData(symbol)
+	if err != nil || data == nil {
+		return false
+	}
+
+	quote, ok := parseQuote(symbol, data, time.Now())
+	if !ok {
+		return false
+	}
+
+	mm.mu.Lock()
+	mm.quotes[symbol] = quote
+	mm.mu.Unlock()
+	return true
+}
+
+// OnTick re-marks a symbol after its price changed and pushes the new
+// unrealised P&L when the symbol is held
+func (mm *MarkToMarket) OnTick(symbol string) {
+	if !mm.refreshQuote(symbol) {
+		return
+	}
+	if position, held := mm.positionBook.Position(symbol); held && position.Quantity != 0 {
+		mm.push(false)
+	}
+}
+
+// push sends the current unrealised P&L to the P&L monitor, at most once
+// per markPushInterval unless forced
+func (mm *MarkToMarket) push(force bool) {
+	mm.mu.Lock()
+	if !force && time.Since(mm.lastPush) < markPushInterval {
+		mm.dirty = true
+		mm.mu.Unlock()
+		return
+	}
+	mm.lastPush = time.Now()
+	mm.dirty = false
+	mm.mu.Unlock()
+
+	if mm.pnlMonitor != nil {
+		mm.pnlMonitor.UpdatePnL()
+	}
+}
+
+// sweep flushes coalesced ticks and alerts once per held symbol whose price
+// went stale
+func (mm *MarkToMarket) sweep() {
+	mm.mu.RLock()
+	dirty := mm.dirty
+	mm.mu.RUnlock()
+	if dirty {
+		mm.push(true)
+	}
+
+	// Positions opened since their symbol last ticked have no quote yet
+	positions := mm.positionBook.Positions()
+	for _, position := range positions {
+		mm.mu.RLock()
+		_, ok := mm.quotes[position.Symbol]
+		mm.mu.RUnlock()
+		if !ok {
+			mm.refreshQuote(position.Symbol)
+		}
+	}
+
+	now := time.Now()
+	var stale []models.PriceMark
+	mm.mu.Lock()
+	held := make(map[string]bool)
+	for _, position := range positions {
+		held[position.Symbol] = true
+		quote, ok := mm.quotes[position.Symbol]
+		if !ok {
+			continue
+		}
+		mark := markPrice(quote, mm.config, position.Quantity, now)
+		if mark.Stale && !mm.staleAlerted[position.Symbol] {
+			stale = append(stale, mark)
+		}
+		mm.staleAlerted[position.Symbol] = mark.Stale
+	}
+	for symbol := range mm.staleAlerted {
+		if !held[symbol] {
+			delete(mm.staleAlerted, symbol)
+		}
+	}
+	mm.mu.Unlock()
+
+	for _, mark := range stale {
+		mm.riskManager.SendAlert("PRICE_STALE", "WARNING", mark.Symbol,
+			fmt.Sprintf("%s marked at a %.0fs old price of %.4f", mark.Symbol, mark.AgeSeconds, mark.Price),
+			map[string]interface{}{
+				"price":       mark.Price,
+				"age_seconds": mark.AgeSeconds,
+				"rule":        mark.Rule,
+			})
+	}
+}
+
+// Mark returns the price to mark a position of quantity in symbol at. Stale
+// quotes still mark at the last known price. Returns false when no price is
+// cached for the symbol.
+func (mm *MarkToMarket) Mark(symbol string, quantity float64) (float64, bool) {
+	mm.mu.RLock()
+	quote, ok := mm.quotes[symbol]
+	config := mm.config
+	mm.mu.RUnlock()
+
+	if !ok {
+		return 0, false
+	}
+	mark := markPrice(quote, config, quantity, time.Now())
+	return mark.Price, mark.Price > 0
+}
+
+// Quote reads the latest quote for a symbol from the market data cache.
+// Returns false when no quote is cached or it is stale.
+func (mm *MarkToMarket) Quote(symbol string) (models.Quote, bool) {
+	mm.refreshQuote(symbol)
+
+	mm.mu.RLock()
+	quote, ok := mm.quotes[symbol]
+	staleAfter := time.Duration(mm.config.StaleAfterSeconds) * time.Second
+	mm.mu.RUnlock()
+
+	if !ok || time.Since(quote.Timestamp) > staleAfter {
+		return models.Quote{}, false
+	}
+	return quote, true
+}
+
+// Config returns the marking configuration
+func (mm *MarkToMarket) Config() models.MarkConfig {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return mm.config
+}
+
+// SetConfig changes the marking rule or stale threshold and re-marks
+func (mm *MarkToMarket) SetConfig(config models.MarkConfig) error {
+	if err := validateMarkConfig(&config); err != nil {
+		return err
+	}
+
+	mm.mu.Lock()
+	mm.config = config
+	mm.mu.Unlock()
+
+	mm.push(true)
+	return nil
+}
+
+// Snapshot returns every open position with the mark applied to it
+func (mm *MarkToMarket) Snapshot() *models.MarkToMarketSnapshot {
+	positions := mm.positionBook.Positions()
+	now := time.Now()
+
+	mm.mu.RLock()
+	snapshot := &models.MarkToMarketSnapshot{
+		Config:       mm.config,
+		Positions:    positions,
+		Marks:        []models.PriceMark{},
+		StaleSymbols: []string{},
+		Timestamp:    now,
+	}
+	for _, position := range positions {
+		snapshot.UnrealizedPnL += position.UnrealizedPnL
+		quote, ok := mm.quotes[position.Symbol]
+		if !ok {
+			continue
+		}
+		mark := markPrice(quote, mm.config, position.Quantity, now)
+		snapshot.Marks = append(snapshot.Marks, mark)
+		if mark.Stale {
+			snapshot.StaleSymbols = append(snapshot.StaleSymbols, mark.Symbol)
+		}
+	}
+	mm.mu.RUnlock()
+
+	sort.Strings(snapshot.StaleSymbols)
+	return snapshot
+}
+
+// markPrice applies a marking rule to a quote. MID needs both sides and SIDE
+// needs the side a position would be closed at; both fall back to the last
+// trade price when the side is missing.
+func markPrice(quote models.Quote, config models.MarkConfig, quantity float64, now time.Time) models.PriceMark {
+	mark := models.PriceMark{
+		Symbol:   quote.Symbol,
+		Rule:     models.MarkRuleLast,
+		Price:    quote.Last,
+		Quote:    quote,
+		MarkedAt: now,
+	}
+
+	switch config.Rule {
+	case models.MarkRuleMid:
+		if quote.Bid > 0 && quote.Ask > 0 {
+			mark.Price, mark.Rule = (quote.Bid+quote.Ask)/2, models.MarkRuleMid
+		}
+	case models.MarkRuleSide:
+		if quantity >= 0 && quote.Bid > 0 {
+			mark.Price, mark.Rule = quote.Bid, models.MarkRuleSide
+		} else if quantity < 0 && quote.Ask > 0 {
+			mark.Price, mark.Rule = quote.Ask, models.MarkRuleSide
+		}
+	}
+
+	mark.AgeSeconds = math.Max(0, now.Sub(quote.Timestamp).Seconds())
+	mark.Stale = mark.AgeSeconds > float64(config.StaleAfterSeconds)
+	return mark
+}
+
+// parseQuote reads a quote from a market data cache entry. The quote time is
+// taken from the entry when present, otherwise receivedAt.
+func parseQuote(symbol string, data map[string]interface{}, receivedAt time.Time) (models.Quote, bool) {
+	quote := models.Quote{Symbol: symbol, Timestamp: receivedAt}
+	quote.Bid, _ = EngineNumber(data, "bid", "bid_price")
+	quote.Ask, _ = EngineNumber(data, "ask", "ask_price")
+	quote.Last, _ = EngineNumber(data, "last", "price", "last_price")
+
+	if quote.Last <= 0 && quote.Bid > 0 && quote.Ask > 0 {
+		quote.Last = (quote.Bid + quote.Ask) / 2
+	}
+	if quote.Last <= 0 && quote.Bid <= 0 && quote.Ask <= 0 {
+		return quote, false
+	}
+
+	if t, ok := parseQuoteTime(data["timestamp"]); ok {
+		quote.Timestamp = t
+	}
+	return quote, true
+}
+
+// parseQuoteTime accepts RFC 3339 strings and Unix times in seconds,
+// milliseconds or nanoseconds
+func parseQuoteTime(value interface{}) (time.Time, bool) {
+	var unix float64
+	switch v := value.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		unix = f
+	case float64:
+		unix = v
+	default:
+		return time.Time{}, false
+	}
+
+	switch {
+	case unix <= 0:
+		return time.Time{}, false
+	case unix > 1e17:
+		return time.Unix(0, int64(unix)), true
+	case unix > 1e11:
+		return time.UnixMilli(int64(unix)), true
+	default:
+		sec, frac := math.Modf(unix)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	}
+}
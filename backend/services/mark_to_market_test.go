@@ -0,0 +1,103 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMarkPriceRules(t *testing.T) {
+	now := time.Now()
+	quote := models.Quote{Symbol: "AAPL", Bid: 99, Ask: 101, Last: 100.5, Timestamp: now}
+	oneSided := models.Quote{Symbol: "AAPL", Bid: 99, Last: 100.5, Timestamp: now}
+
+	tests := []struct {
+		name     string
+		quote    models.Quote
+		rule     string
+		quantity float64
+		price    float64
+		applied  string
+	}{
+		{"last", quote, models.MarkRuleLast, 10, 100.5, models.MarkRuleLast},
+		{"mid", quote, models.MarkRuleMid, 10, 100, models.MarkRuleMid},
+		{"long at bid", quote, models.MarkRuleSide, 10, 99, models.MarkRuleSide},
+		{"short at ask", quote, models.MarkRuleSide, -10, 101, models.MarkRuleSide},
+		{"mid without ask falls back to last", oneSided, models.MarkRuleMid, 10, 100.5, models.MarkRuleLast},
+		{"short without ask falls back to last", oneSided, models.MarkRuleSide, -10, 100.5, models.MarkRuleLast},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mark := markPrice(tt.quote, models.MarkConfig{Rule: tt.rule, StaleAfterSeconds: 30}, tt.quantity, now)
+			if mark.Price != tt.price || mark.Rule != tt.applied {
+				t.Errorf("expected %.2f by %s, got %.2f by %s", tt.price, tt.applied, mark.Price, mark.Rule)
+			}
+		})
+	}
+}
+
+func TestMarkPriceStale(t *testing.T) {
+	now := time.Now()
+	config := models.MarkConfig{Rule: models.MarkRuleLast, StaleAfterSeconds: 30}
+
+	fresh := markPrice(models.Quote{Last: 100, Timestamp: now.Add(-29 * time.Second)}, config, 1, now)
+	if fresh.Stale {
+		t.Error("expected a 29s old quote to be fresh")
+	}
+
+	stale := markPrice(models.Quote{Last: 100, Timestamp: now.Add(-31 * time.Second)}, config, 1, now)
+	if !stale.Stale || stale.Price != 100 {
+		t.Errorf("expected a 31s old quote to be stale and still marked at 100, got %+v", stale)
+	}
+}
+
+func TestParseQuote(t *testing.T) {
+	received := time.Now()
+
+	quote, ok := parseQuote("AAPL", map[string]interface{}{"bid": "99.5", "ask": 100.5}, received)
+	if !ok || quote.Last != 100 || !quote.Timestamp.Equal(received) {
+		t.Errorf("expected last to default to the mid and time to the receive time, got %+v", quote)
+	}
+
+	if _, ok := parseQuote("AAPL", map[string]interface{}{"volume": 5000}, received); ok {
+		t.Error("expected an entry without prices to be rejected")
+	}
+
+	want := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	for _, value := range []interface{}{
+		want.Format(time.RFC3339),
+		float64(want.Unix()),
+		float64(want.UnixMilli()),
+		float64(want.UnixNano()),
+	} {
+		quote, _ := parseQuote("AAPL", map[string]interface{}{"last": 100.0, "timestamp": value}, received)
+		if !quote.Timestamp.Equal(want) {
+			t.Errorf("timestamp %v: expected %v, got %v", value, want, quote.Timestamp)
+		}
+	}
+}
+
+func TestTickSymbol(t *testing.T) {
+	tests := []struct {
+		channel string
+		payload string
+		symbol  string
+		ok      bool
+	}{
+		{"marketdata", "AAPL", "AAPL", true},
+		{"marketdata", "", "", false},
+		{"__keyspace@0__:marketdata:AAPL", "set", "AAPL", true},
+		{"__keyspace@0__:marketdata:AAPL", "expired", "", false},
+		{"__keyspace@0__:orders:AAPL", "set", "", false},
+	}
+
+	for _, tt := range tests {
+		symbol, ok := tickSymbol(&redis.Message{Channel: tt.channel, Payload: tt.payload})
+		if symbol != tt.symbol || ok != tt.ok {
+			t.Errorf("%s %q: expected (%q, %v), got (%q, %v)", tt.channel, tt.payload, tt.symbol, tt.ok, symbol, ok)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	// Embedded zone database so New York time works without system tzdata
+	_ "time/tzdata"
+
+	"github.com/hft/backend/models"
+)
+
+// calendarCacheTTL is how long holiday overrides are reused before reloading
+const calendarCacheTTL = 1 * time.Hour
+
+// Session boundaries in New York time (hour, minute)
+var (
+	preMarketOpen   = [2]int{4, 0}
+	regularOpen     = [2]int{9, 30}
+	regularClose    = [2]int{16, 0}
+	postMarketClose = [2]int{20, 0}
+	earlyCloseTime  = [2]int{13, 0}
+	earlyPostClose  = [2]int{17, 0}
+	marketLocation  = loadMarketLocation()
+)
+
+func loadMarketLocation() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		log.Printf("Failed to load America/New_York, using UTC: %v", err)
+		return time.UTC
+	}
+	return loc
+}
+
+// MarketCalendar knows exchange holidays, early closes and the pre-market,
+// regular and post-market sessions. Holidays come from a built-in table of
+// NYSE rules, overridden by rows in market_holidays.
+type MarketCalendar struct {
+	db        *DatabaseService
+	cheetr    *CheetrClient
+	overrides map[string]models.MarketHoliday
+	loadedAt  time.Time
+	mu        sync.RWMutex
+	ticker    *time.Ticker
+	stopChan  chan bool
+}
+
+// NewMarketCalendar creates a new market calendar
+func NewMarketCalendar(db *DatabaseService, cheetr *CheetrClient) *MarketCalendar {
+	mc := &MarketCalendar{
+		db:        db,
+		cheetr:    cheetr,
+		overrides: make(map[string]models.MarketHoliday),
+		stopChan:  make(chan bool),
+	}
+
+	mc.Reload()
+	return mc
+}
+
+// Start periodically reloads holiday overrides and cross-checks the session
+// against the market status reported by Cheetr
+func (mc *MarketCalendar) Start() {
+	mc.ticker = time.NewTicker(5 * time.Minute)
+
+	go func() {
+		log.Println("Market Calendar started (checking every 5 minutes)")
+
+		for {
+			select {
+			case <-mc.ticker.C:
+				if time.Since(mc.loadedAt) > calendarCacheTTL {
+					mc.Reload()
+				}
+				mc.crossCheck()
+
+			case <-mc.stopChan:
+				log.Println("Market Calendar stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the market calendar
+func (mc *MarketCalendar) Stop() {
+	if mc.ticker != nil {
+		mc.ticker.Stop()
+	}
+	mc.stopChan <- true
+}
+
+// Reload loads holiday overrides from the database
+func (mc *MarketCalendar) Reload() {
+	mc.loadedAt = time.Now()
+	if mc.db == nil || mc.db.GetDB() == nil {
+		return
+	}
+
+	var holidays []models.MarketHoliday
+	if err := mc.db.GetDB().Find(&holidays).Error; err != nil {
+		return
+	}
+
+	overrides := make(map[string]models.MarketHoliday, len(holidays))
+	for _, holiday := range holidays {
+		overrides[holiday.Date] = holiday
+	}
+
+	mc.mu.Lock()
+	mc.overrides = overrides
+	mc.mu.Unlock()
+}
+
+// Session returns the trading session at t
+func (mc *MarketCalendar) Session(t time.Time) *models.MarketSession {
+	now := t.In(marketLocation)
+	date := now.Format("2006-01-02")
+
+	session := &models.MarketSession{
+		Session:    models.SessionClosed,
+		TradingDay: mc.TradingDay(t),
+		Timestamp:  t,
+	}
+
+	open, holiday, closeAt := mc.dayInfo(date)
+	session.Holiday = holiday
+	if open {
+		preOpen := atTime(now, preMarketOpen)
+		regOpen := atTime(now, regularOpen)
+		regClose := atTime(now, closeAt)
+		postClose := atTime(now, postMarketClose)
+		if closeAt != regularClose {
+			session.EarlyClose = true
+			postClose = atTime(now, earlyPostClose)
+		}
+
+		session.PreOpen, session.RegularOpen = &preOpen, &regOpen
+		session.RegularClose, session.PostClose = &regClose, &postClose
+
+		switch {
+		case now.Before(preOpen) || !now.Before(postClose):
+			session.Session = models.SessionClosed
+		case now.Before(regOpen):
+			session.Session = models.SessionPreMarket
+		case now.Before(regClose):
+			session.Session = models.SessionRegular
+			session.IsOpen = true
+		default:
+			session.Session = models.SessionPostMarket
+		}
+	}
+
+	session.NextOpen = mc.nextRegularOpen(now)
+	return session
+}
+
+// TradingDay returns the trading day at t as YYYY-MM-DD. A trading day starts
+// at the pre-market open in New York and lasts until the next one, so nights,
+// weekends and holidays belong to the previous trading day.
+func (mc *MarketCalendar) TradingDay(t time.Time) string {
+	now := t.In(marketLocation)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, marketLocation)
+
+	if now.Before(atTime(now, preMarketOpen)) {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	for i := 0; i < 30; i++ {
+		if open, _, _ := mc.dayInfo(day.Format("2006-01-02")); open {
+			break
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return day.Format("2006-01-02")
+}
+
+// TradingDayStart returns the pre-market open of the trading day at t
+func (mc *MarketCalendar) TradingDayStart(t time.Time) time.Time {
+	day, _ := time.ParseInLocation("2006-01-02", mc.TradingDay(t), marketLocation)
+	return atTime(day, preMarketOpen)
+}
+
+// PreviousTradingDays returns the first of the last n trading days ending on
+// the trading day at t
+func (mc *MarketCalendar) PreviousTradingDays(t time.Time, n int) string {
+	day, _ := time.ParseInLocation("2006-01-02", mc.TradingDay(t), marketLocation)
+
+	for counted := 1; counted < n; {
+		day = day.AddDate(0, 0, -1)
+		if open, _, _ := mc.dayInfo(day.Format("2006-01-02")); open {
+			counted++
+		}
+	}
+
+	return day.Format("2006-01-02")
+}
+
+// IsTradingDay reports whether the exchange opens on date (YYYY-MM-DD)
+func (mc *MarketCalendar) IsTradingDay(date string) bool {
+	open, _, _ := mc.dayInfo(date)
+	return open
+}
+
+// Holidays returns the holidays and early closes for a year, with overrides applied
+func (mc *MarketCalendar) Holidays(year int) []models.MarketHoliday {
+	holidays := []models.MarketHoliday{}
+
+	day := time.Date(year, 1, 1, 0, 0, 0, 0, marketLocation)
+	for day.Year() == year {
+		date := day.Format("2006-01-02")
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			open, name, closeAt := mc.dayInfo(date)
+			if !open {
+				holidays = append(holidays, models.MarketHoliday{Date: date, Name: name})
+			} else if closeAt != regularClose {
+				holidays = append(holidays, models.MarketHoliday{Date: date, Name: name, EarlyClose: fmt.Sprintf("%02d:%02d", closeAt[0], closeAt[1])})
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return holidays
+}
+
+// dayInfo returns whether the exchange opens on date, the holiday or early
+// close name, and the regular session close time
+func (mc *MarketCalendar) dayInfo(date string) (bool, string, [2]int) {
+	mc.mu.RLock()
+	override, ok := mc.overrides[date]
+	mc.mu.RUnlock()
+
+	if ok {
+		if override.EarlyClose == "" {
+			return false, override.Name, regularClose
+		}
+		var hour, minute int
+		if _, err := fmt.Sscanf(override.EarlyClose, "%d:%d", &hour, &minute); err == nil {
+			return true, override.Name, [2]int{hour, minute}
+		}
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", date, marketLocation)
+	if err != nil {
+		return false, "", regularClose
+	}
+
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false, "", regularClose
+	}
+
+	if name, ok := builtinHolidays(day.Year())[date]; ok {
+		return false, name, regularClose
+	}
+
+	if name, ok := builtinEarlyCloses(day.Year())[date]; ok {
+		return true, name, earlyCloseTime
+	}
+
+	return true, "", regularClose
+}
+
+// nextRegularOpen returns the next regular session open after now
+func (mc *MarketCalendar) nextRegularOpen(now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, marketLocation)
+
+	for i := 0; i < 30; i++ {
+		if open, _, _ := mc.dayInfo(day.Format("2006-01-02")); open {
+			openAt := atTime(day, regularOpen)
+			if openAt.After(now) {
+				return openAt
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return atTime(day, regularOpen)
+}
+
+// crossCheck logs a warning when Cheetr disagrees with the calendar about
+// whether the regular session is open
+func (mc *MarketCalendar) crossCheck() {
+	if mc.cheetr == nil {
+		return
+	}
+
+	status, err := mc.cheetr.GetMarketStatus()
+	if err != nil {
+		return
+	}
+
+	session := mc.Session(time.Now())
+	if status.IsOpen != session.IsOpen {
+		log.Printf("⚠️ Market calendar says %s but Cheetr reports open=%v (%s)", session.Session, status.IsOpen, status.Message)
+	}
+}
+
+// atTime returns the given New York wall-clock time on day
+func atTime(day time.Time, hm [2]int) time.Time {
+	day = day.In(marketLocation)
+	return time.Date(day.Year(), day.Month(), day.Day(), hm[0], hm[1], 0, 0, marketLocation)
+}
+
+// builtinHolidays returns the NYSE full-day holidays for a year keyed by date
+func builtinHolidays(year int) map[string]string {
+	holidays := map[string]string{}
+	add := func(day time.Time, name string) {
+		holidays[day.Format("2006-01-02")] = name
+	}
+
+	// New Year's Day is not observed on the preceding Friday
+	newYear := time.Date(year, 1, 1, 0, 0, 0, 0, marketLocation)
+	if newYear.Weekday() == time.Sunday {
+		add(newYear.AddDate(0, 0, 1), "New Year's Day")
+	} else if newYear.Weekday() != time.Saturday {
+		add(newYear, "New Year's Day")
+	}
+
+	add(nthWeekday(year, time.January, time.Monday, 3), "Martin Luther King Jr. Day")
+	add(nthWeekday(year, time.February, time.Monday, 3), "Washington's Birthday")
+	add(easterSunday(year).AddDate(0, 0, -2), "Good Friday")
+	add(lastWeekday(year, time.May, time.Monday), "Memorial Day")
+	if year >= 2022 {
+		add(observed(time.Date(year, time.June, 19, 0, 0, 0, 0, marketLocation)), "Juneteenth")
+	}
+	add(observed(time.Date(year, time.July, 4, 0, 0, 0, 0, marketLocation)), "Independence Day")
+	add(nthWeekday(year, time.September, time.Monday, 1), "Labor Day")
+	add(nthWeekday(year, time.November, time.Thursday, 4), "Thanksgiving Day")
+	add(observed(time.Date(year, time.December, 25, 0, 0, 0, 0, marketLocation)), "Christmas Day")
+
+	return holidays
+}
+
+// builtinEarlyCloses returns the NYSE 1 p.m. early closes for a year keyed by date
+func builtinEarlyCloses(year int) map[string]string {
+	holidays := builtinHolidays(year)
+	closes := map[string]string{}
+	add := func(day time.Time, name string) {
+		date := day.Format("2006-01-02")
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday || holidays[date] != "" {
+			return
+		}
+		closes[date] = name
+	}
+
+	add(time.Date(year, time.July, 3, 0, 0, 0, 0, marketLocation), "Independence Day early close")
+	add(nthWeekday(year, time.November, time.Thursday, 4).AddDate(0, 0, 1), "Day after Thanksgiving early close")
+	add(time.Date(year, time.December, 24, 0, 0, 0, 0, marketLocation), "Christmas Eve early close")
+
+	return closes
+}
+
+// observed moves a Saturday holiday to Friday and a Sunday holiday to Monday
+func observed(day time.Time) time.Time {
+	switch day.Weekday() {
+	case time.Saturday:
+		return day.AddDate(0, 0, -1)
+	case time.Sunday:
+		return day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// nthWeekday returns the nth weekday of a month
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	day := time.Date(year, month, 1, 0, 0, 0, 0, marketLocation)
+	offset := (int(weekday) - int(day.Weekday()) + 7) % 7
+	return day.AddDate(0, 0, offset+7*(n-1))
+}
+
+// lastWeekday returns the last weekday of a month
+func lastWeekday(year int, month time.Month, weekday time.Weekday) time.Time {
+	day := time.Date(year, month+1, 1, 0, 0, 0, 0, marketLocation).AddDate(0, 0, -1)
+	offset := (int(day.Weekday()) - int(weekday) + 7) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// easterSunday returns Easter Sunday (Gregorian) using the anonymous algorithm
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, marketLocation)
+}
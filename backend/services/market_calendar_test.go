@@ -0,0 +1,116 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func nyTime(date, clock string) time.Time {
+	t, _ := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, marketLocation)
+	return t
+}
+
+func TestBuiltinHolidays(t *testing.T) {
+	holidays := builtinHolidays(2024)
+	for _, date := range []string{"2024-01-01", "2024-01-15", "2024-02-19", "2024-03-29", "2024-05-27",
+		"2024-06-19", "2024-07-04", "2024-09-02", "2024-11-28", "2024-12-25"} {
+		if _, ok := holidays[date]; !ok {
+			t.Errorf("Expected %s to be a 2024 holiday", date)
+		}
+	}
+
+	// Independence Day on a Saturday is observed on Friday 3 July 2026
+	if _, ok := builtinHolidays(2026)["2026-07-03"]; !ok {
+		t.Error("Expected 2026-07-03 to be the observed Independence Day")
+	}
+
+	// New Year's Day on a Saturday is not observed
+	if _, ok := builtinHolidays(2022)["2021-12-31"]; ok {
+		t.Error("Expected no observed New Year's holiday on 2021-12-31")
+	}
+
+	closes := builtinEarlyCloses(2024)
+	for _, date := range []string{"2024-07-03", "2024-11-29", "2024-12-24"} {
+		if _, ok := closes[date]; !ok {
+			t.Errorf("Expected %s to be a 2024 early close", date)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	mc := NewMarketCalendar(nil, nil)
+
+	cases := []struct {
+		at      time.Time
+		session string
+	}{
+		{nyTime("2024-03-12", "03:59"), models.SessionClosed},
+		{nyTime("2024-03-12", "04:00"), models.SessionPreMarket},
+		{nyTime("2024-03-12", "09:30"), models.SessionRegular},
+		{nyTime("2024-03-12", "16:00"), models.SessionPostMarket},
+		{nyTime("2024-03-12", "20:00"), models.SessionClosed},
+		{nyTime("2024-11-29", "13:30"), models.SessionPostMarket}, // Early close
+		{nyTime("2024-11-29", "17:00"), models.SessionClosed},
+		{nyTime("2024-11-28", "11:00"), models.SessionClosed}, // Thanksgiving
+		{nyTime("2024-03-16", "11:00"), models.SessionClosed}, // Saturday
+	}
+
+	for _, tc := range cases {
+		if got := mc.Session(tc.at).Session; got != tc.session {
+			t.Errorf("%s: expected %s, got %s", tc.at, tc.session, got)
+		}
+	}
+}
+
+func TestTradingDayRollsAtPreMarketOpen(t *testing.T) {
+	mc := NewMarketCalendar(nil, nil)
+
+	// Before the pre-market open the previous session is still the trading day
+	if day := mc.TradingDay(nyTime("2024-03-12", "02:00")); day != "2024-03-11" {
+		t.Errorf("Expected 2024-03-11, got %s", day)
+	}
+	if day := mc.TradingDay(nyTime("2024-03-12", "04:00")); day != "2024-03-12" {
+		t.Errorf("Expected 2024-03-12, got %s", day)
+	}
+	// Weekend and holiday belong to the last trading day
+	if day := mc.TradingDay(nyTime("2024-04-01", "01:00")); day != "2024-03-28" {
+		t.Errorf("Expected 2024-03-28 (before Good Friday), got %s", day)
+	}
+
+	if first := mc.PreviousTradingDays(nyTime("2024-04-02", "10:00"), 5); first != "2024-03-26" {
+		t.Errorf("Expected 5-day window skipping Good Friday to start 2024-03-26, got %s", first)
+	}
+}
+
+func TestCheckTradingSession(t *testing.T) {
+	rm := &RiskManager{calendar: NewMarketCalendar(nil, nil), limits: &models.RiskLimits{EnforceTradingSession: true}}
+
+	session := rm.calendar.Session(time.Now()).Session
+	market := &models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 1, OrderType: "MARKET"}
+	extended := &models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 1, Price: 100, OrderType: "LIMIT", ExtendedHours: true}
+
+	switch session {
+	case models.SessionRegular:
+		if err := rm.CheckTradingSession(market); err != nil {
+			t.Errorf("Expected order allowed in regular session, got %v", err)
+		}
+	case models.SessionPreMarket, models.SessionPostMarket:
+		if rm.CheckTradingSession(market) == nil {
+			t.Error("Expected market order rejected outside regular hours")
+		}
+		if err := rm.CheckTradingSession(extended); err != nil {
+			t.Errorf("Expected extended-hours limit order allowed, got %v", err)
+		}
+	default:
+		if rm.CheckTradingSession(extended) == nil {
+			t.Error("Expected order rejected while the market is closed")
+		}
+	}
+
+	rm.limits.EnforceTradingSession = false
+	if err := rm.CheckTradingSession(market); err != nil {
+		t.Errorf("Expected no gating when disabled, got %v", err)
+	}
+}
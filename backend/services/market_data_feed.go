@@ -0,0 +1,148 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// marketDataPollInterval is how often quotes are fetched for the symbols we
+// hold or have working orders in
+const marketDataPollInterval = 5 * time.Second
+
+// MarketDataFeed writes quotes to the market data cache for every symbol
+// held or with an open order. Each write notifies the mark-to-market, so
+// positions are marked to these quotes rather than the broker's price.
+type MarketDataFeed struct {
+	redis        *RedisService
+	quotes       *CheetrClient
+	positionBook *PositionBook
+	ledger       *OpenOrderLedger
+	failing      bool
+	ticker       *time.Ticker
+	stopChan     chan bool
+}
+
+// NewMarketDataFeed creates a new market data feed
+func NewMarketDataFeed(redis *RedisService, quotes *CheetrClient, positionBook *PositionBook, ledger *OpenOrderLedger) *MarketDataFeed {
+	return &MarketDataFeed{
+		redis:        redis,
+		quotes:       quotes,
+		positionBook: positionBook,
+		ledger:       ledger,
+		stopChan:     make(chan bool),
+	}
+}
+
+// Start begins polling quotes into the market data cache
+func (f *MarketDataFeed) Start() {
+	if f.redis == nil || f.redis.client == nil {
+		log.Println("Market data feed disabled: Redis is not available")
+		return
+	}
+
+	f.ticker = time.NewTicker(marketDataPollInterval)
+
+	go func() {
+		log.Printf("Market data feed started (polling every %s)", marketDataPollInterval)
+		f.poll()
+
+		for {
+			select {
+			case <-f.ticker.C:
+				f.poll()
+
+			case <-f.stopChan:
+				log.Println("Market data feed stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the market data feed
+func (f *MarketDataFeed) Stop() {
+	if f.ticker != nil {
+		f.ticker.Stop()
+	}
+	close(f.stopChan)
+}
+
+// poll fetches and caches a quote for every tracked symbol. Failures are
+// logged when they start and when they clear, not on every poll.
+func (f *MarketDataFeed) poll() {
+	var orders []models.OpenOrderReservation
+	if f.ledger != nil {
+		orders = f.ledger.GetOpenOrders()
+	}
+	symbols := feedSymbols(f.positionBook.Positions(), orders)
+
+	var lastErr error
+	for _, symbol := range symbols {
+		quote, err := f.quotes.GetQuote(symbol)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		entry, ok := quoteEntry(quote, time.Now())
+		if !ok {
+			continue
+		}
+		if err := f.redis.SetMarketData(symbol, entry); err != nil {
+			lastErr = err
+		}
+	}
+
+	switch {
+	case lastErr != nil && !f.failing:
+		log.Printf("Market data feed failing, marks will fall back to broker prices: %v", lastErr)
+	case lastErr == nil && f.failing:
+		log.Println("Market data feed recovered")
+	}
+	f.failing = lastErr != nil
+}
+
+// feedSymbols returns the symbols of open positions and open orders, sorted
+func feedSymbols(positions []models.Position, orders []models.OpenOrderReservation) []string {
+	seen := map[string]bool{}
+	for _, position := range positions {
+		seen[position.Symbol] = true
+	}
+	for _, order := range orders {
+		seen[order.Symbol] = true
+	}
+
+	symbols := make([]string, 0, len(seen))
+	for symbol := range seen {
+		if symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// quoteEntry builds the market data cache entry for a quote. Quotes without
+// a price are skipped; a quote without a time is stamped with now.
+func quoteEntry(quote *Quote, now time.Time) (map[string]interface{}, bool) {
+	if quote == nil || quote.Price <= 0 {
+		return nil, false
+	}
+
+	timestamp := quote.Timestamp
+	if timestamp == "" {
+		timestamp = now.UTC().Format(time.RFC3339Nano)
+	}
+	return map[string]interface{}{
+		"symbol":    quote.Symbol,
+		"price":     quote.Price,
+		"open":      quote.Open,
+		"high":      quote.High,
+		"low":       quote.Low,
+		"volume":    quote.Volume,
+		"timestamp": timestamp,
+		"source":    "cheetr",
+	}, true
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestFeedSymbols(t *testing.T) {
+	positions := []models.Position{{Symbol: "MSFT"}, {Symbol: "AAPL"}}
+	orders := []models.OpenOrderReservation{{Symbol: "AAPL"}, {Symbol: "TSLA"}, {Symbol: ""}}
+
+	got := feedSymbols(positions, orders)
+	want := []string{"AAPL", "MSFT", "TSLA"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if got := feedSymbols(nil, nil); len(got) != 0 {
+		t.Errorf("expected no symbols when nothing is held, got %v", got)
+	}
+}
+
+func TestQuoteEntryIsMarkable(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+
+	if _, ok := quoteEntry(nil, now); ok {
+		t.Error("expected a missing quote to be skipped")
+	}
+	if _, ok := quoteEntry(&Quote{Symbol: "AAPL"}, now); ok {
+		t.Error("expected a quote without a price to be skipped")
+	}
+
+	entry, ok := quoteEntry(&Quote{Symbol: "AAPL", Price: 187.25, Volume: 1200}, now)
+	if !ok {
+		t.Fatal("expected a priced quote to be cached")
+	}
+
+	// The entry must read back as a mark through the same parser the
+	// mark-to-market uses
+	quote, ok := parseQuote("AAPL", entry, now.Add(time.Minute))
+	if !ok || quote.Last != 187.25 {
+		t.Fatalf("expected the entry to mark at 187.25, got %+v", quote)
+	}
+	if !quote.Timestamp.Equal(now) {
+		t.Errorf("expected a quote without a time to be stamped %v, got %v", now, quote.Timestamp)
+	}
+
+	stamped, _ := quoteEntry(&Quote{Symbol: "AAPL", Price: 187.25, Timestamp: "2026-03-10T15:29:00Z"}, now)
+	if stamped["timestamp"] != "2026-03-10T15:29:00Z" {
+		t.Errorf("expected the quote's own time to be kept, got %v", stamped["timestamp"])
+	}
+}
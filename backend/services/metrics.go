@@ -9,6 +9,7 @@ import (
 type Metrics struct {
 	OrdersTotal          *prometheus.CounterVec
 	OrderLatency         *prometheus.HistogramVec
+	OrderPathLatency     *prometheus.HistogramVec
 	ActivePositions      prometheus.Gauge
 	ExecutionErrors      *prometheus.CounterVec
 	WebSocketConnections prometheus.Gauge
@@ -37,6 +38,14 @@ func InitMetrics() *Metrics {
 			},
 			[]string{"endpoint"},
 		),
+		OrderPathLatency: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "hft_order_path_latency_microseconds",
+				Help:    "Latency of each hop of the order path in microseconds",
+				Buckets: []float64{10, 50, 100, 500, 1000, 5000, 10000, 50000, 100000, 500000, 1000000},
+			},
+			[]string{"stage"},
+		),
 		ActivePositions: promauto.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "hft_active_positions",
@@ -0,0 +1,543 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+const (
+	// DefaultAccount is used for orders that don't name an account
+	DefaultAccount = "primary"
+
+	// ledgerKey is the Redis hash holding open order reservations
+	ledgerKey = "ledger:open_orders"
+
+	// reservationTTL bounds how long a reservation can live without the
+	// engine confirming the order is still open
+	reservationTTL = 24 * time.Hour
+
+	// reconcileGrace gives the engine time to report a new order as open
+	reconcileGrace = 10 * time.Second
+)
+
+// ErrDuplicateClientOrderID is returned when reserving an order whose client
+// order ID already has an open reservation
+var ErrDuplicateClientOrderID = errors.New("client order ID already has an open order")
+
+// NewClientOrderID creates a unique client order ID for orders that don't
+// bring their own
+func NewClientOrderID() string {
+	return fmt.Sprintf("ORD-%d", time.Now().UnixNano())
+}
+
+// OpenOrderLedger tracks the quantity and notional reserved by open orders.
+// Reservations are created on submission and released on fill, cancel,
+// reject or expiry; they are mirrored to Redis so they survive restarts.
+type OpenOrderLedger struct {
+	redis         *RedisService
+	engine        *EngineClient
+	orders        map[string]*models.OpenOrderReservation // Keyed by client order ID
+	buyingPower   float64
+	equity        float64
+	buyingPowerAt time.Time
+	mu            sync.RWMutex
+	ticker        *time.Ticker
+	stopChan      chan bool
+}
+
+// NewOpenOrderLedger creates a new open order ledger and restores reservations from Redis
+func NewOpenOrderLedger(redis *RedisService, engine *EngineClient) *OpenOrderLedger {
+	ledger := &OpenOrderLedger{
+		redis:    redis,
+		engine:   engine,
+		orders:   make(map[string]*models.OpenOrderReservation),
+		stopChan: make(chan bool),
+	}
+
+	ledger.restore()
+	return ledger
+}
+
+// Start begins reconciling reservations against the engine's open orders
+func (ol *OpenOrderLedger) Start() {
+	ol.ticker = time.NewTicker(15 * time.Second)
+
+	go func() {
+		log.Println("Open Order Ledger started (reconciling every 15 seconds)")
+		ol.refreshAccount()
+
+		for {
+			select {
+			case <-ol.ticker.C:
+				ol.reconcile()
+
+			case <-ol.stopChan:
+				log.Println("Open Order Ledger stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the ledger reconciliation
+func (ol *OpenOrderLedger) Stop() {
+	if ol.ticker != nil {
+		ol.ticker.Stop()
+	}
+	ol.stopChan <- true
+}
+
+// Reserve records a new open order before it is sent to the engine. An order
+// the risk checks already reserved keeps that reservation.
+func (ol *OpenOrderLedger) Reserve(req *models.OrderRequest, clientOrderID string) *models.OpenOrderReservation {
+	reservation := ol.newReservation(req, clientOrderID)
+
+	ol.mu.Lock()
+	if existing, ok := ol.orders[clientOrderID]; ok {
+		ol.mu.Unlock()
+		return existing
+	}
+	ol.orders[clientOrderID] = reservation
+	ol.mu.Unlock()
+
+	ol.persist(reservation)
+	return reservation
+}
+
+// ReserveWithinBuyingPower records a new open order if the account can pay
+// for it. Buying power is checked and reserved under one lock, so concurrent
+// buys can't both pass against the same available amount.
+func (ol *OpenOrderLedger) ReserveWithinBuyingPower(req *models.OrderRequest, clientOrderID string) (*models.OpenOrderReservation, error) {
+	reservation := ol.newReservation(req, clientOrderID)
+
+	ol.mu.Lock()
+	if _, exists := ol.orders[clientOrderID]; exists {
+		ol.mu.Unlock()
+		return nil, ErrDuplicateClientOrderID
+	}
+	if reservation.Side == "BUY" && !ol.buyingPowerAt.IsZero() {
+		available := ol.buyingPower - ol.reservedBuyingPower(reservation.Account)
+		if notional := reservation.Notional(); notional > available {
+			ol.mu.Unlock()
+			return nil, fmt.Errorf("insufficient buying power: order $%.2f > available $%.2f (after open order reservations)", notional, available)
+		}
+	}
+	ol.orders[clientOrderID] = reservation
+	ol.mu.Unlock()
+
+	ol.persist(reservation)
+	return reservation, nil
+}
+
+func (ol *OpenOrderLedger) newReservation(req *models.OrderRequest, clientOrderID string) *models.OpenOrderReservation {
+	account := req.Account
+	if account == "" {
+		account = DefaultAccount
+	}
+
+	price := req.Price
+	if price <= 0 {
+		price = ol.estimatePrice(req.Symbol)
+	}
+
+	now := time.Now()
+	return &models.OpenOrderReservation{
+		ClientOrderID: clientOrderID,
+		Account:       account,
+		Symbol:        req.Symbol,
+		Side:          req.Side,
+		Quantity:      req.Quantity,
+		Price:         price,
+		Status:        "PENDING_NEW",
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(reservationTTL),
+	}
+}
+
+// Acknowledge links the engine order ID to a reservation and updates its status
+func (ol *OpenOrderLedger) Acknowledge(clientOrderID, orderID, status string) {
+	ol.mu.Lock()
+	reservation, ok := ol.orders[clientOrderID]
+	if ok {
+		reservation.OrderID = orderID
+		if status != "" {
+			reservation.Status = status
+		}
+	}
+	ol.mu.Unlock()
+
+	if ok {
+		ol.persist(reservation)
+	}
+}
+
+// ApplyFill reduces a reservation by the cumulative filled quantity and
+// releases it once fully filled. id may be the client or engine order ID.
+func (ol *OpenOrderLedger) ApplyFill(id string, filledQty float64) {
+	ol.mu.Lock()
+	reservation := ol.find(id)
+	if reservation == nil {
+		ol.mu.Unlock()
+		return
+	}
+
+	if filledQty > reservation.FilledQty {
+		reservation.FilledQty = filledQty
+	}
+	filled := reservation.RemainingQty() <= 0
+	if !filled {
+		reservation.Status = "PARTIALLY_FILLED"
+	}
+	ol.mu.Unlock()
+
+	if filled {
+		ol.Release(id, "FILLED")
+		return
+	}
+	ol.persist(reservation)
+}
+
+// Release removes a reservation on fill, cancel, reject, error or expiry.
+// id may be the client or engine order ID.
+func (ol *OpenOrderLedger) Release(id, reason string) {
+	ol.mu.Lock()
+	reservation := ol.find(id)
+	if reservation != nil {
+		delete(ol.orders, reservation.ClientOrderID)
+	}
+	ol.mu.Unlock()
+
+	if reservation == nil {
+		return
+	}
+
+	if ol.redis != nil && ol.redis.client != nil {
+		ol.redis.client.HDel(context.Background(), ledgerKey, reservation.ClientOrderID)
+	}
+
+	log.Printf("Released reservation %s (%s %s %.2f): %s", reservation.ClientOrderID, reservation.Side, reservation.Symbol, reservation.RemainingQty(), reason)
+}
+
+// ApplyCorporateAction rescales the open orders of a symbol for a split or
+// renames them for a symbol change. Returns the number of orders adjusted.
+func (ol *OpenOrderLedger) ApplyCorporateAction(action *models.CorporateAction) int {
+	ol.mu.Lock()
+	var adjusted []*models.OpenOrderReservation
+	for _, reservation := range ol.orders {
+		if reservation.Symbol != action.Symbol {
+			continue
+		}
+		switch action.Type {
+		case models.CorporateActionSplit, models.CorporateActionReverseSplit:
+			ratio := action.Ratio()
+			reservation.Quantity *= ratio
+			reservation.FilledQty *= ratio
+			reservation.Price /= ratio
+		case models.CorporateActionSymbolChange:
+			reservation.Symbol = action.NewSymbol
+		default:
+			continue
+		}
+		adjusted = append(adjusted, reservation)
+	}
+	ol.mu.Unlock()
+
+	for _, reservation := range adjusted {
+		ol.persist(reservation)
+	}
+	return len(adjusted)
+}
+
+// PendingQuantity returns the open quantity for a symbol and side
+func (ol *OpenOrderLedger) PendingQuantity(symbol, side string) float64 {
+	ol.mu.RLock()
+	defer ol.mu.RUnlock()
+
+	var total float64
+	for _, reservation := range ol.orders {
+		if reservation.Symbol == symbol && reservation.Side == side {
+			total += reservation.RemainingQty()
+		}
+	}
+	return total
+}
+
+// ReservedBuyingPower returns the notional reserved by open buy orders for an account
+func (ol *OpenOrderLedger) ReservedBuyingPower(account string) float64 {
+	if account == "" {
+		account = DefaultAccount
+	}
+
+	ol.mu.RLock()
+	defer ol.mu.RUnlock()
+	return ol.reservedBuyingPower(account)
+}
+
+// reservedBuyingPower sums open buy notional for an account; the caller holds mu
+func (ol *OpenOrderLedger) reservedBuyingPower(account string) float64 {
+	var total float64
+	for _, reservation := range ol.orders {
+		if reservation.Account == account && reservation.Side == "BUY" {
+			total += reservation.Notional()
+		}
+	}
+	return total
+}
+
+// AvailableBuyingPower returns account buying power minus open buy reservations.
+// ok is false if the account buying power is not known.
+func (ol *OpenOrderLedger) AvailableBuyingPower(account string) (available float64, ok bool) {
+	ol.mu.RLock()
+	buyingPower := ol.buyingPower
+	known := !ol.buyingPowerAt.IsZero()
+	ol.mu.RUnlock()
+
+	if !known {
+		return 0, false
+	}
+
+	return buyingPower - ol.ReservedBuyingPower(account), true
+}
+
+// AccountEquity returns the last account equity reported by the engine.
+// ok is false if the account has not been loaded yet.
+func (ol *OpenOrderLedger) AccountEquity() (equity float64, ok bool) {
+	ol.mu.RLock()
+	defer ol.mu.RUnlock()
+	return ol.equity, !ol.buyingPowerAt.IsZero()
+}
+
+// EstimateNotional returns the notional of an order, estimating market order prices
+func (ol *OpenOrderLedger) EstimateNotional(req *models.OrderRequest) float64 {
+	price := req.Price
+	if price <= 0 {
+		price = ol.estimatePrice(req.Symbol)
+	}
+	return req.Quantity * price
+}
+
+// GetOpenOrders returns all open reservations, oldest first
+func (ol *OpenOrderLedger) GetOpenOrders() []models.OpenOrderReservation {
+	ol.mu.RLock()
+	defer ol.mu.RUnlock()
+
+	orders := make([]models.OpenOrderReservation, 0, len(ol.orders))
+	for _, reservation := range ol.orders {
+		orders = append(orders, *reservation)
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+	})
+	return orders
+}
+
+// GetExposure aggregates open reservations per account and symbol
+func (ol *OpenOrderLedger) GetExposure() []models.SymbolExposure {
+	ol.mu.RLock()
+	defer ol.mu.RUnlock()
+
+	bySymbol := make(map[string]*models.SymbolExposure)
+	for _, reservation := range ol.orders {
+		key := reservation.Account + ":" + reservation.Symbol
+		exposure, ok := bySymbol[key]
+		if !ok {
+			exposure = &models.SymbolExposure{Account: reservation.Account, Symbol: reservation.Symbol}
+			bySymbol[key] = exposure
+		}
+
+		exposure.OpenOrders++
+		if reservation.Side == "BUY" {
+			exposure.BuyQty += reservation.RemainingQty()
+			exposure.BuyNotional += reservation.Notional()
+		} else {
+			exposure.SellQty += reservation.RemainingQty()
+			exposure.SellNotional += reservation.Notional()
+		}
+	}
+
+	exposures := make([]models.SymbolExposure, 0, len(bySymbol))
+	for _, exposure := range bySymbol {
+		exposures = append(exposures, *exposure)
+	}
+
+	sort.Slice(exposures, func(i, j int) bool {
+		if exposures[i].Account != exposures[j].Account {
+			return exposures[i].Account < exposures[j].Account
+		}
+		return exposures[i].Symbol < exposures[j].Symbol
+	})
+	return exposures
+}
+
+// find looks a reservation up by client or engine order ID. Caller holds mu.
+func (ol *OpenOrderLedger) find(id string) *models.OpenOrderReservation {
+	if reservation, ok := ol.orders[id]; ok {
+		return reservation
+	}
+	for _, reservation := range ol.orders {
+		if reservation.OrderID != "" && reservation.OrderID == id {
+			return reservation
+		}
+	}
+	return nil
+}
+
+// reconcile syncs fills from the engine's open orders, releases orders the
+// engine no longer reports as open and expires stale reservations
+func (ol *OpenOrderLedger) reconcile() {
+	ol.refreshAccount()
+
+	now := time.Now()
+	openOrders, err := ol.fetchEngineOpenOrders()
+
+	for _, reservation := range ol.GetOpenOrders() {
+		if now.After(reservation.ExpiresAt) {
+			ol.Release(reservation.ClientOrderID, "EXPIRED")
+			continue
+		}
+
+		if err != nil || now.Sub(reservation.CreatedAt) < reconcileGrace {
+			continue
+		}
+
+		engineOrder, open := openOrders[reservation.ClientOrderID]
+		if !open && reservation.OrderID != "" {
+			engineOrder, open = openOrders[reservation.OrderID]
+		}
+
+		if !open {
+			ol.Release(reservation.ClientOrderID, "CLOSED")
+			continue
+		}
+
+		if filledQty, ok := EngineNumber(engineOrder, "filled_qty"); ok && filledQty > 0 {
+			ol.ApplyFill(reservation.ClientOrderID, filledQty)
+		}
+	}
+}
+
+// fetchEngineOpenOrders returns the engine's open orders keyed by client and engine order ID
+func (ol *OpenOrderLedger) fetchEngineOpenOrders() (map[string]map[string]interface{}, error) {
+	if ol.engine == nil {
+		return nil, fmt.Errorf("engine client not available")
+	}
+
+	response, err := ol.engine.SendRequest(map[string]interface{}{"type": "GET_OPEN_ORDERS"})
+	if err != nil {
+		return nil, err
+	}
+
+	orders, ok := response["orders"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected open orders response")
+	}
+
+	openOrders := make(map[string]map[string]interface{}, len(orders)*2)
+	for _, o := range orders {
+		order, ok := o.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if clientOrderID, ok := order["client_order_id"].(string); ok && clientOrderID != "" {
+			openOrders[clientOrderID] = order
+		}
+		for _, key := range []string{"id", "order_id"} {
+			if orderID, ok := order[key].(string); ok && orderID != "" {
+				openOrders[orderID] = order
+			}
+		}
+	}
+
+	return openOrders, nil
+}
+
+// refreshAccount caches the account buying power and equity reported by the engine
+func (ol *OpenOrderLedger) refreshAccount() {
+	if ol.engine == nil {
+		return
+	}
+
+	response, err := ol.engine.GetAccount()
+	if err != nil {
+		return
+	}
+
+	account, ok := response["account"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if buyingPower, ok := EngineNumber(account, "buying_power"); ok {
+		equity, _ := EngineNumber(account, "equity", "portfolio_value")
+
+		ol.mu.Lock()
+		ol.buyingPower = buyingPower
+		ol.equity = equity
+		ol.buyingPowerAt = time.Now()
+		ol.mu.Unlock()
+	}
+}
+
+// estimatePrice returns the last cached market price for a symbol, or 0
+func (ol *OpenOrderLedger) estimatePrice(symbol string) float64 {
+	if ol.redis == nil {
+		return 0
+	}
+
+	data, err := ol.redis.GetMarketData(symbol)
+	if err != nil || data == nil {
+		return 0
+	}
+
+	price, _ := EngineNumber(data, "price", "last", "close")
+	return price
+}
+
+// persist mirrors a reservation to Redis
+func (ol *OpenOrderLedger) persist(reservation *models.OpenOrderReservation) {
+	if ol.redis == nil || ol.redis.client == nil {
+		return
+	}
+
+	ol.mu.RLock()
+	data, err := json.Marshal(reservation)
+	ol.mu.RUnlock()
+	if err != nil {
+		return
+	}
+
+	ol.redis.client.HSet(context.Background(), ledgerKey, reservation.ClientOrderID, data)
+}
+
+// restore loads reservations from Redis after a restart
+func (ol *OpenOrderLedger) restore() {
+	if ol.redis == nil || ol.redis.client == nil {
+		return
+	}
+
+	values, err := ol.redis.client.HGetAll(context.Background(), ledgerKey).Result()
+	if err != nil {
+		return
+	}
+
+	for clientOrderID, data := range values {
+		var reservation models.OpenOrderReservation
+		if err := json.Unmarshal([]byte(data), &reservation); err != nil {
+			continue
+		}
+		ol.orders[clientOrderID] = &reservation
+	}
+
+	if len(ol.orders) > 0 {
+		log.Printf("Restored %d open order reservations", len(ol.orders))
+	}
+}
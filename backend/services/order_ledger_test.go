@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestLedgerReservesAndReleasesOnFill(t *testing.T) {
+	ledger := NewOpenOrderLedger(nil, nil)
+
+	ledger.Reserve(&models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 10, Price: 100}, "c1")
+	ledger.Acknowledge("c1", "e1", "NEW")
+
+	if qty := ledger.PendingQuantity("AAPL", "BUY"); qty != 10 {
+		t.Fatalf("Expected 10 pending, got %.2f", qty)
+	}
+	if notional := ledger.ReservedBuyingPower(""); notional != 1000 {
+		t.Fatalf("Expected $1000 reserved, got %.2f", notional)
+	}
+
+	// Fills are applied by engine order ID as cumulative quantity
+	ledger.ApplyFill("e1", 4)
+	if qty := ledger.PendingQuantity("AAPL", "BUY"); qty != 6 {
+		t.Errorf("Expected 6 pending after partial fill, got %.2f", qty)
+	}
+
+	ledger.ApplyFill("e1", 10)
+	if qty := ledger.PendingQuantity("AAPL", "BUY"); qty != 0 {
+		t.Errorf("Expected reservation released after full fill, got %.2f", qty)
+	}
+}
+
+func TestLedgerExposureByAccount(t *testing.T) {
+	ledger := NewOpenOrderLedger(nil, nil)
+
+	ledger.Reserve(&models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 5, Price: 100}, "c1")
+	ledger.Reserve(&models.OrderRequest{Symbol: "AAPL", Side: "SELL", Quantity: 2, Price: 110, Account: "hedge"}, "c2")
+	ledger.Release("c1", "CANCELED")
+
+	exposure := ledger.GetExposure()
+	if len(exposure) != 1 {
+		t.Fatalf("Expected 1 exposure row, got %d", len(exposure))
+	}
+	if exposure[0].Account != "hedge" || exposure[0].SellNotional != 220 {
+		t.Errorf("Unexpected exposure: %+v", exposure[0])
+	}
+}
+
+func TestBuyingPowerNetOfReservations(t *testing.T) {
+	ledger := NewOpenOrderLedger(nil, nil)
+	rm := &RiskManager{ledger: ledger}
+
+	order := &models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 5, Price: 100}
+
+	// Unknown buying power does not block orders
+	if err := rm.CheckBuyingPower(order); err != nil {
+		t.Fatalf("Expected order allowed without buying power, got %v", err)
+	}
+
+	ledger.buyingPower = 1000
+	ledger.buyingPowerAt = time.Now()
+	ledger.Reserve(&models.OrderRequest{Symbol: "MSFT", Side: "BUY", Quantity: 2, Price: 300}, "c1")
+
+	if err := rm.CheckBuyingPower(order); err == nil {
+		t.Error("Expected order rejected when reservations exhaust buying power")
+	}
+
+	ledger.Release("c1", "CANCELED")
+	if err := rm.CheckBuyingPower(order); err != nil {
+		t.Errorf("Expected order allowed after release, got %v", err)
+	}
+}
+
+func TestReserveWithinBuyingPowerIsAtomic(t *testing.T) {
+	ledger := NewOpenOrderLedger(nil, nil)
+	ledger.buyingPower = 1000
+	ledger.buyingPowerAt = time.Now()
+
+	// Ten concurrent $300 buys against $1000 of buying power
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			order := &models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 3, Price: 100}
+			if _, err := ledger.ReserveWithinBuyingPower(order, fmt.Sprintf("c%d", i)); err == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if accepted != 3 {
+		t.Errorf("Expected 3 orders reserved, got %d", accepted)
+	}
+	if reserved := ledger.ReservedBuyingPower(""); reserved != 900 {
+		t.Errorf("Expected $900 reserved, got %.2f", reserved)
+	}
+
+	var id string
+	for id = range ledger.orders {
+		break
+	}
+	if _, err := ledger.ReserveWithinBuyingPower(&models.OrderRequest{Symbol: "AAPL", Side: "SELL", Quantity: 1}, id); err != ErrDuplicateClientOrderID {
+		t.Errorf("Expected duplicate client order ID rejected, got %v", err)
+	}
+
+	// The submit path keeps a reservation made by the risk checks
+	ledger.Reserve(&models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 50, Price: 100}, id)
+	if reserved := ledger.ReservedBuyingPower(""); reserved != 900 {
+		t.Errorf("Expected Reserve to keep the existing reservation, got $%.2f reserved", reserved)
+	}
+}
@@ -3,114 +3,237 @@ package services
 import (
 	"context"
 	"fmt"
+	"math"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
-// OrderThrottleCache manages order rate limiting using Redis
+// tokenBucketScript refills a set of token buckets and, if every one has a
+// token, takes one from each. Nothing is stored unless all are taken, so a
+// rejected request doesn't drain the buckets that had room, and a peek leaves
+// them all untouched.
+// KEYS = bucket keys, ARGV = now (ms), take (1 consumes, 0 peeks), then the
+// rate (tokens/sec) and burst of each bucket.
+// Returns {allowed, remaining tokens} for each bucket.
+var tokenBucketScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local take = tonumber(ARGV[2])
+
+local tokens = {}
+local all = true
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[1 + i * 2])
+	local burst = tonumber(ARGV[2 + i * 2])
+	local state = redis.call('HMGET', key, 'tokens', 'ts')
+	local t = tonumber(state[1])
+	local ts = tonumber(state[2])
+	if t == nil or ts == nil then
+		t = burst
+		ts = now
+	end
+	tokens[i] = math.min(burst, t + math.max(0, now - ts) / 1000 * rate)
+	if tokens[i] < 1 then
+		all = false
+	end
+end
+
+local result = {}
+for i, key in ipairs(KEYS) do
+	local allowed = 0
+	if tokens[i] >= 1 then
+		allowed = 1
+	end
+	if take == 1 and all then
+		local rate = tonumber(ARGV[1 + i * 2])
+		local burst = tonumber(ARGV[2 + i * 2])
+		tokens[i] = tokens[i] - 1
+		redis.call('HSET', key, 'tokens', tokens[i], 'ts', now)
+		redis.call('PEXPIRE', key, math.ceil(burst / rate * 1000) + 1000)
+	end
+	table.insert(result, allowed)
+	table.insert(result, tostring(tokens[i]))
+end
+return result
+`)
+
+// ThrottleDecision is the outcome of a token bucket check
+type ThrottleDecision struct {
+	Allowed   bool
+	Remaining float64
+}
+
+// ThrottleBucket is a token bucket key and its limit
+type ThrottleBucket struct {
+	Key   string
+	Rate  float64
+	Burst int
+}
+
+// OrderThrottleCache manages order rate limiting with token buckets.
+// Buckets live in Redis so limits are shared between API instances;
+// an in-memory bucket is used when Redis is unavailable.
 type OrderThrottleCache struct {
-	redis *RedisService
+	redis   *RedisService
+	buckets map[string]*tokenBucket
+	mu      sync.Mutex
+}
+
+// tokenBucket is the in-memory fallback bucket
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
 }
 
 // NewOrderThrottleCache creates a new order throttle cache
 func NewOrderThrottleCache(redis *RedisService) *OrderThrottleCache {
 	return &OrderThrottleCache{
-		redis: redis,
+		redis:   redis,
+		buckets: make(map[string]*tokenBucket),
 	}
 }
 
-// CheckRate checks if the order rate limit has been exceeded
-// Uses Redis INCR with TTL for sliding window rate limiting
-func (otc *OrderThrottleCache) CheckRate(clientID string, maxPerSecond int) error {
-	ctx := context.Background()
-	key := fmt.Sprintf("throttle:%s", clientID)
+// Take consumes one token from the bucket for key
+func (otc *OrderThrottleCache) Take(key string, rate float64, burst int) (*ThrottleDecision, error) {
+	decisions, err := otc.consume([]ThrottleBucket{{Key: key, Rate: rate, Burst: burst}}, true)
+	if err != nil {
+		return nil, err
+	}
+	return &decisions[0], nil
+}
 
-	// Increment the counter
-	count, err := otc.redis.client.Incr(ctx, key).Result()
+// Peek reports whether a token is available without consuming it
+func (otc *OrderThrottleCache) Peek(key string, rate float64, burst int) (*ThrottleDecision, error) {
+	decisions, err := otc.consume([]ThrottleBucket{{Key: key, Rate: rate, Burst: burst}}, false)
 	if err != nil {
-		return fmt.Errorf("failed to increment throttle counter: %w", err)
+		return nil, err
 	}
+	return &decisions[0], nil
+}
 
-	// Set expiration on first request (count == 1)
-	if count == 1 {
-		err = otc.redis.client.Expire(ctx, key, 1*time.Second).Err()
-		if err != nil {
-			return fmt.Errorf("failed to set throttle expiration: %w", err)
+// TakeAll takes one token from every bucket if each has one, and from none
+// otherwise. Returns the decision for each bucket.
+func (otc *OrderThrottleCache) TakeAll(buckets []ThrottleBucket) ([]ThrottleDecision, error) {
+	return otc.consume(buckets, true)
+}
+
+// PeekAll reports the tokens left in every bucket without consuming any
+func (otc *OrderThrottleCache) PeekAll(buckets []ThrottleBucket) ([]ThrottleDecision, error) {
+	return otc.consume(buckets, false)
+}
+
+func (otc *OrderThrottleCache) consume(buckets []ThrottleBucket, take bool) ([]ThrottleDecision, error) {
+	keys := make([]string, len(buckets))
+	for i, bucket := range buckets {
+		if bucket.Rate <= 0 || bucket.Burst <= 0 {
+			return nil, fmt.Errorf("invalid throttle for %s: rate %.2f/s, burst %d", bucket.Key, bucket.Rate, bucket.Burst)
 		}
+		keys[i] = "throttle:" + bucket.Key
+	}
+	if len(buckets) == 0 {
+		return nil, nil
 	}
 
-	// Check if rate limit exceeded
-	if count > int64(maxPerSecond) {
-		return fmt.Errorf("rate limit exceeded: %d orders/sec (limit: %d)", count, maxPerSecond)
+	if otc.redis != nil && otc.redis.client != nil {
+		decisions, err := otc.consumeRedis(keys, buckets, take)
+		if err == nil {
+			return decisions, nil
+		}
+		// Fall through to the in-memory buckets if Redis fails
 	}
 
-	return nil
+	return otc.consumeMemory(keys, buckets, take), nil
 }
 
-// GetCurrentRate returns the current order rate for a client
-func (otc *OrderThrottleCache) GetCurrentRate(clientID string) (int64, error) {
+func (otc *OrderThrottleCache) consumeRedis(keys []string, buckets []ThrottleBucket, take bool) ([]ThrottleDecision, error) {
 	ctx := context.Background()
-	key := fmt.Sprintf("throttle:%s", clientID)
 
-	count, err := otc.redis.client.Get(ctx, key).Int64()
-	if err != nil {
-		// Key doesn't exist, return 0
-		return 0, nil
+	takeFlag := 0
+	if take {
+		takeFlag = 1
 	}
 
-	return count, nil
-}
-
-// ResetRate resets the rate limit counter for a client
-func (otc *OrderThrottleCache) ResetRate(clientID string) error {
-	ctx := context.Background()
-	key := fmt.Sprintf("throttle:%s", clientID)
+	args := []interface{}{time.Now().UnixMilli(), takeFlag}
+	for _, bucket := range buckets {
+		args = append(args, bucket.Rate, bucket.Burst)
+	}
 
-	err := otc.redis.client.Del(ctx, key).Err()
+	values, err := tokenBucketScript.Run(ctx, otc.redis.client, keys, args...).Slice()
 	if err != nil {
-		return fmt.Errorf("failed to reset throttle: %w", err)
+		return nil, fmt.Errorf("failed to run throttle script: %w", err)
+	}
+	if len(values) != 2*len(buckets) {
+		return nil, fmt.Errorf("unexpected throttle script result: %v", values)
 	}
 
-	return nil
+	decisions := make([]ThrottleDecision, len(buckets))
+	for i := range decisions {
+		allowed, _ := values[2*i].(int64)
+		remainingStr, _ := values[2*i+1].(string)
+		remaining, _ := strconv.ParseFloat(remainingStr, 64)
+		decisions[i] = ThrottleDecision{Allowed: allowed == 1, Remaining: remaining}
+	}
+	return decisions, nil
 }
 
-// CheckRateAdvanced implements a more sophisticated sliding window rate limiter
-// This allows for burst capacity while maintaining average rate
-func (otc *OrderThrottleCache) CheckRateAdvanced(clientID string, maxPerSecond int, windowSeconds int) error {
-	ctx := context.Background()
-	now := time.Now()
-	windowStart := now.Add(-time.Duration(windowSeconds) * time.Second)
+func (otc *OrderThrottleCache) consumeMemory(keys []string, buckets []ThrottleBucket, take bool) []ThrottleDecision {
+	otc.mu.Lock()
+	defer otc.mu.Unlock()
 
-	key := fmt.Sprintf("throttle:advanced:%s", clientID)
+	now := time.Now()
+	decisions := make([]ThrottleDecision, len(buckets))
+	all := true
+	for i, bucket := range buckets {
+		tokens := float64(bucket.Burst)
+		if stored, ok := otc.buckets[keys[i]]; ok {
+			tokens = math.Min(float64(bucket.Burst), stored.tokens+now.Sub(stored.last).Seconds()*bucket.Rate)
+		}
+		decisions[i] = ThrottleDecision{Allowed: tokens >= 1, Remaining: tokens}
+		all = all && decisions[i].Allowed
+	}
 
-	// Add current timestamp to sorted set
-	score := float64(now.UnixNano())
-	err := otc.redis.client.ZAdd(ctx, key, struct {
-		Score  float64
-		Member interface{}
-	}{Score: score, Member: score}).Err()
-	if err != nil {
-		return fmt.Errorf("failed to add to rate limiter: %w", err)
+	if take && all {
+		for i := range decisions {
+			decisions[i].Remaining--
+			otc.buckets[keys[i]] = &tokenBucket{tokens: decisions[i].Remaining, last: now}
+		}
 	}
 
-	// Remove old entries outside the window
-	minScore := float64(windowStart.UnixNano())
-	otc.redis.client.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("%f", minScore))
+	return decisions
+}
 
-	// Count entries in window
-	count, err := otc.redis.client.ZCard(ctx, key).Result()
+// CheckRate checks a per-second limit for a client with burst equal to the rate
+func (otc *OrderThrottleCache) CheckRate(clientID string, maxPerSecond int) error {
+	decision, err := otc.Take(clientID, float64(maxPerSecond), maxPerSecond)
 	if err != nil {
-		return fmt.Errorf("failed to count rate: %w", err)
+		return err
 	}
 
-	// Set expiration
-	otc.redis.client.Expire(ctx, key, time.Duration(windowSeconds+1)*time.Second)
-
-	// Check limit
-	maxInWindow := int64(maxPerSecond * windowSeconds)
-	if count > maxInWindow {
-		return fmt.Errorf("rate limit exceeded: %d orders in %ds window (limit: %d)", count, windowSeconds, maxInWindow)
+	if !decision.Allowed {
+		return fmt.Errorf("rate limit exceeded: %s (limit: %d/sec)", clientID, maxPerSecond)
 	}
 
 	return nil
 }
 
+// ResetRate resets the token bucket for a client
+func (otc *OrderThrottleCache) ResetRate(clientID string) error {
+	key := "throttle:" + clientID
+
+	otc.mu.Lock()
+	delete(otc.buckets, key)
+	otc.mu.Unlock()
+
+	if otc.redis == nil || otc.redis.client == nil {
+		return nil
+	}
+
+	err := otc.redis.client.Del(context.Background(), key).Err()
+	if err != nil {
+		return fmt.Errorf("failed to reset throttle: %w", err)
+	}
+
+	return nil
+}
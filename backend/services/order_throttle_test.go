@@ -0,0 +1,128 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestTokenBucketBurstAndRefill(t *testing.T) {
+	otc := NewOrderThrottleCache(nil)
+
+	for i := 0; i < 3; i++ {
+		decision, err := otc.Take("test:burst", 20, 3)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !decision.Allowed {
+			t.Fatalf("Expected order %d within burst to be allowed", i+1)
+		}
+	}
+
+	if decision, _ := otc.Take("test:burst", 20, 3); decision.Allowed {
+		t.Error("Expected order beyond burst to be throttled")
+	}
+
+	// 20 tokens/sec refills one token in 50ms
+	time.Sleep(60 * time.Millisecond)
+	if decision, _ := otc.Take("test:burst", 20, 3); !decision.Allowed {
+		t.Error("Expected order to be allowed after refill")
+	}
+}
+
+func TestTokenBucketPeekDoesNotConsume(t *testing.T) {
+	otc := NewOrderThrottleCache(nil)
+
+	for i := 0; i < 5; i++ {
+		if decision, _ := otc.Peek("test:peek", 1, 1); !decision.Allowed {
+			t.Fatal("Expected peek to leave the bucket full")
+		}
+	}
+
+	if decision, _ := otc.Take("test:peek", 1, 1); !decision.Allowed {
+		t.Error("Expected first take to be allowed")
+	}
+	if decision, _ := otc.Peek("test:peek", 1, 1); decision.Allowed {
+		t.Error("Expected peek to report an empty bucket")
+	}
+}
+
+func TestTokenBucketInvalidLimit(t *testing.T) {
+	otc := NewOrderThrottleCache(nil)
+
+	if _, err := otc.Take("test:invalid", 0, 10); err == nil {
+		t.Error("Expected error for zero rate")
+	}
+}
+
+func TestBasketThrottleCountsEarlierOrders(t *testing.T) {
+	rm := &RiskManager{
+		limits:     &models.RiskLimits{},
+		orderCache: NewOrderThrottleCache(nil),
+		throttles: map[string]models.ThrottleLimit{
+			throttleKey(models.ThrottleScopeUser, "alice", models.ThrottleActionNew): {Rate: 0.01, Burst: 2},
+		},
+	}
+	alice := models.ThrottleIdentity{UserID: "alice"}
+	order := &models.OrderRequest{Symbol: "AAPL", Side: "BUY", Quantity: 1, Price: 100}
+
+	basket := newBasketUsage()
+	for i := 0; i < 2; i++ {
+		if err := rm.checkOrderThrottle(models.ThrottleActionNew, alice, basket); err != nil {
+			t.Fatalf("order %d: expected to fit the burst, got %v", i+1, err)
+		}
+		rm.addToBasket(basket, order, alice)
+	}
+	if err := rm.checkOrderThrottle(models.ThrottleActionNew, alice, basket); err == nil {
+		t.Error("expected the third order of the basket to be throttled")
+	}
+
+	// The dry run took nothing from the live bucket
+	if err := rm.CheckOrderThrottle(models.ThrottleActionNew, alice); err != nil {
+		t.Errorf("expected a live order to be allowed, got %v", err)
+	}
+}
+
+func TestThrottleRejectionRefundsOtherScopes(t *testing.T) {
+	rm := &RiskManager{
+		limits:     &models.RiskLimits{},
+		orderCache: NewOrderThrottleCache(nil),
+		throttles: map[string]models.ThrottleLimit{
+			throttleKey(models.ThrottleScopeUser, "alice", models.ThrottleActionNew):     {Rate: 0.01, Burst: 2},
+			throttleKey(models.ThrottleScopeStrategy, "mm", models.ThrottleActionNew):    {Rate: 0.01, Burst: 1},
+			throttleKey(models.ThrottleScopeStrategy, "trend", models.ThrottleActionNew): {Rate: 0.01, Burst: 5},
+		},
+	}
+	mm := models.ThrottleIdentity{UserID: "alice", Strategy: "mm"}
+	trend := models.ThrottleIdentity{UserID: "alice", Strategy: "trend"}
+
+	if err := rm.CheckOrderThrottle(models.ThrottleActionNew, mm); err != nil {
+		t.Fatalf("expected the first order to be allowed, got %v", err)
+	}
+	// The strategy bucket is empty, so the user bucket must keep its token
+	for i := 0; i < 3; i++ {
+		if err := rm.CheckOrderThrottle(models.ThrottleActionNew, mm); err == nil {
+			t.Fatal("expected the strategy bucket to throttle the order")
+		}
+	}
+	if err := rm.CheckOrderThrottle(models.ThrottleActionNew, trend); err != nil {
+		t.Errorf("expected the user bucket to have a token left, got %v", err)
+	}
+}
+
+func TestTakeAllIsAllOrNothing(t *testing.T) {
+	otc := NewOrderThrottleCache(nil)
+	buckets := []ThrottleBucket{{Key: "test:a", Rate: 0.01, Burst: 3}, {Key: "test:b", Rate: 0.01, Burst: 1}}
+
+	if decisions, _ := otc.TakeAll(buckets); !decisions[0].Allowed || !decisions[1].Allowed {
+		t.Fatalf("expected both buckets to have a token, got %+v", decisions)
+	}
+	decisions, _ := otc.TakeAll(buckets)
+	if !decisions[0].Allowed || decisions[1].Allowed {
+		t.Fatalf("expected only the second bucket to be empty, got %+v", decisions)
+	}
+	if decision, _ := otc.Peek("test:a", 0.01, 3); decision.Remaining < 1.99 || decision.Remaining > 2.01 {
+		t.Errorf("expected the rejected take to leave 2 tokens in the first bucket, got %.2f", decision.Remaining)
+	}
+}
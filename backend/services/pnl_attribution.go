@@ -0,0 +1,356 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hft/backend/models"
+	"gorm.io/gorm"
+)
+
+// ManualStrategy is the strategy reported for orders submitted without one
+const ManualStrategy = "manual"
+
+// pnlContribution is the P&L of one execution, or of one open lot, with the
+// tags of the order behind it
+type pnlContribution struct {
+	Strategy   string
+	Trader     string
+	Symbol     string
+	Tags       []string
+	Time       time.Time
+	Realized   float64
+	Unrealized float64
+	Fees       float64
+	Fills      int
+	Volume     float64
+}
+
+// PnLAttribution breaks realised and unrealised P&L down by strategy, trader,
+// symbol, tag and time bucket
+type PnLAttribution struct {
+	db           *DatabaseService
+	ledger       *PnLLedger
+	positionBook *PositionBook
+	riskManager  *RiskManager
+}
+
+// NewPnLAttribution creates a new P&L attribution service
+func NewPnLAttribution(db *DatabaseService, ledger *PnLLedger, positionBook *PositionBook, riskManager *RiskManager) *PnLAttribution {
+	return &PnLAttribution{
+		db:           db,
+		ledger:       ledger,
+		positionBook: positionBook,
+		riskManager:  riskManager,
+	}
+}
+
+// ValidateAttributionQuery normalises a query and checks its dimensions and
+// interval
+func ValidateAttributionQuery(query *models.PnLAttributionQuery) error {
+	seen := make(map[string]bool)
+	groupBy := []string{}
+	for _, dimension := range query.GroupBy {
+		dimension = strings.ToLower(strings.TrimSpace(dimension))
+		switch dimension {
+		case "":
+			continue
+		case models.AttributeByStrategy, models.AttributeByTrader, models.AttributeBySymbol, models.AttributeByTag:
+		default:
+			return fmt.Errorf("cannot group by %q; use %s, %s, %s or %s", dimension,
+				models.AttributeByStrategy, models.AttributeByTrader, models.AttributeBySymbol, models.AttributeByTag)
+		}
+		if !seen[dimension] {
+			seen[dimension] = true
+			groupBy = append(groupBy, dimension)
+		}
+	}
+	query.GroupBy = groupBy
+
+	query.Interval = strings.ToLower(query.Interval)
+	switch query.Interval {
+	case "", models.AttributionHourly, models.AttributionDaily, models.AttributionWeekly:
+	default:
+		return fmt.Errorf("interval must be %s, %s or %s",
+			models.AttributionHourly, models.AttributionDaily, models.AttributionWeekly)
+	}
+
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	query.Symbol = strings.ToUpper(query.Symbol)
+	return nil
+}
+
+// Attribute returns the P&L of the executions and open lots matching the
+// query. Realised P&L belongs to the execution that closed the lots and falls
+// in the bucket of its fill time. Unrealised P&L belongs to the execution
+// that opened each lot, at the time it was opened; average cost merges lots,
+// so under that method it belongs to the first opening execution. Fees
+// belong to the execution they were charged on.
+func (pa *PnLAttribution) Attribute(query models.PnLAttributionQuery) (*models.PnLAttributionReport, error) {
+	if err := ValidateAttributionQuery(&query); err != nil {
+		return nil, err
+	}
+
+	report := &models.PnLAttributionReport{
+		Query:     query,
+		Rows:      []models.PnLAttributionRow{},
+		Timestamp: time.Now(),
+	}
+	db := pa.db.GetDB()
+	if db == nil {
+		return report, nil // Database disabled
+	}
+
+	if err := pa.ledger.Sync(); err != nil {
+		return nil, err
+	}
+
+	var executions []models.Execution
+	if result := filterExecutions(db, query).Order("id").Find(&executions); result.Error != nil {
+		return nil, result.Error
+	}
+
+	var realized []models.ExecutionPnL
+	if len(executions) > 0 {
+		ids := filterExecutions(db, query).Select("id")
+		if result := db.Where("execution_id IN (?)", ids).Find(&realized); result.Error != nil {
+			return nil, result.Error
+		}
+	}
+	realizedByID := make(map[uint]models.ExecutionPnL, len(realized))
+	for _, row := range realized {
+		realizedByID[row.ExecutionID] = row
+	}
+
+	contributions := make([]pnlContribution, 0, len(executions))
+	for _, execution := range executions {
+		contribution := executionContribution(execution)
+		contribution.Realized = realizedByID[execution.ID].RealizedPnL
+		contribution.Fees = realizedByID[execution.ID].Fees
+		contribution.Fills = 1
+		contribution.Volume = execution.FillQty * execution.FillPrice
+		contributions = append(contributions, contribution)
+	}
+
+	lots, err := pa.unrealizedContributions(db, query)
+	if err != nil {
+		return nil, err
+	}
+	contributions = append(contributions, lots...)
+
+	// Filtering by a tag leaves the other tags of a fill out of the rows
+	if query.Tag != "" {
+		for i := range contributions {
+			contributions[i].Tags = []string{query.Tag}
+		}
+	}
+
+	bucket := func(t time.Time) string {
+		return timeBucket(query.Interval, t, pa.tradingDayOf)
+	}
+	report.Rows = attributePnL(contributions, query.GroupBy, bucket)
+	for _, contribution := range contributions {
+		report.RealizedPnL += contribution.Realized
+		report.UnrealizedPnL += contribution.Unrealized
+		report.Fees += contribution.Fees
+	}
+	report.TotalPnL = report.RealizedPnL + report.UnrealizedPnL - report.Fees
+	return report, nil
+}
+
+// unrealizedContributions marks every open lot opened by a matching
+// execution
+func (pa *PnLAttribution) unrealizedContributions(db *gorm.DB, query models.PnLAttributionQuery) ([]pnlContribution, error) {
+	marks := make(map[string]float64)
+	if pa.positionBook != nil {
+		for _, position := range pa.positionBook.Positions() {
+			marks[position.Symbol] = position.MarketPrice
+		}
+	}
+
+	var lots []models.OpenLot
+	var ids []uint
+	for _, symbolPnL := range pa.ledger.Positions() {
+		if marks[symbolPnL.Symbol] <= 0 {
+			marks[symbolPnL.Symbol] = symbolPnL.LastPrice
+		}
+		for _, lot := range symbolPnL.Lots {
+			lots = append(lots, lot)
+			ids = append(ids, lot.ExecutionID)
+		}
+	}
+	if len(lots) == 0 {
+		return nil, nil
+	}
+
+	var openers []models.Execution
+	if result := filterExecutions(db, query).Where("id IN ?", ids).Find(&openers); result.Error != nil {
+		return nil, result.Error
+	}
+	byID := make(map[uint]models.Execution, len(openers))
+	for _, execution := range openers {
+		byID[execution.ID] = execution
+	}
+
+	var contributions []pnlContribution
+	for _, lot := range lots {
+		execution, ok := byID[lot.ExecutionID]
+		if !ok {
+			continue
+		}
+		contribution := executionContribution(execution)
+		contribution.Time = lot.OpenedAt
+		contribution.Unrealized = lot.Quantity * (marks[lot.Symbol] - lot.Price)
+		contributions = append(contributions, contribution)
+	}
+	return contributions, nil
+}
+
+// filterExecutions restricts executions to the query's filters and time range
+func filterExecutions(db *gorm.DB, query models.PnLAttributionQuery) *gorm.DB {
+	tx := whereStrategy(db.Model(&models.Execution{}), query.Strategy)
+	if query.Trader != "" {
+		tx = tx.Where("trader = ?", query.Trader)
+	}
+	if query.Symbol != "" {
+		tx = tx.Where("symbol = ?", query.Symbol)
+	}
+	tx = whereTag(tx, query.Tag)
+	if query.From != nil {
+		tx = tx.Where("timestamp >= ?", *query.From)
+	}
+	if query.To != nil {
+		tx = tx.Where("timestamp < ?", *query.To)
+	}
+	return tx
+}
+
+// whereStrategy matches orders or executions of a strategy; the manual
+// strategy also matches those submitted without one
+func whereStrategy(tx *gorm.DB, strategy string) *gorm.DB {
+	switch strategy {
+	case "":
+		return tx
+	case ManualStrategy:
+		return tx.Where("strategy = '' OR strategy IS NULL OR strategy = ?", ManualStrategy)
+	}
+	return tx.Where("strategy = ?", strategy)
+}
+
+// whereTag matches orders or executions carrying a tag
+func whereTag(tx *gorm.DB, tag string) *gorm.DB {
+	if tag == "" {
+		return tx
+	}
+	tags, _ := json.Marshal([]string{tag})
+	return tx.Where("tags @> CAST(? AS jsonb)", string(tags))
+}
+
+func executionContribution(execution models.Execution) pnlContribution {
+	strategy := execution.Strategy
+	if strategy == "" {
+		strategy = ManualStrategy
+	}
+	return pnlContribution{
+		Strategy: strategy,
+		Trader:   execution.Trader,
+		Symbol:   execution.Symbol,
+		Tags:     execution.Tags,
+		Time:     execution.Timestamp,
+	}
+}
+
+func (pa *PnLAttribution) tradingDayOf(t time.Time) string {
+	if pa.riskManager == nil {
+		return t.Local().Format("2006-01-02")
+	}
+	return pa.riskManager.tradingDayOf(t)
+}
+
+// timeBucket labels the hour, trading day or week (starting Monday) t falls
+// in. Hours are in market time.
+func timeBucket(interval string, t time.Time, tradingDay func(time.Time) string) string {
+	switch interval {
+	case models.AttributionHourly:
+		return t.In(marketLocation).Format("2006-01-02 15:00")
+	case models.AttributionDaily:
+		return tradingDay(t)
+	case models.AttributionWeekly:
+		day := tradingDate(tradingDay(t))
+		monday := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return monday.Format("2006-01-02")
+	}
+	return ""
+}
+
+// attributePnL sums contributions into one row per combination of the
+// grouped dimensions and time bucket. A contribution with several tags is
+// added to the row of each tag; one without tags to the row with no tag.
+func attributePnL(contributions []pnlContribution, groupBy []string, bucket func(time.Time) string) []models.PnLAttributionRow {
+	group := make(map[string]bool, len(groupBy))
+	for _, dimension := range groupBy {
+		group[dimension] = true
+	}
+
+	rows := make(map[models.PnLAttributionRow]*models.PnLAttributionRow)
+	for _, contribution := range contributions {
+		var key models.PnLAttributionRow
+		if group[models.AttributeByStrategy] {
+			key.Strategy = contribution.Strategy
+		}
+		if group[models.AttributeByTrader] {
+			key.Trader = contribution.Trader
+		}
+		if group[models.AttributeBySymbol] {
+			key.Symbol = contribution.Symbol
+		}
+		key.Bucket = bucket(contribution.Time)
+
+		tags := []string{""}
+		if group[models.AttributeByTag] && len(contribution.Tags) > 0 {
+			tags = contribution.Tags
+		}
+		for _, tag := range tags {
+			key.Tag = tag
+			row, ok := rows[key]
+			if !ok {
+				row = &models.PnLAttributionRow{
+					Strategy: key.Strategy,
+					Trader:   key.Trader,
+					Symbol:   key.Symbol,
+					Tag:      key.Tag,
+					Bucket:   key.Bucket,
+				}
+				rows[key] = row
+			}
+			row.RealizedPnL += contribution.Realized
+			row.UnrealizedPnL += contribution.Unrealized
+			row.Fees += contribution.Fees
+			row.TotalPnL += contribution.Realized + contribution.Unrealized - contribution.Fees
+			row.Fills += contribution.Fills
+			row.Volume += contribution.Volume
+		}
+	}
+
+	result := make([]models.PnLAttributionRow, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Bucket != b.Bucket {
+			return a.Bucket < b.Bucket
+		}
+		if a.TotalPnL != b.TotalPnL {
+			return a.TotalPnL > b.TotalPnL
+		}
+		return strings.Join([]string{a.Strategy, a.Trader, a.Symbol, a.Tag}, "\x00") <
+			strings.Join([]string{b.Strategy, b.Trader, b.Symbol, b.Tag}, "\x00")
+	})
+	return result
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestAttributePnLByStrategy(t *testing.T) {
+	now := time.Now()
+	contributions := []pnlContribution{
+		{Strategy: "movers", Symbol: "AAPL", Time: now, Realized: 100, Fills: 1, Volume: 1000},
+		{Strategy: "movers", Symbol: "MSFT", Time: now, Unrealized: -20},
+		{Strategy: ManualStrategy, Symbol: "AAPL", Time: now, Realized: -50, Fills: 1, Volume: 500},
+	}
+
+	rows := attributePnL(contributions, []string{models.AttributeByStrategy}, func(time.Time) string { return "" })
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+
+	movers := rows[0]
+	if movers.Strategy != "movers" || movers.RealizedPnL != 100 || movers.UnrealizedPnL != -20 || movers.TotalPnL != 80 {
+		t.Errorf("unexpected movers row %+v", movers)
+	}
+	if movers.Symbol != "" {
+		t.Errorf("expected ungrouped symbol to be empty, got %q", movers.Symbol)
+	}
+	if manual := rows[1]; manual.Strategy != ManualStrategy || manual.TotalPnL != -50 || manual.Fills != 1 {
+		t.Errorf("unexpected manual row %+v", manual)
+	}
+}
+
+func TestAttributePnLByTagAndBucket(t *testing.T) {
+	day1 := time.Date(2026, 3, 9, 15, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	contributions := []pnlContribution{
+		{Tags: []string{"earnings", "hedge"}, Time: day1, Realized: 30},
+		{Time: day1, Realized: 10},
+		{Tags: []string{"earnings"}, Time: day2, Realized: 5},
+	}
+
+	bucket := func(t time.Time) string { return t.Format("2006-01-02") }
+	rows := attributePnL(contributions, []string{models.AttributeByTag}, bucket)
+
+	got := make(map[string]float64)
+	for _, row := range rows {
+		got[row.Bucket+"/"+row.Tag] = row.TotalPnL
+	}
+	want := map[string]float64{
+		"2026-03-09/earnings": 30,
+		"2026-03-09/hedge":    30,
+		"2026-03-09/":         10,
+		"2026-03-10/earnings": 5,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected rows %v, got %v", want, got)
+	}
+	for key, pnl := range want {
+		if got[key] != pnl {
+			t.Errorf("%s: expected %.2f, got %.2f", key, pnl, got[key])
+		}
+	}
+}
+
+func TestTimeBucket(t *testing.T) {
+	tradingDay := func(t time.Time) string { return t.Format("2006-01-02") }
+	thursday := time.Date(2026, 3, 12, 18, 45, 0, 0, marketLocation)
+
+	tests := []struct {
+		interval string
+		want     string
+	}{
+		{models.AttributionHourly, "2026-03-12 18:00"},
+		{models.AttributionDaily, "2026-03-12"},
+		{models.AttributionWeekly, "2026-03-09"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := timeBucket(tt.interval, thursday, tradingDay); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.interval, tt.want, got)
+		}
+	}
+
+	sunday := time.Date(2026, 3, 15, 12, 0, 0, 0, marketLocation)
+	if got := timeBucket(models.AttributionWeekly, sunday, tradingDay); got != "2026-03-09" {
+		t.Errorf("expected Sunday in the week of 2026-03-09, got %q", got)
+	}
+}
+
+func TestValidateAttributionQuery(t *testing.T) {
+	query := models.PnLAttributionQuery{GroupBy: []string{" Strategy", "tag", "strategy", ""}, Interval: "DAY", Symbol: "aapl"}
+	if err := ValidateAttributionQuery(&query); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(query.GroupBy) != 2 || query.GroupBy[0] != "strategy" || query.GroupBy[1] != "tag" {
+		t.Errorf("expected [strategy tag], got %v", query.GroupBy)
+	}
+	if query.Interval != "day" || query.Symbol != "AAPL" {
+		t.Errorf("expected normalised interval and symbol, got %q and %q", query.Interval, query.Symbol)
+	}
+
+	if err := ValidateAttributionQuery(&models.PnLAttributionQuery{GroupBy: []string{"desk"}}); err == nil {
+		t.Error("expected an unknown dimension to be rejected")
+	}
+	if err := ValidateAttributionQuery(&models.PnLAttributionQuery{Interval: "month"}); err == nil {
+		t.Error("expected an unknown interval to be rejected")
+	}
+}
@@ -0,0 +1,511 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lotEpsilon is the quantity below which a lot is treated as fully closed
+const lotEpsilon = 1e-9
+
+// lotBook holds the open lots of one symbol and matches closing fills
+// against them under a cost-basis method
+type lotBook struct {
+	method    string
+	lots      []models.OpenLot
+	realized  float64
+	fees      float64
+	wins      int
+	losses    int
+	lastPrice float64
+}
+
+func newLotBook(method string) *lotBook {
+	return &lotBook{method: method}
+}
+
+// position returns the signed open quantity
+func (b *lotBook) position() float64 {
+	total := 0.0
+	for _, lot := range b.lots {
+		total += lot.Quantity
+	}
+	return total
+}
+
+// avgCost returns the quantity-weighted price of the open lots
+func (b *lotBook) avgCost() float64 {
+	qty, cost := 0.0, 0.0
+	for _, lot := range b.lots {
+		qty += math.Abs(lot.Quantity)
+		cost += math.Abs(lot.Quantity) * lot.Price
+	}
+	if qty == 0 {
+		return 0
+	}
+	return cost / qty
+}
+
+// apply books an execution. The part of the fill that opposes the open
+// position closes lots and realises P&L; any remainder opens a new lot, so a
+// fill larger than the position flips it.
+func (b *lotBook) apply(exec models.Execution) models.ExecutionPnL {
+	signed := exec.FillQty
+	if strings.ToUpper(exec.Side) == "SELL" {
+		signed = -signed
+	}
+
+	result := models.ExecutionPnL{
+		ExecutionID: exec.ID,
+		Symbol:      exec.Symbol,
+		Side:        strings.ToUpper(exec.Side),
+		FillQty:     exec.FillQty,
+		FillPrice:   exec.FillPrice,
+		Method:      b.method,
+		Timestamp:   exec.Timestamp,
+	}
+
+	remaining := math.Abs(signed)
+	closedCost := 0.0
+	for remaining > lotEpsilon && len(b.lots) > 0 && b.lots[0].Quantity*signed < 0 {
+		i := 0
+		if b.method == models.CostMethodLIFO {
+			i = len(b.lots) - 1
+		}
+		lot := &b.lots[i]
+
+		closeQty := math.Min(remaining, math.Abs(lot.Quantity))
+		direction := 1.0
+		if lot.Quantity < 0 {
+			direction = -1.0
+		}
+		result.RealizedPnL += closeQty * (exec.FillPrice - lot.Price) * direction
+		result.ClosedQty += closeQty
+		closedCost += closeQty * lot.Price
+
+		lot.Quantity -= closeQty * direction
+		remaining -= closeQty
+		if math.Abs(lot.Quantity) <= lotEpsilon {
+			b.lots = append(b.lots[:i], b.lots[i+1:]...)
+		}
+	}
+
+	if remaining > lotEpsilon {
+		b.open(models.OpenLot{
+			Symbol:      exec.Symbol,
+			Quantity:    math.Copysign(remaining, signed),
+			Price:       exec.FillPrice,
+			ExecutionID: exec.ID,
+			OpenedAt:    exec.Timestamp,
+		})
+	}
+
+	if result.ClosedQty > 0 {
+		result.CostBasis = closedCost / result.ClosedQty
+		b.realized += result.RealizedPnL
+		if result.RealizedPnL > 0 {
+			b.wins++
+		} else if result.RealizedPnL < 0 {
+			b.losses++
+		}
+	}
+	result.PositionAfter = b.position()
+	b.lastPrice = exec.FillPrice
+
+	return result
+}
+
+// open adds a lot. Average cost keeps a single lot at the blended price.
+func (b *lotBook) open(lot models.OpenLot) {
+	if b.method != models.CostMethodAverage || len(b.lots) == 0 {
+		b.lots = append(b.lots, lot)
+		return
+	}
+
+	merged := &b.lots[0]
+	total := merged.Quantity + lot.Quantity
+	merged.Price = (merged.Quantity*merged.Price + lot.Quantity*lot.Price) / total
+	merged.Quantity = total
+}
+
+// applyAction rescales the lots for a split or credits a cash dividend on
+// the open position; shorts pay the dividend
+func (b *lotBook) applyAction(action models.CorporateAction) models.CorporateActionAdjustment {
+	adjustment := models.CorporateActionAdjustment{
+		ActionID:       action.ID,
+		Symbol:         action.Symbol,
+		QuantityBefore: b.position(),
+		AvgCostBefore:  b.avgCost(),
+		EffectiveAt:    corporateActionEffectiveAt(action.ExDate),
+	}
+
+	switch action.Type {
+	case models.CorporateActionSplit, models.CorporateActionReverseSplit:
+		ratio := action.Ratio()
+		for i := range b.lots {
+			b.lots[i].Quantity *= ratio
+			b.lots[i].Price /= ratio
+		}
+		b.lastPrice /= ratio
+	case models.CorporateActionDividend:
+		adjustment.CashAmount = adjustment.QuantityBefore * action.CashAmount
+		b.realized += adjustment.CashAmount
+	}
+
+	adjustment.QuantityAfter = b.position()
+	adjustment.AvgCostAfter = b.avgCost()
+	return adjustment
+}
+
+// PnLLedger computes realised P&L and fees of every execution from open lots
+// and feeds today's net realised P&L to the risk manager
+type PnLLedger struct {
+	db             *DatabaseService
+	riskManager    *RiskManager
+	fees           *FeeModel
+	method         string
+	books          map[string]*lotBook
+	monthShares    map[string]float64 // Share volume per account and month, for commission tiers
+	appliedActions map[uint]bool      // Corporate actions reflected in the books
+	lastID         uint               // Highest execution booked
+	lastRealized   float64            // Net realised P&L last reported to the risk manager
+	mu             sync.Mutex
+	ticker         *time.Ticker
+	stopChan       chan bool
+}
+
+// NewPnLLedger creates a new P&L ledger using FIFO, LIFO or AVERAGE cost
+func NewPnLLedger(db *DatabaseService, riskManager *RiskManager, fees *FeeModel, method string) *PnLLedger {
+	method = strings.ToUpper(method)
+	if !validCostMethod(method) {
+		log.Printf("Unknown cost method %q, using %s", method, models.CostMethodFIFO)
+		method = models.CostMethodFIFO
+	}
+
+	return &PnLLedger{
+		db:             db,
+		riskManager:    riskManager,
+		fees:           fees,
+		method:         method,
+		books:          make(map[string]*lotBook),
+		monthShares:    make(map[string]float64),
+		appliedActions: make(map[uint]bool),
+		lastRealized:   math.NaN(),
+		stopChan:       make(chan bool),
+	}
+}
+
+func validCostMethod(method string) bool {
+	switch method {
+	case models.CostMethodFIFO, models.CostMethodLIFO, models.CostMethodAverage:
+		return true
+	}
+	return false
+}
+
+// Start replays all executions and then books new ones periodically
+func (pl *PnLLedger) Start() {
+	if err := pl.Sync(); err != nil {
+		log.Printf("Error building P&L ledger: %v", err)
+	}
+
+	pl.ticker = time.NewTicker(5 * time.Second)
+
+	go func() {
+		log.Printf("P&L ledger started (%s cost, updating every 5 seconds)", pl.Method())
+
+		for {
+			select {
+			case <-pl.ticker.C:
+				if err := pl.Sync(); err != nil {
+					log.Printf("Error updating P&L ledger: %v", err)
+				}
+
+			case <-pl.stopChan:
+				log.Println("P&L ledger stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the P&L ledger
+func (pl *PnLLedger) Stop() {
+	if pl.ticker != nil {
+		pl.ticker.Stop()
+	}
+	pl.stopChan <- true
+}
+
+// Method returns the cost-basis method in use
+func (pl *PnLLedger) Method() string {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.method
+}
+
+// SetMethod switches the cost-basis method and rebuilds the ledger from
+// every execution
+func (pl *PnLLedger) SetMethod(method string) error {
+	method = strings.ToUpper(method)
+	if !validCostMethod(method) {
+		return fmt.Errorf("cost method must be %s, %s or %s",
+			models.CostMethodFIFO, models.CostMethodLIFO, models.CostMethodAverage)
+	}
+
+	pl.mu.Lock()
+	pl.method = method
+	pl.reset()
+	pl.mu.Unlock()
+
+	return pl.Sync()
+}
+
+// Rebuild books every execution again, repricing fees after a backdated
+// fee schedule change
+func (pl *PnLLedger) Rebuild() error {
+	pl.mu.Lock()
+	pl.reset()
+	pl.mu.Unlock()
+
+	return pl.Sync()
+}
+
+func (pl *PnLLedger) reset() {
+	pl.books = make(map[string]*lotBook)
+	pl.monthShares = make(map[string]float64)
+	pl.appliedActions = make(map[uint]bool)
+	pl.lastID = 0
+}
+
+// Sync books executions recorded since the last sync and applied corporate
+// actions as of their ex-date, stores the realised P&L, fees and action
+// adjustments and reports today's net realised P&L to the risk manager
+func (pl *PnLLedger) Sync() error {
+	db := pl.db.GetDB()
+	if db == nil {
+		return nil // Database disabled
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	var executions []models.Execution
+	if result := db.Where("id > ?", pl.lastID).Order("id").Find(&executions); result.Error != nil {
+		return result.Error
+	}
+
+	var actions []models.CorporateAction
+	if result := db.Where("status = ?", models.CorporateActionApplied).Order("ex_date, id").Find(&actions); result.Error != nil {
+		return result.Error
+	}
+	var due []models.CorporateAction
+	for _, action := range actions {
+		if !pl.appliedActions[action.ID] {
+			due = append(due, action)
+		}
+	}
+
+	var adjustments []models.CorporateActionAdjustment
+	applyDue := func(t time.Time) {
+		for len(due) > 0 && !corporateActionEffectiveAt(due[0].ExDate).After(t) {
+			adjustments = append(adjustments, pl.applyAction(due[0])...)
+			pl.appliedActions[due[0].ID] = true
+			due = due[1:]
+		}
+	}
+
+	rows := make([]models.ExecutionPnL, 0, len(executions))
+	fees := make([]models.ExecutionFee, 0, len(executions))
+	for _, exec := range executions {
+		applyDue(exec.Timestamp)
+
+		book, ok := pl.books[exec.Symbol]
+		if !ok {
+			book = newLotBook(pl.method)
+			pl.books[exec.Symbol] = book
+		}
+		row := book.apply(exec)
+
+		if pl.fees != nil {
+			month := feeMonth(executionAccount(exec), exec.Timestamp)
+			fee := pl.fees.Price(exec, pl.monthShares[month])
+			pl.monthShares[month] += exec.FillQty
+			row.Fees = fee.TotalFees
+			book.fees += fee.TotalFees
+			fees = append(fees, fee)
+		}
+		row.NetPnL = row.RealizedPnL - row.Fees
+		rows = append(rows, row)
+	}
+	applyDue(time.Now())
+
+	if len(rows) > 0 || len(adjustments) > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if len(rows) > 0 {
+				if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(rows, 500).Error; err != nil {
+					return err
+				}
+			}
+			if len(fees) > 0 {
+				if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(fees, 500).Error; err != nil {
+					return err
+				}
+			}
+			if len(adjustments) > 0 {
+				return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&adjustments).Error
+			}
+			return nil
+		})
+		if err != nil {
+			// The books already hold these fills; rebuild from scratch next time
+			pl.reset()
+			return err
+		}
+		if len(executions) > 0 {
+			pl.lastID = executions[len(executions)-1].ID
+		}
+	}
+
+	return pl.reportRealized()
+}
+
+// applyAction adjusts the books for a corporate action. A symbol change
+// moves the old symbol's lots, realised P&L and fees to the new symbol.
+func (pl *PnLLedger) applyAction(action models.CorporateAction) []models.CorporateActionAdjustment {
+	book, ok := pl.books[action.Symbol]
+	if !ok {
+		return nil
+	}
+
+	if action.Type != models.CorporateActionSymbolChange {
+		return []models.CorporateActionAdjustment{book.applyAction(action)}
+	}
+
+	adjustment := models.CorporateActionAdjustment{
+		ActionID:       action.ID,
+		Symbol:         action.Symbol,
+		QuantityBefore: book.position(),
+		QuantityAfter:  book.position(),
+		AvgCostBefore:  book.avgCost(),
+		AvgCostAfter:   book.avgCost(),
+		EffectiveAt:    corporateActionEffectiveAt(action.ExDate),
+	}
+
+	delete(pl.books, action.Symbol)
+	target, ok := pl.books[action.NewSymbol]
+	if !ok {
+		target = newLotBook(pl.method)
+		pl.books[action.NewSymbol] = target
+	}
+	for _, lot := range book.lots {
+		lot.Symbol = action.NewSymbol
+		target.open(lot)
+	}
+	target.realized += book.realized
+	target.fees += book.fees
+	target.wins += book.wins
+	target.losses += book.losses
+	if target.lastPrice == 0 {
+		target.lastPrice = book.lastPrice
+	}
+
+	return []models.CorporateActionAdjustment{adjustment}
+}
+
+// reportRealized pushes today's realised P&L and dividends net of fees to
+// the daily P&L tracking, keeping the unrealised P&L maintained by the P&L
+// monitor
+func (pl *PnLLedger) reportRealized() error {
+	if pl.riskManager == nil {
+		return nil
+	}
+
+	var today struct {
+		Realized float64
+		Fees     float64
+	}
+	pl.db.GetDB().Model(&models.ExecutionPnL{}).
+		Where("timestamp >= ?", pl.riskManager.tradingDayStart(time.Now())).
+		Select("COALESCE(SUM(realized_pnl), 0) AS realized, COALESCE(SUM(fees), 0) AS fees").Scan(&today)
+
+	var dividends float64
+	pl.db.GetDB().Model(&models.CorporateActionAdjustment{}).
+		Where("effective_at >= ?", pl.riskManager.tradingDayStart(time.Now())).
+		Select("COALESCE(SUM(cash_amount), 0)").Scan(&dividends)
+
+	net := today.Realized + dividends - today.Fees
+	if net == pl.lastRealized {
+		return nil
+	}
+
+	dailyPnL, err := pl.riskManager.GetDailyPnL()
+	if err != nil {
+		return err
+	}
+	if err := pl.riskManager.UpdateDailyNetPnL(net, today.Fees, dailyPnL.UnrealizedPnL); err != nil {
+		return err
+	}
+	pl.lastRealized = net
+	return nil
+}
+
+// Positions returns the open lots, realised P&L and fees of every symbol
+func (pl *PnLLedger) Positions() []models.SymbolPnL {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	positions := make([]models.SymbolPnL, 0, len(pl.books))
+	for symbol, book := range pl.books {
+		lots := make([]models.OpenLot, len(book.lots))
+		copy(lots, book.lots)
+		positions = append(positions, models.SymbolPnL{
+			Symbol:        symbol,
+			Position:      book.position(),
+			AvgCost:       book.avgCost(),
+			LastPrice:     book.lastPrice,
+			RealizedPnL:   book.realized,
+			Fees:          book.fees,
+			ClosingTrades: book.wins + book.losses,
+			Lots:          lots,
+		})
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+	return positions
+}
+
+// Totals returns realised P&L and the number of winning and losing closing
+// fills across all symbols
+func (pl *PnLLedger) Totals() (realized float64, wins, losses int) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	for _, book := range pl.books {
+		realized += book.realized
+		wins += book.wins
+		losses += book.losses
+	}
+	return realized, wins, losses
+}
+
+// Fees returns the fees charged on every booked execution
+func (pl *PnLLedger) Fees() float64 {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	total := 0.0
+	for _, book := range pl.books {
+		total += book.fees
+	}
+	return total
+}
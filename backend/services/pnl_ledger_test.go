@@ -0,0 +1,97 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func fill(id uint, side string, qty, price float64) models.Execution {
+	return models.Execution{ID: id, Symbol: "AAPL", Side: side, FillQty: qty, FillPrice: price, Timestamp: time.Now()}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestLotBookCostMethods(t *testing.T) {
+	tests := []struct {
+		method    string
+		realized  float64
+		costBasis float64
+		avgCost   float64
+	}{
+		// Sell 150 against 100@10 then 100@20
+		{models.CostMethodFIFO, 100*5 + 50*(-5), (100*10 + 50*20) / 150.0, 20},
+		{models.CostMethodLIFO, 100*(-5) + 50*5, (100*20 + 50*10) / 150.0, 10},
+		{models.CostMethodAverage, 150 * 0, 15, 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			book := newLotBook(tt.method)
+			book.apply(fill(1, "BUY", 100, 10))
+			book.apply(fill(2, "BUY", 100, 20))
+			result := book.apply(fill(3, "SELL", 150, 15))
+
+			if !closeTo(result.RealizedPnL, tt.realized) {
+				t.Errorf("expected realised %.2f, got %.2f", tt.realized, result.RealizedPnL)
+			}
+			if !closeTo(result.CostBasis, tt.costBasis) {
+				t.Errorf("expected cost basis %.4f, got %.4f", tt.costBasis, result.CostBasis)
+			}
+			if result.ClosedQty != 150 || result.PositionAfter != 50 {
+				t.Errorf("expected 150 closed leaving 50, got %.0f closed leaving %.0f", result.ClosedQty, result.PositionAfter)
+			}
+			if !closeTo(book.avgCost(), tt.avgCost) {
+				t.Errorf("expected remaining cost %.2f, got %.2f", tt.avgCost, book.avgCost())
+			}
+		})
+	}
+}
+
+func TestLotBookShortAndPartialClose(t *testing.T) {
+	book := newLotBook(models.CostMethodFIFO)
+
+	opening := book.apply(fill(1, "SELL", 100, 50))
+	if opening.ClosedQty != 0 || opening.RealizedPnL != 0 || opening.PositionAfter != -100 {
+		t.Fatalf("expected a short opening fill without P&L, got %+v", opening)
+	}
+
+	// Covering part of a short below the entry price is a gain
+	partial := book.apply(fill(2, "BUY", 40, 45))
+	if !closeTo(partial.RealizedPnL, 200) || partial.PositionAfter != -60 {
+		t.Errorf("expected +200 leaving -60, got %.2f leaving %.0f", partial.RealizedPnL, partial.PositionAfter)
+	}
+
+	// Buying more than the short covers it and opens a long at the fill price
+	flip := book.apply(fill(3, "BUY", 100, 55))
+	if !closeTo(flip.RealizedPnL, -300) || flip.ClosedQty != 60 || flip.PositionAfter != 40 {
+		t.Errorf("expected -300 on 60 closed leaving 40, got %.2f on %.0f leaving %.0f",
+			flip.RealizedPnL, flip.ClosedQty, flip.PositionAfter)
+	}
+	if len(book.lots) != 1 || book.lots[0].Price != 55 || book.lots[0].ExecutionID != 3 {
+		t.Errorf("expected one long lot opened by the flip, got %+v", book.lots)
+	}
+
+	if !closeTo(book.realized, -100) || book.wins != 1 || book.losses != 1 {
+		t.Errorf("expected -100 realised over 1 win and 1 loss, got %.2f over %d/%d", book.realized, book.wins, book.losses)
+	}
+}
+
+func TestLotBookAverageCostBlendsOpenings(t *testing.T) {
+	book := newLotBook(models.CostMethodAverage)
+	book.apply(fill(1, "BUY", 100, 10))
+	book.apply(fill(2, "BUY", 300, 14))
+
+	if len(book.lots) != 1 || !closeTo(book.lots[0].Price, 13) {
+		t.Fatalf("expected a single lot at 13, got %+v", book.lots)
+	}
+
+	result := book.apply(fill(3, "SELL", 100, 12))
+	if !closeTo(result.RealizedPnL, -100) || !closeTo(book.lots[0].Price, 13) {
+		t.Errorf("expected -100 with cost unchanged at 13, got %.2f at %.2f", result.RealizedPnL, book.lots[0].Price)
+	}
+}
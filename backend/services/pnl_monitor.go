@@ -2,22 +2,25 @@ package services
 
 import (
 	"log"
+	"sync"
 	"time"
 )
 
 // PnLMonitor monitors profit and loss in real-time
 type PnLMonitor struct {
 	riskManager  *RiskManager
-	engineClient *EngineClient
+	positionBook *PositionBook
 	ticker       *time.Ticker
 	stopChan     chan bool
+	mu           sync.Mutex
 }
 
-// NewPnLMonitor creates a new P&L monitor
-func NewPnLMonitor(riskManager *RiskManager, engineClient *EngineClient) *PnLMonitor {
+// NewPnLMonitor creates a new P&L monitor. Unrealised P&L comes from the
+// position book, marked to market by the backend.
+func NewPnLMonitor(riskManager *RiskManager, positionBook *PositionBook) *PnLMonitor {
 	return &PnLMonitor{
 		riskManager:  riskManager,
-		engineClient: engineClient,
+		positionBook: positionBook,
 		stopChan:     make(chan bool),
 	}
 }
@@ -32,7 +35,7 @@ func (pm *PnLMonitor) Start() {
 		for {
 			select {
 			case <-pm.ticker.C:
-				pm.updatePnL()
+				pm.UpdatePnL()
 
 			case <-pm.stopChan:
 				log.Println("P&L Monitor stopped")
@@ -50,17 +53,14 @@ func (pm *PnLMonitor) Stop() {
 	pm.stopChan <- true
 }
 
-// updatePnL calculates and updates daily P&L
-func (pm *PnLMonitor) updatePnL() {
-	// Get current positions from engine
-	response, err := pm.engineClient.GetPositions()
-	if err != nil {
-		log.Printf("Error getting positions for P&L update: %v", err)
-		return
-	}
+// UpdatePnL recalculates unrealised P&L and updates daily P&L. Called on
+// every price tick by the mark-to-market service and on a timer as backstop.
+func (pm *PnLMonitor) UpdatePnL() {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
 
 	// Calculate unrealized P&L
-	unrealizedPnL := pm.calculateUnrealizedPnL(response)
+	unrealizedPnL := pm.calculateUnrealizedPnL()
 
 	// Get today's realized P&L (from database)
 	dailyPnL, err := pm.riskManager.GetDailyPnL()
@@ -83,22 +83,15 @@ func (pm *PnLMonitor) updatePnL() {
 	}
 }
 
-// calculateUnrealizedPnL calculates unrealized P&L from positions
-func (pm *PnLMonitor) calculateUnrealizedPnL(positionsResponse map[string]interface{}) float64 {
+// calculateUnrealizedPnL sums unrealized P&L over the position book and
+// feeds the per-symbol loss breaker
+func (pm *PnLMonitor) calculateUnrealizedPnL() float64 {
 	var totalUnrealizedPnL float64
 
-	// Parse positions from response
-	if positions, ok := positionsResponse["positions"].([]interface{}); ok {
-		for _, pos := range positions {
-			if posMap, ok := pos.(map[string]interface{}); ok {
-				// Get unrealized P&L from position
-				if unrealizedPnL, ok := posMap["unrealized_pnl"].(float64); ok {
-					totalUnrealizedPnL += unrealizedPnL
-				}
-			}
-		}
+	for _, position := range pm.positionBook.Positions() {
+		totalUnrealizedPnL += position.UnrealizedPnL
+		pm.riskManager.UpdateSymbolPnL(position.Symbol, position.UnrealizedPnL)
 	}
 
 	return totalUnrealizedPnL
 }
-
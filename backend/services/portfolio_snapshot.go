@@ -0,0 +1,95 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SnapshotPosition is a single position in a portfolio snapshot
+type SnapshotPosition struct {
+	Symbol        string  `json:"symbol"`
+	Quantity      float64 `json:"quantity"`
+	AvgPrice      float64 `json:"avg_price"`
+	MarketPrice   float64 `json:"market_price"`
+	MarketValue   float64 `json:"market_value"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+}
+
+// PortfolioSnapshot is a point-in-time view of account equity and positions
+type PortfolioSnapshot struct {
+	Equity      float64                      `json:"equity"`
+	BuyingPower float64                      `json:"buying_power"`
+	Positions   map[string]*SnapshotPosition `json:"positions"`
+	Timestamp   time.Time                    `json:"timestamp"`
+}
+
+// LoadPortfolioSnapshot fetches account and positions from the engine
+func LoadPortfolioSnapshot(engine *EngineClient) (*PortfolioSnapshot, error) {
+	if engine == nil {
+		return nil, fmt.Errorf("engine client not available")
+	}
+
+	snapshot := &PortfolioSnapshot{
+		Positions: make(map[string]*SnapshotPosition),
+		Timestamp: time.Now(),
+	}
+
+	positionsResponse, err := engine.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	if positions, ok := positionsResponse["positions"].([]interface{}); ok {
+		for _, pos := range positions {
+			posMap, ok := pos.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			symbol, ok := posMap["symbol"].(string)
+			if !ok || symbol == "" {
+				continue
+			}
+
+			position := &SnapshotPosition{Symbol: symbol}
+			position.Quantity, _ = EngineNumber(posMap, "quantity", "qty")
+			position.AvgPrice, _ = EngineNumber(posMap, "avg_price", "avg_entry_price")
+			position.MarketPrice, _ = EngineNumber(posMap, "current_price", "market_price", "avg_price", "avg_entry_price")
+			position.UnrealizedPnL, _ = EngineNumber(posMap, "unrealized_pnl", "unrealized_pl")
+			if value, ok := EngineNumber(posMap, "market_value"); ok {
+				position.MarketValue = value
+			} else {
+				position.MarketValue = position.Quantity * position.MarketPrice
+			}
+
+			snapshot.Positions[symbol] = position
+		}
+	}
+
+	accountResponse, err := engine.GetAccount()
+	if err == nil {
+		if account, ok := accountResponse["account"].(map[string]interface{}); ok {
+			snapshot.Equity, _ = EngineNumber(account, "equity", "portfolio_value")
+			snapshot.BuyingPower, _ = EngineNumber(account, "buying_power")
+		}
+	}
+
+	return snapshot, nil
+}
+
+// Price returns the best known price for a symbol, or 0 if unknown
+func (ps *PortfolioSnapshot) Price(symbol string) float64 {
+	if position, ok := ps.Positions[symbol]; ok {
+		return position.MarketPrice
+	}
+	return 0
+}
+
+// GrossExposure returns the sum of absolute position market values
+func (ps *PortfolioSnapshot) GrossExposure() float64 {
+	var gross float64
+	for _, position := range ps.Positions {
+		gross += math.Abs(position.MarketValue)
+	}
+	return gross
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// positionBreakTolerance is the quantity difference below which the book and
+// the broker are considered to agree
+const positionBreakTolerance = 1e-6
+
+// PositionBook serves positions from the executions booked by the P&L ledger
+// and reconciles them against the broker periodically
+type PositionBook struct {
+	ledger         *PnLLedger
+	engine         *EngineClient
+	riskManager    *RiskManager
+	prices         *MarkToMarket
+	marks          map[string]float64 // Broker prices from the last reconciliation
+	account        map[string]interface{}
+	breaks         map[string]models.PositionBreak
+	reconciliation *models.PositionReconciliation
+	mu             sync.RWMutex
+	ticker         *time.Ticker
+	stopChan       chan bool
+}
+
+// NewPositionBook creates a new position book
+func NewPositionBook(ledger *PnLLedger, engine *EngineClient, riskManager *RiskManager) *PositionBook {
+	return &PositionBook{
+		ledger:      ledger,
+		engine:      engine,
+		riskManager: riskManager,
+		marks:       make(map[string]float64),
+		breaks:      make(map[string]models.PositionBreak),
+		stopChan:    make(chan bool),
+	}
+}
+
+// UseMarks marks positions to live prices, falling back to the broker's
+// price and then the latest fill when a symbol has no price
+func (pb *PositionBook) UseMarks(prices *MarkToMarket) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	pb.prices = prices
+}
+
+// Start reconciles with the broker now and then every 30 seconds
+func (pb *PositionBook) Start() {
+	pb.reconcileAndLog()
+
+	pb.ticker = time.NewTicker(30 * time.Second)
+
+	go func() {
+		log.Println("Position book started (reconciling every 30 seconds)")
+
+		for {
+			select {
+			case <-pb.ticker.C:
+				pb.reconcileAndLog()
+
+			case <-pb.stopChan:
+				log.Println("Position book stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the position book
+func (pb *PositionBook) Stop() {
+	if pb.ticker != nil {
+		pb.ticker.Stop()
+	}
+	pb.stopChan <- true
+}
+
+func (pb *PositionBook) reconcileAndLog() {
+	if _, err := pb.Reconcile(); err != nil {
+		log.Printf("Error reconciling positions: %v", err)
+	}
+}
+
+// Positions returns every open position in the book, marked to the live
+// price, the latest broker price or, failing that, the latest fill
+func (pb *PositionBook) Positions() []models.Position {
+	pb.mu.RLock()
+	defer pb.mu.RUnlock()
+
+	positions := []models.Position{}
+	for _, symbolPnL := range pb.ledger.Positions() {
+		if math.Abs(symbolPnL.Position) <= lotEpsilon {
+			continue
+		}
+		mark := pb.marks[symbolPnL.Symbol]
+		if pb.prices != nil {
+			if price, ok := pb.prices.Mark(symbolPnL.Symbol, symbolPnL.Position); ok {
+				mark = price
+			}
+		}
+		positions = append(positions, bookPosition(symbolPnL, mark))
+	}
+	return positions
+}
+
+// Position returns the booked position of a symbol
+func (pb *PositionBook) Position(symbol string) (models.Position, bool) {
+	for _, position := range pb.Positions() {
+		if position.Symbol == symbol {
+			return position, true
+		}
+	}
+	return models.Position{Symbol: symbol, Timestamp: time.Now()}, false
+}
+
+// bookPosition converts a ledger symbol into a position marked at mark,
+// falling back to the latest fill price
+func bookPosition(symbolPnL models.SymbolPnL, mark float64) models.Position {
+	if mark <= 0 {
+		mark = symbolPnL.LastPrice
+	}
+	return models.Position{
+		Symbol:        symbolPnL.Symbol,
+		Quantity:      symbolPnL.Position,
+		AvgPrice:      symbolPnL.AvgCost,
+		MarketPrice:   mark,
+		MarketValue:   symbolPnL.Position * mark,
+		UnrealizedPnL: symbolPnL.Position * (mark - symbolPnL.AvgCost),
+		RealizedPnL:   symbolPnL.RealizedPnL,
+		Timestamp:     time.Now(),
+	}
+}
+
+// RiskQuantity returns the filled quantity risk checks should assume for a
+// symbol. While the symbol is in break, the side with the larger exposure is
+// used.
+func (pb *PositionBook) RiskQuantity(symbol string) float64 {
+	position, _ := pb.Position(symbol)
+
+	pb.mu.RLock()
+	positionBreak, inBreak := pb.breaks[symbol]
+	pb.mu.RUnlock()
+
+	if inBreak && math.Abs(positionBreak.BrokerQuantity) > math.Abs(position.Quantity) {
+		return positionBreak.BrokerQuantity
+	}
+	return position.Quantity
+}
+
+// Account returns the broker account from the last reconciliation
+func (pb *PositionBook) Account() map[string]interface{} {
+	pb.mu.RLock()
+	defer pb.mu.RUnlock()
+	return pb.account
+}
+
+// GetReconciliation returns the result of the last reconciliation
+func (pb *PositionBook) GetReconciliation() *models.PositionReconciliation {
+	pb.mu.RLock()
+	defer pb.mu.RUnlock()
+	return pb.reconciliation
+}
+
+// Reconcile compares the book with the broker's positions and raises an
+// alert for every new or changed break
+func (pb *PositionBook) Reconcile() (*models.PositionReconciliation, error) {
+	if err := pb.ledger.Sync(); err != nil {
+		log.Printf("Error updating P&L ledger before reconciliation: %v", err)
+	}
+
+	response, err := pb.engine.GetPositions()
+	if err != nil {
+		result := &models.PositionReconciliation{
+			Breaks:    []models.PositionBreak{},
+			Error:     err.Error(),
+			CheckedAt: time.Now(),
+		}
+		pb.mu.Lock()
+		pb.reconciliation = result
+		pb.mu.Unlock()
+		return result, fmt.Errorf("failed to get broker positions: %w", err)
+	}
+
+	broker := make(map[string]float64)
+	marks := make(map[string]float64)
+	if positions, ok := response["positions"].([]interface{}); ok {
+		for _, pos := range positions {
+			posMap, ok := pos.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			symbol, ok := posMap["symbol"].(string)
+			if !ok || symbol == "" {
+				continue
+			}
+			broker[symbol], _ = EngineNumber(posMap, "quantity", "qty")
+			if price, ok := EngineNumber(posMap, "current_price", "market_price"); ok && price > 0 {
+				marks[symbol] = price
+			}
+		}
+	}
+
+	book := make(map[string]float64)
+	for _, symbolPnL := range pb.ledger.Positions() {
+		book[symbolPnL.Symbol] = symbolPnL.Position
+	}
+
+	now := time.Now()
+	breaks := findPositionBreaks(book, broker, now)
+	result := &models.PositionReconciliation{
+		Symbols:   len(broker),
+		Breaks:    breaks,
+		CheckedAt: now,
+	}
+
+	// An unchanged break keeps its detection time and is not alerted again
+	pb.mu.Lock()
+	var raised []models.PositionBreak
+	current := make(map[string]models.PositionBreak, len(breaks))
+	for i := range breaks {
+		previous, known := pb.breaks[breaks[i].Symbol]
+		if known && math.Abs(previous.Difference-breaks[i].Difference) <= positionBreakTolerance {
+			breaks[i].DetectedAt = previous.DetectedAt
+		} else {
+			raised = append(raised, breaks[i])
+		}
+		current[breaks[i].Symbol] = breaks[i]
+	}
+
+	pb.breaks = current
+	pb.marks = marks
+	if account, ok := response["account"].(map[string]interface{}); ok {
+		pb.account = account
+	}
+	pb.reconciliation = result
+	pb.mu.Unlock()
+
+	for _, positionBreak := range raised {
+		pb.riskManager.SendAlert("POSITION_BREAK", "WARNING", positionBreak.Symbol,
+			fmt.Sprintf("%s position break: book %.4f, broker %.4f",
+				positionBreak.Symbol, positionBreak.BookQuantity, positionBreak.BrokerQuantity),
+			map[string]interface{}{
+				"book_quantity":   positionBreak.BookQuantity,
+				"broker_quantity": positionBreak.BrokerQuantity,
+				"difference":      positionBreak.Difference,
+			})
+	}
+
+	return result, nil
+}
+
+// findPositionBreaks returns every symbol whose quantity differs between the
+// book and the broker, ordered by symbol
+func findPositionBreaks(book, broker map[string]float64, now time.Time) []models.PositionBreak {
+	symbols := make(map[string]bool, len(book)+len(broker))
+	for symbol := range book {
+		symbols[symbol] = true
+	}
+	for symbol := range broker {
+		symbols[symbol] = true
+	}
+
+	breaks := []models.PositionBreak{}
+	for symbol := range symbols {
+		difference := broker[symbol] - book[symbol]
+		if math.Abs(difference) <= positionBreakTolerance {
+			continue
+		}
+		breaks = append(breaks, models.PositionBreak{
+			Symbol:         symbol,
+			BookQuantity:   book[symbol],
+			BrokerQuantity: broker[symbol],
+			Difference:     difference,
+			DetectedAt:     now,
+		})
+	}
+
+	sort.Slice(breaks, func(i, j int) bool { return breaks[i].Symbol < breaks[j].Symbol })
+	return breaks
+}
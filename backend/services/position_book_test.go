@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestFindPositionBreaks(t *testing.T) {
+	book := map[string]float64{"AAPL": 100, "MSFT": -50, "TSLA": 10}
+	broker := map[string]float64{"AAPL": 100, "MSFT": -40, "NVDA": 25}
+
+	breaks := findPositionBreaks(book, broker, time.Now())
+
+	expected := []struct {
+		symbol     string
+		difference float64
+	}{
+		{"MSFT", 10},  // Broker is 10 shares less short
+		{"NVDA", 25},  // Not in the book
+		{"TSLA", -10}, // Not at the broker
+	}
+	if len(breaks) != len(expected) {
+		t.Fatalf("expected %d breaks, got %+v", len(expected), breaks)
+	}
+	for i, e := range expected {
+		if breaks[i].Symbol != e.symbol || !closeTo(breaks[i].Difference, e.difference) {
+			t.Errorf("break %d: expected %s %.0f, got %s %.0f", i, e.symbol, e.difference, breaks[i].Symbol, breaks[i].Difference)
+		}
+	}
+}
+
+func TestFindPositionBreaksIgnoresRounding(t *testing.T) {
+	breaks := findPositionBreaks(map[string]float64{"AAPL": 0.1 + 0.2}, map[string]float64{"AAPL": 0.3}, time.Now())
+	if len(breaks) != 0 {
+		t.Errorf("expected no breaks for floating point noise, got %+v", breaks)
+	}
+}
+
+func TestBookPositionMarks(t *testing.T) {
+	symbolPnL := models.SymbolPnL{Symbol: "AAPL", Position: -10, AvgCost: 50, LastPrice: 48, RealizedPnL: 25}
+
+	marked := bookPosition(symbolPnL, 45)
+	if marked.MarketValue != -450 || marked.UnrealizedPnL != 50 || marked.RealizedPnL != 25 {
+		t.Errorf("expected short marked at 45 to be worth -450 with +50 unrealised, got %+v", marked)
+	}
+
+	unmarked := bookPosition(symbolPnL, 0)
+	if unmarked.MarketPrice != 48 || unmarked.UnrealizedPnL != 20 {
+		t.Errorf("expected fallback to the last fill price, got %+v", unmarked)
+	}
+}
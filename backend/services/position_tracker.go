@@ -1,10 +1,7 @@
 package services
 
 import (
-	"context"
 	"fmt"
-	"strconv"
-	"time"
 )
 
 // PositionTracker tracks current and pending positions
@@ -12,21 +9,25 @@ type PositionTracker struct {
 	db     *DatabaseService
 	redis  *RedisService
 	engine *EngineClient
+	ledger *OpenOrderLedger
+	book   *PositionBook
 }
 
 // NewPositionTracker creates a new position tracker
-func NewPositionTracker(db *DatabaseService, redis *RedisService, engine *EngineClient) *PositionTracker {
+func NewPositionTracker(db *DatabaseService, redis *RedisService, engine *EngineClient, ledger *OpenOrderLedger, book *PositionBook) *PositionTracker {
 	return &PositionTracker{
 		db:     db,
 		redis:  redis,
 		engine: engine,
+		ledger: ledger,
+		book:   book,
 	}
 }
 
 // GetEffectivePosition returns the effective position including pending orders
 // Effective Position = Current Filled Position + Pending Buys - Pending Sells
 func (pt *PositionTracker) GetEffectivePosition(symbol string) (float64, error) {
-	// Get filled position from Alpaca via engine
+	// Get filled position from the position book
 	currentPos, err := pt.getFilledPosition(symbol)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get filled position: %w", err)
@@ -48,8 +49,13 @@ func (pt *PositionTracker) GetEffectivePosition(symbol string) (float64, error)
 	return effectivePosition, nil
 }
 
-// getFilledPosition retrieves the current filled position from Alpaca
+// getFilledPosition retrieves the current filled position from the position
+// book, or from Alpaca when no book is available
 func (pt *PositionTracker) getFilledPosition(symbol string) (float64, error) {
+	if pt.book != nil {
+		return pt.book.RiskQuantity(symbol), nil
+	}
+
 	// Get positions from engine/Alpaca
 	response, err := pt.engine.GetPositions()
 	if err != nil {
@@ -73,133 +79,10 @@ func (pt *PositionTracker) getFilledPosition(symbol string) (float64, error) {
 	return 0, nil
 }
 
-// getPendingOrders retrieves pending orders quantity for a symbol and side from Redis
+// getPendingOrders returns the open quantity for a symbol and side from the order ledger
 func (pt *PositionTracker) getPendingOrders(symbol, side string) (float64, error) {
-	ctx := context.Background()
-	key := fmt.Sprintf("pending:%s:%s", symbol, side)
-
-	// Get all pending orders for this symbol/side
-	values, err := pt.redis.client.HGetAll(ctx, key).Result()
-	if err != nil {
-		return 0, nil // Return 0 if no pending orders
-	}
-
-	var totalQty float64
-	for _, qtyStr := range values {
-		qty, err := strconv.ParseFloat(qtyStr, 64)
-		if err == nil {
-			totalQty += qty
-		}
-	}
-
-	return totalQty, nil
-}
-
-// AddPendingOrder adds a pending order to tracking
-func (pt *PositionTracker) AddPendingOrder(symbol, side string, quantity float64, orderID string) error {
-	ctx := context.Background()
-	key := fmt.Sprintf("pending:%s:%s", symbol, side)
-
-	// Store the pending order quantity
-	err := pt.redis.client.HSet(ctx, key, orderID, fmt.Sprintf("%.8f", quantity)).Err()
-	if err != nil {
-		return fmt.Errorf("failed to add pending order: %w", err)
-	}
-
-	// Set expiration (24 hours)
-	pt.redis.client.Expire(ctx, key, 24*time.Hour)
-
-	return nil
-}
-
-// RemovePendingOrder removes a pending order from tracking
-func (pt *PositionTracker) RemovePendingOrder(symbol, side, orderID string) error {
-	ctx := context.Background()
-	key := fmt.Sprintf("pending:%s:%s", symbol, side)
-
-	err := pt.redis.client.HDel(ctx, key).Err()
-	if err != nil {
-		return fmt.Errorf("failed to remove pending order: %w", err)
-	}
-
-	return nil
-}
-
-// GetAllPendingOrders returns all pending orders across all symbols
-func (pt *PositionTracker) GetAllPendingOrders() (map[string]map[string]float64, error) {
-	ctx := context.Background()
-
-	// Pattern to match all pending order keys
-	pattern := "pending:*"
-	
-	var cursor uint64
-	allPending := make(map[string]map[string]float64)
-
-	for {
-		keys, nextCursor, err := pt.redis.client.Scan(ctx, cursor, pattern, 100).Result()
-		if err != nil {
-			return nil, err
-		}
-
-		for _, key := range keys {
-			// Parse key format: pending:SYMBOL:SIDE
-			values, err := pt.redis.client.HGetAll(ctx, key).Result()
-			if err != nil {
-				continue
-			}
-
-			var totalQty float64
-			for _, qtyStr := range values {
-				qty, err := strconv.ParseFloat(qtyStr, 64)
-				if err == nil {
-					totalQty += qty
-				}
-			}
-
-			if totalQty > 0 {
-				if allPending[key] == nil {
-					allPending[key] = make(map[string]float64)
-				}
-				allPending[key]["quantity"] = totalQty
-			}
-		}
-
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
+	if pt.ledger == nil {
+		return 0, nil
 	}
-
-	return allPending, nil
-}
-
-// CleanupExpiredPending removes expired pending orders (called periodically)
-func (pt *PositionTracker) CleanupExpiredPending() error {
-	ctx := context.Background()
-	pattern := "pending:*"
-	
-	var cursor uint64
-	for {
-		keys, nextCursor, err := pt.redis.client.Scan(ctx, cursor, pattern, 100).Result()
-		if err != nil {
-			return err
-		}
-
-		for _, key := range keys {
-			// Check TTL
-			ttl, err := pt.redis.client.TTL(ctx, key).Result()
-			if err != nil || ttl < 0 {
-				// Key expired or error, delete it
-				pt.redis.client.Del(ctx, key)
-			}
-		}
-
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
-	}
-
-	return nil
+	return pt.ledger.PendingQuantity(symbol, side), nil
 }
-
@@ -3,15 +3,24 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// marketDataChannel carries the symbol of every market data write made
+// through SetMarketData
+const marketDataChannel = "marketdata"
+
 type RedisService struct {
 	client *redis.Client
 	ctx    context.Context
+	hits   atomic.Uint64
+	misses atomic.Uint64
 }
 
 func NewRedisService(url string) *RedisService {
@@ -64,6 +73,7 @@ func (rs *RedisService) GetPosition(symbol string) (map[string]interface{}, erro
 
 	key := "position:" + symbol
 	data, err := rs.client.Get(rs.ctx, key).Bytes()
+	rs.countLookup(err)
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil // Key doesn't exist
@@ -90,7 +100,11 @@ func (rs *RedisService) SetMarketData(symbol string, data interface{}) error {
 	}
 
 	key := "marketdata:" + symbol
-	return rs.client.Set(rs.ctx, key, jsonData, 5*time.Minute).Err()
+	pipe := rs.client.Pipeline()
+	pipe.Set(rs.ctx, key, jsonData, 5*time.Minute)
+	pipe.Publish(rs.ctx, marketDataChannel, symbol)
+	_, err = pipe.Exec(rs.ctx)
+	return err
 }
 
 func (rs *RedisService) GetMarketData(symbol string) (map[string]interface{}, error) {
@@ -100,6 +114,7 @@ func (rs *RedisService) GetMarketData(symbol string) (map[string]interface{}, er
 
 	key := "marketdata:" + symbol
 	data, err := rs.client.Get(rs.ctx, key).Bytes()
+	rs.countLookup(err)
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil // Key doesn't exist
@@ -115,6 +130,52 @@ func (rs *RedisService) GetMarketData(symbol string) (map[string]interface{}, er
 	return marketData, nil
 }
 
+// SubscribeMarketData subscribes to market data writes. SetMarketData
+// publishes each write; writers that only SET marketdata:* keys are seen
+// through keyspace notifications, which the Redis server must be configured
+// to send (notify-keyspace-events containing K and $ or A). The setting is
+// server-wide, so it is checked here but never changed.
+func (rs *RedisService) SubscribeMarketData() (*redis.PubSub, error) {
+	if rs.client == nil {
+		return nil, fmt.Errorf("redis disabled")
+	}
+
+	if config, err := rs.client.ConfigGet(rs.ctx, "notify-keyspace-events").Result(); err == nil {
+		flags := config["notify-keyspace-events"]
+		if !strings.Contains(flags, "K") || !strings.ContainsAny(flags, "$A") {
+			log.Printf("Redis keyspace notifications are off (notify-keyspace-events=%q); only market data written through the backend will tick marks", flags)
+		}
+	}
+
+	pubsub := rs.client.Subscribe(rs.ctx, marketDataChannel)
+	if err := pubsub.PSubscribe(rs.ctx, "__keyspace@*__:marketdata:*"); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	return pubsub, nil
+}
+
+// countLookup counts a cache read as a hit or, when the key is missing, a
+// miss
+func (rs *RedisService) countLookup(err error) {
+	switch err {
+	case nil:
+		rs.hits.Add(1)
+	case redis.Nil:
+		rs.misses.Add(1)
+	}
+}
+
+// HitRate returns the percentage of cache reads that found their key since
+// startup, with the hit and miss counts
+func (rs *RedisService) HitRate() (float64, uint64, uint64) {
+	hits, misses := rs.hits.Load(), rs.misses.Load()
+	if hits+misses == 0 {
+		return 0, 0, 0
+	}
+	return float64(hits) / float64(hits+misses) * 100, hits, misses
+}
+
 // GetClient returns the underlying Redis client for custom operations
 func (rs *RedisService) GetClient() *redis.Client {
 	return rs.client
@@ -149,6 +210,7 @@ func (rs *RedisService) GetOpenOrders() ([]interface{}, error) {
 
 	key := "open_orders"
 	data, err := rs.client.Get(rs.ctx, key).Bytes()
+	rs.countLookup(err)
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil // Key doesn't exist
@@ -180,7 +242,9 @@ func (rs *RedisService) Get(key string) (string, error) {
 		return "", redis.Nil
 	}
 
-	return rs.client.Get(rs.ctx, key).Result()
+	value, err := rs.client.Get(rs.ctx, key).Result()
+	rs.countLookup(err)
+	return value, err
 }
 
 // SetEx sets a key with expiration in Redis cache
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// loadRestrictedList reads all restricted list entries keyed by symbol
+func (rm *RiskManager) loadRestrictedList() map[string][]models.RestrictedSymbol {
+	var entries []models.RestrictedSymbol
+	rm.db.GetDB().Find(&entries)
+
+	restricted := make(map[string][]models.RestrictedSymbol, len(entries))
+	for _, entry := range entries {
+		restricted[entry.Symbol] = append(restricted[entry.Symbol], entry)
+	}
+	return restricted
+}
+
+// GetRestrictedSymbols returns restricted list entries, optionally filtered by list type
+func (rm *RiskManager) GetRestrictedSymbols(listType string, includeExpired bool) []models.RestrictedSymbol {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	now := time.Now()
+	entries := []models.RestrictedSymbol{}
+	for _, symbolEntries := range rm.restricted {
+		for _, entry := range symbolEntries {
+			if listType != "" && entry.ListType != listType {
+				continue
+			}
+			if !includeExpired && !entry.Active(now) {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// SaveRestrictedSymbol adds or updates the entry for a symbol and list type
+func (rm *RiskManager) SaveRestrictedSymbol(req *models.RestrictedSymbolRequest) (*models.RestrictedSymbol, error) {
+	symbol := strings.ToUpper(strings.TrimSpace(req.Symbol))
+
+	var entry models.RestrictedSymbol
+	rm.db.GetDB().Where("symbol = ? AND list_type = ?", symbol, req.ListType).First(&entry)
+
+	return rm.saveRestrictedEntry(&entry, req)
+}
+
+// UpdateRestrictedSymbol updates an existing restricted list entry by ID.
+// Returns nil if the entry does not exist.
+func (rm *RiskManager) UpdateRestrictedSymbol(id uint, req *models.RestrictedSymbolRequest) (*models.RestrictedSymbol, error) {
+	var entry models.RestrictedSymbol
+	if rm.db.GetDB().First(&entry, id).Error != nil {
+		return nil, nil
+	}
+
+	return rm.saveRestrictedEntry(&entry, req)
+}
+
+func (rm *RiskManager) saveRestrictedEntry(entry *models.RestrictedSymbol, req *models.RestrictedSymbolRequest) (*models.RestrictedSymbol, error) {
+	entry.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+	entry.ListType = req.ListType
+	entry.Reason = req.Reason
+	entry.Author = req.Author
+	entry.ExpiresAt = req.ExpiresAt
+	entry.UpdatedAt = time.Now()
+
+	if result := rm.db.GetDB().Save(entry); result.Error != nil {
+		return nil, result.Error
+	}
+
+	rm.refreshRestrictedList()
+	return entry, nil
+}
+
+// DeleteRestrictedSymbol removes a restricted list entry.
+// Returns false if the entry does not exist.
+func (rm *RiskManager) DeleteRestrictedSymbol(id uint) (bool, error) {
+	result := rm.db.GetDB().Delete(&models.RestrictedSymbol{}, id)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	rm.refreshRestrictedList()
+	return result.RowsAffected > 0, nil
+}
+
+func (rm *RiskManager) refreshRestrictedList() {
+	restricted := rm.loadRestrictedList()
+
+	rm.mu.Lock()
+	rm.restricted = restricted
+	rm.mu.Unlock()
+}
+
+// checkRestrictedSymbol applies the restricted, close-only and hard-to-borrow
+// lists to an order. Caller holds rm.mu.
+func (rm *RiskManager) checkRestrictedSymbol(order *models.OrderRequest, currentPosition float64, dryRun bool) error {
+	now := time.Now()
+	for _, entry := range rm.restricted[strings.ToUpper(order.Symbol)] {
+		if !entry.Active(now) {
+			continue
+		}
+
+		var err error
+		switch entry.ListType {
+		case models.RestrictedListRestricted:
+			err = fmt.Errorf("%s is on the restricted list: %s", order.Symbol, entry.Reason)
+
+		case models.RestrictedListCloseOnly:
+			if !isReduceOnly(order.Side, order.Quantity, currentPosition) {
+				err = fmt.Errorf("%s is close-only: only orders that reduce the position are allowed (%s)", order.Symbol, entry.Reason)
+			}
+
+		case models.RestrictedListHardToBorrow:
+			if order.Side == "SELL" && currentPosition-order.Quantity < 0 {
+				err = fmt.Errorf("%s is hard to borrow: new short sales are blocked (%s)", order.Symbol, entry.Reason)
+			}
+		}
+
+		if err == nil {
+			continue
+		}
+
+		if !dryRun {
+			rm.SendAlert("RESTRICTED_SYMBOL", "WARNING", order.Symbol, err.Error(),
+				map[string]interface{}{
+					"entry_id":        entry.ID,
+					"list_type":       entry.ListType,
+					"reason":          entry.Reason,
+					"author":          entry.Author,
+					"client_order_id": order.ClientOrderID,
+					"side":            order.Side,
+					"quantity":        order.Quantity,
+					"position":        currentPosition,
+				})
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestRestrictedListRules(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	rm := &RiskManager{restricted: map[string][]models.RestrictedSymbol{
+		"AAA": {{Symbol: "AAA", ListType: models.RestrictedListRestricted, Reason: "MNPI"}},
+		"BBB": {{Symbol: "BBB", ListType: models.RestrictedListCloseOnly, Reason: "Delisting"}},
+		"CCC": {{Symbol: "CCC", ListType: models.RestrictedListHardToBorrow, Reason: "No locate"}},
+		"DDD": {{Symbol: "DDD", ListType: models.RestrictedListRestricted, Reason: "Expired", ExpiresAt: &past}},
+	}}
+
+	cases := []struct {
+		name     string
+		order    models.OrderRequest
+		position float64
+		allowed  bool
+	}{
+		{"restricted blocks buy", models.OrderRequest{Symbol: "AAA", Side: "BUY", Quantity: 1}, 0, false},
+		{"restricted blocks closing sell", models.OrderRequest{Symbol: "AAA", Side: "SELL", Quantity: 1}, 10, false},
+		{"close-only allows reducing sell", models.OrderRequest{Symbol: "BBB", Side: "SELL", Quantity: 5}, 10, true},
+		{"close-only blocks adding buy", models.OrderRequest{Symbol: "BBB", Side: "BUY", Quantity: 5}, 10, false},
+		{"htb allows selling long", models.OrderRequest{Symbol: "CCC", Side: "SELL", Quantity: 10}, 10, true},
+		{"htb blocks new short", models.OrderRequest{Symbol: "CCC", Side: "SELL", Quantity: 11}, 10, false},
+		{"htb allows buy to cover", models.OrderRequest{Symbol: "CCC", Side: "BUY", Quantity: 5}, -10, true},
+		{"expired entry ignored", models.OrderRequest{Symbol: "DDD", Side: "BUY", Quantity: 1}, 0, true},
+		{"lower-case symbol matched", models.OrderRequest{Symbol: "aaa", Side: "BUY", Quantity: 1}, 0, false},
+	}
+
+	for _, tc := range cases {
+		err := rm.checkRestrictedSymbol(&tc.order, tc.position, true)
+		if (err == nil) != tc.allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", tc.name, tc.allowed, err)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// historyCacheTTL is how long fetched price history is reused
+const historyCacheTTL = 1 * time.Hour
+
+// defaultStressScenarios are evaluated when no scenarios are stored
+var defaultStressScenarios = []models.StressScenario{
+	{
+		Name:            "Book -10%",
+		Description:     "Every position falls 10%",
+		ScenarioType:    "SHOCK",
+		DefaultShockPct: -10,
+	},
+	{
+		Name:         "2020-03-16 replay",
+		Description:  "Replay each symbol's move on 16 March 2020",
+		ScenarioType: "HISTORICAL",
+		ReplayDate:   "2020-03-16",
+	},
+}
+
+// RiskAnalytics computes forward-looking risk measures for the live portfolio
+type RiskAnalytics struct {
+	riskManager *RiskManager
+	engine      *EngineClient
+	cheetr      *CheetrClient
+	db          *DatabaseService
+	actions     *CorporateActions
+	history     map[string]*historyEntry
+	mu          sync.Mutex
+	ticker      *time.Ticker
+	stopChan    chan bool
+}
+
+type historyEntry struct {
+	bars      []PriceBar
+	days      int
+	fetchedAt time.Time
+}
+
+// NewRiskAnalytics creates a new risk analytics service
+func NewRiskAnalytics(riskManager *RiskManager, engine *EngineClient, cheetr *CheetrClient, db *DatabaseService) *RiskAnalytics {
+	return &RiskAnalytics{
+		riskManager: riskManager,
+		engine:      engine,
+		cheetr:      cheetr,
+		db:          db,
+		history:     make(map[string]*historyEntry),
+		stopChan:    make(chan bool),
+	}
+}
+
+// UseCorporateActions back-adjusts price history for splits and dividends
+func (ra *RiskAnalytics) UseCorporateActions(actions *CorporateActions) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	ra.actions = actions
+}
+
+// Start periodically recomputes portfolio VaR for the VaR limit
+func (ra *RiskAnalytics) Start() {
+	ra.ticker = time.NewTicker(5 * time.Minute)
+
+	go func() {
+		log.Println("Risk Analytics started (VaR every 5 minutes)")
+		ra.updateVaRLimit()
+
+		for {
+			select {
+			case <-ra.ticker.C:
+				ra.updateVaRLimit()
+
+			case <-ra.stopChan:
+				log.Println("Risk Analytics stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the risk analytics service
+func (ra *RiskAnalytics) Stop() {
+	if ra.ticker != nil {
+		ra.ticker.Stop()
+	}
+	ra.stopChan <- true
+}
+
+// updateVaRLimit publishes the latest historical VaR to the risk manager
+func (ra *RiskAnalytics) updateVaRLimit() {
+	limits := ra.riskManager.GetLimits()
+	if limits.MaxVaR <= 0 {
+		return
+	}
+
+	confidence := limits.VaRConfidence / 100
+	report, err := ra.ComputeVaR([]float64{confidence}, 250, 1)
+	if err != nil {
+		log.Printf("Error computing portfolio VaR: %v", err)
+		return
+	}
+
+	for _, result := range report.Results {
+		if result.Method == "HISTORICAL" {
+			ra.riskManager.SetPortfolioVaR(result.VaR)
+			if result.VaR > limits.MaxVaR {
+				ra.riskManager.SendAlert("VAR_LIMIT", "WARNING", "",
+					fmt.Sprintf("Portfolio VaR $%.2f exceeds limit $%.2f", result.VaR, limits.MaxVaR),
+					map[string]interface{}{
+						"var":        result.VaR,
+						"limit":      limits.MaxVaR,
+						"confidence": result.Confidence,
+					})
+			}
+		}
+	}
+}
+
+// ComputeVaR computes historical-simulation and parametric VaR and expected
+// shortfall of the current positions at each confidence level
+func (ra *RiskAnalytics) ComputeVaR(confidences []float64, lookbackDays int, horizonDays int) (*models.VaRReport, error) {
+	snapshot, err := LoadPortfolioSnapshot(ra.engine)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.VaRReport{
+		PortfolioValue: snapshot.Equity,
+		GrossExposure:  snapshot.GrossExposure(),
+		LookbackDays:   lookbackDays,
+		HorizonDays:    horizonDays,
+		Results:        []models.VaRResult{},
+		MissingSymbols: []string{},
+		Timestamp:      time.Now(),
+	}
+
+	// Daily returns per symbol keyed by date
+	returns := make(map[string]map[string]float64)
+	for symbol := range snapshot.Positions {
+		bars, err := ra.getHistory(symbol, lookbackDays+1)
+		if err != nil || len(bars) < 2 {
+			report.MissingSymbols = append(report.MissingSymbols, symbol)
+			continue
+		}
+		returns[symbol] = dailyReturns(bars)
+	}
+
+	pnl := portfolioPnLSeries(snapshot, returns)
+	report.Observations = len(pnl)
+	if len(pnl) < 2 {
+		return report, nil
+	}
+
+	scale := math.Sqrt(float64(horizonDays))
+	for _, confidence := range confidences {
+		histVaR, histES := historicalVaR(pnl, confidence)
+		paramVaR, paramES := parametricVaR(pnl, confidence)
+
+		report.Results = append(report.Results,
+			models.VaRResult{Method: "HISTORICAL", Confidence: confidence, VaR: histVaR * scale, ExpectedShortfall: histES * scale},
+			models.VaRResult{Method: "PARAMETRIC", Confidence: confidence, VaR: paramVaR * scale, ExpectedShortfall: paramES * scale},
+		)
+	}
+
+	return report, nil
+}
+
+// RunStress applies a stress scenario to the current positions
+func (ra *RiskAnalytics) RunStress(scenario *models.StressScenario) (*models.StressResult, error) {
+	snapshot, err := LoadPortfolioSnapshot(ra.engine)
+	if err != nil {
+		return nil, err
+	}
+
+	shocks := make(map[string]float64)
+	if scenario.Shocks != "" {
+		if err := json.Unmarshal([]byte(scenario.Shocks), &shocks); err != nil {
+			return nil, fmt.Errorf("invalid shocks for scenario %s: %w", scenario.Name, err)
+		}
+	}
+
+	result := &models.StressResult{
+		Scenario:  scenario.Name,
+		Positions: []models.StressPositionResult{},
+		Timestamp: time.Now(),
+	}
+
+	for symbol, position := range snapshot.Positions {
+		shockPct, ok := shocks[symbol]
+		if !ok {
+			shockPct = scenario.DefaultShockPct
+			if scenario.ScenarioType == "HISTORICAL" {
+				shockPct = ra.historicalMove(symbol, scenario.ReplayDate)
+			}
+		}
+
+		pnl := position.MarketValue * shockPct / 100
+		result.PnL += pnl
+		result.Positions = append(result.Positions, models.StressPositionResult{
+			Symbol:      symbol,
+			Quantity:    position.Quantity,
+			MarketValue: position.MarketValue,
+			ShockPct:    shockPct,
+			PnL:         pnl,
+		})
+	}
+
+	if snapshot.Equity > 0 {
+		result.PnLPct = result.PnL / snapshot.Equity * 100
+	}
+
+	sort.Slice(result.Positions, func(i, j int) bool {
+		return result.Positions[i].PnL < result.Positions[j].PnL
+	})
+
+	return result, nil
+}
+
+// GetScenarios returns stored stress scenarios, or the built-in defaults
+func (ra *RiskAnalytics) GetScenarios() []models.StressScenario {
+	var scenarios []models.StressScenario
+	if ra.db != nil && ra.db.GetDB() != nil {
+		ra.db.GetDB().Order("name").Find(&scenarios)
+	}
+
+	if len(scenarios) == 0 {
+		return defaultStressScenarios
+	}
+	return scenarios
+}
+
+// historicalMove returns a symbol's close-to-close move in percent on date
+func (ra *RiskAnalytics) historicalMove(symbol, date string) float64 {
+	replayDate, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0
+	}
+
+	days := int(time.Since(replayDate).Hours()/24) + 10
+	bars, err := ra.getHistory(symbol, days)
+	if err != nil {
+		return 0
+	}
+
+	return dailyReturns(bars)[date] * 100
+}
+
+// getHistory returns daily bars for a symbol, back-adjusted for corporate
+// actions and cached for historyCacheTTL
+func (ra *RiskAnalytics) getHistory(symbol string, days int) ([]PriceBar, error) {
+	ra.mu.Lock()
+	entry, ok := ra.history[symbol]
+	ra.mu.Unlock()
+
+	if ok && entry.days >= days && time.Since(entry.fetchedAt) < historyCacheTTL {
+		return entry.bars, nil
+	}
+
+	if ra.cheetr == nil {
+		return nil, fmt.Errorf("price history not available")
+	}
+
+	history, err := ra.cheetr.GetHistory(symbol, days)
+	if err != nil {
+		return nil, err
+	}
+
+	ra.mu.Lock()
+	actions := ra.actions
+	ra.mu.Unlock()
+
+	bars := history.Bars
+	if actions != nil {
+		bars = actions.AdjustBars(symbol, bars)
+	}
+
+	ra.mu.Lock()
+	ra.history[symbol] = &historyEntry{bars: bars, days: days, fetchedAt: time.Now()}
+	ra.mu.Unlock()
+
+	return bars, nil
+}
+
+// dailyReturns converts bars to simple close-to-close returns keyed by date
+func dailyReturns(bars []PriceBar) map[string]float64 {
+	sorted := make([]PriceBar, len(bars))
+	copy(sorted, bars)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp < sorted[j].Timestamp
+	})
+
+	returns := make(map[string]float64, len(sorted))
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i-1].Close <= 0 {
+			continue
+		}
+		returns[barDate(sorted[i].Timestamp)] = sorted[i].Close/sorted[i-1].Close - 1
+	}
+
+	return returns
+}
+
+// barDate returns the YYYY-MM-DD part of a bar timestamp
+func barDate(timestamp string) string {
+	if len(timestamp) >= 10 {
+		return timestamp[:10]
+	}
+	return strings.TrimSpace(timestamp)
+}
+
+// portfolioPnLSeries revalues today's positions with each historical day's returns
+func portfolioPnLSeries(snapshot *PortfolioSnapshot, returns map[string]map[string]float64) []float64 {
+	dates := make(map[string]bool)
+	for _, symbolReturns := range returns {
+		for date := range symbolReturns {
+			dates[date] = true
+		}
+	}
+
+	pnl := make([]float64, 0, len(dates))
+	for date := range dates {
+		var dayPnL float64
+		for symbol, symbolReturns := range returns {
+			dayPnL += snapshot.Positions[symbol].MarketValue * symbolReturns[date]
+		}
+		pnl = append(pnl, dayPnL)
+	}
+
+	return pnl
+}
+
+// historicalVaR returns VaR and expected shortfall (as positive losses) from
+// the empirical P&L distribution
+func historicalVaR(pnl []float64, confidence float64) (float64, float64) {
+	sorted := make([]float64, len(pnl))
+	copy(sorted, pnl)
+	sort.Float64s(sorted)
+
+	index := int(math.Floor((1 - confidence) * float64(len(sorted))))
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+
+	var tail float64
+	for i := 0; i <= index; i++ {
+		tail += sorted[i]
+	}
+
+	return math.Max(0, -sorted[index]), math.Max(0, -tail/float64(index+1))
+}
+
+// parametricVaR returns normal-distribution VaR and expected shortfall
+// (as positive losses) from the mean and standard deviation of P&L
+func parametricVaR(pnl []float64, confidence float64) (float64, float64) {
+	var mean float64
+	for _, v := range pnl {
+		mean += v
+	}
+	mean /= float64(len(pnl))
+
+	var variance float64
+	for _, v := range pnl {
+		variance += (v - mean) * (v - mean)
+	}
+	stdDev := math.Sqrt(variance / float64(len(pnl)-1))
+
+	// z is the (1 - confidence) quantile of the standard normal
+	z := math.Sqrt2 * math.Erfinv(2*(1-confidence)-1)
+	density := math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+
+	varValue := -(mean + z*stdDev)
+	es := -(mean - stdDev*density/(1-confidence))
+
+	return math.Max(0, varValue), math.Max(0, es)
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHistoricalVaR(t *testing.T) {
+	// 100 observations: -100, -99, ..., -1
+	pnl := make([]float64, 100)
+	for i := range pnl {
+		pnl[i] = float64(-100 + i)
+	}
+
+	varValue, es := historicalVaR(pnl, 0.95)
+	if varValue != 95 {
+		t.Errorf("Expected 95%% VaR of 95, got %.2f", varValue)
+	}
+	// Mean of the six worst losses: 100..95
+	if math.Abs(es-97.5) > 1e-9 {
+		t.Errorf("Expected expected shortfall of 97.5, got %.2f", es)
+	}
+}
+
+func TestParametricVaR(t *testing.T) {
+	// Symmetric series with mean 0 and sample standard deviation 1
+	pnl := []float64{-1, 1, -1, 1, -1, 1, -1, 1}
+	stdDev := math.Sqrt(8.0 / 7.0)
+
+	varValue, es := parametricVaR(pnl, 0.99)
+	if math.Abs(varValue-2.3263*stdDev) > 1e-3 {
+		t.Errorf("Expected 99%% VaR of %.4f, got %.4f", 2.3263*stdDev, varValue)
+	}
+	if es <= varValue {
+		t.Errorf("Expected expected shortfall %.4f to exceed VaR %.4f", es, varValue)
+	}
+}
+
+func TestDailyReturns(t *testing.T) {
+	bars := []PriceBar{
+		{Timestamp: "2020-03-16T00:00:00Z", Close: 88},
+		{Timestamp: "2020-03-13T00:00:00Z", Close: 100},
+	}
+
+	returns := dailyReturns(bars)
+	if math.Abs(returns["2020-03-16"]+0.12) > 1e-9 {
+		t.Errorf("Expected -12%% return on 2020-03-16, got %.4f", returns["2020-03-16"])
+	}
+}
@@ -3,8 +3,10 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,17 +20,41 @@ type RiskManager struct {
 	limits      *models.RiskLimits
 	wsHub       *WebSocketHub
 	orderCache  *OrderThrottleCache
+	throttles   map[string]models.ThrottleLimit
+	restricted  map[string][]models.RestrictedSymbol
+	hierarchy   *limitHierarchy
+	ledger      *OpenOrderLedger
+	calendar    *MarketCalendar
 	mu          sync.RWMutex
 	initialized bool
+
+	// Inputs for the automatic circuit breaker triggers
+	orderOutcomes *eventWindow
+	engineErrors  *eventWindow
+	symbolPnL     map[string]float64
+	breakerMu     sync.Mutex
+
+	// Latest portfolio VaR published by the risk analytics service
+	portfolioVaR float64
+	varMu        sync.RWMutex
 }
 
 // NewRiskManager creates a new risk manager
-func NewRiskManager(db *DatabaseService, redis *RedisService, wsHub *WebSocketHub) *RiskManager {
+func NewRiskManager(db *DatabaseService, redis *RedisService, wsHub *WebSocketHub, ledger *OpenOrderLedger, calendar *MarketCalendar) *RiskManager {
 	rm := &RiskManager{
 		db:         db,
 		redis:      redis,
 		wsHub:      wsHub,
 		orderCache: NewOrderThrottleCache(redis),
+		throttles:  make(map[string]models.ThrottleLimit),
+		restricted: make(map[string][]models.RestrictedSymbol),
+		hierarchy:  newLimitHierarchy(nil),
+		ledger:     ledger,
+		calendar:   calendar,
+
+		orderOutcomes: newEventWindow(breakerWindow),
+		engineErrors:  newEventWindow(breakerWindow),
+		symbolPnL:     make(map[string]float64),
 	}
 
 	// Load initial limits
@@ -41,6 +67,19 @@ func NewRiskManager(db *DatabaseService, redis *RedisService, wsHub *WebSocketHu
 			MaxPortfolioConcentration: 25.00,
 			MaxLeverage:               2.00,
 			MaxOrdersPerSecond:        10,
+			BreakerCooldownSeconds:    300,
+			RejectionRateThreshold:    50.00,
+			EngineErrorBurst:          5,
+			SymbolLossLimit:           1000.00,
+			VaRConfidence:             95.00,
+			SelfTradeAction:           models.SelfTradeActionRejectNew,
+			WashTradeWindowSeconds:    60,
+			WashTradePriceTolerance:   0.10,
+			PDTMaxDayTrades:           3,
+			PDTEquityThreshold:        25000.00,
+			EnforceTradingSession:     true,
+			UtilisationWarningPct:     80.00,
+			UtilisationCriticalPct:    95.00,
 			Enabled:                   true,
 		}
 	}
@@ -49,8 +88,74 @@ func NewRiskManager(db *DatabaseService, redis *RedisService, wsHub *WebSocketHu
 	return rm
 }
 
-// ValidateOrder performs all risk checks on an order
-func (rm *RiskManager) ValidateOrder(order *models.OrderRequest, effectivePosition float64) *models.RiskCheckResult {
+// riskRule is a single pre-trade check in the ValidateOrder chain
+type riskRule struct {
+	name  string
+	alert string
+	check func() error
+}
+
+// orderRules returns the pre-trade checks in evaluation order. With a basket
+// the checks are a dry run with no side effects: throttle buckets are only
+// peeked, breaker state transitions are not persisted, and what the earlier
+// orders of the basket use up is added to the live state.
+func (rm *RiskManager) orderRules(order *models.OrderRequest, effectivePosition float64, identity models.ThrottleIdentity, basket *basketUsage) []riskRule {
+	dryRun := basket != nil
+
+	// Orders that only reduce the position may still flatten after the loss limit
+	reduceOnly := isReduceOnly(order.Side, order.Quantity, effectivePosition)
+
+	return []riskRule{
+		{"trading_session", "Market session closed", func() error {
+			return rm.CheckTradingSession(order)
+		}},
+		{"circuit_breaker", "Circuit breaker active", func() error {
+			return rm.checkCircuitBreakerForOrder(order, effectivePosition, dryRun)
+		}},
+		{"restricted_symbol", "Restricted symbol", func() error {
+			return rm.checkRestrictedSymbol(order, effectivePosition, dryRun)
+		}},
+		{"daily_loss_limit", "Daily loss limit reached", func() error {
+			if reduceOnly {
+				return nil
+			}
+			return rm.checkDailyLossLimit(dryRun)
+		}},
+		{"order_size", "Order size exceeds limit", func() error {
+			return rm.CheckOrderSize(order.Quantity, order.Price)
+		}},
+		{"position_limit", "Position limit exceeded", func() error {
+			return rm.CheckPositionLimit(order.Symbol, order.Side, order.Quantity, effectivePosition)
+		}},
+		{"limit_hierarchy", "Hierarchy limit exceeded", func() error {
+			return rm.checkHierarchyLimits(order, identity, reduceOnly, basket)
+		}},
+		{"buying_power", "Insufficient buying power", func() error {
+			return rm.checkBuyingPower(order, basket)
+		}},
+		{"pattern_day_trader", "Pattern day trader limit reached", func() error {
+			return rm.CheckPatternDayTrader(order)
+		}},
+		{"trades_per_day", "Daily trade limit reached", func() error {
+			return rm.checkTradesPerDay(basket)
+		}},
+		{"daily_turnover", "Daily turnover limit reached", func() error {
+			return rm.checkDailyTurnover(order, basket)
+		}},
+		{"var_limit", "VaR limit exceeded", func() error {
+			if reduceOnly {
+				return nil
+			}
+			return rm.CheckVaRLimit()
+		}},
+		{"order_throttle", "Order rate limit exceeded", func() error {
+			return rm.checkOrderThrottle(models.ThrottleActionNew, identity, basket)
+		}},
+	}
+}
+
+// ValidateOrder performs all risk checks on an order, stopping at the first failure
+func (rm *RiskManager) ValidateOrder(order *models.OrderRequest, effectivePosition float64, identity models.ThrottleIdentity) *models.RiskCheckResult {
 	rm.mu.RLock()
 	defer rm.mu.RUnlock()
 
@@ -64,54 +169,74 @@ func (rm *RiskManager) ValidateOrder(order *models.OrderRequest, effectivePositi
 		return result
 	}
 
-	// Check circuit breaker first
-	if err := rm.CheckCircuitBreaker(); err != nil {
-		result.Allowed = false
-		result.RejectionReason = err.Error()
-		result.Alerts = append(result.Alerts, "Circuit breaker active")
-		return result
+	for _, rule := range rm.orderRules(order, effectivePosition, identity, nil) {
+		if err := rule.check(); err != nil {
+			result.Allowed = false
+			result.RejectionReason = err.Error()
+			result.Alerts = append(result.Alerts, rule.alert)
+			return result
+		}
 	}
 
-	// Check daily loss limit
-	if err := rm.CheckDailyLossLimit(); err != nil {
-		result.Allowed = false
-		result.RejectionReason = err.Error()
-		result.Alerts = append(result.Alerts, "Daily loss limit reached")
-		return result
+	// Reserve once every rule has passed, so no later rule rejects an order
+	// holding buying power. The ledger checks buying power again under its
+	// lock, so two buys can't both pass against the same available amount.
+	if rm.ledger != nil && order.ClientOrderID != "" {
+		if _, err := rm.ledger.ReserveWithinBuyingPower(order, order.ClientOrderID); err != nil {
+			alert := "Insufficient buying power"
+			if errors.Is(err, ErrDuplicateClientOrderID) {
+				alert = "Duplicate client order ID"
+			}
+			result.Allowed = false
+			result.RejectionReason = err.Error()
+			result.Alerts = append(result.Alerts, alert)
+		}
 	}
 
-	// Check order size
-	if err := rm.CheckOrderSize(order.Quantity, order.Price); err != nil {
-		result.Allowed = false
-		result.RejectionReason = err.Error()
-		result.Alerts = append(result.Alerts, "Order size exceeds limit")
-		return result
-	}
+	return result
+}
 
-	// Check position limit
-	newPosition := effectivePosition
-	if order.Side == "BUY" {
-		newPosition += order.Quantity
-	} else {
-		newPosition -= order.Quantity
+// ReleaseOrder releases the reservation of an order rejected after it
+// passed the risk checks
+func (rm *RiskManager) ReleaseOrder(clientOrderID, reason string) {
+	if rm.ledger != nil {
+		rm.ledger.Release(clientOrderID, reason)
 	}
+}
 
-	if err := rm.CheckPositionLimit(order.Symbol, order.Side, order.Quantity, effectivePosition); err != nil {
-		result.Allowed = false
-		result.RejectionReason = err.Error()
-		result.Alerts = append(result.Alerts, "Position limit exceeded")
-		return result
+// EvaluateOrderRules runs every rule of the ValidateOrder chain without side
+// effects and returns the result of each one
+func (rm *RiskManager) EvaluateOrderRules(order *models.OrderRequest, effectivePosition float64, identity models.ThrottleIdentity) []models.RiskRuleResult {
+	return rm.evaluateOrderRules(order, effectivePosition, identity, newBasketUsage())
+}
+
+// evaluateOrderRules runs every rule against the live state plus the basket
+// and, if the order passes, adds it to the basket
+func (rm *RiskManager) evaluateOrderRules(order *models.OrderRequest, effectivePosition float64, identity models.ThrottleIdentity, basket *basketUsage) []models.RiskRuleResult {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	rules := rm.orderRules(order, effectivePosition, identity, basket)
+	results := make([]models.RiskRuleResult, 0, len(rules))
+
+	allowed := true
+	for _, rule := range rules {
+		ruleResult := models.RiskRuleResult{Rule: rule.name, Passed: true}
+		if !rm.limits.Enabled {
+			ruleResult.Message = "risk management disabled"
+		} else if err := rule.check(); err != nil {
+			ruleResult.Passed = false
+			ruleResult.Message = err.Error()
+			allowed = false
+		}
+		results = append(results, ruleResult)
 	}
 
-	// Check order throttle
-	if err := rm.CheckOrderThrottle("default"); err != nil {
-		result.Allowed = false
-		result.RejectionReason = err.Error()
-		result.Alerts = append(result.Alerts, "Order rate limit exceeded")
-		return result
+	if allowed {
+		rm.addToBasket(basket, order, identity)
 	}
 
-	return result
+	return results
 }
 
 // CheckPositionLimit validates position size against limits
@@ -159,15 +284,15 @@ func (rm *RiskManager) CheckOrderSize(quantity float64, price float64) error {
 
 // CheckDailyLossLimit checks if daily loss limit has been reached
 func (rm *RiskManager) CheckDailyLossLimit() error {
-	pnl, err := rm.GetDailyPnL()
+	return rm.checkDailyLossLimit(false)
+}
+
+func (rm *RiskManager) checkDailyLossLimit(dryRun bool) error {
+	pnl, err := rm.loadDailyPnL(!dryRun)
 	if err != nil {
 		return nil // Allow if can't check
 	}
 
-	if pnl.CircuitBreakerTriggered {
-		return fmt.Errorf("daily loss limit triggered: circuit breaker active")
-	}
-
 	rm.mu.RLock()
 	limit := rm.limits.DailyLossLimit
 	rm.mu.RUnlock()
@@ -207,49 +332,283 @@ func (rm *RiskManager) CheckConcentrationLimit(symbol string, orderValue float64
 	return nil
 }
 
-// CheckOrderThrottle validates order rate limit
-func (rm *RiskManager) CheckOrderThrottle(clientID string) error {
+// CheckVaRLimit blocks risk-increasing orders while portfolio VaR exceeds the limit
+func (rm *RiskManager) CheckVaRLimit() error {
 	rm.mu.RLock()
-	maxRate := rm.limits.MaxOrdersPerSecond
+	limit := rm.limits.MaxVaR
 	rm.mu.RUnlock()
 
-	return rm.orderCache.CheckRate(clientID, maxRate)
+	if limit <= 0 {
+		return nil // Not enforced
+	}
+
+	rm.varMu.RLock()
+	current := rm.portfolioVaR
+	rm.varMu.RUnlock()
+
+	if current > limit {
+		return fmt.Errorf("portfolio VaR exceeds limit: $%.2f > $%.2f", current, limit)
+	}
+
+	return nil
 }
 
-// CheckCircuitBreaker checks if circuit breaker is active
+// CheckTradingSession only allows orders in the regular session, or LIMIT
+// orders flagged extended_hours in the pre/post-market sessions
+func (rm *RiskManager) CheckTradingSession(order *models.OrderRequest) error {
+	if rm.calendar == nil || !rm.limits.EnforceTradingSession {
+		return nil
+	}
+
+	session := rm.calendar.Session(time.Now())
+	switch session.Session {
+	case models.SessionRegular:
+		return nil
+	case models.SessionPreMarket, models.SessionPostMarket:
+		if !order.ExtendedHours {
+			return fmt.Errorf("market is in %s session: set extended_hours to trade outside regular hours", session.Session)
+		}
+		if !strings.EqualFold(order.OrderType, "LIMIT") {
+			return fmt.Errorf("extended-hours orders must be LIMIT orders")
+		}
+		return nil
+	default:
+		reason := "market closed"
+		if session.Holiday != "" {
+			reason = fmt.Sprintf("market closed for %s", session.Holiday)
+		}
+		return fmt.Errorf("%s (next open %s)", reason, session.NextOpen.Format(time.RFC3339))
+	}
+}
+
+// CheckBuyingPower validates a buy order's notional against account buying
+// power minus the notional reserved by open buy orders
+func (rm *RiskManager) CheckBuyingPower(order *models.OrderRequest) error {
+	return rm.checkBuyingPower(order, nil)
+}
+
+func (rm *RiskManager) checkBuyingPower(order *models.OrderRequest, basket *basketUsage) error {
+	if rm.ledger == nil || order.Side != "BUY" {
+		return nil
+	}
+
+	available, ok := rm.ledger.AvailableBuyingPower(order.Account)
+	if !ok {
+		return nil // Allow if buying power is not known yet
+	}
+	if basket != nil {
+		available -= basket.buyNotional[order.Account]
+	}
+
+	notional := rm.ledger.EstimateNotional(order)
+	if notional > available {
+		return fmt.Errorf("insufficient buying power: order $%.2f > available $%.2f (after open order reservations)", notional, available)
+	}
+
+	return nil
+}
+
+// orderNotional estimates an order's notional, using the market price for
+// orders without a limit price
+func (rm *RiskManager) orderNotional(order *models.OrderRequest) float64 {
+	if rm.ledger != nil {
+		return rm.ledger.EstimateNotional(order)
+	}
+	return order.Quantity * order.Price
+}
+
+// SetPortfolioVaR records the latest portfolio VaR used by the VaR limit
+func (rm *RiskManager) SetPortfolioVaR(value float64) {
+	rm.varMu.Lock()
+	rm.portfolioVaR = value
+	rm.varMu.Unlock()
+}
+
+// CheckOrderThrottle takes a token from every bucket the identity is subject to
+// (user, API key, strategy and symbol) for the given action
+func (rm *RiskManager) CheckOrderThrottle(action string, identity models.ThrottleIdentity) error {
+	return rm.checkOrderThrottle(action, identity, nil)
+}
+
+// throttleScope is one bucket an identity is throttled against
+type throttleScope struct {
+	scope string
+	key   string
+}
+
+// throttleScopes returns the buckets an identity is subject to
+func throttleScopes(identity models.ThrottleIdentity) []throttleScope {
+	scopes := []throttleScope{}
+	for _, s := range []throttleScope{
+		{models.ThrottleScopeUser, identity.UserID},
+		{models.ThrottleScopeAPIKey, identity.APIKey},
+		{models.ThrottleScopeStrategy, identity.Strategy},
+		{models.ThrottleScopeSymbol, identity.Symbol},
+	} {
+		if s.key != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+func throttleBucketKey(action string, s throttleScope) string {
+	return fmt.Sprintf("%s:%s:%s", action, s.scope, s.key)
+}
+
+// checkOrderThrottle takes a token from every bucket, or from none if any
+// bucket is empty. With a basket it only checks that each bucket has a token
+// left after those the basket has taken.
+func (rm *RiskManager) checkOrderThrottle(action string, identity models.ThrottleIdentity, basket *basketUsage) error {
+	scopes := []throttleScope{}
+	limits := []models.ThrottleLimit{}
+	buckets := []ThrottleBucket{}
+	for _, s := range throttleScopes(identity) {
+		limit := rm.GetThrottleLimit(s.scope, s.key, action)
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			continue // Allow if can't check
+		}
+		scopes = append(scopes, s)
+		limits = append(limits, limit)
+		buckets = append(buckets, ThrottleBucket{Key: throttleBucketKey(action, s), Rate: limit.Rate, Burst: limit.Burst})
+	}
+
+	check := rm.orderCache.TakeAll
+	if basket != nil {
+		check = rm.orderCache.PeekAll
+	}
+
+	decisions, err := check(buckets)
+	if err != nil {
+		return nil // Allow if can't check
+	}
+
+	for i, decision := range decisions {
+		allowed := decision.Allowed
+		if basket != nil {
+			allowed = decision.Remaining-float64(basket.tokens[buckets[i].Key]) >= 1
+		}
+		if !allowed {
+			return fmt.Errorf("rate limit exceeded for %s %s: %s limit %.2f/sec (burst %d)",
+				strings.ToLower(scopes[i].scope), scopes[i].key, action, limits[i].Rate, limits[i].Burst)
+		}
+	}
+
+	return nil
+}
+
+// GetThrottleLimit resolves the throttle for a scope key and action:
+// exact key first, then the scope default "*", then a limit derived from
+// MaxOrdersPerSecond (cancels and amends get twice the new-order rate)
+func (rm *RiskManager) GetThrottleLimit(scope, key, action string) models.ThrottleLimit {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	if limit, ok := rm.throttles[throttleKey(scope, key, action)]; ok {
+		return limit
+	}
+	if limit, ok := rm.throttles[throttleKey(scope, "*", action)]; ok {
+		return limit
+	}
+
+	rate := rm.limits.MaxOrdersPerSecond
+	if action != models.ThrottleActionNew {
+		rate *= 2
+	}
+	if rate <= 0 {
+		rate = 1
+	}
+
+	return models.ThrottleLimit{
+		Scope:  scope,
+		Key:    key,
+		Action: action,
+		Rate:   float64(rate),
+		Burst:  rate,
+	}
+}
+
+// GetThrottleLimits returns all configured throttle limits
+func (rm *RiskManager) GetThrottleLimits() []models.ThrottleLimit {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	limits := make([]models.ThrottleLimit, 0, len(rm.throttles))
+	for _, limit := range rm.throttles {
+		limits = append(limits, limit)
+	}
+	return limits
+}
+
+// UpdateThrottleLimit creates or updates a throttle limit
+func (rm *RiskManager) UpdateThrottleLimit(update *models.ThrottleLimitUpdate) (*models.ThrottleLimit, error) {
+	var limit models.ThrottleLimit
+	rm.db.GetDB().Where("scope = ? AND key = ? AND action = ?", update.Scope, update.Key, update.Action).First(&limit)
+
+	limit.Scope = update.Scope
+	limit.Key = update.Key
+	limit.Action = update.Action
+	limit.Rate = update.Rate
+	limit.Burst = update.Burst
+	limit.UpdatedAt = time.Now()
+
+	if result := rm.db.GetDB().Save(&limit); result.Error != nil {
+		return nil, result.Error
+	}
+
+	rm.mu.Lock()
+	rm.throttles[throttleKey(limit.Scope, limit.Key, limit.Action)] = limit
+	rm.mu.Unlock()
+
+	// Start the key with a fresh bucket at the new burst
+	rm.orderCache.ResetRate(fmt.Sprintf("%s:%s:%s", limit.Action, limit.Scope, limit.Key))
+
+	return &limit, nil
+}
+
+func throttleKey(scope, key, action string) string {
+	return scope + "|" + key + "|" + action
+}
+
+// CheckCircuitBreaker checks if a global circuit breaker is open
 func (rm *RiskManager) CheckCircuitBreaker() error {
-	var activeBreakers []models.CircuitBreakerEvent
-	result := rm.db.GetDB().Where("active = ?", true).Find(&activeBreakers)
-	
-	if result.Error != nil {
+	activeBreakers, err := rm.GetActiveCircuitBreakers()
+	if err != nil {
 		return nil // Allow if can't check
 	}
 
-	for _, breaker := range activeBreakers {
-		// Check if breaker has expired
-		if breaker.DurationSeconds > 0 {
-			expiryTime := breaker.CreatedAt.Add(time.Duration(breaker.DurationSeconds) * time.Second)
-			if time.Now().After(expiryTime) {
-				// Auto-reset expired breaker
-				rm.ResetCircuitBreaker(breaker.ID)
-				continue
-			}
+	for i := range activeBreakers {
+		breaker := &activeBreakers[i]
+		if breaker.Scope != "" && breaker.Scope != models.BreakerScopeGlobal {
+			continue
 		}
 
-		return fmt.Errorf("circuit breaker active: %s (triggered at %s)", breaker.TriggerType, breaker.CreatedAt.Format(time.RFC3339))
+		if rm.refreshBreakerState(breaker) == models.BreakerStateOpen {
+			return fmt.Errorf("circuit breaker active: %s (triggered at %s)", breaker.TriggerType, breaker.CreatedAt.Format(time.RFC3339))
+		}
 	}
 
 	return nil
 }
 
-// IsCircuitBreakerActive returns true if circuit breaker is active
+// IsCircuitBreakerActive returns true if a global circuit breaker is open
 func (rm *RiskManager) IsCircuitBreakerActive() bool {
 	return rm.CheckCircuitBreaker() != nil
 }
 
 // UpdateDailyPnL updates the daily P&L tracking
 func (rm *RiskManager) UpdateDailyPnL(realizedPnL, unrealizedPnL float64) error {
-	today := time.Now().Format("2006-01-02")
+	return rm.updateDailyPnL(realizedPnL, unrealizedPnL, nil)
+}
+
+// UpdateDailyNetPnL updates today's realised P&L net of fees along with the
+// fees charged today
+func (rm *RiskManager) UpdateDailyNetPnL(netRealizedPnL, fees, unrealizedPnL float64) error {
+	return rm.updateDailyPnL(netRealizedPnL, unrealizedPnL, &fees)
+}
+
+func (rm *RiskManager) updateDailyPnL(realizedPnL, unrealizedPnL float64, fees *float64) error {
+	today := rm.TradingDay()
 	totalPnL := realizedPnL + unrealizedPnL
 
 	// Update or insert today's P&L
@@ -259,18 +618,24 @@ func (rm *RiskManager) UpdateDailyPnL(realizedPnL, unrealizedPnL float64) error
 	if result.Error != nil {
 		// Create new record
 		pnl = models.DailyPnLTracking{
-			Date:          time.Now(),
+			Date:          tradingDate(today),
 			RealizedPnL:   realizedPnL,
 			UnrealizedPnL: unrealizedPnL,
 			TotalPnL:      totalPnL,
 			UpdatedAt:     time.Now(),
 		}
+		if fees != nil {
+			pnl.Fees = *fees
+		}
 		rm.db.GetDB().Create(&pnl)
 	} else {
 		// Update existing record
 		pnl.RealizedPnL = realizedPnL
 		pnl.UnrealizedPnL = unrealizedPnL
 		pnl.TotalPnL = totalPnL
+		if fees != nil {
+			pnl.Fees = *fees
+		}
 		pnl.UpdatedAt = time.Now()
 		rm.db.GetDB().Save(&pnl)
 	}
@@ -289,74 +654,68 @@ func (rm *RiskManager) UpdateDailyPnL(realizedPnL, unrealizedPnL float64) error
 	return nil
 }
 
-// GetDailyPnL retrieves today's P&L
+// TradingDay returns the current trading day as YYYY-MM-DD. The day follows
+// the market session calendar, falling back to the server's date without one.
+func (rm *RiskManager) TradingDay() string {
+	if rm.calendar == nil {
+		return time.Now().Format("2006-01-02")
+	}
+	return rm.calendar.TradingDay(time.Now())
+}
+
+// tradingDate converts a YYYY-MM-DD trading day to a date value
+func tradingDate(day string) time.Time {
+	date, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return time.Now()
+	}
+	return date
+}
+
+// GetDailyPnL retrieves today's P&L, creating the day's record if needed
 func (rm *RiskManager) GetDailyPnL() (*models.DailyPnLTracking, error) {
+	return rm.loadDailyPnL(true)
+}
+
+// loadDailyPnL retrieves today's P&L. Without create, a missing record reads
+// as zero and nothing is written.
+func (rm *RiskManager) loadDailyPnL(create bool) (*models.DailyPnLTracking, error) {
 	// Try Redis cache first
 	ctx := context.Background()
 	key := "daily_pnl:latest"
 	cachedData, err := rm.redis.client.Get(ctx, key).Result()
 	
+	today := rm.TradingDay()
 	if err == nil {
 		var pnl models.DailyPnLTracking
-		if json.Unmarshal([]byte(cachedData), &pnl) == nil {
+		if json.Unmarshal([]byte(cachedData), &pnl) == nil && pnl.Date.Format("2006-01-02") == today {
 			return &pnl, nil
 		}
 	}
 
 	// Fallback to database
-	today := time.Now().Format("2006-01-02")
 	var pnl models.DailyPnLTracking
 	result := rm.db.GetDB().Where("date = ?", today).First(&pnl)
 
 	if result.Error != nil {
 		// Create today's record if it doesn't exist
 		pnl = models.DailyPnLTracking{
-			Date:          time.Now(),
+			Date:          tradingDate(today),
 			RealizedPnL:   0,
 			UnrealizedPnL: 0,
 			TotalPnL:      0,
 		}
-		rm.db.GetDB().Create(&pnl)
+		if create {
+			rm.db.GetDB().Create(&pnl)
+		}
 	}
 
 	return &pnl, nil
 }
 
-// TriggerCircuitBreaker activates the circuit breaker
+// TriggerCircuitBreaker activates a global circuit breaker with the configured cooldown
 func (rm *RiskManager) TriggerCircuitBreaker(triggerType string, value, threshold float64) error {
-	// Create circuit breaker event
-	event := models.CircuitBreakerEvent{
-		TriggerType:     triggerType,
-		TriggerValue:    value,
-		Threshold:       threshold,
-		DurationSeconds: 0, // Infinite until manual reset
-		Active:          true,
-		CreatedAt:       time.Now(),
-	}
-
-	rm.db.GetDB().Create(&event)
-
-	// Update daily P&L tracking
-	today := time.Now().Format("2006-01-02")
-	rm.db.GetDB().Model(&models.DailyPnLTracking{}).
-		Where("date = ?", today).
-		Update("circuit_breaker_triggered", true)
-
-	// Send critical alert
-	rm.SendAlert("CIRCUIT_BREAKER", "CRITICAL", "", 
-		fmt.Sprintf("Circuit breaker triggered: %s (value: %.2f, threshold: %.2f)", triggerType, value, threshold),
-		map[string]interface{}{
-			"trigger_type":  triggerType,
-			"trigger_value": value,
-			"threshold":     threshold,
-		})
-
-	// Broadcast via WebSocket
-	if rm.wsHub != nil {
-		rm.wsHub.BroadcastCircuitBreaker(&event)
-	}
-
-	return nil
+	return rm.TriggerScopedCircuitBreaker(models.BreakerScopeGlobal, "", triggerType, value, threshold, rm.breakerCooldown())
 }
 
 // ResetCircuitBreaker deactivates a circuit breaker
@@ -366,6 +725,7 @@ func (rm *RiskManager) ResetCircuitBreaker(breakerID uint) error {
 		Where("id = ?", breakerID).
 		Updates(map[string]interface{}{
 			"active":   false,
+			"state":    models.BreakerStateClosed,
 			"reset_at": now,
 		})
 
@@ -414,10 +774,25 @@ func (rm *RiskManager) ReloadLimits() error {
 		return result.Error
 	}
 
+	var throttleLimits []models.ThrottleLimit
+	rm.db.GetDB().Find(&throttleLimits)
+
+	throttles := make(map[string]models.ThrottleLimit, len(throttleLimits))
+	for _, limit := range throttleLimits {
+		throttles[throttleKey(limit.Scope, limit.Key, limit.Action)] = limit
+	}
+
+	restricted := rm.loadRestrictedList()
+	rm.snapshotLoadedLimits(limits)
+
 	rm.mu.Lock()
 	rm.limits = &limits
+	rm.throttles = throttles
+	rm.restricted = restricted
 	rm.mu.Unlock()
 
+	rm.refreshLimitHierarchy()
+
 	// Cache in Redis
 	ctx := context.Background()
 	key := "risk_limits:active"
@@ -427,8 +802,8 @@ func (rm *RiskManager) ReloadLimits() error {
 	return nil
 }
 
-// UpdateLimit updates a specific risk limit
-func (rm *RiskManager) UpdateLimit(update *models.RiskLimitsUpdate) error {
+// UpdateLimit updates a specific risk limit and records the new version
+func (rm *RiskManager) UpdateLimit(update *models.RiskLimitsUpdate, meta models.LimitChangeMeta) error {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
 
@@ -457,12 +832,63 @@ func (rm *RiskManager) UpdateLimit(update *models.RiskLimitsUpdate) error {
 	if update.MaxOrdersPerSecond != nil {
 		limits.MaxOrdersPerSecond = *update.MaxOrdersPerSecond
 	}
+	if update.BreakerCooldownSeconds != nil {
+		limits.BreakerCooldownSeconds = *update.BreakerCooldownSeconds
+	}
+	if update.RejectionRateThreshold != nil {
+		limits.RejectionRateThreshold = *update.RejectionRateThreshold
+	}
+	if update.EngineErrorBurst != nil {
+		limits.EngineErrorBurst = *update.EngineErrorBurst
+	}
+	if update.SymbolLossLimit != nil {
+		limits.SymbolLossLimit = *update.SymbolLossLimit
+	}
+	if update.MaxVaR != nil {
+		limits.MaxVaR = *update.MaxVaR
+	}
+	if update.VaRConfidence != nil {
+		limits.VaRConfidence = *update.VaRConfidence
+	}
+	if update.SelfTradeAction != nil {
+		limits.SelfTradeAction = *update.SelfTradeAction
+	}
+	if update.WashTradeWindowSeconds != nil {
+		limits.WashTradeWindowSeconds = *update.WashTradeWindowSeconds
+	}
+	if update.WashTradePriceTolerance != nil {
+		limits.WashTradePriceTolerance = *update.WashTradePriceTolerance
+	}
+	if update.PDTMaxDayTrades != nil {
+		limits.PDTMaxDayTrades = *update.PDTMaxDayTrades
+	}
+	if update.PDTEquityThreshold != nil {
+		limits.PDTEquityThreshold = *update.PDTEquityThreshold
+	}
+	if update.MaxTradesPerDay != nil {
+		limits.MaxTradesPerDay = *update.MaxTradesPerDay
+	}
+	if update.MaxDailyTurnover != nil {
+		limits.MaxDailyTurnover = *update.MaxDailyTurnover
+	}
+	if update.EnforceTradingSession != nil {
+		limits.EnforceTradingSession = *update.EnforceTradingSession
+	}
+	if update.UtilisationWarningPct != nil {
+		limits.UtilisationWarningPct = *update.UtilisationWarningPct
+	}
+	if update.UtilisationCriticalPct != nil {
+		limits.UtilisationCriticalPct = *update.UtilisationCriticalPct
+	}
 	if update.Enabled != nil {
 		limits.Enabled = *update.Enabled
 	}
 
 	limits.UpdatedAt = time.Now()
-	rm.db.GetDB().Save(&limits)
+	if result := rm.db.GetDB().Save(&limits); result.Error != nil {
+		return result.Error
+	}
+	rm.recordRiskLimits(limits, meta)
 
 	// Reload
 	rm.limits = &limits
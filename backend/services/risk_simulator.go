@@ -0,0 +1,269 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// RiskSimulator runs what-if risk checks on orders and baskets without side effects
+type RiskSimulator struct {
+	riskManager     *RiskManager
+	positionTracker *PositionTracker
+	engine          *EngineClient
+}
+
+// NewRiskSimulator creates a new risk simulator
+func NewRiskSimulator(riskManager *RiskManager, positionTracker *PositionTracker, engine *EngineClient) *RiskSimulator {
+	return &RiskSimulator{
+		riskManager:     riskManager,
+		positionTracker: positionTracker,
+		engine:          engine,
+	}
+}
+
+// basketUsage is what the allowed orders of a simulated basket would use up:
+// throttle tokens, trades, turnover, buying power, and hierarchy node
+// positions and order counts. Dry-run rules add it to the live state.
+type basketUsage struct {
+	tokens        map[string]int     // Throttle bucket key -> tokens taken
+	trades        int                // Orders counted against the daily trade limit
+	turnover      float64            // Notional counted against the daily turnover limit
+	buyNotional   map[string]float64 // Account -> buying power used by buys
+	nodePositions map[uint]map[string]float64
+	nodeOrders    map[uint]int
+}
+
+func newBasketUsage() *basketUsage {
+	return &basketUsage{
+		tokens:        make(map[string]int),
+		buyNotional:   make(map[string]float64),
+		nodePositions: make(map[uint]map[string]float64),
+		nodeOrders:    make(map[uint]int),
+	}
+}
+
+// addToBasket counts an allowed order in the basket. Caller holds rm.mu.
+func (rm *RiskManager) addToBasket(basket *basketUsage, order *models.OrderRequest, identity models.ThrottleIdentity) {
+	notional := rm.orderNotional(order)
+	basket.trades++
+	basket.turnover += notional
+	if order.Side == "BUY" {
+		basket.buyNotional[order.Account] += notional
+	}
+
+	for _, s := range throttleScopes(identity) {
+		basket.tokens[throttleBucketKey(models.ThrottleActionNew, s)]++
+	}
+
+	h := rm.hierarchy
+	if h == nil {
+		return
+	}
+	leaf, ok := h.attribute(order.Strategy, identity.UserID)
+	if !ok {
+		return
+	}
+	delta := order.Quantity
+	if order.Side != "BUY" {
+		delta = -order.Quantity
+	}
+	for _, node := range h.path(leaf) {
+		if basket.nodePositions[node.ID] == nil {
+			basket.nodePositions[node.ID] = make(map[string]float64)
+		}
+		basket.nodePositions[node.ID][order.Symbol] += delta
+		basket.nodeOrders[node.ID]++
+	}
+}
+
+// Simulate evaluates every rule of the ValidateOrder chain for each order and
+// projects limit utilisation. Orders in a basket are applied in sequence:
+// each order is checked as if the allowed orders before it had been sent,
+// seeing their positions, throttle tokens, trades, turnover and buying power.
+// Nothing is written.
+func (rs *RiskSimulator) Simulate(orders []models.OrderRequest, identity models.ThrottleIdentity) (*models.RiskSimulationResult, error) {
+	snapshot, err := LoadPortfolioSnapshot(rs.engine)
+	if err != nil {
+		// Simulate against flat positions if the engine is unavailable
+		snapshot = &PortfolioSnapshot{Positions: make(map[string]*SnapshotPosition), Timestamp: time.Now()}
+	}
+
+	limits := rs.riskManager.GetLimits()
+	dailyLoss := 0.0
+	if pnl, err := rs.riskManager.loadDailyPnL(false); err == nil && pnl.TotalPnL < 0 {
+		dailyLoss = -pnl.TotalPnL
+	}
+
+	result := &models.RiskSimulationResult{
+		Allowed:           true,
+		Orders:            make([]models.OrderSimulation, 0, len(orders)),
+		BasketUtilisation: make(map[string]models.LimitUtilisation),
+		PortfolioValue:    snapshot.Equity,
+		Timestamp:         time.Now(),
+	}
+
+	projected := make(map[string]float64)
+	prices := make(map[string]float64)
+	basket := newBasketUsage()
+
+	for _, order := range orders {
+		symbol := order.Symbol
+		if _, ok := projected[symbol]; !ok {
+			projected[symbol] = rs.effectivePosition(symbol, snapshot)
+		}
+
+		price := order.Price
+		if price <= 0 {
+			price = snapshot.Price(symbol)
+		}
+		if price > 0 {
+			prices[symbol] = price
+		}
+
+		orderIdentity := identity
+		orderIdentity.Strategy = order.Strategy
+		orderIdentity.Symbol = symbol
+
+		current := projected[symbol]
+		rules := rs.riskManager.evaluateOrderRules(&order, current, orderIdentity, basket)
+
+		next := current
+		if order.Side == "BUY" {
+			next += order.Quantity
+		} else {
+			next -= order.Quantity
+		}
+
+		allowed := true
+		for _, rule := range rules {
+			if !rule.Passed {
+				allowed = false
+			}
+		}
+		if allowed {
+			// A rejected order would not be sent, so later orders don't see it
+			projected[symbol] = next
+		} else {
+			result.Allowed = false
+		}
+
+		utilisation := map[string]models.LimitUtilisation{
+			"order_size":    computeUtilisation(order.Quantity*price, limits.MaxOrderSize),
+			"position":      computeUtilisation(math.Abs(next), rs.positionLimit(symbol, limits)),
+			"concentration": computeUtilisation(concentrationPct(next, prices[symbol], snapshot.Equity), rs.concentrationLimit(symbol, limits)),
+			"leverage":      computeUtilisation(projectedLeverage(snapshot, projected, prices), limits.MaxLeverage),
+			"daily_loss":    computeUtilisation(dailyLoss, limits.DailyLossLimit),
+		}
+
+		result.Orders = append(result.Orders, models.OrderSimulation{
+			Order:             order,
+			Allowed:           allowed,
+			Rules:             rules,
+			CurrentPosition:   current,
+			ProjectedPosition: next,
+			Utilisation:       utilisation,
+		})
+	}
+
+	// Basket utilisation is the worst case across symbols after all orders
+	var worstPosition, worstConcentration models.LimitUtilisation
+	for symbol, quantity := range projected {
+		position := computeUtilisation(math.Abs(quantity), rs.positionLimit(symbol, limits))
+		if position.UtilisationPct >= worstPosition.UtilisationPct {
+			worstPosition = position
+		}
+
+		concentration := computeUtilisation(concentrationPct(quantity, prices[symbol], snapshot.Equity), rs.concentrationLimit(symbol, limits))
+		if concentration.UtilisationPct >= worstConcentration.UtilisationPct {
+			worstConcentration = concentration
+		}
+	}
+
+	result.BasketUtilisation["position"] = worstPosition
+	result.BasketUtilisation["concentration"] = worstConcentration
+	result.BasketUtilisation["leverage"] = computeUtilisation(projectedLeverage(snapshot, projected, prices), limits.MaxLeverage)
+	result.BasketUtilisation["daily_loss"] = computeUtilisation(dailyLoss, limits.DailyLossLimit)
+
+	return result, nil
+}
+
+// effectivePosition returns the snapshot position plus pending orders
+func (rs *RiskSimulator) effectivePosition(symbol string, snapshot *PortfolioSnapshot) float64 {
+	position := 0.0
+	if pos, ok := snapshot.Positions[symbol]; ok {
+		position = pos.Quantity
+	}
+
+	if rs.positionTracker != nil {
+		pendingBuys, _ := rs.positionTracker.getPendingOrders(symbol, "BUY")
+		pendingSells, _ := rs.positionTracker.getPendingOrders(symbol, "SELL")
+		position += pendingBuys - pendingSells
+	}
+
+	return position
+}
+
+// positionLimit returns the tighter of the global and symbol position limits
+func (rs *RiskSimulator) positionLimit(symbol string, limits *models.RiskLimits) float64 {
+	limit := limits.MaxPositionSize
+
+	var posLimit models.PositionLimit
+	if rs.riskManager.db.GetDB().Where("symbol = ?", symbol).First(&posLimit).Error == nil {
+		limit = math.Min(limit, posLimit.MaxPosition)
+	}
+
+	return limit
+}
+
+// concentrationLimit returns the symbol concentration limit, or the global one
+func (rs *RiskSimulator) concentrationLimit(symbol string, limits *models.RiskLimits) float64 {
+	var posLimit models.PositionLimit
+	if rs.riskManager.db.GetDB().Where("symbol = ?", symbol).First(&posLimit).Error == nil {
+		return math.Min(limits.MaxPortfolioConcentration, posLimit.MaxConcentrationPct)
+	}
+
+	return limits.MaxPortfolioConcentration
+}
+
+// projectedLeverage returns gross exposure over equity with the projected
+// positions replacing the snapshot positions they cover
+func projectedLeverage(snapshot *PortfolioSnapshot, projected map[string]float64, prices map[string]float64) float64 {
+	if snapshot.Equity <= 0 {
+		return 0
+	}
+
+	gross := snapshot.GrossExposure()
+	for symbol, quantity := range projected {
+		if pos, ok := snapshot.Positions[symbol]; ok {
+			gross -= math.Abs(pos.MarketValue)
+		}
+		gross += math.Abs(quantity * prices[symbol])
+	}
+
+	return gross / snapshot.Equity
+}
+
+// concentrationPct returns a position's market value as a percentage of equity
+func concentrationPct(quantity, price, equity float64) float64 {
+	if equity <= 0 {
+		return 0
+	}
+	return math.Abs(quantity*price) / equity * 100
+}
+
+// computeUtilisation returns value as a share of limit
+func computeUtilisation(value, limit float64) models.LimitUtilisation {
+	utilisation := models.LimitUtilisation{
+		Value: value,
+		Limit: limit,
+	}
+
+	if limit > 0 {
+		utilisation.UtilisationPct = value / limit * 100
+		utilisation.Headroom = limit - value
+	}
+
+	return utilisation
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// unreachableStores returns a database and Redis that refuse every
+// connection, so rules that read them take their "allow if can't check" path
+func unreachableStores(t *testing.T) (*DatabaseService, *RedisService) {
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1"), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: time.Second})
+	t.Cleanup(func() { client.Close() })
+
+	return &DatabaseService{db: db}, &RedisService{client: client}
+}
+
+func TestSimulateBasketLeavesLiveStateUntouched(t *testing.T) {
+	db, redisService := unreachableStores(t)
+	ledger := NewOpenOrderLedger(nil, nil)
+	ledger.buyingPower = 1000
+	ledger.buyingPowerAt = time.Now()
+	hub := &WebSocketHub{broadcast: make(chan []byte, 16)}
+
+	userThrottle := models.ThrottleLimit{Rate: 0.01, Burst: 2}
+	rm := &RiskManager{
+		db:    db,
+		redis: redisService,
+		wsHub: hub,
+		limits: &models.RiskLimits{
+			MaxPositionSize:    1000,
+			MaxOrderSize:       10000,
+			DailyLossLimit:     5000,
+			MaxOrdersPerSecond: 100,
+			Enabled:            true,
+		},
+		orderCache: NewOrderThrottleCache(nil),
+		throttles: map[string]models.ThrottleLimit{
+			throttleKey(models.ThrottleScopeUser, "alice", models.ThrottleActionNew): userThrottle,
+		},
+		restricted: make(map[string][]models.RestrictedSymbol),
+		hierarchy:  newLimitHierarchy(nil),
+		ledger:     ledger,
+	}
+	rs := NewRiskSimulator(rm, nil, nil)
+	alice := models.ThrottleIdentity{UserID: "alice"}
+
+	basket := []models.OrderRequest{
+		{Symbol: "AAPL", Side: "BUY", Quantity: 5, Price: 100},  // $500 of $1000 buying power
+		{Symbol: "MSFT", Side: "BUY", Quantity: 6, Price: 100},  // $600 more is over buying power
+		{Symbol: "AAPL", Side: "SELL", Quantity: 1, Price: 100}, // Second throttle token
+		{Symbol: "AAPL", Side: "SELL", Quantity: 1, Price: 100}, // Burst of 2 used up
+	}
+
+	result, err := rs.Simulate(basket, alice)
+	if err != nil {
+		t.Fatalf("simulate failed: %v", err)
+	}
+	if len(result.Orders) != len(basket) || result.Allowed {
+		t.Fatalf("expected 4 orders and a rejected basket, got %+v", result)
+	}
+
+	failed := func(i int) []string {
+		var rules []string
+		for _, rule := range result.Orders[i].Rules {
+			if !rule.Passed {
+				rules = append(rules, rule.Rule)
+			}
+		}
+		return rules
+	}
+
+	if !result.Orders[0].Allowed {
+		t.Errorf("order 1: expected allowed, failed %v", failed(0))
+	}
+	if got := failed(1); len(got) != 1 || got[0] != "buying_power" {
+		t.Errorf("order 2: expected only buying_power to fail after order 1, failed %v", got)
+	}
+	if !result.Orders[2].Allowed || result.Orders[2].CurrentPosition != 5 {
+		t.Errorf("order 3: expected allowed against the 5 bought by order 1, got position %.0f, failed %v",
+			result.Orders[2].CurrentPosition, failed(2))
+	}
+	if got := failed(3); len(got) != 1 || got[0] != "order_throttle" {
+		t.Errorf("order 4: expected only order_throttle to fail after orders 1 and 3, failed %v", got)
+	}
+
+	// No throttle token was taken
+	decision, err := rm.orderCache.Peek(throttleBucketKey(models.ThrottleActionNew, throttleScope{models.ThrottleScopeUser, "alice"}),
+		userThrottle.Rate, userThrottle.Burst)
+	if err != nil || decision.Remaining != float64(userThrottle.Burst) {
+		t.Errorf("expected the live throttle bucket to be full, got %+v (%v)", decision, err)
+	}
+
+	// No buying power was reserved
+	if orders := ledger.GetOpenOrders(); len(orders) != 0 {
+		t.Errorf("expected no ledger reservations, got %+v", orders)
+	}
+	if available, _ := ledger.AvailableBuyingPower(""); available != 1000 {
+		t.Errorf("expected $1000 buying power still available, got $%.2f", available)
+	}
+
+	// No alert was sent for the rejected orders
+	if len(hub.broadcast) != 0 {
+		t.Errorf("expected no alerts, got %d", len(hub.broadcast))
+	}
+
+	// A single-order evaluation is equally side-effect free
+	rm.EvaluateOrderRules(&basket[0], 0, alice)
+	if len(ledger.GetOpenOrders()) != 0 || len(hub.broadcast) != 0 {
+		t.Error("expected EvaluateOrderRules to leave the ledger and alerts untouched")
+	}
+	for i := 0; i < userThrottle.Burst; i++ {
+		if err := rm.CheckOrderThrottle(models.ThrottleActionNew, alice); err != nil {
+			t.Fatalf("live order %d: expected the full burst to be available, got %v", i+1, err)
+		}
+	}
+}
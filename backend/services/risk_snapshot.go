@@ -0,0 +1,270 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// utilisationMaxAge is how old the latest snapshot may be before a request
+// recomputes it
+const utilisationMaxAge = 10 * time.Second
+
+// RiskSnapshotService computes how close the portfolio is to each limit,
+// pushes changes over the WebSocket hub and alerts at soft thresholds
+type RiskSnapshotService struct {
+	riskManager *RiskManager
+	engine      *EngineClient
+	wsHub       *WebSocketHub
+	latest      *models.RiskUtilisation
+	levels      map[string]string // Alert level reached per limit
+	mu          sync.RWMutex
+	ticker      *time.Ticker
+	stopChan    chan bool
+}
+
+// NewRiskSnapshotService creates a new risk snapshot service
+func NewRiskSnapshotService(riskManager *RiskManager, engine *EngineClient, wsHub *WebSocketHub) *RiskSnapshotService {
+	return &RiskSnapshotService{
+		riskManager: riskManager,
+		engine:      engine,
+		wsHub:       wsHub,
+		levels:      make(map[string]string),
+		stopChan:    make(chan bool),
+	}
+}
+
+// Start begins recomputing utilisation periodically
+func (rs *RiskSnapshotService) Start() {
+	rs.ticker = time.NewTicker(5 * time.Second)
+
+	go func() {
+		log.Println("Risk snapshot service started (updating every 5 seconds)")
+
+		for {
+			select {
+			case <-rs.ticker.C:
+				if _, err := rs.Refresh(); err != nil {
+					log.Printf("Error computing limit utilisation: %v", err)
+				}
+
+			case <-rs.stopChan:
+				log.Println("Risk snapshot service stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the risk snapshot service
+func (rs *RiskSnapshotService) Stop() {
+	if rs.ticker != nil {
+		rs.ticker.Stop()
+	}
+	rs.stopChan <- true
+}
+
+// GetUtilisation returns the latest utilisation, recomputing it when stale
+func (rs *RiskSnapshotService) GetUtilisation() (*models.RiskUtilisation, error) {
+	rs.mu.RLock()
+	latest := rs.latest
+	rs.mu.RUnlock()
+
+	if latest != nil && time.Since(latest.Timestamp) < utilisationMaxAge {
+		return latest, nil
+	}
+	return rs.Refresh()
+}
+
+// Refresh recomputes utilisation, broadcasts it if it changed and raises
+// alerts for limits that crossed a soft threshold
+func (rs *RiskSnapshotService) Refresh() (*models.RiskUtilisation, error) {
+	snapshot, err := LoadPortfolioSnapshot(rs.engine)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := rs.riskManager.GetLimits()
+
+	dailyLoss := 0.0
+	if pnl, err := rs.riskManager.GetDailyPnL(); err == nil && pnl.TotalPnL < 0 {
+		dailyLoss = -pnl.TotalPnL
+	}
+
+	var positionLimits []models.PositionLimit
+	rs.riskManager.db.GetDB().Find(&positionLimits)
+	symbolLimits := make(map[string]models.PositionLimit, len(positionLimits))
+	for _, limit := range positionLimits {
+		symbolLimits[limit.Symbol] = limit
+	}
+
+	orderPeak := rs.riskManager.orderOutcomes.Peak(time.Second)
+	utilisation := buildUtilisation(snapshot, limits, symbolLimits, dailyLoss, orderPeak)
+
+	rs.mu.Lock()
+	previous := rs.latest
+	rs.latest = utilisation
+	rs.mu.Unlock()
+
+	if rs.wsHub != nil && utilisationChanged(previous, utilisation) {
+		rs.wsHub.BroadcastRiskUtilisation(utilisation)
+	}
+	rs.raiseAlerts(utilisation)
+
+	return utilisation, nil
+}
+
+// buildUtilisation computes utilisation of every global and per-symbol limit.
+// Global position and concentration are the worst case across symbols.
+func buildUtilisation(snapshot *PortfolioSnapshot, limits *models.RiskLimits, symbolLimits map[string]models.PositionLimit, dailyLoss float64, orderPeak int) *models.RiskUtilisation {
+	utilisation := &models.RiskUtilisation{
+		Global:         make(map[string]models.LimitUtilisation),
+		Symbols:        make(map[string]map[string]models.LimitUtilisation),
+		Breaches:       []models.UtilisationBreach{},
+		WarningPct:     limits.UtilisationWarningPct,
+		CriticalPct:    limits.UtilisationCriticalPct,
+		PortfolioValue: snapshot.Equity,
+		Timestamp:      time.Now(),
+	}
+
+	var worstPosition, worstConcentration models.LimitUtilisation
+	for symbol, position := range snapshot.Positions {
+		positionLimit := limits.MaxPositionSize
+		concentrationLimit := limits.MaxPortfolioConcentration
+		if symbolLimit, ok := symbolLimits[symbol]; ok {
+			positionLimit = math.Min(positionLimit, symbolLimit.MaxPosition)
+			concentrationLimit = math.Min(concentrationLimit, symbolLimit.MaxConcentrationPct)
+		}
+
+		symbolUtilisation := map[string]models.LimitUtilisation{
+			"position":      computeUtilisation(math.Abs(position.Quantity), positionLimit),
+			"concentration": computeUtilisation(concentrationPct(position.Quantity, position.MarketPrice, snapshot.Equity), concentrationLimit),
+		}
+		utilisation.Symbols[symbol] = symbolUtilisation
+
+		if symbolUtilisation["position"].UtilisationPct >= worstPosition.UtilisationPct {
+			worstPosition = symbolUtilisation["position"]
+		}
+		if symbolUtilisation["concentration"].UtilisationPct >= worstConcentration.UtilisationPct {
+			worstConcentration = symbolUtilisation["concentration"]
+		}
+
+		for name, u := range symbolUtilisation {
+			if level := utilisationLevel(u.UtilisationPct, limits); level != "" {
+				utilisation.Breaches = append(utilisation.Breaches, models.UtilisationBreach{
+					Limit: name, Symbol: symbol, Level: level, UtilisationPct: u.UtilisationPct,
+				})
+			}
+		}
+	}
+
+	leverage := 0.0
+	if snapshot.Equity > 0 {
+		leverage = snapshot.GrossExposure() / snapshot.Equity
+	}
+
+	utilisation.Global["position"] = worstPosition
+	utilisation.Global["concentration"] = worstConcentration
+	utilisation.Global["leverage"] = computeUtilisation(leverage, limits.MaxLeverage)
+	utilisation.Global["daily_loss"] = computeUtilisation(dailyLoss, limits.DailyLossLimit)
+	utilisation.Global["order_rate"] = computeUtilisation(float64(orderPeak), float64(limits.MaxOrdersPerSecond))
+
+	// Position and concentration breaches are already reported per symbol
+	for _, name := range []string{"leverage", "daily_loss", "order_rate"} {
+		u := utilisation.Global[name]
+		if level := utilisationLevel(u.UtilisationPct, limits); level != "" {
+			utilisation.Breaches = append(utilisation.Breaches, models.UtilisationBreach{
+				Limit: name, Level: level, UtilisationPct: u.UtilisationPct,
+			})
+		}
+	}
+
+	return utilisation
+}
+
+// utilisationLevel returns the soft threshold a utilisation has reached, if any
+func utilisationLevel(pct float64, limits *models.RiskLimits) string {
+	if limits.UtilisationCriticalPct > 0 && pct >= limits.UtilisationCriticalPct {
+		return models.UtilisationLevelCritical
+	}
+	if limits.UtilisationWarningPct > 0 && pct >= limits.UtilisationWarningPct {
+		return models.UtilisationLevelWarning
+	}
+	return ""
+}
+
+// utilisationChanged reports whether any utilisation moved by at least a
+// tenth of a percentage point, or a limit appeared or disappeared
+func utilisationChanged(previous, next *models.RiskUtilisation) bool {
+	if previous == nil {
+		return true
+	}
+
+	changed := func(a, b map[string]models.LimitUtilisation) bool {
+		if len(a) != len(b) {
+			return true
+		}
+		for name, u := range a {
+			other, ok := b[name]
+			if !ok || math.Abs(u.UtilisationPct-other.UtilisationPct) >= 0.1 {
+				return true
+			}
+		}
+		return false
+	}
+
+	if changed(previous.Global, next.Global) || len(previous.Symbols) != len(next.Symbols) {
+		return true
+	}
+	for symbol, u := range next.Symbols {
+		if changed(previous.Symbols[symbol], u) {
+			return true
+		}
+	}
+	return false
+}
+
+// raiseAlerts sends an alert when a limit reaches a higher soft threshold
+// than before. A limit is re-armed once it drops back below its level.
+func (rs *RiskSnapshotService) raiseAlerts(utilisation *models.RiskUtilisation) {
+	rank := map[string]int{"": 0, models.UtilisationLevelWarning: 1, models.UtilisationLevelCritical: 2}
+
+	current := make(map[string]models.UtilisationBreach, len(utilisation.Breaches))
+	for _, breach := range utilisation.Breaches {
+		current[breach.Limit+":"+breach.Symbol] = breach
+	}
+
+	rs.mu.Lock()
+	var raised []models.UtilisationBreach
+	for key, breach := range current {
+		if rank[breach.Level] > rank[rs.levels[key]] {
+			raised = append(raised, breach)
+		}
+		rs.levels[key] = breach.Level
+	}
+	for key := range rs.levels {
+		if _, ok := current[key]; !ok {
+			delete(rs.levels, key)
+		}
+	}
+	rs.mu.Unlock()
+
+	for _, breach := range raised {
+		target := breach.Limit
+		if breach.Symbol != "" {
+			target = breach.Symbol + " " + breach.Limit
+		}
+		rs.riskManager.SendAlert("LIMIT_UTILISATION", breach.Level, breach.Symbol,
+			fmt.Sprintf("%s limit utilisation at %.1f%%", target, breach.UtilisationPct),
+			map[string]interface{}{
+				"limit":           breach.Limit,
+				"utilisation_pct": breach.UtilisationPct,
+				"warning_pct":     utilisation.WarningPct,
+				"critical_pct":    utilisation.CriticalPct,
+			})
+	}
+}
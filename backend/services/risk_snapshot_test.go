@@ -0,0 +1,99 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestBuildUtilisation(t *testing.T) {
+	snapshot := &PortfolioSnapshot{
+		Equity: 100000,
+		Positions: map[string]*SnapshotPosition{
+			"AAPL": {Symbol: "AAPL", Quantity: 850, MarketPrice: 20, MarketValue: 17000},
+			"MSFT": {Symbol: "MSFT", Quantity: -100, MarketPrice: 300, MarketValue: -30000},
+		},
+	}
+	limits := &models.RiskLimits{
+		MaxPositionSize:           1000,
+		MaxPortfolioConcentration: 25,
+		MaxLeverage:               2,
+		DailyLossLimit:            5000,
+		MaxOrdersPerSecond:        10,
+		UtilisationWarningPct:     80,
+		UtilisationCriticalPct:    95,
+	}
+	symbolLimits := map[string]models.PositionLimit{
+		"MSFT": {Symbol: "MSFT", MaxPosition: 500, MaxConcentrationPct: 31},
+	}
+
+	u := buildUtilisation(snapshot, limits, symbolLimits, 1000, 3)
+
+	if got := u.Symbols["AAPL"]["position"].UtilisationPct; got != 85 {
+		t.Errorf("AAPL position utilisation = %.2f, expected 85", got)
+	}
+	if got := u.Symbols["MSFT"]["position"].UtilisationPct; got != 20 {
+		t.Errorf("MSFT position utilisation against symbol limit = %.2f, expected 20", got)
+	}
+	if got := u.Global["position"].UtilisationPct; got != 85 {
+		t.Errorf("global position utilisation = %.2f, expected worst symbol 85", got)
+	}
+	if got := u.Global["leverage"].Value; got != 0.47 {
+		t.Errorf("leverage = %.2f, expected 0.47", got)
+	}
+	if got := u.Global["order_rate"].UtilisationPct; got != 30 {
+		t.Errorf("order rate utilisation = %.2f, expected 30", got)
+	}
+
+	levels := map[string]string{}
+	for _, breach := range u.Breaches {
+		levels[breach.Symbol+":"+breach.Limit] = breach.Level
+	}
+	if levels["AAPL:position"] != models.UtilisationLevelWarning {
+		t.Errorf("expected AAPL position warning, got %+v", u.Breaches)
+	}
+	if levels["MSFT:concentration"] != models.UtilisationLevelCritical {
+		t.Errorf("expected MSFT concentration critical, got %+v", u.Breaches)
+	}
+	if len(u.Breaches) != 2 {
+		t.Errorf("expected 2 breaches, got %+v", u.Breaches)
+	}
+}
+
+func TestUtilisationChanged(t *testing.T) {
+	base := &models.RiskUtilisation{
+		Global:  map[string]models.LimitUtilisation{"leverage": {UtilisationPct: 50}},
+		Symbols: map[string]map[string]models.LimitUtilisation{"AAPL": {"position": {UtilisationPct: 40}}},
+	}
+	same := &models.RiskUtilisation{
+		Global:  map[string]models.LimitUtilisation{"leverage": {UtilisationPct: 50.04}},
+		Symbols: map[string]map[string]models.LimitUtilisation{"AAPL": {"position": {UtilisationPct: 40}}},
+	}
+	moved := &models.RiskUtilisation{
+		Global:  map[string]models.LimitUtilisation{"leverage": {UtilisationPct: 50}},
+		Symbols: map[string]map[string]models.LimitUtilisation{"AAPL": {"position": {UtilisationPct: 41}}},
+	}
+
+	if !utilisationChanged(nil, base) {
+		t.Error("expected first snapshot to count as a change")
+	}
+	if utilisationChanged(base, same) {
+		t.Error("expected a sub-0.1 point move to be ignored")
+	}
+	if !utilisationChanged(base, moved) {
+		t.Error("expected a symbol utilisation move to count as a change")
+	}
+}
+
+func TestEventWindowPeak(t *testing.T) {
+	w := newEventWindow(time.Minute)
+	now := time.Now()
+	for _, offset := range []time.Duration{-30 * time.Second, -10 * time.Second, -9900 * time.Millisecond, -9800 * time.Millisecond, -2 * time.Second} {
+		w.events = append(w.events, windowEvent{at: now.Add(offset)})
+	}
+
+	if got := w.Peak(time.Second); got != 3 {
+		t.Errorf("peak = %d, expected 3", got)
+	}
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// SelfTradeGuard prevents our own orders from crossing each other before
+// they reach the engine and flags potential wash trades after execution
+type SelfTradeGuard struct {
+	riskManager     *RiskManager
+	engine          *EngineClient
+	redis           *RedisService
+	ledger          *OpenOrderLedger
+	db              *DatabaseService
+	lastExecutionID uint
+	ticker          *time.Ticker
+	stopChan        chan bool
+}
+
+// NewSelfTradeGuard creates a new self-trade guard
+func NewSelfTradeGuard(riskManager *RiskManager, engine *EngineClient, redis *RedisService, ledger *OpenOrderLedger, db *DatabaseService) *SelfTradeGuard {
+	return &SelfTradeGuard{
+		riskManager: riskManager,
+		engine:      engine,
+		redis:       redis,
+		ledger:      ledger,
+		db:          db,
+		stopChan:    make(chan bool),
+	}
+}
+
+// Start begins scanning new executions for potential wash trades
+func (sg *SelfTradeGuard) Start() {
+	sg.ticker = time.NewTicker(30 * time.Second)
+
+	go func() {
+		log.Println("Wash trade detector started (scanning every 30 seconds)")
+
+		// Only executions after startup are scanned so restarts don't re-flag history
+		if sg.db != nil && sg.db.GetDB() != nil {
+			sg.db.GetDB().Model(&models.Execution{}).Select("COALESCE(MAX(id), 0)").Scan(&sg.lastExecutionID)
+		}
+
+		for {
+			select {
+			case <-sg.ticker.C:
+				sg.DetectWashTrades()
+
+			case <-sg.stopChan:
+				log.Println("Wash trade detector stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the wash trade detector
+func (sg *SelfTradeGuard) Stop() {
+	if sg.ticker != nil {
+		sg.ticker.Stop()
+	}
+	sg.stopChan <- true
+}
+
+// Prevent checks an incoming order against our resting orders and applies
+// the configured self-trade action
+func (sg *SelfTradeGuard) Prevent(order *models.OrderRequest) *models.SelfTradeResult {
+	action := sg.riskManager.GetLimits().SelfTradeAction
+	if action == "" {
+		action = models.SelfTradeActionRejectNew
+	}
+
+	result := &models.SelfTradeResult{
+		Allowed:        true,
+		Action:         action,
+		Conflicts:      []models.RestingOrder{},
+		CanceledOrders: []string{},
+	}
+
+	if action == models.SelfTradeActionNone {
+		return result
+	}
+
+	resting, err := sg.getRestingOrders()
+	if err != nil {
+		log.Printf("Self-trade check skipped: %v", err)
+		return result // Allow if can't check
+	}
+
+	result.Conflicts = crossingOrders(order, resting)
+	if len(result.Conflicts) == 0 {
+		return result
+	}
+
+	if action == models.SelfTradeActionCancelResting || action == models.SelfTradeActionCancelBoth {
+		for _, conflict := range result.Conflicts {
+			if err := sg.cancelResting(conflict); err != nil {
+				log.Printf("Failed to cancel resting order %s: %v", conflict.OrderID, err)
+				continue
+			}
+			result.CanceledOrders = append(result.CanceledOrders, conflict.OrderID)
+		}
+
+		// The new order can only go through once every crossing order is gone
+		if action == models.SelfTradeActionCancelResting && len(result.CanceledOrders) == len(result.Conflicts) {
+			return result
+		}
+	}
+
+	result.Allowed = false
+	result.Reason = fmt.Sprintf("self-trade prevented: %s %s crosses %d resting order(s) (action: %s)",
+		order.Side, order.Symbol, len(result.Conflicts), action)
+
+	return result
+}
+
+// crossingOrders returns the resting orders an incoming order would trade against
+func crossingOrders(order *models.OrderRequest, resting []models.RestingOrder) []models.RestingOrder {
+	conflicts := []models.RestingOrder{}
+	for _, r := range resting {
+		if r.Symbol != order.Symbol || r.Side == order.Side || r.ClientOrderID == order.ClientOrderID {
+			continue
+		}
+
+		// Market orders cross any opposite order
+		if order.Price <= 0 || r.Price <= 0 {
+			conflicts = append(conflicts, r)
+			continue
+		}
+
+		if (order.Side == "BUY" && order.Price >= r.Price) || (order.Side == "SELL" && order.Price <= r.Price) {
+			conflicts = append(conflicts, r)
+		}
+	}
+	return conflicts
+}
+
+// getRestingOrders reads our open orders from the cache behind GET_OPEN_ORDERS,
+// falling back to the engine on a cache miss
+func (sg *SelfTradeGuard) getRestingOrders() ([]models.RestingOrder, error) {
+	var orders []interface{}
+	if sg.redis != nil {
+		orders, _ = sg.redis.GetOpenOrders()
+	}
+
+	if orders == nil {
+		if sg.engine == nil {
+			return nil, fmt.Errorf("engine client not available")
+		}
+
+		response, err := sg.engine.SendRequest(map[string]interface{}{"type": "GET_OPEN_ORDERS"})
+		if err != nil {
+			return nil, err
+		}
+
+		orders, _ = response["orders"].([]interface{})
+		if sg.redis != nil {
+			sg.redis.SetOpenOrders(orders)
+		}
+	}
+
+	resting := make([]models.RestingOrder, 0, len(orders))
+	for _, o := range orders {
+		order, ok := o.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		r := models.RestingOrder{}
+		r.OrderID, _ = order["id"].(string)
+		if r.OrderID == "" {
+			r.OrderID, _ = order["order_id"].(string)
+		}
+		r.ClientOrderID, _ = order["client_order_id"].(string)
+		r.Symbol, _ = order["symbol"].(string)
+		side, _ := order["side"].(string)
+		r.Side = strings.ToUpper(side)
+		orderType, _ := order["type"].(string)
+		if orderType == "" {
+			orderType, _ = order["order_type"].(string)
+		}
+		r.OrderType = strings.ToUpper(orderType)
+		r.Price, _ = EngineNumber(order, "limit_price", "price")
+		r.Quantity, _ = EngineNumber(order, "qty", "quantity")
+
+		resting = append(resting, r)
+	}
+
+	return resting, nil
+}
+
+// cancelResting cancels one of our resting orders and releases its reservation
+func (sg *SelfTradeGuard) cancelResting(order models.RestingOrder) error {
+	if sg.engine == nil {
+		return fmt.Errorf("engine client not available")
+	}
+
+	response, err := sg.engine.SendRequest(map[string]interface{}{
+		"type":     "CANCEL_ORDER",
+		"order_id": order.OrderID,
+	})
+	if err != nil {
+		return err
+	}
+	if success, ok := response["success"].(bool); ok && !success {
+		return fmt.Errorf("engine refused cancel: %v", response["error"])
+	}
+
+	if sg.ledger != nil {
+		sg.ledger.Release(order.OrderID, "SELF_TRADE_CANCEL")
+	}
+	if sg.redis != nil {
+		sg.redis.InvalidateOpenOrders()
+	}
+
+	return nil
+}
+
+// DetectWashTrades flags new executions that trade against one of our own
+// opposite-side executions in the same symbol at a similar price and time
+func (sg *SelfTradeGuard) DetectWashTrades() {
+	if sg.db == nil || sg.db.GetDB() == nil {
+		return
+	}
+
+	limits := sg.riskManager.GetLimits()
+	window := time.Duration(limits.WashTradeWindowSeconds) * time.Second
+	if window <= 0 {
+		return
+	}
+
+	var executions []models.Execution
+	sg.db.GetDB().Where("id > ?", sg.lastExecutionID).Order("id").Find(&executions)
+
+	for _, execution := range executions {
+		sg.lastExecutionID = execution.ID
+
+		var candidates []models.Execution
+		sg.db.GetDB().
+			Where("id < ? AND symbol = ? AND side <> ? AND timestamp BETWEEN ? AND ?",
+				execution.ID, execution.Symbol, execution.Side,
+				execution.Timestamp.Add(-window), execution.Timestamp.Add(window)).
+			Find(&candidates)
+
+		for _, match := range candidates {
+			if !isWashTrade(execution, match, window, limits.WashTradePriceTolerance) {
+				continue
+			}
+
+			sg.riskManager.SendAlert("WASH_TRADE", "WARNING", execution.Symbol,
+				fmt.Sprintf("Potential wash trade in %s: %s %.2f @ %.2f and %s %.2f @ %.2f within %s",
+					execution.Symbol, match.Side, match.FillQty, match.FillPrice,
+					execution.Side, execution.FillQty, execution.FillPrice,
+					execution.Timestamp.Sub(match.Timestamp).Abs().Round(time.Second)),
+				map[string]interface{}{
+					"execution_ids": []uint{match.ID, execution.ID},
+					"order_ids":     []string{match.OrderID, execution.OrderID},
+					"prices":        []float64{match.FillPrice, execution.FillPrice},
+					"quantities":    []float64{match.FillQty, execution.FillQty},
+				})
+		}
+	}
+}
+
+// isWashTrade reports whether two executions are opposite sides of the same
+// symbol within the time window and price tolerance (in percent)
+func isWashTrade(a, b models.Execution, window time.Duration, tolerancePct float64) bool {
+	if a.Symbol != b.Symbol || a.Side == b.Side || (a.OrderID != "" && a.OrderID == b.OrderID) {
+		return false
+	}
+
+	if a.Timestamp.Sub(b.Timestamp).Abs() > window {
+		return false
+	}
+
+	if a.FillPrice <= 0 || b.FillPrice <= 0 {
+		return false
+	}
+
+	diffPct := math.Abs(a.FillPrice-b.FillPrice) / math.Min(a.FillPrice, b.FillPrice) * 100
+	return diffPct <= tolerancePct
+}
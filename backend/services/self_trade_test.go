@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestCrossingOrders(t *testing.T) {
+	resting := []models.RestingOrder{
+		{OrderID: "s1", Symbol: "AAPL", Side: "SELL", Price: 101},
+		{OrderID: "s2", Symbol: "AAPL", Side: "SELL", Price: 99},
+		{OrderID: "b1", Symbol: "AAPL", Side: "BUY", Price: 98},
+		{OrderID: "m1", Symbol: "MSFT", Side: "SELL", Price: 0},
+	}
+
+	conflicts := crossingOrders(&models.OrderRequest{ClientOrderID: "n1", Symbol: "AAPL", Side: "BUY", Price: 100}, resting)
+	if len(conflicts) != 1 || conflicts[0].OrderID != "s2" {
+		t.Errorf("Expected limit buy at 100 to cross only s2, got %+v", conflicts)
+	}
+
+	conflicts = crossingOrders(&models.OrderRequest{ClientOrderID: "n2", Symbol: "AAPL", Side: "SELL"}, resting)
+	if len(conflicts) != 1 || conflicts[0].OrderID != "b1" {
+		t.Errorf("Expected market sell to cross b1, got %+v", conflicts)
+	}
+
+	conflicts = crossingOrders(&models.OrderRequest{ClientOrderID: "n3", Symbol: "MSFT", Side: "BUY", Price: 1}, resting)
+	if len(conflicts) != 1 {
+		t.Errorf("Expected resting market order to cross, got %+v", conflicts)
+	}
+}
+
+func TestIsWashTrade(t *testing.T) {
+	now := time.Now()
+	buy := models.Execution{OrderID: "a", Symbol: "AAPL", Side: "BUY", FillPrice: 100, Timestamp: now}
+
+	cases := []struct {
+		name string
+		sell models.Execution
+		want bool
+	}{
+		{"same price within window", models.Execution{OrderID: "b", Symbol: "AAPL", Side: "SELL", FillPrice: 100.05, Timestamp: now.Add(10 * time.Second)}, true},
+		{"outside window", models.Execution{OrderID: "b", Symbol: "AAPL", Side: "SELL", FillPrice: 100, Timestamp: now.Add(2 * time.Minute)}, false},
+		{"outside price tolerance", models.Execution{OrderID: "b", Symbol: "AAPL", Side: "SELL", FillPrice: 101, Timestamp: now}, false},
+		{"same side", models.Execution{OrderID: "b", Symbol: "AAPL", Side: "BUY", FillPrice: 100, Timestamp: now}, false},
+	}
+
+	for _, tc := range cases {
+		if got := isWashTrade(tc.sell, buy, time.Minute, 0.10); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
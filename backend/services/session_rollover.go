@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+	"gorm.io/gorm"
+)
+
+// ErrSessionAlreadyClosed is returned when closing a trading day twice
+var ErrSessionAlreadyClosed = errors.New("trading day already closed")
+
+// dailyBreakerTriggers are breakers measured against the day's P&L. They are
+// reset when a new session starts; rolling-window and manual breakers are not.
+var dailyBreakerTriggers = map[string]bool{
+	"DAILY_LOSS":  true,
+	"SYMBOL_LOSS": true,
+}
+
+// SessionRollover freezes each trading day after the post-market close and
+// resets daily risk state when the next trading day starts
+type SessionRollover struct {
+	riskManager  *RiskManager
+	calendar     *MarketCalendar
+	positionBook *PositionBook
+	orderLedger  *OpenOrderLedger
+	mu           sync.Mutex
+	ticker       *time.Ticker
+	stopChan     chan bool
+}
+
+// NewSessionRollover creates a new end-of-day and start-of-day job
+func NewSessionRollover(riskManager *RiskManager, calendar *MarketCalendar, positionBook *PositionBook, orderLedger *OpenOrderLedger) *SessionRollover {
+	return &SessionRollover{
+		riskManager:  riskManager,
+		calendar:     calendar,
+		positionBook: positionBook,
+		orderLedger:  orderLedger,
+		stopChan:     make(chan bool),
+	}
+}
+
+// Start checks for a due end-of-day or start-of-day every minute
+func (sr *SessionRollover) Start() {
+	sr.check()
+
+	sr.ticker = time.NewTicker(1 * time.Minute)
+
+	go func() {
+		log.Println("Session rollover started (checking every minute)")
+
+		for {
+			select {
+			case <-sr.ticker.C:
+				sr.check()
+
+			case <-sr.stopChan:
+				log.Println("Session rollover stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the session rollover job
+func (sr *SessionRollover) Stop() {
+	if sr.ticker != nil {
+		sr.ticker.Stop()
+	}
+	sr.stopChan <- true
+}
+
+// check runs the end-of-day once the post-market session is over and the
+// start-of-day once the trading day has changed. A day the backend did not
+// close in time is closed late before rolling over.
+func (sr *SessionRollover) check() {
+	if sr.riskManager.db.GetDB() == nil {
+		return // Database disabled
+	}
+
+	now := time.Now()
+	today := sr.riskManager.TradingDay()
+
+	if sr.calendar != nil && endOfDayDue(sr.calendar.Session(now), now) && !sr.closed(today) {
+		if _, err := sr.closeDay(today, false); err != nil {
+			log.Printf("Error running end of day for %s: %v", today, err)
+		}
+	}
+
+	var lastTracked models.DailyPnLTracking
+	result := sr.riskManager.db.GetDB().Where("date < ?", today).Order("date DESC").First(&lastTracked)
+	if result.Error == nil {
+		day := lastTracked.Date.Format("2006-01-02")
+		if !sr.closed(day) {
+			if _, err := sr.closeDay(day, true); err != nil {
+				log.Printf("Error running late end of day for %s: %v", day, err)
+			}
+		}
+	}
+
+	var pending []models.SessionSummary
+	sr.riskManager.db.GetDB().
+		Where("trading_day < ? AND rolled_over_at IS NULL", today).
+		Order("trading_day").Find(&pending)
+	if len(pending) > 0 {
+		if err := sr.startDay(pending, today); err != nil {
+			log.Printf("Error running start of day for %s: %v", today, err)
+		}
+	}
+}
+
+// endOfDayDue reports whether the day's post-market session has ended
+func endOfDayDue(session *models.MarketSession, now time.Time) bool {
+	return session != nil && session.PostClose != nil && !now.Before(*session.PostClose)
+}
+
+// expiresWithSession reports whether a breaker was tripped by the P&L of a
+// session that ended before dayStart
+func expiresWithSession(breaker *models.CircuitBreakerEvent, dayStart time.Time) bool {
+	return dailyBreakerTriggers[breaker.TriggerType] && breaker.CreatedAt.Before(dayStart)
+}
+
+func (sr *SessionRollover) closed(day string) bool {
+	var count int64
+	sr.riskManager.db.GetDB().Model(&models.SessionSummary{}).Where("trading_day = ?", day).Count(&count)
+	return count > 0
+}
+
+// CloseDay freezes the current trading day now, ahead of the scheduled
+// end-of-day
+func (sr *SessionRollover) CloseDay() (*models.SessionSummary, error) {
+	return sr.closeDay(sr.riskManager.TradingDay(), false)
+}
+
+// closeDay freezes the day's P&L, positions, open orders and breaker state
+// into a session summary and position snapshots
+func (sr *SessionRollover) closeDay(day string, late bool) (*models.SessionSummary, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if sr.closed(day) {
+		return nil, ErrSessionAlreadyClosed
+	}
+
+	db := sr.riskManager.db.GetDB()
+	now := time.Now()
+
+	summary := &models.SessionSummary{
+		TradingDay:     day,
+		OpenOrders:     []models.OpenOrderReservation{},
+		ActiveBreakers: []models.CircuitBreakerEvent{},
+		Late:           late,
+		ClosedAt:       now,
+	}
+
+	var pnl models.DailyPnLTracking
+	if db.Where("date = ?", day).First(&pnl).Error == nil {
+		summary.RealizedPnL = pnl.RealizedPnL
+		summary.UnrealizedPnL = pnl.UnrealizedPnL
+		summary.TotalPnL = pnl.TotalPnL
+		summary.Fees = pnl.Fees
+		summary.CircuitBreakerTriggered = pnl.CircuitBreakerTriggered
+	}
+
+	if executions, err := sr.riskManager.loadExecutionsSince(tradingDate(day).AddDate(0, 0, -1)); err == nil {
+		var dayExecutions []models.Execution
+		for _, execution := range executions {
+			if sr.riskManager.tradingDayOf(execution.Timestamp) == day {
+				dayExecutions = append(dayExecutions, execution)
+			}
+		}
+		summary.Trades, summary.Turnover = tradesAndTurnover(dayExecutions)
+	}
+
+	if sr.orderLedger != nil {
+		summary.OpenOrders = sr.orderLedger.GetOpenOrders()
+	}
+	if breakers, err := sr.riskManager.GetActiveCircuitBreakers(); err == nil {
+		summary.ActiveBreakers = breakers
+	}
+
+	var snapshots []models.PositionSnapshot
+	if sr.positionBook != nil {
+		for _, position := range sr.positionBook.Positions() {
+			snapshots = append(snapshots, models.PositionSnapshot{
+				TradingDay:    day,
+				Symbol:        position.Symbol,
+				Quantity:      position.Quantity,
+				AvgPrice:      position.AvgPrice,
+				MarketPrice:   position.MarketPrice,
+				UnrealizedPnL: position.UnrealizedPnL,
+				RealizedPnL:   position.RealizedPnL,
+				Timestamp:     now,
+			})
+			summary.GrossExposure += math.Abs(position.MarketValue)
+		}
+	}
+	summary.Positions = len(snapshots)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if len(snapshots) > 0 {
+			if err := tx.Create(&snapshots).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(summary).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Trading day %s closed: P&L %.2f, %d positions, %d open orders",
+		day, summary.TotalPnL, summary.Positions, len(summary.OpenOrders))
+	if late {
+		message += " (closed late)"
+	}
+	sr.riskManager.SendAlert("SESSION_CLOSED", "INFO", "", message, map[string]interface{}{
+		"trading_day":     day,
+		"total_pnl":       summary.TotalPnL,
+		"positions":       summary.Positions,
+		"open_orders":     len(summary.OpenOrders),
+		"active_breakers": len(summary.ActiveBreakers),
+		"late":            late,
+	})
+
+	return summary, nil
+}
+
+// startDay resets daily risk state for the new trading day and marks the
+// closed sessions as rolled over
+func (sr *SessionRollover) startDay(closed []models.SessionSummary, day string) error {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	reset, err := sr.riskManager.StartTradingDay()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i, summary := range closed {
+		updates := map[string]interface{}{
+			"next_trading_day": day,
+			"rolled_over_at":   now,
+		}
+		// Breaker resets are recorded against the session that tripped them
+		if i == len(closed)-1 {
+			updates["breakers_reset"] = reset
+		}
+		sr.riskManager.db.GetDB().Model(&models.SessionSummary{}).
+			Where("trading_day = ?", summary.TradingDay).Updates(updates)
+	}
+
+	log.Printf("Started trading day %s (%d daily breakers reset)", day, reset)
+	return nil
+}
+
+// StartTradingDay resets the daily P&L state and the breakers tripped by
+// earlier sessions' P&L for a new trading day. Returns the number of
+// breakers reset.
+func (rm *RiskManager) StartTradingDay() (int, error) {
+	breakers, err := rm.GetActiveCircuitBreakers()
+	if err != nil {
+		return 0, err
+	}
+
+	dayStart := rm.tradingDayStart(time.Now())
+	reset := 0
+	for i := range breakers {
+		if !expiresWithSession(&breakers[i], dayStart) {
+			continue
+		}
+		if err := rm.ResetCircuitBreaker(breakers[i].ID); err != nil {
+			return reset, err
+		}
+		reset++
+	}
+
+	rm.breakerMu.Lock()
+	rm.symbolPnL = make(map[string]float64)
+	rm.breakerMu.Unlock()
+
+	// Drop the cached P&L so the new day's record is created now
+	rm.redis.client.Del(context.Background(), "daily_pnl:latest")
+	if _, err := rm.GetDailyPnL(); err != nil {
+		return reset, err
+	}
+
+	return reset, nil
+}
+
+// GetSessionSummaries returns the most recent session summaries
+func (sr *SessionRollover) GetSessionSummaries(limit int) ([]models.SessionSummary, error) {
+	summaries := []models.SessionSummary{}
+	result := sr.riskManager.db.GetDB().Order("trading_day DESC").Limit(limit).Find(&summaries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return summaries, nil
+}
+
+// GetSession returns the summary and position snapshots of a closed day
+func (sr *SessionRollover) GetSession(day string) (*models.SessionDetail, error) {
+	db := sr.riskManager.db.GetDB()
+
+	var detail models.SessionDetail
+	if result := db.Where("trading_day = ?", day).First(&detail.Summary); result.Error != nil {
+		return nil, result.Error
+	}
+
+	detail.Positions = []models.PositionSnapshot{}
+	db.Where("trading_day = ?", day).Order("symbol").Find(&detail.Positions)
+	return &detail, nil
+}
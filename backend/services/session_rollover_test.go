@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestEndOfDayDue(t *testing.T) {
+	now := time.Date(2026, 3, 10, 20, 0, 0, 0, marketLocation)
+	postClose := now.Add(time.Minute)
+
+	if endOfDayDue(&models.MarketSession{PostClose: &postClose}, now) {
+		t.Error("expected end of day not to be due before the post-market close")
+	}
+	if !endOfDayDue(&models.MarketSession{PostClose: &postClose}, postClose) {
+		t.Error("expected end of day to be due at the post-market close")
+	}
+	if endOfDayDue(&models.MarketSession{Holiday: "Christmas Day"}, now) {
+		t.Error("expected no end of day on a day without a session")
+	}
+}
+
+func TestExpiresWithSession(t *testing.T) {
+	dayStart := time.Date(2026, 3, 11, 4, 0, 0, 0, marketLocation)
+	yesterday := dayStart.Add(-10 * time.Hour)
+
+	tests := []struct {
+		name    string
+		breaker models.CircuitBreakerEvent
+		expires bool
+	}{
+		{"daily loss from yesterday", models.CircuitBreakerEvent{TriggerType: "DAILY_LOSS", CreatedAt: yesterday}, true},
+		{"symbol loss from yesterday", models.CircuitBreakerEvent{TriggerType: "SYMBOL_LOSS", CreatedAt: yesterday}, true},
+		{"daily loss from today", models.CircuitBreakerEvent{TriggerType: "DAILY_LOSS", CreatedAt: dayStart.Add(time.Hour)}, false},
+		{"rejection rate", models.CircuitBreakerEvent{TriggerType: "REJECTION_RATE", CreatedAt: yesterday}, false},
+		{"manual", models.CircuitBreakerEvent{TriggerType: "MANUAL", CreatedAt: yesterday}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expiresWithSession(&tt.breaker, dayStart); got != tt.expires {
+				t.Errorf("expected %v, got %v", tt.expires, got)
+			}
+		})
+	}
+}
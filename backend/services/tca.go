@@ -0,0 +1,332 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// tcaOrderCost is an order's transaction cost with whether a two-sided
+// quote was known at arrival, which spread measures need
+type tcaOrderCost struct {
+	models.TCAOrder
+	twoSided bool
+}
+
+// TransactionCostAnalysis measures what orders lose to execution against the
+// price when they arrived and alerts on fills that slip too far
+type TransactionCostAnalysis struct {
+	db           *DatabaseService
+	markToMarket *MarkToMarket
+	riskManager  *RiskManager
+	config       models.TCAConfig
+	mu           sync.RWMutex
+}
+
+// NewTransactionCostAnalysis creates a new transaction cost analysis service
+func NewTransactionCostAnalysis(db *DatabaseService, markToMarket *MarkToMarket, riskManager *RiskManager, config models.TCAConfig) *TransactionCostAnalysis {
+	if err := validateTCAConfig(config); err != nil {
+		log.Printf("Invalid TCA config (%v), slippage alerts disabled", err)
+		config = models.TCAConfig{}
+	}
+
+	return &TransactionCostAnalysis{
+		db:           db,
+		markToMarket: markToMarket,
+		riskManager:  riskManager,
+		config:       config,
+	}
+}
+
+func validateTCAConfig(config models.TCAConfig) error {
+	if config.SlippageAlertBps < 0 {
+		return fmt.Errorf("slippage_alert_bps cannot be negative")
+	}
+	return nil
+}
+
+// Config returns the slippage alert configuration
+func (tca *TransactionCostAnalysis) Config() models.TCAConfig {
+	tca.mu.RLock()
+	defer tca.mu.RUnlock()
+	return tca.config
+}
+
+// SetConfig changes the slippage alert threshold
+func (tca *TransactionCostAnalysis) SetConfig(config models.TCAConfig) error {
+	if err := validateTCAConfig(config); err != nil {
+		return err
+	}
+
+	tca.mu.Lock()
+	tca.config = config
+	tca.mu.Unlock()
+	return nil
+}
+
+// Arrival returns the arrival price of an order in symbol placed now with
+// the bid and ask behind it. All are zero without a fresh quote.
+func (tca *TransactionCostAnalysis) Arrival(symbol string) (price, bid, ask float64) {
+	if tca.markToMarket == nil {
+		return 0, 0, 0
+	}
+	quote, ok := tca.markToMarket.Quote(symbol)
+	if !ok {
+		return 0, 0, 0
+	}
+	return arrivalPrice(quote), quote.Bid, quote.Ask
+}
+
+// arrivalPrice is the mid of a two-sided quote, or else the last trade
+func arrivalPrice(quote models.Quote) float64 {
+	if quote.Bid > 0 && quote.Ask >= quote.Bid {
+		return (quote.Bid + quote.Ask) / 2
+	}
+	return quote.Last
+}
+
+// sideSign is 1 for buys and -1 for sells, so that costs are positive when
+// they lose money
+func sideSign(side string) float64 {
+	if strings.ToUpper(side) == "SELL" {
+		return -1
+	}
+	return 1
+}
+
+// slippageBps is how far a fill is from the arrival price, in basis points,
+// positive when worse
+func slippageBps(side string, arrival, fillPrice float64) float64 {
+	if arrival <= 0 {
+		return 0
+	}
+	return sideSign(side) * (fillPrice - arrival) / arrival * 10000
+}
+
+// CheckFill alerts when a fill slips beyond the configured threshold
+func (tca *TransactionCostAnalysis) CheckFill(order *models.Order, execution *models.Execution) {
+	threshold := tca.Config().SlippageAlertBps
+	if threshold <= 0 || order.ArrivalPrice <= 0 || tca.riskManager == nil {
+		return
+	}
+
+	bps := slippageBps(execution.Side, order.ArrivalPrice, execution.FillPrice)
+	if bps <= threshold {
+		return
+	}
+
+	tca.riskManager.SendAlert("SLIPPAGE", "WARNING", execution.Symbol,
+		fmt.Sprintf("%s %s filled at %.4f, %.1f bps worse than the %.4f arrival price",
+			execution.Side, execution.Symbol, execution.FillPrice, bps, order.ArrivalPrice),
+		map[string]interface{}{
+			"order_id":      order.OrderID,
+			"strategy":      order.Strategy,
+			"order_type":    order.OrderType,
+			"fill_qty":      execution.FillQty,
+			"fill_price":    execution.FillPrice,
+			"arrival_price": order.ArrivalPrice,
+			"slippage_bps":  bps,
+			"threshold_bps": threshold,
+		})
+}
+
+// Report analyses the transaction costs of orders created between from and
+// to, either of which may be nil, optionally for one symbol or strategy
+func (tca *TransactionCostAnalysis) Report(from, to *time.Time, symbol, strategy string) (*models.TCAReport, error) {
+	report := &models.TCAReport{
+		Config:      tca.Config(),
+		BySymbol:    []models.TCASummary{},
+		ByStrategy:  []models.TCASummary{},
+		ByOrderType: []models.TCASummary{},
+		Orders:      []models.TCAOrder{},
+		Timestamp:   time.Now(),
+	}
+	db := tca.db.GetDB()
+	if db == nil {
+		return report, nil // Database disabled
+	}
+
+	query := db.Where("(arrival_price > 0 OR filled_qty > 0) AND order_id <> '' AND UPPER(status) <> 'REJECTED'")
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+	if symbol != "" {
+		query = query.Where("symbol = ?", strings.ToUpper(symbol))
+	}
+	switch strategy {
+	case "":
+	case ManualStrategy:
+		query = query.Where("strategy = '' OR strategy IS NULL OR strategy = ?", ManualStrategy)
+	default:
+		query = query.Where("strategy = ?", strategy)
+	}
+
+	var orders []models.Order
+	if result := query.Order("created_at DESC").Find(&orders); result.Error != nil {
+		return nil, result.Error
+	}
+	if len(orders) == 0 {
+		return report, nil
+	}
+
+	orderIDs := make([]string, len(orders))
+	for i, order := range orders {
+		orderIDs[i] = order.OrderID
+	}
+	var executions []models.Execution
+	if result := db.Where("order_id IN ?", orderIDs).Order("id").Find(&executions); result.Error != nil {
+		return nil, result.Error
+	}
+	fills := make(map[string][]models.Execution)
+	executionIDs := make([]uint, len(executions))
+	for i, execution := range executions {
+		fills[execution.OrderID] = append(fills[execution.OrderID], execution)
+		executionIDs[i] = execution.ID
+	}
+
+	fees := make(map[uint]float64)
+	if len(executionIDs) > 0 {
+		var rows []models.ExecutionFee
+		if result := db.Where("execution_id IN ?", executionIDs).Find(&rows); result.Error != nil {
+			return nil, result.Error
+		}
+		for _, row := range rows {
+			fees[row.ExecutionID] = row.TotalFees
+		}
+	}
+
+	prices := make(map[string]float64)
+	var costs []tcaOrderCost
+	for _, order := range orders {
+		if order.ArrivalPrice <= 0 {
+			if len(fills[order.OrderID]) > 0 {
+				report.UnpricedOrders++
+			}
+			continue
+		}
+		if _, ok := prices[order.Symbol]; !ok {
+			prices[order.Symbol], _, _ = tca.Arrival(order.Symbol)
+		}
+		costs = append(costs, orderCost(order, fills[order.OrderID], fees, prices[order.Symbol]))
+	}
+
+	report.Overall = summarizeCosts("", costs)
+	report.BySymbol = summarizeCostsBy(costs, func(cost tcaOrderCost) string { return cost.Symbol })
+	report.ByStrategy = summarizeCostsBy(costs, func(cost tcaOrderCost) string { return cost.Strategy })
+	report.ByOrderType = summarizeCostsBy(costs, func(cost tcaOrderCost) string { return cost.OrderType })
+	for _, cost := range costs {
+		report.Orders = append(report.Orders, cost.TCAOrder)
+	}
+	return report, nil
+}
+
+// orderCost measures an order's fills against its arrival price. Unfilled
+// quantity is marked at currentPrice, when known, as opportunity cost.
+func orderCost(order models.Order, fills []models.Execution, fees map[uint]float64, currentPrice float64) tcaOrderCost {
+	strategy := order.Strategy
+	if strategy == "" {
+		strategy = ManualStrategy
+	}
+	cost := tcaOrderCost{
+		TCAOrder: models.TCAOrder{
+			OrderID:       order.OrderID,
+			ClientOrderID: order.ClientOrderID,
+			Symbol:        order.Symbol,
+			Side:          strings.ToUpper(order.Side),
+			OrderType:     strings.ToUpper(order.OrderType),
+			Strategy:      strategy,
+			Quantity:      order.Quantity,
+			Fills:         len(fills),
+			ArrivalPrice:  order.ArrivalPrice,
+			CreatedAt:     order.CreatedAt,
+		},
+		twoSided: order.ArrivalBid > 0 && order.ArrivalAsk >= order.ArrivalBid,
+	}
+	sign := sideSign(order.Side)
+
+	notional := 0.0
+	for _, fill := range fills {
+		cost.FilledQty += fill.FillQty
+		notional += fill.FillQty * fill.FillPrice
+		cost.SlippageCost += sign * (fill.FillPrice - order.ArrivalPrice) * fill.FillQty
+		cost.Fees += fees[fill.ID]
+	}
+	if cost.twoSided {
+		// The arrival price is the mid
+		cost.SpreadPaid = cost.SlippageCost
+	}
+
+	if unfilled := order.Quantity - cost.FilledQty; unfilled > lotEpsilon && currentPrice > 0 {
+		cost.OpportunityCost = sign * (currentPrice - order.ArrivalPrice) * unfilled
+	}
+	cost.ImplementationShortfall = cost.SlippageCost + cost.OpportunityCost + cost.Fees
+
+	if cost.FilledQty > 0 {
+		cost.AvgFillPrice = notional / cost.FilledQty
+		filledValue := order.ArrivalPrice * cost.FilledQty
+		cost.SlippageBps = cost.SlippageCost / filledValue * 10000
+		if cost.twoSided {
+			cost.EffectiveSpreadBps = 2 * cost.SpreadPaid / filledValue * 10000
+		}
+	}
+	if order.Quantity > 0 {
+		cost.ShortfallBps = cost.ImplementationShortfall / (order.ArrivalPrice * order.Quantity) * 10000
+	}
+	return cost
+}
+
+// summarizeCosts adds up order costs, weighting bps by arrival value
+func summarizeCosts(key string, costs []tcaOrderCost) models.TCASummary {
+	summary := models.TCASummary{Key: key, Orders: len(costs)}
+	var filledValue, spreadValue, orderValue float64
+	for _, cost := range costs {
+		summary.Fills += cost.Fills
+		summary.FilledQty += cost.FilledQty
+		summary.Notional += cost.AvgFillPrice * cost.FilledQty
+		summary.SlippageCost += cost.SlippageCost
+		summary.SpreadPaid += cost.SpreadPaid
+		summary.OpportunityCost += cost.OpportunityCost
+		summary.Fees += cost.Fees
+		summary.ImplementationShortfall += cost.ImplementationShortfall
+
+		filledValue += cost.ArrivalPrice * cost.FilledQty
+		orderValue += cost.ArrivalPrice * cost.Quantity
+		if cost.twoSided {
+			spreadValue += cost.ArrivalPrice * cost.FilledQty
+		}
+	}
+
+	if filledValue > 0 {
+		summary.SlippageBps = summary.SlippageCost / filledValue * 10000
+	}
+	if spreadValue > 0 {
+		summary.EffectiveSpreadBps = 2 * summary.SpreadPaid / spreadValue * 10000
+	}
+	if orderValue > 0 {
+		summary.ShortfallBps = summary.ImplementationShortfall / orderValue * 10000
+	}
+	return summary
+}
+
+// summarizeCostsBy summarises order costs per key, in key order
+func summarizeCostsBy(costs []tcaOrderCost, key func(tcaOrderCost) string) []models.TCASummary {
+	groups := make(map[string][]tcaOrderCost)
+	for _, cost := range costs {
+		groups[key(cost)] = append(groups[key(cost)], cost)
+	}
+
+	summaries := make([]models.TCASummary, 0, len(groups))
+	for name, group := range groups {
+		summaries = append(summaries, summarizeCosts(name, group))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Key < summaries[j].Key })
+	return summaries
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/hft/backend/models"
+)
+
+func TestArrivalPrice(t *testing.T) {
+	if price := arrivalPrice(models.Quote{Bid: 99.9, Ask: 100.1, Last: 100.5}); !closeTo(price, 100) {
+		t.Errorf("expected the mid, got %.4f", price)
+	}
+	if price := arrivalPrice(models.Quote{Bid: 99.9, Last: 100.5}); price != 100.5 {
+		t.Errorf("expected the last trade without an ask, got %.4f", price)
+	}
+}
+
+func TestSlippageBps(t *testing.T) {
+	if bps := slippageBps("BUY", 100, 100.1); !closeTo(bps, 10) {
+		t.Errorf("expected a buy above arrival to cost 10 bps, got %.4f", bps)
+	}
+	if bps := slippageBps("SELL", 100, 100.1); !closeTo(bps, -10) {
+		t.Errorf("expected a sell above arrival to gain 10 bps, got %.4f", bps)
+	}
+	if bps := slippageBps("BUY", 0, 100); bps != 0 {
+		t.Errorf("expected no slippage without an arrival price, got %.4f", bps)
+	}
+}
+
+func TestOrderCost(t *testing.T) {
+	order := models.Order{
+		OrderID: "o1", Symbol: "AAPL", Side: "BUY", OrderType: "LIMIT", Quantity: 300,
+		ArrivalPrice: 100, ArrivalBid: 99.98, ArrivalAsk: 100.02,
+	}
+	fills := []models.Execution{
+		{ID: 1, OrderID: "o1", Side: "BUY", FillQty: 100, FillPrice: 100.02},
+		{ID: 2, OrderID: "o1", Side: "BUY", FillQty: 100, FillPrice: 100.04},
+	}
+	fees := map[uint]float64{1: 0.5, 2: 0.5}
+
+	cost := orderCost(order, fills, fees, 101)
+	if cost.FilledQty != 200 || cost.Fills != 2 || !closeTo(cost.AvgFillPrice, 100.03) {
+		t.Errorf("unexpected fills %+v", cost.TCAOrder)
+	}
+	if !closeTo(cost.SlippageCost, 6) || !closeTo(cost.SlippageBps, 3) {
+		t.Errorf("expected $6 and 3 bps of slippage, got %.4f and %.4f", cost.SlippageCost, cost.SlippageBps)
+	}
+	if !closeTo(cost.SpreadPaid, 6) || !closeTo(cost.EffectiveSpreadBps, 6) {
+		t.Errorf("expected $6 spread paid and a 6 bps effective spread, got %.4f and %.4f", cost.SpreadPaid, cost.EffectiveSpreadBps)
+	}
+	// The 100 unfilled shares missed a move from 100 to 101
+	if !closeTo(cost.OpportunityCost, 100) || !closeTo(cost.ImplementationShortfall, 6+100+1) {
+		t.Errorf("expected $100 opportunity cost and $107 shortfall, got %.4f and %.4f", cost.OpportunityCost, cost.ImplementationShortfall)
+	}
+	if !closeTo(cost.ShortfallBps, 107/(100*300.0)*10000) {
+		t.Errorf("unexpected shortfall %.4f bps", cost.ShortfallBps)
+	}
+
+	// A sell filled above arrival without a quote has negative cost and no
+	// spread measure
+	sell := models.Order{OrderID: "o2", Symbol: "AAPL", Side: "SELL", OrderType: "MARKET", Quantity: 100, ArrivalPrice: 100}
+	cost = orderCost(sell, []models.Execution{{ID: 3, Side: "SELL", FillQty: 100, FillPrice: 100.1}}, nil, 0)
+	if !closeTo(cost.SlippageCost, -10) || cost.SpreadPaid != 0 || cost.OpportunityCost != 0 || cost.Strategy != ManualStrategy {
+		t.Errorf("unexpected sell cost %+v", cost.TCAOrder)
+	}
+}
+
+func TestSummarizeCosts(t *testing.T) {
+	costs := []tcaOrderCost{
+		{TCAOrder: models.TCAOrder{Symbol: "AAPL", Quantity: 100, FilledQty: 100, AvgFillPrice: 100.1, ArrivalPrice: 100, SlippageCost: 10, SpreadPaid: 10, ImplementationShortfall: 10}, twoSided: true},
+		{TCAOrder: models.TCAOrder{Symbol: "MSFT", Quantity: 100, FilledQty: 100, AvgFillPrice: 50, ArrivalPrice: 50, SlippageCost: 0, ImplementationShortfall: 0}},
+	}
+
+	summary := summarizeCosts("", costs)
+	if summary.Orders != 2 || !closeTo(summary.Notional, 15010) {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	// $10 over $15,000 of arrival value; the spread only over the quoted order
+	if !closeTo(summary.SlippageBps, 10/15000.0*10000) || !closeTo(summary.EffectiveSpreadBps, 2*10/10000.0*10000) {
+		t.Errorf("unexpected weighting: %.4f bps slippage, %.4f bps spread", summary.SlippageBps, summary.EffectiveSpreadBps)
+	}
+
+	bySymbol := summarizeCostsBy(costs, func(cost tcaOrderCost) string { return cost.Symbol })
+	if len(bySymbol) != 2 || bySymbol[0].Key != "AAPL" || !closeTo(bySymbol[0].SlippageBps, 10) {
+		t.Errorf("unexpected per-symbol summaries %+v", bySymbol)
+	}
+}
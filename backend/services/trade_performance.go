@@ -0,0 +1,347 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// tradingDaysPerYear annualises daily Sharpe and Sortino ratios
+const tradingDaysPerYear = 252
+
+// openRoundTrip is a round trip still being built from fills
+type openRoundTrip struct {
+	trip          models.RoundTrip
+	entryQty      float64
+	entryNotional float64
+	exitQty       float64
+	exitNotional  float64
+}
+
+// TradePerformance pairs entries and exits from the P&L ledger into round
+// trips and computes trading statistics from them
+type TradePerformance struct {
+	db       *DatabaseService
+	ledger   *PnLLedger
+	calendar *MarketCalendar
+}
+
+// NewTradePerformance creates a new trade performance service
+func NewTradePerformance(db *DatabaseService, ledger *PnLLedger, calendar *MarketCalendar) *TradePerformance {
+	return &TradePerformance{
+		db:       db,
+		ledger:   ledger,
+		calendar: calendar,
+	}
+}
+
+// Report returns the statistics of round trips closed between from and to,
+// either of which may be nil. The trades themselves are included when
+// withTrades is set.
+func (tp *TradePerformance) Report(from, to *time.Time, withTrades bool) (*models.PerformanceReport, error) {
+	report := &models.PerformanceReport{
+		From:       from,
+		To:         to,
+		BySymbol:   map[string]models.PerformanceStats{},
+		ByStrategy: map[string]models.PerformanceStats{},
+		Timestamp:  time.Now(),
+	}
+	db := tp.db.GetDB()
+	if db == nil {
+		return report, nil // Database disabled
+	}
+
+	if err := tp.ledger.Sync(); err != nil {
+		return nil, err
+	}
+
+	var rows []models.ExecutionPnL
+	if result := db.Order("execution_id").Find(&rows); result.Error != nil {
+		return nil, result.Error
+	}
+
+	var executions []models.Execution
+	if result := db.Select("id", "strategy").Find(&executions); result.Error != nil {
+		return nil, result.Error
+	}
+	strategies := make(map[uint]string, len(executions))
+	for _, execution := range executions {
+		strategies[execution.ID] = execution.Strategy
+	}
+
+	closed, open := matchRoundTrips(rows, strategies)
+	report.OpenTrades = open
+
+	trips := make([]models.RoundTrip, 0, len(closed))
+	for _, trip := range closed {
+		if from != nil && trip.ExitTime.Before(*from) {
+			continue
+		}
+		if to != nil && !trip.ExitTime.Before(*to) {
+			continue
+		}
+		trips = append(trips, trip)
+	}
+	if len(trips) == 0 {
+		return report, nil
+	}
+
+	days := tp.tradingDays(trips)
+	report.TradingDays = len(days)
+	report.Overall = performanceStats(trips, days, tp.tradingDay)
+
+	bySymbol := make(map[string][]models.RoundTrip)
+	byStrategy := make(map[string][]models.RoundTrip)
+	for _, trip := range trips {
+		bySymbol[trip.Symbol] = append(bySymbol[trip.Symbol], trip)
+		byStrategy[trip.Strategy] = append(byStrategy[trip.Strategy], trip)
+	}
+	for symbol, group := range bySymbol {
+		report.BySymbol[symbol] = performanceStats(group, days, tp.tradingDay)
+	}
+	for strategy, group := range byStrategy {
+		report.ByStrategy[strategy] = performanceStats(group, days, tp.tradingDay)
+	}
+
+	if withTrades {
+		report.Trades = trips
+	}
+	return report, nil
+}
+
+func (tp *TradePerformance) tradingDay(t time.Time) string {
+	if tp.calendar == nil {
+		return t.In(marketLocation).Format("2006-01-02")
+	}
+	return tp.calendar.TradingDay(t)
+}
+
+// tradingDays lists every trading day from the first exit to the last
+func (tp *TradePerformance) tradingDays(trips []models.RoundTrip) []string {
+	first, last := trips[0].ExitTime, trips[0].ExitTime
+	for _, trip := range trips {
+		if trip.ExitTime.Before(first) {
+			first = trip.ExitTime
+		}
+		if trip.ExitTime.After(last) {
+			last = trip.ExitTime
+		}
+	}
+
+	isTradingDay := func(date string) bool {
+		weekday := tradingDate(date).Weekday()
+		return weekday != time.Saturday && weekday != time.Sunday
+	}
+	if tp.calendar != nil {
+		isTradingDay = tp.calendar.IsTradingDay
+	}
+	return tradingDaysBetween(tp.tradingDay(first), tp.tradingDay(last), isTradingDay)
+}
+
+// tradingDaysBetween lists the trading days from first to last inclusive
+func tradingDaysBetween(first, last string, isTradingDay func(string) bool) []string {
+	var days []string
+	for day := tradingDate(first); !day.After(tradingDate(last)); day = day.AddDate(0, 0, 1) {
+		if date := day.Format("2006-01-02"); isTradingDay(date) {
+			days = append(days, date)
+		}
+	}
+	return days
+}
+
+// matchRoundTrips walks the ledger's fills in booking order and pairs the
+// fills that open a position with those that close it. Returns the closed
+// round trips in exit order and the number still open.
+func matchRoundTrips(rows []models.ExecutionPnL, strategies map[uint]string) ([]models.RoundTrip, int) {
+	var closed []models.RoundTrip
+	open := make(map[string]*openRoundTrip)
+
+	for _, row := range rows {
+		if row.FillQty <= 0 {
+			continue
+		}
+		signed := row.FillQty
+		if strings.ToUpper(row.Side) == "SELL" {
+			signed = -signed
+		}
+		before := row.PositionAfter - signed
+		openedQty := row.FillQty - row.ClosedQty
+
+		current := open[row.Symbol]
+		if current == nil && math.Abs(before) > lotEpsilon {
+			// The position was carried in, e.g. from a symbol change
+			current = startRoundTrip(row, before, strategies)
+			current.trip.Quantity = math.Abs(before)
+			open[row.Symbol] = current
+		}
+
+		if row.ClosedQty > 0 && current != nil {
+			current.exitQty += row.ClosedQty
+			current.exitNotional += row.ClosedQty * row.FillPrice
+			current.trip.GrossPnL += row.RealizedPnL
+			current.trip.Fees += row.Fees * row.ClosedQty / row.FillQty
+			current.trip.Executions++
+
+			if math.Abs(row.PositionAfter) <= lotEpsilon || openedQty > lotEpsilon {
+				closed = append(closed, finishRoundTrip(current, row.Timestamp))
+				delete(open, row.Symbol)
+				current = nil
+			}
+		}
+
+		if openedQty > lotEpsilon {
+			if current == nil {
+				current = startRoundTrip(row, signed, strategies)
+				open[row.Symbol] = current
+			}
+			current.entryQty += openedQty
+			current.entryNotional += openedQty * row.FillPrice
+			current.trip.Fees += row.Fees * openedQty / row.FillQty
+			current.trip.Quantity = math.Max(current.trip.Quantity, math.Abs(row.PositionAfter))
+			current.trip.Executions++
+		}
+	}
+
+	sort.SliceStable(closed, func(i, j int) bool { return closed[i].ExitTime.Before(closed[j].ExitTime) })
+	return closed, len(open)
+}
+
+func startRoundTrip(row models.ExecutionPnL, direction float64, strategies map[uint]string) *openRoundTrip {
+	strategy := strategies[row.ExecutionID]
+	if strategy == "" {
+		strategy = ManualStrategy
+	}
+	side := "LONG"
+	if direction < 0 {
+		side = "SHORT"
+	}
+	return &openRoundTrip{trip: models.RoundTrip{
+		Symbol:    row.Symbol,
+		Strategy:  strategy,
+		Side:      side,
+		EntryTime: row.Timestamp,
+	}}
+}
+
+func finishRoundTrip(current *openRoundTrip, exitTime time.Time) models.RoundTrip {
+	trip := current.trip
+	if current.entryQty > 0 {
+		trip.EntryPrice = current.entryNotional / current.entryQty
+	}
+	if current.exitQty > 0 {
+		trip.ExitPrice = current.exitNotional / current.exitQty
+	}
+	trip.ExitTime = exitTime
+	trip.HoldingSeconds = exitTime.Sub(trip.EntryTime).Seconds()
+	trip.NetPnL = trip.GrossPnL - trip.Fees
+	return trip
+}
+
+// performanceStats summarises round trips in exit order. Daily P&L is
+// counted on days, with zero for days without exits.
+func performanceStats(trips []models.RoundTrip, days []string, tradingDay func(time.Time) string) models.PerformanceStats {
+	stats := models.PerformanceStats{Trades: len(trips)}
+	if len(trips) == 0 {
+		return stats
+	}
+
+	var grossWins, grossLosses, holding, cumulative, peak float64
+	streak := 0
+	daily := make(map[string]float64)
+	for _, trip := range trips {
+		stats.GrossPnL += trip.GrossPnL
+		stats.Fees += trip.Fees
+		stats.NetPnL += trip.NetPnL
+		holding += trip.HoldingSeconds
+		daily[tradingDay(trip.ExitTime)] += trip.NetPnL
+
+		switch {
+		case trip.NetPnL > 0:
+			stats.Wins++
+			grossWins += trip.NetPnL
+			stats.LargestWin = math.Max(stats.LargestWin, trip.NetPnL)
+			if streak < 0 {
+				streak = 0
+			}
+			streak++
+		case trip.NetPnL < 0:
+			stats.Losses++
+			grossLosses += trip.NetPnL
+			stats.LargestLoss = math.Min(stats.LargestLoss, trip.NetPnL)
+			if streak > 0 {
+				streak = 0
+			}
+			streak--
+		default:
+			streak = 0
+		}
+		if streak > stats.MaxWinStreak {
+			stats.MaxWinStreak = streak
+		}
+		if -streak > stats.MaxLossStreak {
+			stats.MaxLossStreak = -streak
+		}
+
+		cumulative += trip.NetPnL
+		peak = math.Max(peak, cumulative)
+		stats.MaxDrawdown = math.Max(stats.MaxDrawdown, peak-cumulative)
+	}
+	stats.CurrentStreak = streak
+
+	n := float64(len(trips))
+	stats.WinRate = float64(stats.Wins) / n * 100
+	if stats.Wins > 0 {
+		stats.AvgWin = grossWins / float64(stats.Wins)
+	}
+	if stats.Losses > 0 {
+		stats.AvgLoss = grossLosses / float64(stats.Losses)
+		stats.ProfitFactor = grossWins / -grossLosses
+	}
+	stats.Expectancy = stats.NetPnL / n
+	stats.AvgHoldingSeconds = holding / n
+
+	returns := make([]float64, len(days))
+	for i, day := range days {
+		returns[i] = daily[day]
+	}
+	stats.SharpeRatio, stats.SortinoRatio = riskAdjustedReturns(returns)
+	return stats
+}
+
+// riskAdjustedReturns returns the annualised Sharpe and Sortino ratios of
+// daily returns, or zero when they are undefined
+func riskAdjustedReturns(returns []float64) (float64, float64) {
+	if len(returns) < 2 {
+		return 0, 0
+	}
+
+	n := float64(len(returns))
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= n
+
+	variance, downside := 0.0, 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	stdDev := math.Sqrt(variance / (n - 1))
+	downsideDev := math.Sqrt(downside / n)
+
+	annualise := math.Sqrt(tradingDaysPerYear)
+	sharpe, sortino := 0.0, 0.0
+	if stdDev > 0 {
+		sharpe = mean / stdDev * annualise
+	}
+	if downsideDev > 0 {
+		sortino = mean / downsideDev * annualise
+	}
+	return sharpe, sortino
+}
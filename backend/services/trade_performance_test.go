@@ -0,0 +1,112 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// bookFills books fills through a FIFO lot book, one hour apart, with a
+// dollar of fees on each
+func bookFills(fills ...models.Execution) []models.ExecutionPnL {
+	book := newLotBook(models.CostMethodFIFO)
+	start := time.Date(2026, 6, 1, 14, 0, 0, 0, time.UTC)
+	rows := make([]models.ExecutionPnL, 0, len(fills))
+	for i, exec := range fills {
+		exec.Timestamp = start.Add(time.Duration(i) * time.Hour)
+		row := book.apply(exec)
+		row.Fees = 1
+		row.NetPnL = row.RealizedPnL - row.Fees
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestMatchRoundTrips(t *testing.T) {
+	rows := bookFills(
+		fill(1, "BUY", 100, 10),
+		fill(2, "BUY", 100, 12),
+		fill(3, "SELL", 200, 13), // Closes the long: 400 - 3 fees
+		fill(4, "SELL", 50, 13),  // Opens a short
+		fill(5, "BUY", 100, 12),  // Covers for 50 and flips long with 50
+		fill(6, "SELL", 20, 11),  // Reduces the open long
+	)
+	strategies := map[uint]string{1: "momentum", 4: "meanrev"}
+
+	trips, open := matchRoundTrips(rows, strategies)
+	if len(trips) != 2 || open != 1 {
+		t.Fatalf("expected 2 closed and 1 open round trip, got %d and %d", len(trips), open)
+	}
+
+	long := trips[0]
+	if long.Side != "LONG" || long.Strategy != "momentum" || long.Quantity != 200 || long.Executions != 3 {
+		t.Errorf("unexpected long round trip %+v", long)
+	}
+	if !closeTo(long.EntryPrice, 11) || !closeTo(long.ExitPrice, 13) || !closeTo(long.NetPnL, 400-3) {
+		t.Errorf("expected 11 to 13 for 397 net, got %.2f to %.2f for %.2f", long.EntryPrice, long.ExitPrice, long.NetPnL)
+	}
+	if long.HoldingSeconds != 2*3600 {
+		t.Errorf("expected a two hour hold, got %.0fs", long.HoldingSeconds)
+	}
+
+	short := trips[1]
+	if short.Side != "SHORT" || short.Strategy != "meanrev" || short.Quantity != 50 {
+		t.Errorf("unexpected short round trip %+v", short)
+	}
+	// Half of the flipping fill's fee belongs to the short
+	if !closeTo(short.GrossPnL, 50) || !closeTo(short.Fees, 1.5) || !closeTo(short.NetPnL, 48.5) {
+		t.Errorf("expected 50 gross less 1.50 fees, got %.2f less %.2f", short.GrossPnL, short.Fees)
+	}
+}
+
+func TestPerformanceStats(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 6, d, 15, 0, 0, 0, time.UTC) }
+	trips := []models.RoundTrip{
+		{NetPnL: 100, GrossPnL: 102, Fees: 2, HoldingSeconds: 60, ExitTime: day(1)},
+		{NetPnL: -50, GrossPnL: -48, Fees: 2, HoldingSeconds: 120, ExitTime: day(1)},
+		{NetPnL: -30, GrossPnL: -28, Fees: 2, HoldingSeconds: 60, ExitTime: day(2)},
+		{NetPnL: 80, GrossPnL: 82, Fees: 2, HoldingSeconds: 60, ExitTime: day(4)},
+	}
+	days := tradingDaysBetween("2026-06-01", "2026-06-04", func(string) bool { return true })
+	stats := performanceStats(trips, days, func(t time.Time) string { return t.Format("2006-01-02") })
+
+	if stats.Trades != 4 || stats.Wins != 2 || stats.Losses != 2 || stats.WinRate != 50 {
+		t.Errorf("unexpected counts %+v", stats)
+	}
+	if stats.AvgWin != 90 || stats.AvgLoss != -40 || stats.LargestWin != 100 || stats.LargestLoss != -50 {
+		t.Errorf("unexpected averages %+v", stats)
+	}
+	if !closeTo(stats.ProfitFactor, 180.0/80) || stats.Expectancy != 25 || stats.NetPnL != 100 || stats.Fees != 8 {
+		t.Errorf("unexpected totals %+v", stats)
+	}
+	if stats.MaxDrawdown != 80 || stats.MaxWinStreak != 1 || stats.MaxLossStreak != 2 || stats.CurrentStreak != 1 {
+		t.Errorf("unexpected drawdown or streaks %+v", stats)
+	}
+	if stats.AvgHoldingSeconds != 75 {
+		t.Errorf("expected 75s average hold, got %.0f", stats.AvgHoldingSeconds)
+	}
+
+	// Daily P&L 50, -30, 0, 80
+	mean := 25.0
+	stdDev := math.Sqrt((25*25 + 55*55 + 25*25 + 55*55) / 3.0)
+	downside := math.Sqrt(30 * 30 / 4.0)
+	if !closeTo(stats.SharpeRatio, mean/stdDev*math.Sqrt(252)) {
+		t.Errorf("unexpected Sharpe %.4f", stats.SharpeRatio)
+	}
+	if !closeTo(stats.SortinoRatio, mean/downside*math.Sqrt(252)) {
+		t.Errorf("unexpected Sortino %.4f", stats.SortinoRatio)
+	}
+}
+
+func TestTradingDaysBetween(t *testing.T) {
+	weekdays := func(date string) bool {
+		weekday := tradingDate(date).Weekday()
+		return weekday != time.Saturday && weekday != time.Sunday
+	}
+	days := tradingDaysBetween("2026-06-05", "2026-06-09", weekdays)
+	if len(days) != 3 || days[0] != "2026-06-05" || days[1] != "2026-06-08" {
+		t.Errorf("expected Friday, Monday and Tuesday, got %v", days)
+	}
+}
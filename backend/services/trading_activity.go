@@ -0,0 +1,367 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+// pdtWindowDays is the number of business days in the pattern-day-trader window
+const pdtWindowDays = 5
+
+// pdtWindowStart returns the first of the n trading days ending on day,
+// skipping weekends and exchange holidays. Without a calendar the built-in
+// holiday rules apply.
+func pdtWindowStart(day time.Time, n int, calendar *MarketCalendar) time.Time {
+	if calendar == nil {
+		calendar = &MarketCalendar{}
+	}
+	d := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+
+	counted := 0
+	for {
+		if calendar.IsTradingDay(d.Format("2006-01-02")) {
+			counted++
+			if counted == n {
+				return d
+			}
+		}
+		d = d.AddDate(0, 0, -1)
+	}
+}
+
+// dayTradeState is a symbol's position as fills are replayed, with the part
+// of it opened on the current trading day
+type dayTradeState struct {
+	day      string
+	position float64 // Signed; negative is short
+	opened   float64 // Quantity of the position opened on day
+	closing  bool    // The last fill closed quantity opened on day
+}
+
+// apply replays a fill on day and reports whether it is a new day trade.
+// Quantity opened today is closed first, and a run of closing fills after
+// an opening one is a single day trade, so buy, buy, sell, sell is one day
+// trade and buy, sell, buy, sell is two. Closing a position carried
+// overnight is not a day trade.
+func (s *dayTradeState) apply(day, side string, quantity float64) bool {
+	if day != s.day {
+		s.day, s.opened, s.closing = day, 0, false
+	}
+
+	signed := quantity
+	if side != "BUY" {
+		signed = -quantity
+	}
+	if s.position == 0 || (s.position > 0) == (signed > 0) {
+		s.position += signed
+		s.opened += quantity
+		s.closing = false
+		return false
+	}
+
+	closed := math.Min(quantity, math.Abs(s.position))
+	closedToday := math.Min(closed, s.opened)
+	dayTrade := closedToday > 0 && !s.closing
+
+	s.opened -= closedToday
+	if closedToday > 0 {
+		s.closing = true
+	}
+	s.position += signed
+
+	// A fill through flat opens the remainder the other way
+	if reversed := quantity - closed; reversed > 0 {
+		s.opened = reversed
+		s.closing = false
+	}
+
+	return dayTrade
+}
+
+// replayDayTrades replays executions in time order from the positions held
+// before the first of them. It returns the day trades per trading day (as
+// given by dayOf) and each symbol's state after its last fill.
+func replayDayTrades(executions []models.Execution, opening map[string]float64, dayOf func(time.Time) string) (map[string]int, map[string]*dayTradeState) {
+	sorted := make([]models.Execution, len(executions))
+	copy(sorted, executions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	states := make(map[string]*dayTradeState)
+	counts := make(map[string]int)
+	for _, execution := range sorted {
+		state, ok := states[execution.Symbol]
+		if !ok {
+			state = &dayTradeState{position: opening[execution.Symbol]}
+			states[execution.Symbol] = state
+		}
+
+		date := dayOf(execution.Timestamp)
+		if state.apply(date, execution.Side, execution.FillQty) {
+			counts[date]++
+		}
+	}
+
+	return counts, states
+}
+
+// countDayTrades returns the number of same-day round trips per trading day
+func countDayTrades(executions []models.Execution, opening map[string]float64, dayOf func(time.Time) string) map[string]int {
+	counts, _ := replayDayTrades(executions, opening, dayOf)
+	return counts
+}
+
+// isDayTrade reports whether an order would be a new day trade: it closes
+// quantity opened in the symbol today, given the symbol's replayed state
+func isDayTrade(order *models.OrderRequest, state *dayTradeState, today string) bool {
+	if state == nil || state.day != today || state.opened <= 0 || state.closing {
+		return false
+	}
+	return (state.position > 0) != (order.Side == "BUY")
+}
+
+// positionsBefore returns the net executed quantity of each symbol before t
+func (rm *RiskManager) positionsBefore(t time.Time, symbols []string) map[string]float64 {
+	positions := make(map[string]float64)
+	if len(symbols) == 0 {
+		return positions
+	}
+
+	var rows []struct {
+		Symbol   string
+		Quantity float64
+	}
+	rm.db.GetDB().Raw(`
+		SELECT symbol, SUM(CASE WHEN side = 'BUY' THEN fill_qty ELSE -fill_qty END) AS quantity
+		FROM executions
+		WHERE timestamp < ? AND symbol IN ?
+		GROUP BY symbol`, t, symbols).Scan(&rows)
+
+	for _, row := range rows {
+		positions[row.Symbol] = row.Quantity
+	}
+	return positions
+}
+
+// replayDayTradeWindow loads the executions of the PDT window and replays
+// them from the positions carried into it
+func (rm *RiskManager) replayDayTradeWindow(now time.Time) ([]models.Execution, map[string]int, map[string]*dayTradeState, error) {
+	windowStart := rm.dayTradeWindowStart(now)
+	executions, err := rm.loadExecutionsSince(windowStart)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	seen := make(map[string]bool)
+	symbols := []string{}
+	for _, execution := range executions {
+		if !seen[execution.Symbol] {
+			seen[execution.Symbol] = true
+			symbols = append(symbols, execution.Symbol)
+		}
+	}
+
+	counts, states := replayDayTrades(executions, rm.positionsBefore(windowStart, symbols), rm.tradingDayOf)
+	return executions, counts, states, nil
+}
+
+// tradesAndTurnover returns the number of orders traded and the gross notional
+func tradesAndTurnover(executions []models.Execution) (int, float64) {
+	orders := make(map[string]bool)
+	var trades int
+	var turnover float64
+
+	for _, execution := range executions {
+		turnover += execution.FillPrice * execution.FillQty
+
+		// Partial fills of one order count as a single trade
+		if execution.OrderID == "" {
+			trades++
+		} else if !orders[execution.OrderID] {
+			orders[execution.OrderID] = true
+			trades++
+		}
+	}
+
+	return trades, turnover
+}
+
+// loadExecutionsSince returns executions at or after since
+func (rm *RiskManager) loadExecutionsSince(since time.Time) ([]models.Execution, error) {
+	var executions []models.Execution
+	result := rm.db.GetDB().Where("timestamp >= ?", since).Order("timestamp").Find(&executions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return executions, nil
+}
+
+// startOfDay returns midnight of the current day
+func startOfDay(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// tradingDayStart returns the start of the current trading day from the
+// market calendar, or local midnight without one
+func (rm *RiskManager) tradingDayStart(now time.Time) time.Time {
+	if rm.calendar == nil {
+		return startOfDay(now)
+	}
+	return rm.calendar.TradingDayStart(now)
+}
+
+// dayTradeWindowStart returns the start of the rolling PDT window
+func (rm *RiskManager) dayTradeWindowStart(now time.Time) time.Time {
+	if rm.calendar == nil {
+		return pdtWindowStart(now, pdtWindowDays, nil)
+	}
+	day, _ := time.ParseInLocation("2006-01-02", rm.calendar.TradingDay(now), marketLocation)
+	return atTime(pdtWindowStart(day, pdtWindowDays, rm.calendar), preMarketOpen)
+}
+
+// tradingDayOf returns the trading day of a timestamp
+func (rm *RiskManager) tradingDayOf(t time.Time) string {
+	if rm.calendar == nil {
+		return t.Local().Format("2006-01-02")
+	}
+	return rm.calendar.TradingDay(t)
+}
+
+// GetTradingActivity returns the day-trade count over the PDT window and
+// today's trade count and turnover
+func (rm *RiskManager) GetTradingActivity() (*models.TradingActivity, error) {
+	limits := rm.GetLimits()
+	now := time.Now()
+
+	executions, dayTrades, _, err := rm.replayDayTradeWindow(now)
+	if err != nil {
+		return nil, err
+	}
+
+	activity := &models.TradingActivity{
+		DayTradesByDate:  dayTrades,
+		WindowStart:      rm.tradingDayOf(rm.dayTradeWindowStart(now)),
+		DayTradeLimit:    limits.PDTMaxDayTrades,
+		MaxTradesPerDay:  limits.MaxTradesPerDay,
+		MaxDailyTurnover: limits.MaxDailyTurnover,
+		Timestamp:        now,
+	}
+	for _, count := range activity.DayTradesByDate {
+		activity.DayTrades += count
+	}
+
+	dayStart := rm.tradingDayStart(now)
+	var today []models.Execution
+	for _, execution := range executions {
+		if !execution.Timestamp.Before(dayStart) {
+			today = append(today, execution)
+		}
+	}
+	activity.TradesToday, activity.TurnoverToday = tradesAndTurnover(today)
+
+	if rm.ledger != nil {
+		activity.Equity, activity.EquityKnown = rm.ledger.AccountEquity()
+	}
+	activity.PDTRestricted = limits.PDTMaxDayTrades > 0 && activity.EquityKnown &&
+		activity.Equity < limits.PDTEquityThreshold && activity.DayTrades >= limits.PDTMaxDayTrades
+
+	return activity, nil
+}
+
+// CheckPatternDayTrader blocks a day-trading order that would exceed the
+// day-trade limit over the rolling window while equity is below the threshold
+func (rm *RiskManager) CheckPatternDayTrader(order *models.OrderRequest) error {
+	limits := rm.GetLimits()
+	if limits.PDTMaxDayTrades <= 0 || rm.ledger == nil {
+		return nil // Not enforced
+	}
+
+	equity, ok := rm.ledger.AccountEquity()
+	if !ok || equity >= limits.PDTEquityThreshold {
+		return nil
+	}
+
+	now := time.Now()
+	_, counts, states, err := rm.replayDayTradeWindow(now)
+	if err != nil {
+		return nil // Allow if can't check
+	}
+
+	if !isDayTrade(order, states[order.Symbol], rm.tradingDayOf(now)) {
+		return nil
+	}
+
+	dayTrades := 0
+	for _, count := range counts {
+		dayTrades += count
+	}
+
+	if dayTrades+1 > limits.PDTMaxDayTrades {
+		return fmt.Errorf("pattern day trader limit: %d day trades in %d business days (limit: %d, equity $%.2f < $%.2f)",
+			dayTrades+1, pdtWindowDays, limits.PDTMaxDayTrades, equity, limits.PDTEquityThreshold)
+	}
+
+	return nil
+}
+
+// CheckTradesPerDay validates today's trade count against the daily limit
+func (rm *RiskManager) CheckTradesPerDay() error {
+	return rm.checkTradesPerDay(nil)
+}
+
+func (rm *RiskManager) checkTradesPerDay(basket *basketUsage) error {
+	limit := rm.GetLimits().MaxTradesPerDay
+	if limit <= 0 {
+		return nil // Not enforced
+	}
+
+	today, err := rm.loadExecutionsSince(rm.tradingDayStart(time.Now()))
+	if err != nil {
+		return nil // Allow if can't check
+	}
+
+	trades, _ := tradesAndTurnover(today)
+	if basket != nil {
+		trades += basket.trades
+	}
+	if trades+1 > limit {
+		return fmt.Errorf("daily trade limit reached: %d trades (limit: %d)", trades, limit)
+	}
+
+	return nil
+}
+
+// CheckDailyTurnover validates today's gross turnover plus the order's
+// notional against the daily turnover limit
+func (rm *RiskManager) CheckDailyTurnover(order *models.OrderRequest) error {
+	return rm.checkDailyTurnover(order, nil)
+}
+
+func (rm *RiskManager) checkDailyTurnover(order *models.OrderRequest, basket *basketUsage) error {
+	limit := rm.GetLimits().MaxDailyTurnover
+	if limit <= 0 {
+		return nil // Not enforced
+	}
+
+	today, err := rm.loadExecutionsSince(rm.tradingDayStart(time.Now()))
+	if err != nil {
+		return nil // Allow if can't check
+	}
+
+	_, turnover := tradesAndTurnover(today)
+	if basket != nil {
+		turnover += basket.turnover
+	}
+
+	notional := rm.orderNotional(order)
+	if turnover+notional > limit {
+		return fmt.Errorf("daily turnover limit exceeded: $%.2f + $%.2f > $%.2f", turnover, notional, limit)
+	}
+
+	return nil
+}
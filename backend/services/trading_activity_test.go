@@ -0,0 +1,110 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hft/backend/models"
+)
+
+func TestPDTWindowSkipsWeekends(t *testing.T) {
+	// Tuesday 2024-03-12: window is Wed 6th .. Tue 12th
+	day := time.Date(2024, 3, 12, 15, 0, 0, 0, time.Local)
+	start := pdtWindowStart(day, 5, nil)
+	if got := start.Format("2006-01-02"); got != "2024-03-06" {
+		t.Errorf("Expected window start 2024-03-06, got %s", got)
+	}
+}
+
+func TestPDTWindowSkipsHolidays(t *testing.T) {
+	// Tuesday 2024-07-09: window is Tue 2nd .. Tue 9th without Independence Day
+	day := time.Date(2024, 7, 9, 15, 0, 0, 0, time.Local)
+	start := pdtWindowStart(day, 5, nil)
+	if got := start.Format("2006-01-02"); got != "2024-07-02" {
+		t.Errorf("Expected window start 2024-07-02, got %s", got)
+	}
+}
+
+func TestCountDayTrades(t *testing.T) {
+	day := time.Date(2024, 3, 12, 10, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return day.Add(time.Duration(minutes) * time.Minute) }
+	dayOf := func(t time.Time) string { return t.Format("2006-01-02") }
+
+	executions := []models.Execution{
+		// Two buys then one sell is a single day trade
+		{Symbol: "AAPL", Side: "BUY", FillQty: 50, Timestamp: at(0)},
+		{Symbol: "AAPL", Side: "BUY", FillQty: 50, Timestamp: at(1)},
+		{Symbol: "AAPL", Side: "SELL", FillQty: 100, Timestamp: at(2)},
+		// Short then cover is a day trade
+		{Symbol: "MSFT", Side: "SELL", FillQty: 10, Timestamp: at(3)},
+		{Symbol: "MSFT", Side: "BUY", FillQty: 10, Timestamp: at(4)},
+		// Buy today and sell tomorrow is not
+		{Symbol: "TSLA", Side: "BUY", FillQty: 20, Timestamp: at(5)},
+		{Symbol: "TSLA", Side: "SELL", FillQty: 20, Timestamp: at(24 * 60)},
+	}
+
+	counts := countDayTrades(executions, nil, dayOf)
+	if counts["2024-03-12"] != 2 {
+		t.Errorf("Expected 2 day trades on 2024-03-12, got %d", counts["2024-03-12"])
+	}
+	if counts["2024-03-13"] != 0 {
+		t.Errorf("Expected no day trades on 2024-03-13, got %d", counts["2024-03-13"])
+	}
+
+	_, states := replayDayTrades(executions[:6], nil, dayOf)
+	if !isDayTrade(&models.OrderRequest{Symbol: "TSLA", Side: "SELL"}, states["TSLA"], "2024-03-12") {
+		t.Error("Expected selling a position bought today to be a day trade")
+	}
+	if isDayTrade(&models.OrderRequest{Symbol: "TSLA", Side: "SELL"}, states["TSLA"], "2024-03-13") {
+		t.Error("Expected selling a position bought yesterday not to be a day trade")
+	}
+	if isDayTrade(&models.OrderRequest{Symbol: "NVDA", Side: "SELL"}, states["NVDA"], "2024-03-12") {
+		t.Error("Expected order in an untraded symbol not to be a day trade")
+	}
+}
+
+func TestCountDayTradesFollowsRoundTrips(t *testing.T) {
+	day := time.Date(2024, 3, 12, 10, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return day.Add(time.Duration(minutes) * time.Minute) }
+	dayOf := func(t time.Time) string { return t.Format("2006-01-02") }
+
+	cases := []struct {
+		name       string
+		opening    map[string]float64
+		sides      []string
+		quantities []float64
+		expected   int
+	}{
+		{"buy sell buy sell", nil, []string{"BUY", "SELL", "BUY", "SELL"}, []float64{100, 100, 100, 100}, 2},
+		{"buy buy sell sell", nil, []string{"BUY", "BUY", "SELL", "SELL"}, []float64{100, 100, 100, 100}, 1},
+		{"sell and buy back an overnight position", map[string]float64{"AAPL": 100}, []string{"SELL", "BUY"}, []float64{100, 100}, 0},
+		{"add to an overnight position and sell the addition", map[string]float64{"AAPL": 100}, []string{"BUY", "SELL"}, []float64{50, 50}, 1},
+		{"sell through flat and cover", map[string]float64{"AAPL": 100}, []string{"SELL", "BUY"}, []float64{150, 50}, 1},
+	}
+
+	for _, tc := range cases {
+		executions := make([]models.Execution, len(tc.sides))
+		for i, side := range tc.sides {
+			executions[i] = models.Execution{Symbol: "AAPL", Side: side, FillQty: tc.quantities[i], Timestamp: at(i)}
+		}
+
+		if got := countDayTrades(executions, tc.opening, dayOf)["2024-03-12"]; got != tc.expected {
+			t.Errorf("%s: expected %d day trades, got %d", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestTradesAndTurnover(t *testing.T) {
+	trades, turnover := tradesAndTurnover([]models.Execution{
+		{OrderID: "a", FillPrice: 100, FillQty: 5},
+		{OrderID: "a", FillPrice: 100, FillQty: 5},
+		{OrderID: "b", FillPrice: 50, FillQty: 2},
+	})
+
+	if trades != 2 {
+		t.Errorf("Expected partial fills to count as one trade, got %d trades", trades)
+	}
+	if turnover != 1100 {
+		t.Errorf("Expected turnover 1100, got %.2f", turnover)
+	}
+}
@@ -170,6 +170,23 @@ func (hub *WebSocketHub) BroadcastPositionUpdate(positions interface{}) {
 	hub.broadcast <- jsonData
 }
 
+// BroadcastRiskUtilisation sends a limit utilisation snapshot to all clients
+func (hub *WebSocketHub) BroadcastRiskUtilisation(utilisation *models.RiskUtilisation) {
+	message := map[string]interface{}{
+		"type":      "RISK_UTILISATION",
+		"data":      utilisation,
+		"timestamp": time.Now().Unix(),
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling risk utilisation: %v", err)
+		return
+	}
+
+	hub.broadcast <- jsonData
+}
+
 // GetClientCount returns the number of connected clients
 func (hub *WebSocketHub) GetClientCount() int {
 	hub.mu.RLock()
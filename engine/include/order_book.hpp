@@ -39,6 +39,7 @@ struct Order {
     double quantity;
     double price;
     OrderType order_type;
+    bool extended_hours = false;
     double filled_qty = 0.0;
     OrderStatus status = OrderStatus::NEW;
     std::chrono::time_point<std::chrono::high_resolution_clock> timestamp;
@@ -152,6 +152,11 @@ nlohmann::json AlpacaClient::orderToAlpacaJson(const Order& order) {
         j["stop_price"] = std::to_string(order.price);
     }
     
+    // Alpaca only accepts extended-hours flags on day limit orders
+    if (order.extended_hours && order.order_type == OrderType::LIMIT) {
+        j["extended_hours"] = true;
+    }
+    
     j["client_order_id"] = order.client_order_id;
     
     return j;
@@ -126,6 +126,7 @@ std::string ExecutionEngine::handleOrderRequest(const std::string& request_json)
         order.side = (request["side"] == "BUY") ? Side::BUY : Side::SELL;
         order.quantity = request["quantity"];
         order.price = request["price"];
+        order.extended_hours = request.value("extended_hours", false);
         
         std::string order_type_str = request.value("order_type", "LIMIT");
         if (order_type_str == "MARKET") {
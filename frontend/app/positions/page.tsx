@@ -67,9 +67,9 @@ export default function PositionsPage() {
 
   const totalPnL = Array.isArray(positions)
     ? positions.reduce((sum, p) => {
-        const qty = parseFloat(p.qty || 0)
-        const avgPrice = parseFloat(p.avg_entry_price || 0)
-        const currentPrice = parseFloat(p.current_price || 0)
+        const qty = parseFloat(p.quantity || p.qty || 0)
+        const avgPrice = parseFloat(p.avg_price || p.avg_entry_price || 0)
+        const currentPrice = parseFloat(p.market_price || p.current_price || 0)
         const unrealizedPnL = (currentPrice - avgPrice) * qty
         return sum + unrealizedPnL
       }, 0)
@@ -188,10 +188,10 @@ export default function PositionsPage() {
                 </thead>
                 <tbody>
                   {positions.map((position, index) => {
-                    // Parse numeric values from Alpaca (they come as strings)
-                    const qty = parseFloat(position.qty || 0)
-                    const avgPrice = parseFloat(position.avg_entry_price || 0)
-                    const currentPrice = parseFloat(position.current_price || 0)
+                    // Parse numeric values (the position book sends numbers, Alpaca sends strings)
+                    const qty = parseFloat(position.quantity || position.qty || 0)
+                    const avgPrice = parseFloat(position.avg_price || position.avg_entry_price || 0)
+                    const currentPrice = parseFloat(position.market_price || position.current_price || 0)
                     const marketValue = parseFloat(position.market_value || 0)
                     
                     // Calculate unrealized P&L: (Current Price - Avg Price) × Quantity
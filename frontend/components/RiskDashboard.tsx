@@ -104,9 +104,9 @@ export default function RiskDashboard() {
       
       // Calculate unrealized P&L from positions
       const unrealizedPnL = positionsData.reduce((sum, p) => {
-        const qty = parseFloat(p.qty || 0)
-        const avgPrice = parseFloat(p.avg_entry_price || 0)
-        const currentPrice = parseFloat(p.current_price || 0)
+        const qty = parseFloat(p.quantity || p.qty || 0)
+        const avgPrice = parseFloat(p.avg_price || p.avg_entry_price || 0)
+        const currentPrice = parseFloat(p.market_price || p.current_price || 0)
         return sum + ((currentPrice - avgPrice) * qty)
       }, 0)
       
@@ -0,0 +1,27 @@
+-- Scoped Circuit Breakers
+-- Migration: 005_scoped_circuit_breakers.sql
+-- Description: Scope breakers to global/symbol/strategy and add timed half-open recovery
+
+ALTER TABLE circuit_breaker_events
+    ADD COLUMN IF NOT EXISTS scope VARCHAR(20) NOT NULL DEFAULT 'GLOBAL'
+        CHECK (scope IN ('GLOBAL', 'SYMBOL', 'STRATEGY')),
+    ADD COLUMN IF NOT EXISTS scope_key VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS state VARCHAR(20) NOT NULL DEFAULT 'OPEN'
+        CHECK (state IN ('OPEN', 'HALF_OPEN', 'CLOSED')),
+    ADD COLUMN IF NOT EXISTS half_open_at TIMESTAMP;
+
+UPDATE circuit_breaker_events SET state = 'CLOSED' WHERE active = false;
+
+CREATE INDEX IF NOT EXISTS idx_circuit_breaker_scope ON circuit_breaker_events(scope, scope_key) WHERE active = true;
+
+-- Automatic trigger thresholds
+ALTER TABLE risk_limits
+    ADD COLUMN IF NOT EXISTS breaker_cooldown_seconds INTEGER NOT NULL DEFAULT 300,
+    ADD COLUMN IF NOT EXISTS rejection_rate_threshold DECIMAL(5,2) NOT NULL DEFAULT 50.00,
+    ADD COLUMN IF NOT EXISTS engine_error_burst INTEGER NOT NULL DEFAULT 5,
+    ADD COLUMN IF NOT EXISTS symbol_loss_limit DECIMAL(20,8) NOT NULL DEFAULT 1000.00;
+
+COMMENT ON COLUMN circuit_breaker_events.duration_seconds IS 'Cooldown before the breaker goes half-open; 0 means manual reset only';
+COMMENT ON COLUMN risk_limits.rejection_rate_threshold IS 'Engine rejection rate (%) over 60s that trips a global breaker';
+COMMENT ON COLUMN risk_limits.engine_error_burst IS 'Engine errors within 60s that trip a global breaker';
+COMMENT ON COLUMN risk_limits.symbol_loss_limit IS 'Per-symbol loss that trips a symbol-scoped breaker';
@@ -0,0 +1,26 @@
+-- Order Throttle Limits
+-- Migration: 006_order_throttle_limits.sql
+-- Description: Token bucket rate limits per user, API key, strategy and symbol
+
+CREATE TABLE IF NOT EXISTS throttle_limits (
+    id SERIAL PRIMARY KEY,
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('USER', 'API_KEY', 'STRATEGY', 'SYMBOL')),
+    key VARCHAR(100) NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('NEW', 'CANCEL', 'AMEND')),
+    rate DECIMAL(10,2) NOT NULL,
+    burst INTEGER NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_throttle_limit ON throttle_limits(scope, key, action);
+
+-- Scope defaults ('*' applies to every key in the scope)
+INSERT INTO throttle_limits (scope, key, action, rate, burst) VALUES
+    ('USER', '*', 'NEW', 10.00, 10),
+    ('USER', '*', 'CANCEL', 20.00, 20),
+    ('USER', '*', 'AMEND', 20.00, 20),
+    ('SYMBOL', '*', 'NEW', 5.00, 10)
+ON CONFLICT (scope, key, action) DO NOTHING;
+
+COMMENT ON TABLE throttle_limits IS 'Token bucket order rate limits by scope, key and action';
@@ -0,0 +1,28 @@
+-- VaR Limit and Stress Scenarios
+-- Migration: 007_var_and_stress.sql
+-- Description: Optional portfolio VaR limit and user-defined stress scenarios
+
+ALTER TABLE risk_limits
+    ADD COLUMN IF NOT EXISTS max_var DECIMAL(20,8) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS var_confidence DECIMAL(5,2) NOT NULL DEFAULT 95.00;
+
+COMMENT ON COLUMN risk_limits.max_var IS '1-day historical VaR limit; 0 disables enforcement';
+
+CREATE TABLE IF NOT EXISTS stress_scenarios (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    scenario_type VARCHAR(20) NOT NULL CHECK (scenario_type IN ('SHOCK', 'HISTORICAL')),
+    default_shock_pct DECIMAL(10,4) DEFAULT 0,
+    shocks JSONB,
+    replay_date VARCHAR(10),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO stress_scenarios (name, description, scenario_type, default_shock_pct, replay_date) VALUES
+    ('Book -10%', 'Every position falls 10%', 'SHOCK', -10, NULL),
+    ('2020-03-16 replay', 'Replay each symbol''s move on 16 March 2020', 'HISTORICAL', 0, '2020-03-16')
+ON CONFLICT (name) DO NOTHING;
+
+COMMENT ON TABLE stress_scenarios IS 'User-defined stress scenarios applied to the live portfolio';
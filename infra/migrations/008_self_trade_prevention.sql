@@ -0,0 +1,15 @@
+-- Self-Trade Prevention and Wash-Trade Detection
+-- Migration: 008_self_trade_prevention.sql
+-- Description: Configurable self-trade action and wash-trade detection thresholds
+
+ALTER TABLE risk_limits
+    ADD COLUMN IF NOT EXISTS self_trade_action VARCHAR(20) NOT NULL DEFAULT 'REJECT_NEW'
+        CHECK (self_trade_action IN ('NONE', 'REJECT_NEW', 'CANCEL_RESTING', 'CANCEL_BOTH')),
+    ADD COLUMN IF NOT EXISTS wash_trade_window_seconds INTEGER NOT NULL DEFAULT 60,
+    ADD COLUMN IF NOT EXISTS wash_trade_price_tolerance DECIMAL(5,2) NOT NULL DEFAULT 0.10;
+
+COMMENT ON COLUMN risk_limits.self_trade_action IS 'Action when a new order crosses one of our resting orders';
+COMMENT ON COLUMN risk_limits.wash_trade_window_seconds IS 'Opposite-side fills within this window are checked for wash trades; 0 disables';
+COMMENT ON COLUMN risk_limits.wash_trade_price_tolerance IS 'Max price difference in percent for a potential wash trade';
+
+CREATE INDEX IF NOT EXISTS idx_executions_symbol_timestamp ON executions (symbol, timestamp);
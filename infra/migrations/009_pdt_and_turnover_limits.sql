@@ -0,0 +1,13 @@
+-- Pattern-Day-Trader and Turnover Limits
+-- Migration: 009_pdt_and_turnover_limits.sql
+-- Description: Day-trade limit below the PDT equity threshold, daily trade count and turnover limits
+
+ALTER TABLE risk_limits
+    ADD COLUMN IF NOT EXISTS pdt_max_day_trades INTEGER NOT NULL DEFAULT 3,
+    ADD COLUMN IF NOT EXISTS pdt_equity_threshold DECIMAL(20,8) NOT NULL DEFAULT 25000.00,
+    ADD COLUMN IF NOT EXISTS max_trades_per_day INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS max_daily_turnover DECIMAL(20,8) NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN risk_limits.pdt_max_day_trades IS 'Day trades allowed in 5 business days while equity is below pdt_equity_threshold; 0 disables';
+COMMENT ON COLUMN risk_limits.max_trades_per_day IS 'Orders traded per day; 0 disables';
+COMMENT ON COLUMN risk_limits.max_daily_turnover IS 'Gross traded notional per day; 0 disables';
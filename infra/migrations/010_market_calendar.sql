@@ -0,0 +1,16 @@
+-- Market Calendar
+-- Migration: 010_market_calendar.sql
+-- Description: Holiday/early-close overrides for the built-in NYSE calendar and session gating
+
+CREATE TABLE IF NOT EXISTS market_holidays (
+    date VARCHAR(10) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    early_close VARCHAR(5),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+ALTER TABLE risk_limits
+    ADD COLUMN IF NOT EXISTS enforce_trading_session BOOLEAN NOT NULL DEFAULT true;
+
+COMMENT ON TABLE market_holidays IS 'Exchange closures (early_close NULL) or early closes (HH:MM New York) overriding the built-in calendar';
+COMMENT ON COLUMN risk_limits.enforce_trading_session IS 'Reject orders outside the session; pre/post-market requires extended_hours LIMIT orders';
@@ -0,0 +1,19 @@
+-- Restricted and Hard-to-Borrow Lists
+-- Migration: 011_restricted_symbols.sql
+-- Description: Compliance-maintained restricted, close-only and hard-to-borrow symbol lists
+
+CREATE TABLE IF NOT EXISTS restricted_symbols (
+    id SERIAL PRIMARY KEY,
+    symbol VARCHAR(20) NOT NULL,
+    list_type VARCHAR(20) NOT NULL CHECK (list_type IN ('RESTRICTED', 'CLOSE_ONLY', 'HARD_TO_BORROW')),
+    reason TEXT NOT NULL,
+    author VARCHAR(100) NOT NULL,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_restricted_symbol ON restricted_symbols(symbol, list_type);
+
+COMMENT ON TABLE restricted_symbols IS 'Symbols blocked (RESTRICTED), limited to reducing orders (CLOSE_ONLY) or barred from new shorts (HARD_TO_BORROW)';
+COMMENT ON COLUMN restricted_symbols.expires_at IS 'Entry stops applying after this time; NULL never expires';
@@ -0,0 +1,25 @@
+-- Four-Eyes Approval for Limit Changes
+-- Migration: 012_limit_change_approval.sql
+-- Description: Pending risk limit and position limit changes awaiting a second user's approval
+
+CREATE TABLE IF NOT EXISTS limit_change_requests (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('RISK_LIMITS', 'POSITION_LIMIT')),
+    symbol VARCHAR(20),
+    payload JSONB NOT NULL,
+    diff JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'APPLIED', 'REJECTED', 'EXPIRED')),
+    requested_by VARCHAR(100) NOT NULL,
+    reviewed_by VARCHAR(100),
+    comment TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_limit_change_requests_kind ON limit_change_requests(kind);
+CREATE INDEX IF NOT EXISTS idx_limit_change_requests_status ON limit_change_requests(status);
+
+COMMENT ON TABLE limit_change_requests IS 'Limit changes that loosen a limit; applied only once approved by a user other than the requester';
+COMMENT ON COLUMN limit_change_requests.diff IS 'Per-field current and proposed values, flagged where the change loosens the limit';
@@ -0,0 +1,36 @@
+-- Risk Limit History
+-- Migration: 013_risk_limit_versions.sql
+-- Description: Immutable versions of risk limits and position limits for point-in-time lookup and rollback
+
+CREATE TABLE IF NOT EXISTS risk_limit_versions (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('RISK_LIMITS', 'POSITION_LIMIT')),
+    symbol VARCHAR(20) NOT NULL DEFAULT '',
+    version INTEGER NOT NULL,
+    risk_limits JSONB,
+    position_limit JSONB,
+    author VARCHAR(100) NOT NULL,
+    approved_by VARCHAR(100),
+    reason TEXT,
+    source VARCHAR(20) NOT NULL CHECK (source IN ('INITIAL', 'UPDATE', 'ROLLBACK', 'RELOAD')),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_limit_version ON risk_limit_versions(kind, symbol, version);
+CREATE INDEX IF NOT EXISTS idx_risk_limit_versions_created_at ON risk_limit_versions(created_at);
+
+ALTER TABLE limit_change_requests ADD COLUMN IF NOT EXISTS reason TEXT;
+ALTER TABLE limit_change_requests ADD COLUMN IF NOT EXISTS source VARCHAR(20) NOT NULL DEFAULT 'UPDATE';
+
+-- Seed the history with the limits in force today
+INSERT INTO risk_limit_versions (kind, symbol, version, risk_limits, author, reason, source, created_at)
+SELECT 'RISK_LIMITS', '', 1, to_jsonb(r) - 'created_at' - 'updated_at', 'system', 'Initial version', 'INITIAL', r.updated_at
+FROM (SELECT * FROM risk_limits ORDER BY id DESC LIMIT 1) r
+WHERE NOT EXISTS (SELECT 1 FROM risk_limit_versions WHERE kind = 'RISK_LIMITS');
+
+INSERT INTO risk_limit_versions (kind, symbol, version, position_limit, author, reason, source, created_at)
+SELECT 'POSITION_LIMIT', p.symbol, 1, to_jsonb(p) - 'created_at' - 'updated_at', 'system', 'Initial version', 'INITIAL', p.updated_at
+FROM position_limits p
+WHERE NOT EXISTS (SELECT 1 FROM risk_limit_versions v WHERE v.kind = 'POSITION_LIMIT' AND v.symbol = p.symbol);
+
+COMMENT ON TABLE risk_limit_versions IS 'Every change to risk_limits and position_limits; the latest version at or before a time is the one in force';
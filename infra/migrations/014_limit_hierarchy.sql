@@ -0,0 +1,30 @@
+-- Hierarchical Limits
+-- Migration: 014_limit_hierarchy.sql
+-- Description: Firm -> desk -> trader -> strategy limit tree and order attribution
+
+CREATE TABLE IF NOT EXISTS limit_nodes (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    level VARCHAR(20) NOT NULL CHECK (level IN ('FIRM', 'DESK', 'TRADER', 'STRATEGY')),
+    key VARCHAR(100) NOT NULL,
+    parent_id INTEGER REFERENCES limit_nodes(id),
+    max_position DECIMAL(20, 8) DEFAULT 0,
+    max_order_size DECIMAL(20, 8) DEFAULT 0,
+    daily_loss_limit DECIMAL(20, 8) DEFAULT 0,
+    max_orders_per_minute INTEGER DEFAULT 0,
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_limit_node_key ON limit_nodes(level, key);
+CREATE INDEX IF NOT EXISTS idx_limit_nodes_parent_id ON limit_nodes(parent_id);
+
+-- Attribute orders to strategy and trader nodes
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS strategy VARCHAR(100) DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS trader VARCHAR(100) DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_orders_strategy ON orders(strategy);
+CREATE INDEX IF NOT EXISTS idx_orders_trader ON orders(trader);
+
+COMMENT ON TABLE limit_nodes IS 'Limit hierarchy; an order must pass the limits of its node and every ancestor. Zero limits are not enforced';
+COMMENT ON COLUMN limit_nodes.key IS 'User ID for TRADER nodes, strategy name for STRATEGY nodes';
@@ -0,0 +1,10 @@
+-- Limit Utilisation Alerts
+-- Migration: 015_limit_utilisation_thresholds.sql
+-- Description: Soft utilisation thresholds for warning and critical alerts
+
+ALTER TABLE risk_limits
+    ADD COLUMN IF NOT EXISTS utilisation_warning_pct DECIMAL(5,2) NOT NULL DEFAULT 80.00,
+    ADD COLUMN IF NOT EXISTS utilisation_critical_pct DECIMAL(5,2) NOT NULL DEFAULT 95.00;
+
+COMMENT ON COLUMN risk_limits.utilisation_warning_pct IS 'Utilisation of any limit that raises a WARNING alert; 0 disables';
+COMMENT ON COLUMN risk_limits.utilisation_critical_pct IS 'Utilisation of any limit that raises a CRITICAL alert; 0 disables';
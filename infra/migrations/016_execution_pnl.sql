@@ -0,0 +1,23 @@
+-- Cost-Basis P&L Ledger
+-- Migration: 016_execution_pnl.sql
+-- Description: Realised P&L of every execution matched against open lots
+
+CREATE TABLE IF NOT EXISTS execution_pnl (
+    execution_id INTEGER PRIMARY KEY REFERENCES executions(id),
+    symbol VARCHAR(20) NOT NULL,
+    side VARCHAR(10) NOT NULL,
+    fill_qty DECIMAL(20, 8) NOT NULL,
+    fill_price DECIMAL(20, 8) NOT NULL,
+    closed_qty DECIMAL(20, 8) DEFAULT 0,
+    cost_basis DECIMAL(20, 8) DEFAULT 0,
+    realized_pnl DECIMAL(20, 8) DEFAULT 0,
+    position_after DECIMAL(20, 8) DEFAULT 0,
+    method VARCHAR(10) NOT NULL CHECK (method IN ('FIFO', 'LIFO', 'AVERAGE')),
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_execution_pnl_symbol ON execution_pnl(symbol);
+CREATE INDEX IF NOT EXISTS idx_execution_pnl_timestamp ON execution_pnl(timestamp);
+
+COMMENT ON TABLE execution_pnl IS 'Rebuilt from executions by the P&L ledger whenever the cost method changes';
+COMMENT ON COLUMN execution_pnl.closed_qty IS 'Part of the fill that closed open lots; the rest opened a new lot';
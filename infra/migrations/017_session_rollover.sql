@@ -0,0 +1,43 @@
+-- End-of-Day Snapshots
+-- Migration: 017_session_rollover.sql
+-- Description: Per-day session summaries and frozen end-of-day positions
+
+-- position_snapshots also exists in the Timescale schema; add the trading day
+-- so snapshots can be looked up per session
+CREATE TABLE IF NOT EXISTS position_snapshots (
+    trading_day VARCHAR(10),
+    symbol VARCHAR(20) NOT NULL,
+    quantity DECIMAL(20, 8) NOT NULL,
+    avg_price DECIMAL(20, 8) NOT NULL,
+    market_price DECIMAL(20, 8) DEFAULT 0,
+    unrealized_pnl DECIMAL(20, 8) DEFAULT 0,
+    realized_pnl DECIMAL(20, 8) DEFAULT 0,
+    timestamp TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+ALTER TABLE position_snapshots ADD COLUMN IF NOT EXISTS trading_day VARCHAR(10);
+ALTER TABLE position_snapshots ADD COLUMN IF NOT EXISTS market_price DECIMAL(20, 8) DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_position_snapshots_trading_day ON position_snapshots(trading_day);
+
+CREATE TABLE IF NOT EXISTS session_summaries (
+    trading_day VARCHAR(10) PRIMARY KEY,
+    realized_pnl DECIMAL(20, 8) DEFAULT 0,
+    unrealized_pnl DECIMAL(20, 8) DEFAULT 0,
+    total_pnl DECIMAL(20, 8) DEFAULT 0,
+    circuit_breaker_triggered BOOLEAN DEFAULT false,
+    trades INTEGER DEFAULT 0,
+    turnover DECIMAL(20, 8) DEFAULT 0,
+    positions INTEGER DEFAULT 0,
+    gross_exposure DECIMAL(20, 8) DEFAULT 0,
+    open_orders JSONB DEFAULT '[]',
+    active_breakers JSONB DEFAULT '[]',
+    late BOOLEAN DEFAULT false,
+    closed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    next_trading_day VARCHAR(10) DEFAULT '',
+    breakers_reset INTEGER DEFAULT 0,
+    rolled_over_at TIMESTAMP WITH TIME ZONE
+);
+
+COMMENT ON TABLE session_summaries IS 'Written after the post-market close; rolled_over_at is set when the next trading day starts';
+COMMENT ON COLUMN session_summaries.late IS 'Closed at the next start of day because the backend missed the scheduled end of day';
+COMMENT ON COLUMN session_summaries.breakers_reset IS 'DAILY_LOSS and SYMBOL_LOSS breakers reset for the next session';
@@ -0,0 +1,26 @@
+-- P&L Attribution
+-- Migration: 018_pnl_attribution.sql
+-- Description: Tag orders and executions with strategy, trader and free-form tags
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tags JSONB DEFAULT '[]';
+
+ALTER TABLE executions
+    ADD COLUMN IF NOT EXISTS strategy VARCHAR(100) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS trader VARCHAR(100) DEFAULT '',
+    ADD COLUMN IF NOT EXISTS tags JSONB DEFAULT '[]';
+
+CREATE INDEX IF NOT EXISTS idx_executions_strategy ON executions(strategy);
+CREATE INDEX IF NOT EXISTS idx_executions_trader ON executions(trader);
+CREATE INDEX IF NOT EXISTS idx_executions_tags ON executions USING GIN (tags);
+
+-- Carry the tags of existing orders onto their executions
+UPDATE executions e
+SET strategy = COALESCE(o.strategy, ''),
+    trader = COALESCE(o.trader, ''),
+    tags = COALESCE(o.tags, '[]')
+FROM orders o
+WHERE o.client_order_id = e.client_order_id
+  AND e.strategy = '' AND e.trader = '';
+
+COMMENT ON COLUMN executions.strategy IS 'Strategy of the order; empty for manual trading';
+COMMENT ON COLUMN executions.tags IS 'Free-form labels of the order, used by P&L attribution';
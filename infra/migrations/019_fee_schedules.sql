@@ -0,0 +1,60 @@
+-- Commission and Regulatory Fees
+-- Migration: 019_fee_schedules.sql
+-- Description: Versioned fee schedules per account and the fees charged on each execution
+
+CREATE TABLE IF NOT EXISTS fee_schedules (
+    id SERIAL PRIMARY KEY,
+    account VARCHAR(50) NOT NULL DEFAULT 'primary',
+    version INTEGER NOT NULL,
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    commission_per_share DECIMAL(20, 8) DEFAULT 0,
+    commission_bps DECIMAL(20, 8) DEFAULT 0,
+    commission_tiers JSONB DEFAULT '[]',
+    minimum_commission DECIMAL(20, 8) DEFAULT 0,
+    sec_fee_rate DECIMAL(20, 10) DEFAULT 0,
+    taf_per_share DECIMAL(20, 8) DEFAULT 0,
+    taf_maximum DECIMAL(20, 8) DEFAULT 0,
+    author VARCHAR(100) NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fee_schedule_version ON fee_schedules(account, version);
+CREATE INDEX IF NOT EXISTS idx_fee_schedules_effective_from ON fee_schedules(effective_from);
+
+CREATE TABLE IF NOT EXISTS execution_fees (
+    execution_id INTEGER PRIMARY KEY REFERENCES executions(id),
+    account VARCHAR(50) NOT NULL,
+    symbol VARCHAR(20) NOT NULL,
+    side VARCHAR(10) NOT NULL,
+    fee_schedule_id INTEGER DEFAULT 0,
+    commission DECIMAL(20, 8) DEFAULT 0,
+    sec_fee DECIMAL(20, 8) DEFAULT 0,
+    taf_fee DECIMAL(20, 8) DEFAULT 0,
+    total_fees DECIMAL(20, 8) DEFAULT 0,
+    month_to_date_shares DECIMAL(20, 8) DEFAULT 0,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_execution_fees_account ON execution_fees(account);
+CREATE INDEX IF NOT EXISTS idx_execution_fees_timestamp ON execution_fees(timestamp);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS account VARCHAR(50) DEFAULT 'primary';
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS account VARCHAR(50) DEFAULT 'primary';
+CREATE INDEX IF NOT EXISTS idx_orders_account ON orders(account);
+CREATE INDEX IF NOT EXISTS idx_executions_account ON executions(account);
+
+ALTER TABLE execution_pnl
+    ADD COLUMN IF NOT EXISTS fees DECIMAL(20, 8) DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS net_pnl DECIMAL(20, 8) DEFAULT 0;
+ALTER TABLE daily_pnl_tracking ADD COLUMN IF NOT EXISTS fees DECIMAL(20, 8) DEFAULT 0;
+ALTER TABLE session_summaries ADD COLUMN IF NOT EXISTS fees DECIMAL(20, 8) DEFAULT 0;
+
+-- Commission-free broker; Section 31 at $27.80 per million sold and TAF at
+-- $0.000166 per share sold, capped at $8.30
+INSERT INTO fee_schedules (account, version, effective_from, sec_fee_rate, taf_per_share, taf_maximum, author, reason)
+SELECT 'primary', 1, '2024-01-01T00:00:00Z', 0.0000278, 0.000166, 8.30, 'system', 'Initial schedule'
+WHERE NOT EXISTS (SELECT 1 FROM fee_schedules);
+
+COMMENT ON TABLE fee_schedules IS 'Versions are immutable; the latest effective at a fill''s time prices it. Accounts without a schedule use primary';
+COMMENT ON COLUMN daily_pnl_tracking.fees IS 'Fees charged during the day; realized_pnl is net of them';
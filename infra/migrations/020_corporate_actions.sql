@@ -0,0 +1,46 @@
+-- Corporate Actions
+-- Migration: 020_corporate_actions.sql
+-- Description: Splits, dividends and symbol changes and their effect on booked positions
+
+CREATE TABLE IF NOT EXISTS corporate_actions (
+    id SERIAL PRIMARY KEY,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('SPLIT', 'REVERSE_SPLIT', 'CASH_DIVIDEND', 'SYMBOL_CHANGE')),
+    symbol VARCHAR(20) NOT NULL,
+    ex_date VARCHAR(10) NOT NULL,
+    old_shares DECIMAL(20, 8) DEFAULT 0,
+    new_shares DECIMAL(20, 8) DEFAULT 0,
+    cash_amount DECIMAL(20, 8) DEFAULT 0,
+    new_symbol VARCHAR(20) DEFAULT '',
+    source VARCHAR(10) NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'APPLIED')),
+    applied_at TIMESTAMP WITH TIME ZONE,
+    applied_by VARCHAR(100) DEFAULT '',
+    position_limit_before DECIMAL(20, 8) DEFAULT 0,
+    position_limit_after DECIMAL(20, 8) DEFAULT 0,
+    orders_adjusted INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_corporate_action ON corporate_actions(type, symbol, ex_date);
+
+CREATE TABLE IF NOT EXISTS corporate_action_adjustments (
+    action_id INTEGER NOT NULL REFERENCES corporate_actions(id),
+    symbol VARCHAR(20) NOT NULL,
+    quantity_before DECIMAL(20, 8) DEFAULT 0,
+    quantity_after DECIMAL(20, 8) DEFAULT 0,
+    avg_cost_before DECIMAL(20, 8) DEFAULT 0,
+    avg_cost_after DECIMAL(20, 8) DEFAULT 0,
+    cash_amount DECIMAL(20, 8) DEFAULT 0,
+    effective_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (action_id, symbol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_corporate_action_adjustments_effective_at ON corporate_action_adjustments(effective_at);
+
+-- Position limits rescaled by splits are versioned like any other change
+ALTER TABLE risk_limit_versions DROP CONSTRAINT IF EXISTS risk_limit_versions_source_check;
+ALTER TABLE risk_limit_versions ADD CONSTRAINT risk_limit_versions_source_check
+    CHECK (source IN ('INITIAL', 'UPDATE', 'ROLLBACK', 'RELOAD', 'CORPORATE_ACTION'));
+
+COMMENT ON TABLE corporate_actions IS 'Pending actions are applied at the start of their ex-date; splits turn old_shares into new_shares';
+COMMENT ON TABLE corporate_action_adjustments IS 'Rebuilt by the P&L ledger; cash_amount is dividend income counted in realised P&L';
@@ -0,0 +1,12 @@
+-- Transaction Cost Analysis
+-- Migration: 021_transaction_cost_analysis.sql
+-- Description: Record the price when each order arrived to measure slippage against
+
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS arrival_price DECIMAL(20, 8) DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS arrival_bid DECIMAL(20, 8) DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS arrival_ask DECIMAL(20, 8) DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_executions_order_id ON executions(order_id);
+
+COMMENT ON COLUMN orders.arrival_price IS 'Mid when the order was received, or the last trade without a two-sided quote; 0 when no fresh quote was cached';
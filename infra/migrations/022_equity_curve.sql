@@ -0,0 +1,74 @@
+-- Equity Curve
+-- Migration: 022_equity_curve.sql
+-- Description: Equity and P&L sampled every minute into a hypertable with hourly and daily continuous aggregates
+
+CREATE EXTENSION IF NOT EXISTS timescaledb;
+
+-- Equity and P&L sampled every minute by the backend
+CREATE TABLE IF NOT EXISTS equity_samples (
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL PRIMARY KEY,
+    equity DECIMAL(20, 8) DEFAULT 0,
+    estimated BOOLEAN DEFAULT FALSE,
+    market_value DECIMAL(20, 8) DEFAULT 0,
+    realized_pnl DECIMAL(20, 8) DEFAULT 0,
+    unrealized_pnl DECIMAL(20, 8) DEFAULT 0,
+    total_pnl DECIMAL(20, 8) DEFAULT 0,
+    daily_pnl DECIMAL(20, 8) DEFAULT 0,
+    fees DECIMAL(20, 8) DEFAULT 0
+);
+
+-- The backend may already have created the table
+SELECT create_hypertable('equity_samples', 'timestamp', if_not_exists => TRUE, migrate_data => TRUE);
+
+-- Continuous aggregates for the hourly and daily equity curve
+CREATE MATERIALIZED VIEW IF NOT EXISTS equity_hourly
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    time_bucket('1 hour', timestamp) AS bucket,
+    first(equity, timestamp) AS equity_open,
+    MAX(equity) AS equity_high,
+    MIN(equity) AS equity_low,
+    last(equity, timestamp) AS equity,
+    last(market_value, timestamp) AS market_value,
+    last(realized_pnl, timestamp) AS realized_pnl,
+    last(unrealized_pnl, timestamp) AS unrealized_pnl,
+    last(total_pnl, timestamp) AS total_pnl,
+    last(daily_pnl, timestamp) AS daily_pnl,
+    last(fees, timestamp) AS fees,
+    COUNT(*) AS samples
+FROM equity_samples
+GROUP BY bucket;
+
+SELECT add_continuous_aggregate_policy('equity_hourly',
+    start_offset => INTERVAL '3 hours',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '1 hour',
+    if_not_exists => TRUE);
+
+-- Days follow the exchange's calendar day
+CREATE MATERIALIZED VIEW IF NOT EXISTS equity_daily
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    time_bucket('1 day', timestamp, 'America/New_York') AS bucket,
+    first(equity, timestamp) AS equity_open,
+    MAX(equity) AS equity_high,
+    MIN(equity) AS equity_low,
+    last(equity, timestamp) AS equity,
+    last(market_value, timestamp) AS market_value,
+    last(realized_pnl, timestamp) AS realized_pnl,
+    last(unrealized_pnl, timestamp) AS unrealized_pnl,
+    last(total_pnl, timestamp) AS total_pnl,
+    last(daily_pnl, timestamp) AS daily_pnl,
+    last(fees, timestamp) AS fees,
+    COUNT(*) AS samples
+FROM equity_samples
+GROUP BY bucket;
+
+SELECT add_continuous_aggregate_policy('equity_daily',
+    start_offset => INTERVAL '3 days',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '1 hour',
+    if_not_exists => TRUE);
+
+COMMENT ON TABLE equity_samples IS 'Account equity and P&L every minute; realized_pnl and fees are cumulative';
+COMMENT ON COLUMN equity_samples.estimated IS 'Equity carried forward by the change in P&L while the engine was unreachable';
@@ -0,0 +1,26 @@
+-- Limit Node Approval
+-- Migration: 023_limit_node_approval.sql
+-- Description: Route limit hierarchy node changes through four-eyes approval and the limit history
+
+ALTER TABLE limit_change_requests ADD COLUMN IF NOT EXISTS target VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE limit_change_requests DROP CONSTRAINT IF EXISTS limit_change_requests_kind_check;
+ALTER TABLE limit_change_requests ADD CONSTRAINT limit_change_requests_kind_check
+    CHECK (kind IN ('RISK_LIMITS', 'POSITION_LIMIT', 'LIMIT_NODE'));
+
+ALTER TABLE risk_limit_versions ADD COLUMN IF NOT EXISTS target VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE risk_limit_versions ADD COLUMN IF NOT EXISTS limit_node JSONB;
+ALTER TABLE risk_limit_versions DROP CONSTRAINT IF EXISTS risk_limit_versions_kind_check;
+ALTER TABLE risk_limit_versions ADD CONSTRAINT risk_limit_versions_kind_check
+    CHECK (kind IN ('RISK_LIMITS', 'POSITION_LIMIT', 'LIMIT_NODE'));
+
+-- Versions are numbered per node as well as per symbol
+DROP INDEX IF EXISTS idx_limit_version;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_limit_version ON risk_limit_versions(kind, symbol, target, version);
+
+-- Seed the history with the nodes in force today
+INSERT INTO risk_limit_versions (kind, target, version, limit_node, author, reason, source, created_at)
+SELECT 'LIMIT_NODE', n.id::text, 1, to_jsonb(n) - 'created_at' - 'updated_at', 'system', 'Initial version', 'INITIAL', n.updated_at
+FROM limit_nodes n
+WHERE NOT EXISTS (SELECT 1 FROM risk_limit_versions v WHERE v.kind = 'LIMIT_NODE' AND v.target = n.id::text);
+
+COMMENT ON COLUMN risk_limit_versions.target IS 'Limit node ID for LIMIT_NODE versions; limit_node is null once the node is deleted';
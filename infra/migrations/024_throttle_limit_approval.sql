@@ -0,0 +1,9 @@
+-- Throttle Limit Approval
+-- Migration: 024_throttle_limit_approval.sql
+-- Description: Queue throttle limit increases for four-eyes approval
+
+ALTER TABLE limit_change_requests DROP CONSTRAINT IF EXISTS limit_change_requests_kind_check;
+ALTER TABLE limit_change_requests ADD CONSTRAINT limit_change_requests_kind_check
+    CHECK (kind IN ('RISK_LIMITS', 'POSITION_LIMIT', 'LIMIT_NODE', 'THROTTLE_LIMIT'));
+
+COMMENT ON COLUMN limit_change_requests.target IS 'Limit node ID for LIMIT_NODE, scope|key|action for THROTTLE_LIMIT';
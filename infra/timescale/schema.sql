@@ -90,6 +90,71 @@ SELECT add_continuous_aggregate_policy('hourly_pnl',
     schedule_interval => INTERVAL '1 hour',
     if_not_exists => TRUE);
 
+-- Equity and P&L sampled every minute by the backend
+CREATE TABLE IF NOT EXISTS equity_samples (
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL PRIMARY KEY,
+    equity DECIMAL(20, 8) DEFAULT 0,
+    estimated BOOLEAN DEFAULT FALSE,
+    market_value DECIMAL(20, 8) DEFAULT 0,
+    realized_pnl DECIMAL(20, 8) DEFAULT 0,
+    unrealized_pnl DECIMAL(20, 8) DEFAULT 0,
+    total_pnl DECIMAL(20, 8) DEFAULT 0,
+    daily_pnl DECIMAL(20, 8) DEFAULT 0,
+    fees DECIMAL(20, 8) DEFAULT 0
+);
+
+SELECT create_hypertable('equity_samples', 'timestamp', if_not_exists => TRUE);
+
+-- Continuous aggregates for the hourly and daily equity curve
+CREATE MATERIALIZED VIEW IF NOT EXISTS equity_hourly
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    time_bucket('1 hour', timestamp) AS bucket,
+    first(equity, timestamp) AS equity_open,
+    MAX(equity) AS equity_high,
+    MIN(equity) AS equity_low,
+    last(equity, timestamp) AS equity,
+    last(market_value, timestamp) AS market_value,
+    last(realized_pnl, timestamp) AS realized_pnl,
+    last(unrealized_pnl, timestamp) AS unrealized_pnl,
+    last(total_pnl, timestamp) AS total_pnl,
+    last(daily_pnl, timestamp) AS daily_pnl,
+    last(fees, timestamp) AS fees,
+    COUNT(*) AS samples
+FROM equity_samples
+GROUP BY bucket;
+
+SELECT add_continuous_aggregate_policy('equity_hourly',
+    start_offset => INTERVAL '3 hours',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '1 hour',
+    if_not_exists => TRUE);
+
+-- Days follow the exchange's calendar day
+CREATE MATERIALIZED VIEW IF NOT EXISTS equity_daily
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    time_bucket('1 day', timestamp, 'America/New_York') AS bucket,
+    first(equity, timestamp) AS equity_open,
+    MAX(equity) AS equity_high,
+    MIN(equity) AS equity_low,
+    last(equity, timestamp) AS equity,
+    last(market_value, timestamp) AS market_value,
+    last(realized_pnl, timestamp) AS realized_pnl,
+    last(unrealized_pnl, timestamp) AS unrealized_pnl,
+    last(total_pnl, timestamp) AS total_pnl,
+    last(daily_pnl, timestamp) AS daily_pnl,
+    last(fees, timestamp) AS fees,
+    COUNT(*) AS samples
+FROM equity_samples
+GROUP BY bucket;
+
+SELECT add_continuous_aggregate_policy('equity_daily',
+    start_offset => INTERVAL '3 days',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '1 hour',
+    if_not_exists => TRUE);
+
 -- Grants (adjust as needed)
 GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO pbieda;
 GRANT ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA public TO pbieda;
@@ -98,4 +163,5 @@ COMMENT ON TABLE orders IS 'All trading orders submitted to the system';
 COMMENT ON TABLE executions IS 'Trade execution records with nanosecond timestamps';
 COMMENT ON TABLE market_data_ticks IS 'Real-time market data ticks';
 COMMENT ON TABLE position_snapshots IS 'Historical position snapshots';
+COMMENT ON TABLE equity_samples IS 'Account equity and P&L every minute; realized_pnl and fees are cumulative';
 